@@ -0,0 +1,171 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// alias creates stable alias directories (latest/, 2/, 3/, ...) in the output
+// directory. Each alias is a copy of the newest version it refers to so that
+// editor configurations can use a URL that does not change with every
+// package-spec release. The $ids in the copies are rewritten to the URL of
+// the alias.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+	"github.com/andrewkroh/package-spec-schema/internal/versionindex"
+)
+
+var (
+	outDir  string // Directory containing the versioned directories and versions.json.
+	baseURI string // Base URI of the schema $ids.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versions.json")
+	flag.StringVar(&baseURI, "base-uri", "https://schemas.elastic.dev/package-spec", "base URI of the schema $ids, the version below it is replaced by the alias")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+func run() error {
+	index, err := versionindex.Read(outDir)
+	if err != nil {
+		return fmt.Errorf("failed to read version index: %w", err)
+	}
+	if index.Latest == "" {
+		return errors.New("version index does not contain any versions")
+	}
+
+	aliases := map[string]string{"latest": index.Latest}
+	maps.Copy(aliases, index.LatestByMajor)
+
+	for _, alias := range slices.Sorted(maps.Keys(aliases)) {
+		if err := writeAlias(alias, aliases[alias]); err != nil {
+			return fmt.Errorf("failed to write alias %q: %w", alias, err)
+		}
+	}
+	return nil
+}
+
+// writeAlias replaces the alias directory with a copy of the version directory.
+func writeAlias(alias, version string) (err error) {
+	slog.Info("Updating alias.", "alias", alias, "version", version)
+	dir := filepath.Join(outDir, alias)
+
+	staging, err := fsutil.StageDir(dir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Discard the staging directory if it was not committed.
+		if rmErr := os.RemoveAll(staging); rmErr != nil {
+			err = errors.Join(err, rmErr)
+		}
+	}()
+
+	if err = fsutil.CopyDir(filepath.Join(outDir, version), staging); err != nil {
+		return err
+	}
+	if err = rewriteIDs(staging, version, alias); err != nil {
+		return err
+	}
+	_, err = fsutil.CommitDir(staging, dir)
+	return err
+}
+
+// rewriteIDs replaces the URIs below the version with the same URIs below the
+// alias in the schemas beneath dir, so that the copies do not declare the
+// $ids of the versioned schemas. Besides the $ids this covers the $defs of
+// bundles, which are named by URI, and the JSON pointers to them. The bytes
+// are replaced in place to keep the formatting of the schemas.
+func rewriteIDs(dir, version, alias string) error {
+	from := strings.TrimSuffix(baseURI, "/") + "/" + version + "/"
+	to := strings.TrimSuffix(baseURI, "/") + "/" + alias + "/"
+	r := strings.NewReplacer(from, to, escapePointer(from), escapePointer(to))
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		compressed := strings.HasSuffix(path, ".jsonschema.json.gz")
+		if !compressed && !strings.HasSuffix(path, ".jsonschema.json") {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if compressed {
+			if data, err = gunzipBytes(data); err != nil {
+				return fmt.Errorf("failed to decompress %s: %w", path, err)
+			}
+		}
+		data = []byte(r.Replace(string(data)))
+		if compressed {
+			if data, err = gzipBytes(data); err != nil {
+				return err
+			}
+		}
+		return fsutil.WriteFile(path, data)
+	})
+}
+
+// escapePointer escapes s for use as a JSON pointer reference token.
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+func gunzipBytes(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// gzipBytes compresses data the same way as the compress tool, at the best
+// compression level and with an empty header.
+func gzipBytes(data []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w, err := gzip.NewWriterLevel(buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,116 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	const (
+		schema = `{
+  "$id": "https://schemas.elastic.dev/package-spec/3.6.0/integration/manifest.jsonschema.json",
+  "$ref": "../base.jsonschema.json"
+}
+`
+		bundle = `{
+  "$id": "https://schemas.elastic.dev/package-spec/3.6.0/integration/manifest.jsonschema.json",
+  "$ref": "#/$defs/https:~1~1schemas.elastic.dev~1package-spec~13.6.0~1base.jsonschema.json",
+  "$defs": {
+    "https://schemas.elastic.dev/package-spec/3.6.0/base.jsonschema.json": {
+      "$id": "https://schemas.elastic.dev/package-spec/3.6.0/base.jsonschema.json",
+      "description": "Added in 3.6.0."
+    }
+  }
+}
+`
+	)
+
+	defer func(o, b string) { outDir, baseURI = o, b }(outDir, baseURI)
+	outDir = t.TempDir()
+	baseURI = "https://schemas.elastic.dev/package-spec"
+
+	files := map[string]string{
+		"versions.json": `{"latest":"3.6.0","latest_by_major":{"3":"3.6.0"},"versions":[{"version":"3.6.0"}]}`,
+		"3.6.0/jsonschema/integration/manifest.jsonschema.json": schema,
+		"3.6.0/bundles/integration/manifest.jsonschema.json":    bundle,
+	}
+	for name, data := range files {
+		path := filepath.Join(outDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gz, err := gzipBytes([]byte(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "3.6.0/jsonschema/integration/manifest.jsonschema.json.gz"), gz, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, alias := range []string{"latest", "3"} {
+		t.Run(alias, func(t *testing.T) {
+			wantSchema := `{
+  "$id": "https://schemas.elastic.dev/package-spec/` + alias + `/integration/manifest.jsonschema.json",
+  "$ref": "../base.jsonschema.json"
+}
+`
+			wantBundle := `{
+  "$id": "https://schemas.elastic.dev/package-spec/` + alias + `/integration/manifest.jsonschema.json",
+  "$ref": "#/$defs/https:~1~1schemas.elastic.dev~1package-spec~1` + alias + `~1base.jsonschema.json",
+  "$defs": {
+    "https://schemas.elastic.dev/package-spec/` + alias + `/base.jsonschema.json": {
+      "$id": "https://schemas.elastic.dev/package-spec/` + alias + `/base.jsonschema.json",
+      "description": "Added in 3.6.0."
+    }
+  }
+}
+`
+			dir := filepath.Join(outDir, alias)
+			for name, want := range map[string]string{
+				"jsonschema/integration/manifest.jsonschema.json": wantSchema,
+				"bundles/integration/manifest.jsonschema.json":    wantBundle,
+			} {
+				b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != want {
+					t.Errorf("%s =\n%s\nwant\n%s", name, b, want)
+				}
+			}
+
+			b, err := os.ReadFile(filepath.Join(dir, "jsonschema/integration/manifest.jsonschema.json.gz"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b, err = gunzipBytes(b); err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != wantSchema {
+				t.Errorf("compressed schema =\n%s\nwant\n%s", b, wantSchema)
+			}
+		})
+	}
+
+	// The versioned schemas are unchanged.
+	b, err := os.ReadFile(filepath.Join(outDir, "3.6.0/bundles/integration/manifest.jsonschema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != bundle {
+		t.Errorf("versioned bundle was modified:\n%s", b)
+	}
+}
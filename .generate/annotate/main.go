@@ -0,0 +1,222 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// annotate inserts or updates `# yaml-language-server: $schema=<url>` comments
+// in the files of Elastic packages so that editors validate them against the
+// schemas of the package's format_version. It walks the given directories,
+// which may be single packages or a repository of packages such as
+// elastic/integrations. Rerun it after bumping format_version to keep the
+// comments current.
+//
+//	go run ./annotate [flags] [dir ...]
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+	"gopkg.in/yaml.v3"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+	"github.com/andrewkroh/package-spec-schema/internal/packagefiles"
+)
+
+const commentPrefix = "# yaml-language-server: $schema="
+
+var (
+	baseURL string // URL at which the output directory is published.
+	check   bool   // Report files with missing or outdated comments instead of updating them.
+)
+
+func init() {
+	flag.StringVar(&baseURL, "base-url", "https://raw.githubusercontent.com/andrewkroh/package-spec-schema/refs/heads/main", "URL at which the output directory is published")
+	flag.BoolVar(&check, "check", false, "report files whose schema comment is missing or outdated and exit with an error instead of updating them")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [dir ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+func run() error {
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	var packages, outdated int
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return err
+			}
+			if strings.HasPrefix(d.Name(), ".") && p != dir {
+				return fs.SkipDir
+			}
+
+			ver, err := formatVersion(filepath.Join(p, "manifest.yml"))
+			if err != nil {
+				return err
+			}
+			if ver == "" {
+				return nil
+			}
+			packages++
+			n, err := annotatePackage(p, ver)
+			if err != nil {
+				return fmt.Errorf("failed annotating package %s: %w", p, err)
+			}
+			outdated += n
+			// Packages do not contain other packages.
+			return fs.SkipDir
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if check && outdated > 0 {
+		return fmt.Errorf("%d files have a missing or outdated schema comment", outdated)
+	}
+	slog.Info("Annotated packages.", "packages", packages, "files", outdated)
+	return nil
+}
+
+// formatVersion returns the format_version declared in a package manifest.
+// It returns an empty string if the file does not exist or is not a package
+// manifest, such as the manifest of a data stream.
+func formatVersion(manifest string) (string, error) {
+	b, err := os.ReadFile(manifest)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	var m struct {
+		FormatVersion string `yaml:"format_version"`
+	}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return "", fmt.Errorf("failed to decode %s: %w", manifest, err)
+	}
+	if m.FormatVersion == "" {
+		return "", nil
+	}
+	if _, err := semver.NewVersion(m.FormatVersion); err != nil {
+		return "", fmt.Errorf("invalid format_version in %s: %w", manifest, err)
+	}
+	return m.FormatVersion, nil
+}
+
+// annotatePackage updates the schema comment of each file in the package that
+// has a schema. It returns the number of files whose comment was missing or
+// outdated.
+func annotatePackage(root, ver string) (int, error) {
+	var outdated int
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		schema, ok := matchSchema(filepath.ToSlash(rel))
+		if !ok {
+			return nil
+		}
+
+		url := strings.TrimSuffix(baseURL, "/") + "/" + path.Join(ver, "bundles", schema)
+		changed, err := annotateFile(p, url)
+		if err != nil {
+			return err
+		}
+		if changed {
+			outdated++
+			if check {
+				slog.Warn("Schema comment is missing or outdated.", "path", p, "schema", url)
+			} else {
+				slog.Debug("Updated schema comment.", "path", p, "schema", url)
+			}
+		}
+		return nil
+	})
+	return outdated, err
+}
+
+// matchSchema returns the schema of the file at rel, a path relative to the
+// package root.
+func matchSchema(rel string) (string, bool) {
+	for _, f := range packagefiles.Files {
+		for _, pattern := range f.Patterns {
+			if ok, _ := path.Match(pattern, rel); ok {
+				return f.Schema, true
+			}
+		}
+	}
+	return "", false
+}
+
+// annotateFile sets the schema comment of the file to url, replacing an
+// existing comment or inserting one as the first line. It reports whether the
+// file needed a change. In check mode the file is not modified.
+func annotateFile(name, url string) (bool, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return false, err
+	}
+
+	comment := commentPrefix + url
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	found := false
+	for i, line := range lines {
+		if bytes.HasPrefix(line, []byte(commentPrefix)) {
+			eol := line[len(bytes.TrimRight(line, "\r\n")):]
+			lines[i] = append([]byte(comment), eol...)
+			found = true
+			break
+		}
+	}
+	out := bytes.Join(lines, nil)
+	if !found {
+		out = append([]byte(comment+"\n"), b...)
+	}
+	if bytes.Equal(out, b) {
+		return false, nil
+	}
+	if check {
+		return true, nil
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return false, err
+	}
+	return true, os.WriteFile(name, out, info.Mode())
+}
@@ -0,0 +1,203 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// archive packages each version directory as a package-spec-schema-<version>
+// archive suitable for attaching to a release. Archives are reproducible;
+// entries are sorted and carry a fixed modification time so that
+// identical input produces identical archives.
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+var (
+	outDir  string // Directory containing the versioned directories.
+	destDir string // Directory where archives are written.
+	formats string // Comma separated list of archive formats.
+)
+
+// modTime is the modification time applied to all archive entries.
+var modTime = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	flag.StringVar(&destDir, "d", "dist", "directory where archives are written")
+	flag.StringVar(&formats, "format", "tar.gz", "comma separated list of archive formats (tar.gz, zip)")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+func run() error {
+	var writers []func(prefix string, files []string, srcDir string) ([]byte, error)
+	var exts []string
+	for f := range strings.SplitSeq(formats, ",") {
+		switch f = strings.TrimSpace(f); f {
+		case "tar.gz":
+			writers = append(writers, tarGz)
+		case "zip":
+			writers = append(writers, zipArchive)
+		default:
+			return fmt.Errorf("unsupported archive format %q", f)
+		}
+		exts = append(exts, f)
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := semver.NewVersion(e.Name()); err != nil {
+			continue
+		}
+
+		srcDir := filepath.Join(outDir, e.Name())
+		files, err := listFiles(srcDir)
+		if err != nil {
+			return err
+		}
+
+		name := "package-spec-schema-" + e.Name()
+		for i, write := range writers {
+			b, err := write(name, files, srcDir)
+			if err != nil {
+				return fmt.Errorf("failed to archive %s: %w", srcDir, err)
+			}
+			dest := filepath.Join(destDir, name+"."+exts[i])
+			changed, err := fsutil.WriteFileIfChanged(dest, b)
+			if err != nil {
+				return err
+			}
+			if changed {
+				slog.Info("Wrote archive.", "path", dest, "bytes", len(b))
+			}
+		}
+	}
+	return nil
+}
+
+// listFiles returns the sorted slash-separated paths of all regular files
+// beneath dir relative to dir.
+func listFiles(dir string) ([]string, error) {
+	var out []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		out = append(out, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+func tarGz(prefix string, files []string, srcDir string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+
+	for _, f := range files {
+		b, err := os.ReadFile(filepath.Join(srcDir, filepath.FromSlash(f)))
+		if err != nil {
+			return nil, err
+		}
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     path.Join(prefix, f),
+			Mode:     0o644,
+			Size:     int64(len(b)),
+			ModTime:  modTime,
+			Format:   tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(b); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := errors.Join(tw.Close(), gz.Close()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func zipArchive(prefix string, files []string, srcDir string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	zw := zip.NewWriter(buf)
+
+	for _, f := range files {
+		b, err := os.ReadFile(filepath.Join(srcDir, filepath.FromSlash(f)))
+		if err != nil {
+			return nil, err
+		}
+		hdr := &zip.FileHeader{
+			Name:     path.Join(prefix, f),
+			Method:   zip.Deflate,
+			Modified: modTime,
+		}
+		hdr.SetMode(0o644)
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
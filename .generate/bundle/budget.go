@@ -0,0 +1,202 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/summary"
+)
+
+// topContributors is the number of $defs entries and bundles listed in the
+// breakdown of a bundle or version that exceeds its budget.
+const topContributors = 5
+
+// sizeFlag is a flag.Value holding a size in bytes. Values are a number of
+// bytes with an optional KB, MB, KiB, or MiB suffix, e.g. "512KiB". Zero
+// disables the budget.
+type sizeFlag int64
+
+func (f *sizeFlag) String() string {
+	if f == nil {
+		return "0"
+	}
+	return strconv.FormatInt(int64(*f), 10)
+}
+
+func (f *sizeFlag) Set(value string) error {
+	units := []struct {
+		suffix string
+		factor int64
+	}{
+		{"KiB", 1 << 10},
+		{"MiB", 1 << 20},
+		{"KB", 1000},
+		{"MB", 1000 * 1000},
+		{"B", 1},
+	}
+	factor := int64(1)
+	for _, u := range units {
+		if n, found := strings.CutSuffix(value, u.suffix); found {
+			value, factor = n, u.factor
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid size %q", value)
+	}
+	*f = sizeFlag(n * float64(factor))
+	return nil
+}
+
+// sizeBudget limits the size of the bundles of a version.
+type sizeBudget struct {
+	file    int64 // Maximum size of each bundle, zero for no limit.
+	version int64 // Maximum total size of the bundles, zero for no limit.
+	warn    bool  // Log budget overruns as warnings instead of failing.
+}
+
+// defSize is the size of a $defs entry, or of all copies of an entry in the
+// bundles of a version.
+type defSize struct {
+	name string
+	size int64
+}
+
+// bundleSize is the size of a bundle and of its $defs entries, largest
+// first.
+type bundleSize struct {
+	path string
+	size int64
+	defs []defSize
+}
+
+// check measures the bundles in dir against the budget. Each overrun is
+// logged with the largest $defs entries that contribute to it. Unless
+// b.warn is set, an error listing the overruns is returned.
+func (b sizeBudget) check(dir string) error {
+	if b.file == 0 && b.version == 0 {
+		return nil
+	}
+	bundles, err := measureBundles(dir)
+	if err != nil {
+		return fmt.Errorf("failed measuring bundles: %w", err)
+	}
+
+	level := slog.LevelError
+	if b.warn {
+		level = slog.LevelWarn
+	}
+
+	var errs []error
+	var total int64
+	for _, bs := range bundles {
+		total += bs.size
+		if b.file == 0 || bs.size <= b.file {
+			continue
+		}
+		slog.Log(context.Background(), level, "Bundle exceeds size budget.", "path", bs.path, "size", bs.size, "budget", b.file,
+			"largest_defs", formatDefs(bs.defs[:min(len(bs.defs), topContributors)]))
+		err := fmt.Errorf("%s is %d bytes, exceeding the budget of %d bytes", bs.path, bs.size, b.file)
+		if !b.warn {
+			summary.AddFailure("", bs.path, err)
+		}
+		errs = append(errs, err)
+	}
+
+	if b.version != 0 && total > b.version {
+		// Entries copied into many bundles contribute once per copy.
+		sums := map[string]int64{}
+		for _, bs := range bundles {
+			for _, d := range bs.defs {
+				sums[d.name] += d.size
+			}
+		}
+		defs := make([]defSize, 0, len(sums))
+		for _, name := range slices.Sorted(maps.Keys(sums)) {
+			defs = append(defs, defSize{name: name, size: sums[name]})
+		}
+		sortSizes(defs)
+		largest := slices.Clone(bundles)
+		slices.SortStableFunc(largest, func(a, b bundleSize) int { return cmp.Compare(b.size, a.size) })
+		files := make([]defSize, 0, topContributors)
+		for _, bs := range largest[:min(len(largest), topContributors)] {
+			files = append(files, defSize{name: bs.path, size: bs.size})
+		}
+
+		slog.Log(context.Background(), level, "Bundles exceed version size budget.", "dir", dir, "size", total, "budget", b.version,
+			"largest_bundles", formatDefs(files),
+			"largest_defs", formatDefs(defs[:min(len(defs), topContributors)]))
+		err := fmt.Errorf("bundles of %s are %d bytes, exceeding the budget of %d bytes", dir, total, b.version)
+		if !b.warn {
+			summary.AddFailure("", dir, err)
+		}
+		errs = append(errs, err)
+	}
+
+	if b.warn {
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// measureBundles returns the size of each bundle in dir and of its $defs
+// entries, sorted by path. The size of an entry is the length of its
+// encoding in the bundle.
+func measureBundles(dir string) ([]bundleSize, error) {
+	var out []bundleSize
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".jsonschema.json") {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var bundle struct {
+			Defs map[string]json.RawMessage `json:"$defs"`
+		}
+		if err := json.Unmarshal(b, &bundle); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+
+		bs := bundleSize{path: path, size: int64(len(b))}
+		for _, name := range slices.Sorted(maps.Keys(bundle.Defs)) {
+			bs.defs = append(bs.defs, defSize{name: name, size: int64(len(bundle.Defs[name]))})
+		}
+		sortSizes(bs.defs)
+		out = append(out, bs)
+		return nil
+	})
+	return out, err
+}
+
+// sortSizes sorts the entries largest first, keeping the order of entries of
+// equal size.
+func sortSizes(defs []defSize) {
+	slices.SortStableFunc(defs, func(a, b defSize) int { return cmp.Compare(b.size, a.size) })
+}
+
+// formatDefs formats the entries as a comma separated list of name=size.
+func formatDefs(defs []defSize) string {
+	parts := make([]string, len(defs))
+	for i, d := range defs {
+		parts[i] = d.name + "=" + strconv.FormatInt(d.size, 10)
+	}
+	return strings.Join(parts, ", ")
+}
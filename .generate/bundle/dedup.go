@@ -0,0 +1,152 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/schemawalk"
+)
+
+// dedupDefs merges structurally identical entries of the top-level $defs of a
+// bundle. Entries that differ only in $comment are identical, but entries
+// with a different $id are distinct resources and are kept. For each set of identical entries the one with the smallest name is
+// kept, and references to the others are rewritten to point to it. Merging
+// repeats until no identical entries remain because rewriting references can
+// make further entries identical. The bundle is returned unchanged along with
+// a count of zero if there is nothing to merge.
+func dedupDefs(bundle []byte) ([]byte, int, error) {
+	dec := json.NewDecoder(bytes.NewReader(bundle))
+	dec.UseNumber()
+	var root map[string]any
+	if err := dec.Decode(&root); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode bundle: %w", err)
+	}
+	defs, ok := root["$defs"].(map[string]any)
+	if !ok {
+		return bundle, 0, nil
+	}
+
+	var removed int
+	for {
+		replace, err := identicalDefs(defs)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(replace) == 0 {
+			break
+		}
+		for name := range replace {
+			delete(defs, name)
+		}
+		rewriteRefs(root, replace)
+		removed += len(replace)
+	}
+	if removed == 0 {
+		return bundle, 0, nil
+	}
+
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(root); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), removed, nil
+}
+
+// identicalDefs returns a map from the name of each definition that
+// duplicates another to the $ref of the definition that replaces it.
+func identicalDefs(defs map[string]any) (map[string]string, error) {
+	kept := map[string]string{} // Definition name keyed by canonical encoding.
+	replace := map[string]string{}
+	for _, name := range slices.Sorted(maps.Keys(defs)) {
+		// Maps are encoded with sorted keys, making the encoding canonical.
+		b, err := json.Marshal(withoutComments(defs[name]))
+		if err != nil {
+			return nil, err
+		}
+		if keep, ok := kept[string(b)]; ok {
+			replace[name] = keep
+			continue
+		}
+		kept[string(b)] = name
+	}
+
+	refs := make(map[string]string, len(replace))
+	for name, keep := range replace {
+		refs[name] = defRef(keep)
+	}
+	return refs, nil
+}
+
+// withoutComments returns a copy of node without $comment keywords, which do
+// not affect validation.
+func withoutComments(node any) any {
+	switch node := node.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(node))
+		for k, child := range node {
+			switch {
+			case k == "$comment":
+			case slices.Contains(schemawalk.LiteralKeywords, k):
+				out[k] = child
+			case slices.Contains(schemawalk.MapKeywords, k):
+				// Keys are property names, which may be "$comment".
+				members, ok := child.(map[string]any)
+				if !ok {
+					out[k] = child
+					continue
+				}
+				kept := make(map[string]any, len(members))
+				for name, member := range members {
+					kept[name] = withoutComments(member)
+				}
+				out[k] = kept
+			default:
+				out[k] = withoutComments(child)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(node))
+		for i, child := range node {
+			out[i] = withoutComments(child)
+		}
+		return out
+	default:
+		return node
+	}
+}
+
+// defRef returns the fragment-only $ref to the named top-level definition.
+func defRef(name string) string {
+	return "#/$defs/" + escapePointer(name)
+}
+
+// rewriteRefs rewrites each $ref in node that points to or into a replaced
+// definition to use the replacement instead.
+func rewriteRefs(node any, replace map[string]string) {
+	schemawalk.Walk(node, "", func(schema map[string]any, _ string) bool {
+		ref, ok := schema["$ref"].(string)
+		if !ok {
+			return true
+		}
+		for name, target := range replace {
+			old := defRef(name)
+			if ref == old || strings.HasPrefix(ref, old+"/") {
+				schema["$ref"] = target + strings.TrimPrefix(ref, old)
+				break
+			}
+		}
+		return true
+	})
+}
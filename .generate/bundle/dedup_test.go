@@ -0,0 +1,157 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDedupDefs(t *testing.T) {
+	tests := []struct {
+		name    string
+		bundle  string
+		want    string // Empty if the bundle is returned unchanged.
+		removed int
+	}{
+		{
+			name: "identical defs under different names",
+			bundle: `{
+				"properties": {"a": {"$ref": "#/$defs/b"}, "c": {"$ref": "#/$defs/c/properties/x"}},
+				"$defs": {
+					"b": {"type": "object", "properties": {"x": {"type": "string"}}},
+					"c": {"type": "object", "properties": {"x": {"type": "string"}}}
+				}
+			}`,
+			want: `{
+				"properties": {"a": {"$ref": "#/$defs/b"}, "c": {"$ref": "#/$defs/b/properties/x"}},
+				"$defs": {
+					"b": {"type": "object", "properties": {"x": {"type": "string"}}}
+				}
+			}`,
+			removed: 1,
+		},
+		{
+			name: "identical after rewriting references",
+			bundle: `{
+				"$ref": "#/$defs/d",
+				"$defs": {
+					"a": {"type": "string"},
+					"b": {"type": "string"},
+					"c": {"items": {"$ref": "#/$defs/a"}},
+					"d": {"items": {"$ref": "#/$defs/b"}}
+				}
+			}`,
+			want: `{
+				"$ref": "#/$defs/c",
+				"$defs": {
+					"a": {"type": "string"},
+					"c": {"items": {"$ref": "#/$defs/a"}}
+				}
+			}`,
+			removed: 2,
+		},
+		{
+			name: "reference in a property named like a literal keyword",
+			bundle: `{
+				"properties": {
+					"default": {"$ref": "#/$defs/c/properties/x"},
+					"enum": {"items": {"$ref": "#/$defs/c"}}
+				},
+				"default": {"$ref": "#/$defs/c"},
+				"$defs": {
+					"b": {"type": "object", "properties": {"x": {"type": "string"}}},
+					"c": {"type": "object", "properties": {"x": {"type": "string"}}}
+				}
+			}`,
+			want: `{
+				"properties": {
+					"default": {"$ref": "#/$defs/b/properties/x"},
+					"enum": {"items": {"$ref": "#/$defs/b"}}
+				},
+				"default": {"$ref": "#/$defs/c"},
+				"$defs": {
+					"b": {"type": "object", "properties": {"x": {"type": "string"}}}
+				}
+			}`,
+			removed: 1,
+		},
+		{
+			name: "differ only by $comment",
+			bundle: `{
+				"$ref": "#/$defs/b",
+				"$defs": {
+					"a": {"$comment": "first", "type": "string"},
+					"b": {"$comment": "second", "type": "string"}
+				}
+			}`,
+			want: `{
+				"$ref": "#/$defs/a",
+				"$defs": {
+					"a": {"$comment": "first", "type": "string"}
+				}
+			}`,
+			removed: 1,
+		},
+		{
+			name: "differ only by $id",
+			bundle: `{
+				"$defs": {
+					"a": {"$id": "https://example.com/a", "type": "string"},
+					"b": {"$id": "https://example.com/b", "type": "string"}
+				}
+			}`,
+		},
+		{
+			name: "differ by property named $comment",
+			bundle: `{
+				"$defs": {
+					"a": {"properties": {"$comment": {"type": "string"}}},
+					"b": {"properties": {}}
+				}
+			}`,
+		},
+		{
+			name: "differ by literal value",
+			bundle: `{
+				"$defs": {
+					"a": {"const": {"$comment": "x"}},
+					"b": {"const": {}}
+				}
+			}`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, removed, err := dedupDefs([]byte(tc.bundle))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if removed != tc.removed {
+				t.Errorf("removed = %d, want %d", removed, tc.removed)
+			}
+			if err := verifyRefs(out); err != nil {
+				t.Errorf("dangling references: %v", err)
+			}
+			if tc.want == "" {
+				if string(out) != tc.bundle {
+					t.Errorf("bundle was modified:\n%s", out)
+				}
+				return
+			}
+			var got, want any
+			if err := json.Unmarshal(out, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("bundle = %s", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/schemawalk"
+)
+
+// identifierKeywords identify a schema as a resource or as the target of
+// references. They are removed from dereferenced copies so that the copies do
+// not declare the same identifier more than once.
+var identifierKeywords = []string{"$anchor", "$dynamicAnchor", "$id", "$schema"}
+
+// annotationKeywords do not affect validation. When a schema with a $ref
+// and its target both have one, the value of the referencing schema is kept.
+var annotationKeywords = []string{"$comment", "default", "deprecated", "description", "examples", "readOnly", "title", "writeOnly"}
+
+// dereferenceBundle replaces every $ref of a bundle with a copy of the
+// subschema it points to and removes the $defs and definitions that held the
+// targets. The keywords next to a $ref are merged with the copy, or combined
+// with it in an allOf when both constrain the same keyword.
+//
+// Recursive schemas cannot be inlined. A $ref that points back to one of the
+// schemas containing it refers to the root as "#", or to a copy of the
+// recursive schema in $defs, which is the only $defs the result retains. It
+// returns the number of such copies.
+func dereferenceBundle(bundle []byte) ([]byte, int, error) {
+	dec := json.NewDecoder(bytes.NewReader(bundle))
+	dec.UseNumber()
+	var root map[string]any
+	if err := dec.Decode(&root); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode bundle: %w", err)
+	}
+
+	refs := &refVerifier{resources: map[string]any{}, anchors: map[string]any{}}
+	refs.index(root, &url.URL{})
+	dialect, _ := root["$schema"].(string)
+	d := &dereferencer{
+		refs:            refs,
+		root:            scope(root, &url.URL{}).String(),
+		names:           map[string]string{},
+		siblingsIgnored: strings.Contains(dialect, "/draft-0"),
+	}
+
+	v, err := d.expand(root, &url.URL{}, []string{d.root})
+	if err != nil {
+		return nil, 0, err
+	}
+	out, ok := v.(map[string]any)
+	if !ok {
+		// The root $ref pointed to a boolean schema.
+		out = map[string]any{"allOf": []any{v}}
+	}
+	for _, k := range identifierKeywords {
+		if value, found := root[k]; found {
+			out[k] = value
+		}
+	}
+
+	// Copying a recursive schema may find references to further ones.
+	defs := map[string]any{}
+	for len(d.pending) > 0 {
+		p := d.pending[0]
+		d.pending = d.pending[1:]
+		if defs[d.names[p.key]], err = d.expand(p.node, p.base, []string{p.key}); err != nil {
+			return nil, 0, err
+		}
+	}
+	if len(defs) > 0 {
+		out["$defs"] = defs
+	}
+
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), len(defs), nil
+}
+
+type dereferencer struct {
+	refs            *refVerifier
+	root            string            // URI of the root schema.
+	names           map[string]string // $defs name of each recursive schema keyed by its URI.
+	pending         []recursiveSchema // Recursive schemas not yet copied into $defs.
+	siblingsIgnored bool              // The dialect ignores keywords next to $ref.
+}
+
+// recursiveSchema is a schema that contains a reference to itself.
+type recursiveSchema struct {
+	key  string   // URI of the schema.
+	node any      // Schema in the bundle.
+	base *url.URL // Base URI of the schema.
+}
+
+// expand returns a copy of the schema node with every $ref replaced by a copy
+// of its target. The stack holds the URIs of the targets being expanded.
+func (d *dereferencer) expand(node any, base *url.URL, stack []string) (any, error) {
+	switch n := node.(type) {
+	case map[string]any:
+		base = scope(n, base)
+		out := make(map[string]any, len(n))
+		for k, child := range n {
+			switch {
+			case k == "$ref", k == "$defs", k == "definitions", slices.Contains(identifierKeywords, k):
+			case slices.Contains(schemawalk.LiteralKeywords, k):
+				out[k] = child
+			case slices.Contains(schemawalk.MapKeywords, k):
+				members, ok := child.(map[string]any)
+				if !ok {
+					out[k] = child
+					continue
+				}
+				expanded := make(map[string]any, len(members))
+				for name, member := range members {
+					v, err := d.expand(member, base, stack)
+					if err != nil {
+						return nil, err
+					}
+					expanded[name] = v
+				}
+				out[k] = expanded
+			default:
+				v, err := d.expand(child, base, stack)
+				if err != nil {
+					return nil, err
+				}
+				out[k] = v
+			}
+		}
+
+		ref, ok := n["$ref"].(string)
+		if !ok {
+			return out, nil
+		}
+		target, uri, err := d.refs.lookup(base, ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to dereference %q: %w", ref, err)
+		}
+		key := uri.String()
+		if slices.Contains(stack, key) {
+			return d.merge(out, map[string]any{"$ref": d.recursiveRef(key, target, uri)}), nil
+		}
+		resource := *uri
+		resource.Fragment = ""
+		expanded, err := d.expand(target, &resource, append(slices.Clip(stack), key))
+		if err != nil {
+			return nil, err
+		}
+		return d.merge(out, expanded), nil
+	case []any:
+		out := make([]any, len(n))
+		for i, child := range n {
+			v, err := d.expand(child, base, stack)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	default:
+		return node, nil
+	}
+}
+
+// merge combines the keywords of a schema that contained a $ref with the
+// dereferenced target of the $ref.
+func (d *dereferencer) merge(schema map[string]any, target any) any {
+	if d.siblingsIgnored || len(schema) == 0 {
+		return target
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		// A true target allows everything, so only the schema applies.
+		if b, _ := target.(bool); b {
+			return schema
+		}
+		allOf, _ := schema["allOf"].([]any)
+		schema["allOf"] = append(slices.Clip(allOf), target)
+		return schema
+	}
+
+	for k := range t {
+		if _, found := schema[k]; found && !slices.Contains(annotationKeywords, k) {
+			allOf, _ := schema["allOf"].([]any)
+			schema["allOf"] = append(slices.Clip(allOf), t)
+			return schema
+		}
+	}
+	for k, v := range t {
+		if _, found := schema[k]; !found {
+			schema[k] = v
+		}
+	}
+	return schema
+}
+
+// recursiveRef returns the $ref to the recursive schema with the given URI,
+// adding the schema to those copied into $defs.
+func (d *dereferencer) recursiveRef(key string, target any, uri *url.URL) string {
+	if key == d.root {
+		return "#"
+	}
+	name, ok := d.names[key]
+	if !ok {
+		// Name the copy after the location of the schema in the bundle,
+		// leaving out the $defs of the schemas that the bundler embedded.
+		name = strings.TrimPrefix(strings.TrimPrefix(uri.Fragment, "/"), "$defs/")
+		if strings.HasPrefix(uri.Fragment, "/") {
+			name = strings.NewReplacer("~1", "/", "~0", "~").Replace(name)
+		}
+		if name == "" {
+			name = key
+		}
+		for slices.Contains(slices.Collect(maps.Values(d.names)), name) {
+			name += "_"
+		}
+		d.names[key] = name
+
+		resource := *uri
+		resource.Fragment = ""
+		d.pending = append(d.pending, recursiveSchema{key: key, node: target, base: &resource})
+	}
+	ref := url.URL{Fragment: strings.TrimPrefix(defRef(name), "#")}
+	return ref.String()
+}
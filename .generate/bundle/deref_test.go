@@ -0,0 +1,169 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDereferenceBundle(t *testing.T) {
+	tests := []struct {
+		name      string
+		bundle    string
+		want      string
+		recursive int
+	}{
+		{
+			name: "shared def referenced twice",
+			bundle: `{
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"properties": {"a": {"$ref": "#/$defs/s"}, "b": {"items": {"$ref": "#/$defs/s"}}},
+				"$defs": {"s": {"type": "string", "minLength": 1}}
+			}`,
+			want: `{
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"properties": {
+					"a": {"type": "string", "minLength": 1},
+					"b": {"items": {"type": "string", "minLength": 1}}
+				}
+			}`,
+		},
+		{
+			name: "ref into a def",
+			bundle: `{
+				"properties": {"a": {"$ref": "#/$defs/o/properties/x"}},
+				"$defs": {"o": {"properties": {"x": {"$ref": "#/$defs/s"}}}, "s": {"type": "string"}}
+			}`,
+			want: `{"properties": {"a": {"type": "string"}}}`,
+		},
+		{
+			name: "ref with annotation siblings",
+			bundle: `{
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"properties": {"a": {"$ref": "#/$defs/s", "description": "mine", "default": "x"}},
+				"$defs": {"s": {"type": "string", "description": "theirs"}}
+			}`,
+			want: `{
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"properties": {"a": {"type": "string", "description": "mine", "default": "x"}}
+			}`,
+		},
+		{
+			name: "ref with conflicting siblings",
+			bundle: `{
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"properties": {"a": {"$ref": "#/$defs/s", "type": "string", "maxLength": 3}},
+				"$defs": {"s": {"type": "string", "minLength": 1}}
+			}`,
+			want: `{
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"properties": {"a": {"type": "string", "maxLength": 3, "allOf": [{"type": "string", "minLength": 1}]}}
+			}`,
+		},
+		{
+			name: "draft-07 ignores siblings",
+			bundle: `{
+				"$schema": "http://json-schema.org/draft-07/schema#",
+				"properties": {"a": {"$ref": "#/definitions/s", "maxLength": 3}},
+				"definitions": {"s": {"type": "string"}}
+			}`,
+			want: `{
+				"$schema": "http://json-schema.org/draft-07/schema#",
+				"properties": {"a": {"type": "string"}}
+			}`,
+		},
+		{
+			name: "ref to a boolean schema",
+			bundle: `{
+				"properties": {"a": {"$ref": "#/$defs/f", "type": "string"}, "b": {"$ref": "#/$defs/t", "type": "string"}},
+				"$defs": {"f": false, "t": true}
+			}`,
+			want: `{"properties": {"a": {"type": "string", "allOf": [false]}, "b": {"type": "string"}}}`,
+		},
+		{
+			name: "recursive root",
+			bundle: `{
+				"$id": "https://example.com/tree.json",
+				"properties": {"children": {"type": "array", "items": {"$ref": "#"}}}
+			}`,
+			want: `{
+				"$id": "https://example.com/tree.json",
+				"properties": {"children": {"type": "array", "items": {"$ref": "#"}}}
+			}`,
+		},
+		{
+			name: "recursive def",
+			bundle: `{
+				"properties": {"head": {"$ref": "#/$defs/node"}},
+				"$defs": {
+					"node": {"type": "object", "properties": {"next": {"$ref": "#/$defs/node"}, "value": {"$ref": "#/$defs/value"}}},
+					"value": {"type": "integer"}
+				}
+			}`,
+			want: `{
+				"properties": {"head": {"type": "object", "properties": {"next": {"$ref": "#/$defs/node"}, "value": {"type": "integer"}}}},
+				"$defs": {
+					"node": {"type": "object", "properties": {"next": {"$ref": "#/$defs/node"}, "value": {"type": "integer"}}}
+				}
+			}`,
+			recursive: 1,
+		},
+		{
+			name: "mutually recursive defs",
+			bundle: `{
+				"$ref": "#/$defs/a",
+				"$defs": {
+					"a": {"properties": {"b": {"$ref": "#/$defs/b"}}},
+					"b": {"properties": {"a": {"$ref": "#/$defs/a"}}}
+				}
+			}`,
+			want: `{
+				"properties": {"b": {"properties": {"a": {"$ref": "#/$defs/a"}}}},
+				"$defs": {
+					"a": {"properties": {"b": {"properties": {"a": {"$ref": "#/$defs/a"}}}}}
+				}
+			}`,
+			recursive: 1,
+		},
+		{
+			name: "property names are not keywords",
+			bundle: `{
+				"properties": {"$ref": {"type": "string"}, "$id": {"$ref": "#/$defs/s"}},
+				"$defs": {"s": {"type": "integer"}}
+			}`,
+			want: `{"properties": {"$ref": {"type": "string"}, "$id": {"type": "integer"}}}`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			out, recursive, err := dereferenceBundle([]byte(tc.bundle))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recursive != tc.recursive {
+				t.Errorf("recursive = %d, want %d", recursive, tc.recursive)
+			}
+			var got, want any
+			if err := json.Unmarshal(out, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("bundle = %s", out)
+			}
+		})
+	}
+}
+
+func TestDereferenceBundleUnresolved(t *testing.T) {
+	_, _, err := dereferenceBundle([]byte(`{"properties": {"a": {"$ref": "#/$defs/missing"}}}`))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -6,32 +6,116 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+	"github.com/andrewkroh/package-spec-schema/internal/profiling"
+	"github.com/andrewkroh/package-spec-schema/internal/summary"
 )
 
 var (
-	inDir  string // Directory containing the JSON schemas.
-	outDir string // Directory where to write bundled schemas.
+	inDir       string   // Directory containing the JSON schemas.
+	outDir      string   // Directory where to write bundled schemas.
+	resolveDirs dirsFlag // Directories from which references are resolved.
+	jobs        int      // Number of schemas bundled concurrently.
+	state       string   // File recording the input hash of each bundle.
+	force       bool     // Bundle every schema even if its inputs are unchanged.
+	preserveID  bool     // Keep the $id of the bundled schemas.
+	minify      bool     // Write bundles without insignificant whitespace.
+	dedup       bool     // Merge identical $defs entries.
+	uber        bool     // Also write a single bundle containing every schema.
+	dereference bool     // Inline the target of every $ref.
+	budget      sizeBudget
 )
 
+// remote caches schemas referenced by http(s) URLs.
+var remote = remoteCache{client: &http.Client{Timeout: time.Minute}}
+
+// bundleOptions returns the options passed to the bundler in addition to the
+// schema and resolve directories. They are part of the input hash of each
+// bundle.
+func bundleOptions() []string {
+	var opts []string
+	if !preserveID {
+		// By default bundles omit identifiers so they stand alone as files.
+		opts = append(opts, "--without-id")
+	}
+	return opts
+}
+
+// hashOptions returns every option that affects the content of a bundle.
+func hashOptions() []string {
+	return append(bundleOptions(),
+		"minify="+strconv.FormatBool(minify),
+		"dedup-defs="+strconv.FormatBool(dedup),
+		"dereference="+strconv.FormatBool(dereference))
+}
+
 func init() {
 	flag.StringVar(&inDir, "i", "", "input directory containing JSON Schema files")
 	flag.StringVar(&outDir, "o", "", "output directory")
+	flag.Var(&resolveDirs, "resolve", "directory of schemas from which references are resolved, may be repeated (default input directory)")
+	flag.IntVar(&jobs, "jobs", runtime.NumCPU(), "number of schemas to bundle concurrently")
+	flag.StringVar(&state, "state", ".package-spec-schema/bundle-state.json", "file recording a hash of the inputs of each bundle, used to skip bundles whose inputs are unchanged; empty disables skipping")
+	flag.BoolVar(&force, "force", false, "bundle every schema even if its inputs are unchanged")
+	flag.BoolVar(&preserveID, "preserve-id", false, "keep $id in bundles for consumers that look up schemas by URI")
+	flag.BoolVar(&dedup, "dedup-defs", false, "merge structurally identical $defs entries and rewrite references to them")
+	flag.BoolVar(&dereference, "dereference", false, "inline the target of every $ref to produce standalone schemas without $defs, except for copies of recursive schemas")
+	flag.StringVar(&remote.dir, "remote-cache", ".package-spec-schema/remote-cache", "directory where schemas referenced by http(s) URLs are cached")
+	flag.Var(&remote.hosts, "allow-host", "host from which referenced schemas may be downloaded, may be repeated; remote references are rejected when no host is allowed")
+	flag.BoolVar(&remote.offline, "offline", false, "resolve remote references only from the cache")
+	remote.client.CheckRedirect = remote.checkRedirect
+	flag.BoolVar(&uber, "uber", false, "also write package-spec-<version>.jsonschema.json containing the combined manifest and every other schema under $defs")
+	flag.Var((*sizeFlag)(&budget.file), "max-bundle-size", "fail if a bundle is larger than this size, e.g. 512KiB; 0 disables the check")
+	flag.Var((*sizeFlag)(&budget.version), "max-version-size", "fail if the bundles of the version are larger than this size in total, e.g. 4MiB; 0 disables the check")
+	flag.BoolVar(&budget.warn, "size-budget-warn", false, "log bundles exceeding the size budget as warnings instead of failing")
+	flag.BoolVar(&minify, "m", false, "minify bundles by removing insignificant whitespace; do not combine with the fmt target")
+	logging.AddFlags(flag.CommandLine)
+	summary.AddFlags(flag.CommandLine)
+	profiling.AddFlags(flag.CommandLine)
 }
 
 func main() {
 	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+	summary.Start("bundle")
+	stopProfile, err := profiling.Start()
+	if err != nil {
+		logging.Fatal(err)
+	}
 
-	if err := run(); err != nil {
-		log.Fatal(err)
+	err = run()
+	if profErr := stopProfile(); profErr != nil {
+		err = errors.Join(err, profErr)
+	}
+	if sumErr := summary.Write(err); sumErr != nil {
+		err = errors.Join(err, sumErr)
+	}
+	if err != nil {
+		logging.Fatal(err)
 	}
 }
 
@@ -42,6 +126,12 @@ func run() error {
 	if outDir == "" {
 		return errors.New("no output dir specified")
 	}
+	if jobs < 1 {
+		return errors.New("-jobs must be at least 1")
+	}
+	if dereference && uber {
+		return errors.New("-dereference cannot be combined with -uber, which holds its schemas in $defs")
+	}
 
 	_, err := exec.LookPath("jsonschema")
 	if err != nil {
@@ -49,43 +139,158 @@ func run() error {
 	}
 
 	// Find the .jsonschema.json files.
+	endFind := summary.StartPhase("find")
 	schemas, err := findFiles(inDir, func(path string, _ os.FileInfo) bool {
 		return strings.HasSuffix(path, ".jsonschema.json")
 	})
+	endFind()
 	if err != nil {
 		return fmt.Errorf("failed finding files: %w", err)
 	}
 
+	if len(resolveDirs) == 0 {
+		resolveDirs = dirsFlag{inDir}
+	}
+	var rc *remoteCache
+	if len(remote.hosts) > 0 {
+		rc = &remote
+	}
+	resolver, err := newResolver(resolveDirs, rc)
+	if err != nil {
+		return err
+	}
+
+	st, err := readBundleState(state, force)
+	if err != nil {
+		return err
+	}
+
 	// Convert each file into a bundle.
-	for _, schema := range schemas {
-		if err = bundleSchema(schema, inDir, outDir); err != nil {
-			return fmt.Errorf("bundling %q failed: %w", schema, err)
+	start := time.Now()
+	endBundle := summary.StartPhase("bundle")
+	defer endBundle()
+	err = bundleSchemas(schemas, inDir, outDir, jobs, resolver, st)
+	if err == nil && uber {
+		if err = bundleUber(schemas, inDir, outDir, resolver, st); err != nil {
+			err = fmt.Errorf("uber bundle failed: %w", err)
 		}
 	}
+
+	// Record the bundles that succeeded even if others failed.
+	if stErr := st.write(); stErr != nil {
+		return errors.Join(err, fmt.Errorf("failed to write bundle state: %w", stErr))
+	}
+	if err != nil {
+		return err
+	}
+	// Unchanged bundles that were skipped count toward the budget too.
+	if err := budget.check(outDir); err != nil {
+		return err
+	}
+	slog.Info("Bundled schemas.", "dir", inDir, "schemas", len(schemas), "duration", time.Since(start))
 	return nil
 }
 
-func bundleSchema(schemaPath, inDir, outDir string) error {
+// bundleSchemas bundles the schemas using a pool of jobs workers. Every schema
+// is attempted, and the errors for all schemas that failed are returned and
+// recorded as failures in the summary.
+func bundleSchemas(schemas []string, inDir, outDir string, jobs int, r *resolver, st *bundleState) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	work := make(chan string)
+	for range jobs {
+		wg.Go(func() {
+			for schema := range work {
+				if err := bundleSchema(schema, inDir, outDir, r, st); err != nil {
+					summary.AddFailure("", schema, err)
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("bundling %q failed: %w", schema, err))
+					mu.Unlock()
+				}
+			}
+		})
+	}
+	for _, schema := range schemas {
+		work <- schema
+	}
+	close(work)
+	wg.Wait()
+
+	// Report errors in a deterministic order regardless of scheduling.
+	slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(errs...)
+}
+
+func bundleSchema(schemaPath, inDir, outDir string, r *resolver, st *bundleState) error {
+	return bundleFile(schemaPath, filepath.Join(outDir, trimFilePrefix(schemaPath, inDir)), r, st)
+}
+
+// bundleFile bundles the schema and writes the result to outFile.
+func bundleFile(schemaPath, outFile string, r *resolver, st *bundleState) error {
+	start := time.Now()
+
+	hash, err := inputHash(schemaPath, r, hashOptions())
+	if err != nil {
+		return err
+	}
+	if st.unchanged(outFile, hash) {
+		summary.AddFile(false)
+		slog.Debug("Skipped unchanged bundle.", "path", outFile)
+		return nil
+	}
+
 	// https://github.com/sourcemeta/jsonschema/blob/main/docs/bundle.markdown
-	args := []string{
-		"bundle",
-		schemaPath,
-		"--resolve", inDir,
-		"--without-id",
+	args := []string{"bundle", schemaPath}
+	for _, dir := range r.dirs {
+		args = append(args, "--resolve", dir)
 	}
+	args = append(args, bundleOptions()...)
 	out, err := jsonschemaExec(args...)
 	if err != nil {
 		return err
 	}
 
-	outFile := filepath.Join(outDir, trimFilePrefix(schemaPath, inDir))
+	if dedup {
+		var removed int
+		out, removed, err = dedupDefs(out)
+		if err != nil {
+			return fmt.Errorf("failed to merge duplicate definitions: %w", err)
+		}
+		if removed > 0 {
+			slog.Debug("Merged duplicate definitions.", "path", outFile, "removed", removed)
+		}
+	}
+	if err := verifyRefs(out); err != nil {
+		return fmt.Errorf("bundle contains unresolved references: %w", err)
+	}
+	if dereference {
+		var recursive int
+		out, recursive, err = dereferenceBundle(out)
+		if err != nil {
+			return fmt.Errorf("failed to dereference bundle: %w", err)
+		}
+		slog.Debug("Dereferenced bundle.", "path", outFile, "recursive", recursive)
+	}
 
-	if err = os.MkdirAll(filepath.Dir(outFile), 0o700); err != nil {
-		return err
+	if minify {
+		buf := new(bytes.Buffer)
+		if err := json.Compact(buf, out); err != nil {
+			return fmt.Errorf("failed to minify bundle: %w", err)
+		}
+		out = append(buf.Bytes(), '\n')
 	}
-	if err = os.WriteFile(outFile, out, 0o600); err != nil {
+
+	// Leave identical bundles untouched to keep modification times stable.
+	changed, err := fsutil.WriteFileIfChanged(outFile, out)
+	if err != nil {
 		return err
 	}
+	st.set(outFile, hash)
+	summary.AddFile(changed)
+	slog.Debug("Bundled schema.", "path", outFile, "changed", changed, "duration", time.Since(start))
 	return nil
 }
 
@@ -116,9 +321,12 @@ func jsonschemaExec(args ...string) (stdout []byte, err error) {
 	cmd.Stderr = errBuf
 
 	if err := cmd.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", errBuf.Bytes())
+		slog.Error("jsonschema failed.", "args", args, "stderr", strings.TrimSpace(errBuf.String()))
 		return nil, fmt.Errorf("failed running jsonschema %s: %w", strings.Join(args, " "), err)
 	}
+	if errBuf.Len() > 0 {
+		slog.Log(context.Background(), logging.LevelTrace, "jsonschema output.", "args", args, "stderr", strings.TrimSpace(errBuf.String()))
+	}
 	return outBuf.Bytes(), nil
 }
 
@@ -0,0 +1,174 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+)
+
+// maxRemoteSchemaSize is the maximum size of a downloaded schema.
+const maxRemoteSchemaSize = 10 << 20
+
+// hostsFlag is a flag.Value accepting repeated host names. A single value may
+// also contain a comma separated list.
+type hostsFlag []string
+
+func (f *hostsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *hostsFlag) Set(value string) error {
+	for host := range strings.SplitSeq(value, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			*f = append(*f, strings.ToLower(host))
+		}
+	}
+	return nil
+}
+
+// remoteCache downloads schemas referenced by http(s) URLs into a directory
+// that is passed to the bundler as an additional resolve directory. Cached
+// schemas are reused on later runs so that builds are reproducible and can
+// run without network access.
+type remoteCache struct {
+	dir     string    // Cache directory.
+	hosts   hostsFlag // Hosts from which schemas may be downloaded.
+	offline bool      // Only use cached schemas.
+	client  *http.Client
+}
+
+// checkAllowed returns an error if schemas may not be downloaded from u.
+func (c *remoteCache) checkAllowed(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported reference scheme %q", u.Scheme)
+	}
+	if !slices.Contains(c.hosts, strings.ToLower(u.Hostname())) {
+		return fmt.Errorf("host %q is not allowed, add it with -allow-host", u.Hostname())
+	}
+	return nil
+}
+
+// checkRedirect is the CheckRedirect policy of the client. Redirects must
+// stay on the allowed hosts and must not downgrade https to http, because a
+// downloaded schema without an $id is cached under the requested URL.
+func (c *remoteCache) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if via[len(via)-1].URL.Scheme == "https" && req.URL.Scheme != "https" {
+		return fmt.Errorf("redirect from https to %s is not allowed", req.URL)
+	}
+	if err := c.checkAllowed(req.URL); err != nil {
+		return fmt.Errorf("redirect to %s: %w", req.URL, err)
+	}
+	return nil
+}
+
+// file returns the cache file for the schema at u.
+func (c *remoteCache) file(u *url.URL) string {
+	p := path.Clean("/" + u.Path)
+	if p == "/" {
+		p = "/index"
+	}
+	if !strings.HasSuffix(p, ".json") {
+		// The bundler only reads .json files from resolve directories.
+		p += ".json"
+	}
+	host := strings.ReplaceAll(strings.ToLower(u.Host), ":", "_")
+	return filepath.Join(c.dir, host, filepath.FromSlash(p))
+}
+
+// fetch returns the cache file containing the schema at u, downloading it if
+// it is not cached yet.
+func (c *remoteCache) fetch(u *url.URL) (string, error) {
+	if err := c.checkAllowed(u); err != nil {
+		return "", err
+	}
+	if u.RawQuery != "" || u.ForceQuery {
+		// The cache is keyed by host and path.
+		return "", fmt.Errorf("%s has a query string, which remote references may not have", u)
+	}
+
+	file := c.file(u)
+	if _, err := os.Stat(file); err == nil {
+		return file, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	if c.offline {
+		return "", fmt.Errorf("%s is not cached and -offline is set", u)
+	}
+
+	start := time.Now()
+	data, err := c.download(u)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fsutil.WriteFileIfChanged(file, data); err != nil {
+		return "", err
+	}
+	slog.Info("Downloaded remote schema.", "url", u.String(), "path", file, "duration", time.Since(start))
+	return file, nil
+}
+
+// download retrieves the schema at u. Schemas without an $id are assigned
+// u so that the bundler can find them by the referenced URL.
+func (c *remoteCache) download(u *url.URL) (data []byte, err error) {
+	resp, err := c.client.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", u, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteSchemaSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", u, err)
+	}
+	if len(body) > maxRemoteSchemaSize {
+		return nil, fmt.Errorf("%s exceeds the maximum schema size of %d bytes", u, maxRemoteSchemaSize)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("%s is not a JSON schema: %w", u, err)
+	}
+	switch id, _ := doc["$id"].(string); {
+	case id == "":
+		doc["$id"] = u.String()
+	case strings.TrimSuffix(id, "#") != u.String():
+		return nil, fmt.Errorf("%s has a different $id %q", u, id)
+	default:
+		return body, nil
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
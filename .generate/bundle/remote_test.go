@@ -0,0 +1,80 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRemoteCacheFetch(t *testing.T) {
+	// Served on 127.0.0.1, which is the only allowed host, and reachable as
+	// localhost, which is not.
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	local := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	tlsSrv := httptest.NewTLSServer(http.RedirectHandler(srv.URL+"/schema.json", http.StatusFound))
+	defer tlsSrv.Close()
+
+	mux.HandleFunc("/schema.json", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(`{"type": "string"}`))
+	})
+	mux.Handle("/moved.json", http.RedirectHandler("/schema.json", http.StatusFound))
+	mux.Handle("/elsewhere.json", http.RedirectHandler(local+"/schema.json", http.StatusFound))
+
+	tests := []struct {
+		name string
+		url  string
+		err  string // Empty if the download succeeds.
+	}{
+		{name: "download", url: srv.URL + "/schema.json"},
+		{name: "redirect on the host", url: srv.URL + "/moved.json"},
+		{name: "redirect to another host", url: srv.URL + "/elsewhere.json", err: `host "localhost" is not allowed`},
+		{name: "redirect from https to http", url: tlsSrv.URL + "/a.json", err: "redirect from https to"},
+		{name: "host not allowed", url: local + "/schema.json", err: `host "localhost" is not allowed`},
+		{name: "query string", url: srv.URL + "/schema.json?v=1", err: "query string"},
+		{name: "empty query string", url: srv.URL + "/schema.json?", err: "query string"},
+		{name: "scheme", url: "file:///schema.json", err: "unsupported reference scheme"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client := tlsSrv.Client()
+			c := &remoteCache{dir: t.TempDir(), hosts: hostsFlag{"127.0.0.1"}, client: client}
+			client.CheckRedirect = c.checkRedirect
+
+			u, err := url.Parse(tc.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file, err := c.fetch(u)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("error = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc map[string]any
+			if err := json.Unmarshal(b, &doc); err != nil {
+				t.Fatal(err)
+			}
+			if doc["$id"] != tc.url {
+				t.Errorf("$id = %v, want %s", doc["$id"], tc.url)
+			}
+		})
+	}
+}
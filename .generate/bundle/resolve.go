@@ -0,0 +1,239 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// dirsFlag is a flag.Value accepting repeated directory values. A single
+// value may also contain a list of directories separated by the OS path list
+// separator, which allows setting multiple directories from an environment
+// variable.
+type dirsFlag []string
+
+func (f *dirsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, string(filepath.ListSeparator))
+}
+
+func (f *dirsFlag) Set(value string) error {
+	for dir := range strings.SplitSeq(value, string(filepath.ListSeparator)) {
+		if dir == "" {
+			continue
+		}
+		*f = append(*f, dir)
+	}
+	return nil
+}
+
+// resolver finds the schemas that a schema depends on within the directories
+// that the bundler resolves references from. It is safe for concurrent use.
+type resolver struct {
+	dirs   []string          // Resolve directories.
+	remote *remoteCache      // Cache of remote schemas, nil if remote references are not allowed.
+	mu     sync.Mutex        // Guards ids.
+	ids    map[string]string // File path keyed by the $id of each schema in dirs.
+}
+
+// newResolver indexes the schemas in dirs by their $id. If remote is not
+// nil, its directory is appended to dirs and remote schemas that are not
+// found locally are downloaded into it.
+func newResolver(dirs []string, remote *remoteCache) (*resolver, error) {
+	if remote != nil {
+		if err := os.MkdirAll(remote.dir, 0o700); err != nil {
+			return nil, err
+		}
+		dirs = append(slices.Clip(dirs), remote.dir)
+	}
+
+	r := &resolver{remote: remote, ids: map[string]string{}}
+	for _, dir := range dirs {
+		r.dirs = append(r.dirs, filepath.Clean(dir))
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, ".json") {
+				return err
+			}
+			id, err := schemaID(path)
+			if err != nil {
+				return err
+			}
+			if id != "" {
+				r.ids[id] = filepath.Clean(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed indexing %s: %w", dir, err)
+		}
+	}
+	return r, nil
+}
+
+// schemaID returns the top-level $id of the JSON document in file.
+func schemaID(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	var doc struct {
+		ID string `json:"$id"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("failed to decode %s: %w", file, err)
+	}
+	return doc.ID, nil
+}
+
+// key returns a stable name for file made up of the index of the resolve
+// directory containing it and its slash-separated path within that directory.
+// Files outside of the resolve directories, such as generated entry points in
+// temporary directories, are named by their base name.
+func (r *resolver) key(file string) string {
+	for i, dir := range r.dirs {
+		if rel, ok := within(dir, file); ok {
+			return strconv.Itoa(i) + ":" + rel
+		}
+	}
+	return filepath.Base(file)
+}
+
+// closure returns the sorted paths of the schema and of every schema in the
+// resolve directories that is reachable from it through $refs.
+func (r *resolver) closure(schemaPath string) ([]string, error) {
+	seen := map[string]bool{}
+	queue := []string{filepath.Clean(schemaPath)}
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var doc any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", file, err)
+		}
+
+		var base *url.URL
+		if m, ok := doc.(map[string]any); ok {
+			if id, ok := m["$id"].(string); ok {
+				base, _ = url.Parse(id)
+			}
+		}
+		for _, ref := range collectRefs(doc, nil) {
+			target, ok, err := r.resolve(file, base, ref)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve %q in %s: %w", ref, file, err)
+			}
+			if ok {
+				queue = append(queue, target)
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(seen)), nil
+}
+
+// resolve returns the file that ref, found in file with the given base URI,
+// points to. Relative references are first looked up as paths relative to
+// file, then like absolute references by $id. Absolute http(s) references
+// that are not found locally are downloaded. Other unresolvable references
+// are left for the bundler to report.
+func (r *resolver) resolve(file string, base *url.URL, ref string) (string, bool, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", false, nil
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	if u.String() == "" {
+		// Reference within the same document.
+		return "", false, nil
+	}
+
+	if !u.IsAbs() && u.Host == "" {
+		target := filepath.Join(filepath.Dir(file), filepath.FromSlash(u.Path))
+		if _, err := os.Stat(target); err == nil && r.contains(target) {
+			return target, true, nil
+		}
+		if base == nil {
+			return "", false, nil
+		}
+		u = base.ResolveReference(u)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if target, ok := r.ids[u.String()]; ok {
+		return target, true, nil
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false, nil
+	}
+	if r.remote == nil {
+		return "", false, errors.New("remote references are not allowed, permit hosts with -allow-host")
+	}
+	target, err := r.remote.fetch(u)
+	if err != nil {
+		return "", false, err
+	}
+	r.ids[u.String()] = target
+	return target, true, nil
+}
+
+// contains reports whether file is within one of the resolve directories.
+func (r *resolver) contains(file string) bool {
+	return slices.ContainsFunc(r.dirs, func(dir string) bool {
+		_, ok := within(dir, file)
+		return ok
+	})
+}
+
+// within returns the slash-separated path of file relative to dir if file is
+// within dir.
+func within(dir, file string) (string, bool) {
+	rel, err := filepath.Rel(dir, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// collectRefs appends the value of every $ref keyword in v to refs.
+func collectRefs(v any, refs []string) []string {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if s, ok := child.(string); ok && k == "$ref" {
+				refs = append(refs, s)
+				continue
+			}
+			refs = collectRefs(child, refs)
+		}
+	case []any:
+		for _, child := range v {
+			refs = collectRefs(child, refs)
+		}
+	}
+	return refs
+}
@@ -0,0 +1,113 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+)
+
+// bundleState records a hash of the inputs of each bundle so that bundles
+// whose inputs did not change since the previous run can be skipped. It is
+// safe for concurrent use.
+type bundleState struct {
+	path   string            // File where the state is stored. Empty disables the state.
+	force  bool              // Treat every bundle as changed.
+	mu     sync.Mutex        // Guards hashes.
+	hashes map[string]string // Input hash keyed by bundle file path.
+}
+
+// readBundleState reads the state stored at path. A missing file yields an
+// empty state.
+func readBundleState(path string, force bool) (*bundleState, error) {
+	s := &bundleState{path: path, force: force, hashes: map[string]string{}}
+	if path == "" {
+		return s, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &s.hashes); err != nil {
+		return nil, fmt.Errorf("failed to decode bundle state %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// unchanged reports whether outFile exists and was produced from inputs with
+// the given hash.
+func (s *bundleState) unchanged(outFile, hash string) bool {
+	if s.path == "" || s.force {
+		return false
+	}
+
+	s.mu.Lock()
+	prev := s.hashes[filepath.ToSlash(outFile)]
+	s.mu.Unlock()
+	if prev != hash {
+		return false
+	}
+	_, err := os.Stat(outFile)
+	return err == nil
+}
+
+// set records the hash of the inputs that produced outFile.
+func (s *bundleState) set(outFile, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hashes[filepath.ToSlash(outFile)] = hash
+}
+
+// write stores the state.
+func (s *bundleState) write() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := json.MarshalIndent(s.hashes, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fsutil.WriteFileIfChanged(s.path, append(b, '\n'))
+	return err
+}
+
+// inputHash returns a hash covering the bundler options, the schema, and
+// every schema in the resolve directories that it transitively references.
+func inputHash(schemaPath string, r *resolver, options []string) (string, error) {
+	files, err := r.closure(schemaPath)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	for _, opt := range options {
+		fmt.Fprintf(h, "%s\x00", opt)
+	}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", r.key(f), len(data))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,83 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// combinedManifest is the path of the combined manifest schema relative to
+// the input directory. It is the root of the uber bundle.
+const combinedManifest = "manifest.jsonschema.json"
+
+// bundleUber writes package-spec-<version>.jsonschema.json to outDir. It
+// validates package manifests like the combined manifest schema, and it
+// contains every other schema of the version under $defs keyed by its path
+// so that consumers need only one file per version.
+func bundleUber(schemas []string, inDir, outDir string, r *resolver, st *bundleState) (err error) {
+	b, err := os.ReadFile(filepath.Join(inDir, combinedManifest))
+	if err != nil {
+		return err
+	}
+	var manifest struct {
+		Schema string `json:"$schema"`
+		ID     string `json:"$id"`
+	}
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", combinedManifest, err)
+	}
+	base, err := url.Parse(manifest.ID)
+	if err != nil || manifest.ID == "" {
+		return fmt.Errorf("%s has an invalid $id %q", combinedManifest, manifest.ID)
+	}
+
+	// The combined manifest $id ends with <version>/manifest.jsonschema.json.
+	version := path.Base(path.Dir(base.Path))
+	name := "package-spec-" + version + ".jsonschema.json"
+
+	defs := map[string]any{}
+	for _, schema := range schemas {
+		rel := trimFilePrefix(schema, inDir)
+		if rel == combinedManifest {
+			continue
+		}
+		defs[rel] = map[string]any{"$ref": "./" + rel}
+	}
+	entry, err := json.MarshalIndent(map[string]any{
+		"$schema":     manifest.Schema,
+		"$id":         base.ResolveReference(&url.URL{Path: name}).String(),
+		"title":       "Package Spec " + version,
+		"description": "Schema for package manifests. Every other schema of the package-spec version is contained in $defs.",
+		"$ref":        "./" + combinedManifest,
+		"$defs":       defs,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// The entry point lives outside of the resolve directories so that it is
+	// not picked up as input by later runs.
+	tmp, err := os.MkdirTemp("", "uber-bundle-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if rmErr := os.RemoveAll(tmp); rmErr != nil {
+			err = errors.Join(err, rmErr)
+		}
+	}()
+	entryFile := filepath.Join(tmp, name)
+	if err := os.WriteFile(entryFile, entry, 0o600); err != nil {
+		return err
+	}
+
+	return bundleFile(entryFile, filepath.Join(outDir, name), r, st)
+}
@@ -0,0 +1,159 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/schemawalk"
+)
+
+// verifyRefs checks that every $ref in the bundle resolves to a location
+// within the bundle itself. References may be fragment-only JSON pointers or
+// anchors, or, when identifiers are preserved, point to an embedded
+// resource by its $id.
+func verifyRefs(bundle []byte) error {
+	var root any
+	if err := json.Unmarshal(bundle, &root); err != nil {
+		return fmt.Errorf("failed to decode bundle: %w", err)
+	}
+
+	v := &refVerifier{resources: map[string]any{}, anchors: map[string]any{}}
+	v.index(root, &url.URL{})
+	v.check(root, &url.URL{}, "")
+
+	// Report errors in a deterministic order regardless of map iteration.
+	slices.SortFunc(v.errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(v.errs...)
+}
+
+type refVerifier struct {
+	resources map[string]any // Embedded resources keyed by absolute $id without fragment.
+	anchors   map[string]any // Subschemas keyed by resource $id and anchor name.
+	errs      []error
+}
+
+// scope returns the base URI that applies within node.
+func scope(node map[string]any, base *url.URL) *url.URL {
+	if id, ok := node["$id"].(string); ok {
+		if u, err := url.Parse(id); err == nil {
+			base = base.ResolveReference(u)
+			base.Fragment = ""
+		}
+	}
+	return base
+}
+
+// index records every embedded resource and anchor in the schema node.
+func (v *refVerifier) index(node any, base *url.URL) {
+	schema, ok := node.(map[string]any)
+	if !ok {
+		return
+	}
+	base = scope(schema, base)
+	if _, ok := v.resources[base.String()]; !ok {
+		v.resources[base.String()] = schema
+	}
+	if anchor, ok := schema["$anchor"].(string); ok {
+		v.anchors[base.String()+"#"+anchor] = schema
+	}
+	for _, sub := range schemawalk.Subschemas(schema) {
+		v.index(sub, base)
+	}
+}
+
+// check verifies the $refs in the schema node.
+func (v *refVerifier) check(node any, base *url.URL, ptr string) {
+	schema, ok := node.(map[string]any)
+	if !ok {
+		return
+	}
+	base = scope(schema, base)
+	if ref, ok := schema["$ref"].(string); ok {
+		if err := v.resolve(base, ref); err != nil {
+			v.errs = append(v.errs, fmt.Errorf("$ref %q at %q: %w", ref, ptr+"/$ref", err))
+		}
+	}
+	for rel, sub := range schemawalk.Subschemas(schema) {
+		v.check(sub, base, ptr+rel)
+	}
+}
+
+// resolve returns an error if ref, relative to base, does not point into the
+// bundle.
+func (v *refVerifier) resolve(base *url.URL, ref string) error {
+	_, _, err := v.lookup(base, ref)
+	return err
+}
+
+// lookup returns the subschema of the bundle that ref, relative to base,
+// points to and the absolute URI of the reference.
+func (v *refVerifier) lookup(base *url.URL, ref string) (any, *url.URL, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	target := base.ResolveReference(u)
+	fragment := target.Fragment
+	target.Fragment = ""
+	target.RawFragment = ""
+
+	resource, ok := v.resources[target.String()]
+	if !ok {
+		return nil, nil, errors.New("reference to a resource outside of the bundle")
+	}
+
+	var node any
+	switch {
+	case fragment == "":
+		node = resource
+	case strings.HasPrefix(fragment, "/"):
+		if node, err = resolvePointer(resource, fragment); err != nil {
+			return nil, nil, err
+		}
+	default:
+		if node, ok = v.anchors[target.String()+"#"+fragment]; !ok {
+			return nil, nil, fmt.Errorf("anchor %q not found", fragment)
+		}
+	}
+	target.Fragment = fragment
+	return node, target, nil
+}
+
+// resolvePointer returns the value that the JSON pointer refers to in doc.
+func resolvePointer(doc any, pointer string) (any, error) {
+	node := doc
+	for tok := range strings.SplitSeq(strings.TrimPrefix(pointer, "/"), "/") {
+		tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+		switch n := node.(type) {
+		case map[string]any:
+			child, ok := n[tok]
+			if !ok {
+				return nil, fmt.Errorf("pointer %q not found", pointer)
+			}
+			node = child
+		case []any:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, fmt.Errorf("pointer %q not found", pointer)
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("pointer %q not found", pointer)
+		}
+	}
+	return node, nil
+}
+
+// escapePointer escapes a JSON pointer reference token.
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
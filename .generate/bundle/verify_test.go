@@ -0,0 +1,65 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifyRefs(t *testing.T) {
+	tests := []struct {
+		name   string
+		bundle string
+		err    string // Empty if the bundle is valid.
+	}{
+		{
+			name:   "resolved",
+			bundle: `{"properties": {"a": {"$ref": "#/$defs/s"}}, "$defs": {"s": {"type": "string"}}}`,
+		},
+		{
+			name:   "unresolved",
+			bundle: `{"properties": {"a": {"$ref": "#/$defs/missing"}}}`,
+			err:    `$ref "#/$defs/missing" at "/properties/a/$ref"`,
+		},
+		{
+			name:   "unresolved in a property named like a literal keyword",
+			bundle: `{"properties": {"default": {"$ref": "#/$defs/missing"}}}`,
+			err:    `$ref "#/$defs/missing" at "/properties/default/$ref"`,
+		},
+		{
+			name:   "unresolved in a definition named like a literal keyword",
+			bundle: `{"$defs": {"const": {"$ref": "#/$defs/missing"}}}`,
+			err:    `$ref "#/$defs/missing" at "/$defs/const/$ref"`,
+		},
+		{
+			name:   "literal values are not schemas",
+			bundle: `{"default": {"$ref": "#/$defs/missing"}, "enum": [{"$ref": "#/$defs/missing"}]}`,
+		},
+		{
+			name:   "embedded resource",
+			bundle: `{"properties": {"a": {"$ref": "https://example.com/s.json"}}, "$defs": {"s": {"$id": "https://example.com/s.json", "$anchor": "top"}}}`,
+		},
+		{
+			name:   "resource outside the bundle",
+			bundle: `{"properties": {"a": {"$ref": "https://example.com/s.json"}}}`,
+			err:    "reference to a resource outside of the bundle",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifyRefs([]byte(tc.bundle))
+			if tc.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("error = %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// catalog writes a catalog.json in the SchemaStore catalog format that maps
+// package file name patterns to the URLs of the bundled schemas. It can be
+// submitted to schemastore.org or used directly by editors that accept a
+// schema catalog.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+	"github.com/andrewkroh/package-spec-schema/internal/packagefiles"
+)
+
+const catalogFile = "catalog.json"
+
+var (
+	outDir  string // Directory containing the versioned directories.
+	baseURL string // URL at which the output directory is published.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories, the catalog is written here")
+	flag.StringVar(&baseURL, "base-url", "https://raw.githubusercontent.com/andrewkroh/package-spec-schema/refs/heads/main", "URL at which the output directory is published")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+// catalog is the SchemaStore catalog format.
+type catalog struct {
+	Schema  string         `json:"$schema"`
+	Version int            `json:"version"`
+	Schemas []catalogEntry `json:"schemas"`
+}
+
+type catalogEntry struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	FileMatch   []string          `json:"fileMatch"`
+	URL         string            `json:"url"`                // Schema of the latest version.
+	Versions    map[string]string `json:"versions,omitempty"` // Schema URL keyed by version.
+}
+
+func run() error {
+	versions, err := versionDirs(outDir)
+	if err != nil {
+		return err
+	}
+
+	c := catalog{
+		Schema:  "https://json.schemastore.org/schema-catalog.json",
+		Version: 1,
+		Schemas: []catalogEntry{},
+	}
+	for _, f := range packagefiles.Files {
+		entry := catalogEntry{
+			Name:        f.Name,
+			Description: f.Description,
+			FileMatch:   f.Patterns,
+			Versions:    map[string]string{},
+		}
+		for _, v := range versions {
+			if _, err := os.Stat(filepath.Join(outDir, v.String(), "bundles", filepath.FromSlash(f.Schema))); err != nil {
+				continue
+			}
+			u := strings.TrimSuffix(baseURL, "/") + "/" + v.String() + "/bundles/" + f.Schema
+			entry.Versions[v.String()] = u
+			if v.PreRelease == "" {
+				entry.URL = u
+			}
+		}
+		if entry.URL == "" {
+			slog.Debug("Schema is not present in any release.", "path", f.Schema)
+			continue
+		}
+		c.Schemas = append(c.Schemas, entry)
+	}
+
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := fsutil.WriteFileIfChanged(filepath.Join(outDir, catalogFile), append(b, '\n')); err != nil {
+		return err
+	}
+	slog.Info("Wrote schema catalog.", "schemas", len(c.Schemas), "versions", len(versions))
+	return nil
+}
+
+// versionDirs returns the versions in dir that contain a bundles directory
+// sorted by semantic version.
+func versionDirs(dir string) ([]*semver.Version, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*semver.Version
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		v, err := semver.NewVersion(e.Name())
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(dir, e.Name(), "bundles")); err == nil && info.IsDir() {
+			versions = append(versions, v)
+		}
+	}
+	semver.Sort(versions)
+	return versions, nil
+}
@@ -0,0 +1,151 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// changes writes a changes.json into each version directory that lists the
+// schema files and JSON pointers that changed relative to the previous
+// version. Downstream tooling such as documentation generators and migration
+// assistants can use it to find what changed without diffing the schemas
+// themselves.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/jsondiff"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+const changesFile = "changes.json"
+
+var outDir string // Directory containing the versioned directories.
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+// changeReport is the content of changes.json.
+type changeReport struct {
+	Version string `json:"version"`
+	// Previous is the version that the schemas are compared with. It is
+	// omitted for the oldest version, whose files are all reported as added.
+	Previous string       `json:"previous,omitempty"`
+	Files    []fileChange `json:"files"`
+}
+
+// fileChange describes how a schema file changed.
+type fileChange struct {
+	Path    string          `json:"path"` // Path relative to the jsonschema directory.
+	Op      jsondiff.Op     `json:"op"`
+	Changes []pointerChange `json:"changes,omitempty"` // Changes of a modified file.
+}
+
+// pointerChange identifies a changed value within a schema. The values
+// themselves are omitted to keep the report small.
+type pointerChange struct {
+	Op      jsondiff.Op `json:"op"`
+	Pointer string      `json:"pointer"`
+}
+
+func run() error {
+	versions, err := versionDirs(outDir)
+	if err != nil {
+		return err
+	}
+
+	for i, ver := range versions {
+		r := changeReport{Version: ver.String(), Files: []fileChange{}}
+		var prevDir string
+		if i > 0 {
+			r.Previous = versions[i-1].String()
+			prevDir = filepath.Join(outDir, r.Previous, "jsonschema")
+		}
+
+		diffs, err := jsondiff.CompareDirs(prevDir, filepath.Join(outDir, r.Version, "jsonschema"), isSchema)
+		if err != nil {
+			return fmt.Errorf("failed comparing %s: %w", r.Version, err)
+		}
+		for _, d := range diffs {
+			fc := fileChange{Path: d.Path, Op: d.Op}
+			for _, c := range d.Changes {
+				// The $id contains the version, so it differs in every file.
+				// Likewise the root $comment when it records the provenance.
+				if c.Pointer == "/$id" || c.Pointer == "/$comment" {
+					continue
+				}
+				fc.Changes = append(fc.Changes, pointerChange{Op: c.Op, Pointer: c.Pointer})
+			}
+			if fc.Op == jsondiff.Changed && len(fc.Changes) == 0 {
+				continue
+			}
+			r.Files = append(r.Files, fc)
+		}
+
+		b, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		if _, err := fsutil.WriteFileIfChanged(filepath.Join(outDir, r.Version, changesFile), append(b, '\n')); err != nil {
+			return err
+		}
+		slog.Debug("Wrote change report.", "version", r.Version, "previous", r.Previous, "files", len(r.Files))
+	}
+	slog.Info("Wrote change reports.", "versions", len(versions))
+	return nil
+}
+
+// isSchema reports whether the file is a schema, excluding generation metadata.
+func isSchema(rel string) bool {
+	return strings.HasSuffix(rel, ".jsonschema.json")
+}
+
+// versionDirs returns the versions in dir that contain a jsonschema directory
+// sorted by semantic version.
+func versionDirs(dir string) ([]*semver.Version, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*semver.Version
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		v, err := semver.NewVersion(e.Name())
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(dir, e.Name(), "jsonschema")); err == nil && info.IsDir() {
+			versions = append(versions, v)
+		}
+	}
+	slices.SortFunc(versions, func(a, b *semver.Version) int { return a.Compare(*b) })
+	return versions, nil
+}
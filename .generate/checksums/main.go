@@ -0,0 +1,264 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// checksums writes a SHA256SUMS file into each version directory covering
+// every generated and bundled schema, or verifies a tree against them. The
+// files use the sha256sum(1) format so that `sha256sum -c SHA256SUMS` works
+// from within a version directory. Optionally an in-toto SLSA provenance
+// statement is written alongside, and both are signed with cosign.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+const checksumFile = "SHA256SUMS"
+
+var (
+	outDir     string // Directory containing the versioned directories.
+	verify     bool   // Verify checksums instead of writing them.
+	sign       bool   // Sign the checksum files with cosign.
+	signKey    string // Cosign key reference. Keyless signing is used when empty.
+	provenance bool   // Write SLSA provenance statements.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	flag.BoolVar(&verify, "verify", false, "verify schema files against existing SHA256SUMS files")
+	flag.BoolVar(&sign, "sign", false, "sign SHA256SUMS files with cosign")
+	flag.StringVar(&signKey, "sign-key", "", "cosign key reference used for signing, defaults to keyless signing")
+	flag.BoolVar(&provenance, "provenance", false, "write an in-toto SLSA provenance statement into each version directory")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+func run() error {
+	if verify && (sign || provenance) {
+		return errors.New("-sign and -provenance cannot be used with -verify")
+	}
+	if sign {
+		if _, err := exec.LookPath("cosign"); err != nil {
+			return errors.New("cosign tool not found in $PATH")
+		}
+	}
+
+	dirs, err := versionDirs(outDir)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, dir := range dirs {
+		if verify {
+			if err := verifyChecksums(dir); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", dir, err))
+			}
+			continue
+		}
+		digests, err := digestSchemaFiles(dir)
+		if err != nil {
+			return err
+		}
+		changed, err := writeChecksums(dir, digests)
+		if err != nil {
+			return fmt.Errorf("failed writing checksums for %s: %w", dir, err)
+		}
+		if sign {
+			if err := signFile(filepath.Join(dir, checksumFile), signKey, changed); err != nil {
+				return fmt.Errorf("failed signing checksums for %s: %w", dir, err)
+			}
+		}
+
+		if provenance {
+			changed, err := writeProvenance(dir, digests)
+			if err != nil {
+				return fmt.Errorf("failed writing provenance for %s: %w", dir, err)
+			}
+			if sign {
+				if err := signFile(filepath.Join(dir, provenanceFile), signKey, changed); err != nil {
+					return fmt.Errorf("failed signing provenance for %s: %w", dir, err)
+				}
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if verify {
+		slog.Info("Verified checksums.", "dirs", len(dirs))
+	}
+	return nil
+}
+
+// versionDirs returns the directories in dir that contain a jsonschema
+// directory.
+func versionDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if info, err := os.Stat(filepath.Join(path, "jsonschema")); err == nil && info.IsDir() {
+			out = append(out, path)
+		}
+	}
+	return out, nil
+}
+
+// schemaFiles returns the slash-separated paths of all schema files and their
+// precompressed .gz siblings beneath dir relative to dir, sorted.
+func schemaFiles(dir string) ([]string, error) {
+	var out []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(strings.TrimSuffix(path, ".gz"), ".jsonschema.json") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		out = append(out, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(out)
+	return out, nil
+}
+
+// fileDigest is the SHA-256 digest of a file identified by its
+// slash-separated path relative to a version directory.
+type fileDigest struct {
+	Path   string
+	SHA256 string
+}
+
+// digestSchemaFiles computes the digests of all schema files beneath dir.
+func digestSchemaFiles(dir string) ([]fileDigest, error) {
+	files, err := schemaFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]fileDigest, 0, len(files))
+	for _, f := range files {
+		sum, err := sha256File(filepath.Join(dir, f))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, fileDigest{Path: f, SHA256: sum})
+	}
+	return out, nil
+}
+
+// writeChecksums writes the SHA256SUMS file for dir and reports whether its
+// content changed.
+func writeChecksums(dir string, digests []fileDigest) (bool, error) {
+	buf := new(bytes.Buffer)
+	for _, d := range digests {
+		fmt.Fprintf(buf, "%s  %s\n", d.SHA256, d.Path)
+	}
+
+	return fsutil.WriteFileIfChanged(filepath.Join(dir, checksumFile), buf.Bytes())
+}
+
+func verifyChecksums(dir string) error {
+	sums, err := readChecksums(filepath.Join(dir, checksumFile))
+	if err != nil {
+		return err
+	}
+
+	files, err := schemaFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, f := range files {
+		if _, found := sums[f]; !found {
+			errs = append(errs, fmt.Errorf("%s is not listed in %s", f, checksumFile))
+		}
+	}
+	for _, f := range slices.Sorted(maps.Keys(sums)) {
+		sum, err := sha256File(filepath.Join(dir, filepath.FromSlash(f)))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if sum != sums[f] {
+			errs = append(errs, fmt.Errorf("%s checksum mismatch", f))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readChecksums parses a sha256sum(1) formatted file into a map of path to
+// hex encoded digest.
+func readChecksums(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sums := map[string]string{}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for line := 1; s.Scan(); line++ {
+		sum, file, found := strings.Cut(s.Text(), "  ")
+		if !found {
+			return nil, fmt.Errorf("%s:%d: malformed line", path, line)
+		}
+		sums[file] = sum
+	}
+	return sums, s.Err()
+}
+
+func sha256File(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
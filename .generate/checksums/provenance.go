@@ -0,0 +1,130 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+)
+
+const (
+	provenanceFile = "provenance.intoto.json"
+
+	// buildType identifies the process described by the provenance predicate.
+	buildType = "https://github.com/andrewkroh/package-spec-schema/.generate@v1"
+
+	// localBuilderID is used when the build is not running in GitHub Actions.
+	localBuilderID = "https://github.com/andrewkroh/package-spec-schema/.generate/local"
+)
+
+// generationMetadata holds the fields of the metadata.json file written by
+// the clone tool that are needed to describe provenance.
+type generationMetadata struct {
+	GitURL           string    `json:"git_url"`
+	Commit           string    `json:"commit"`
+	Tag              string    `json:"tag"`
+	Generated        time.Time `json:"generated"`
+	GeneratorVersion string    `json:"generator_version"`
+	Dialect          string    `json:"dialect"`
+}
+
+// https://github.com/in-toto/attestation/blob/main/spec/v1/statement.md
+type statement struct {
+	Type          string               `json:"_type"`
+	Subject       []resourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     provenancePredicate  `json:"predicate"`
+}
+
+// https://slsa.dev/spec/v1.0/provenance
+type provenancePredicate struct {
+	BuildDefinition struct {
+		BuildType            string               `json:"buildType"`
+		ExternalParameters   map[string]any       `json:"externalParameters"`
+		InternalParameters   map[string]any       `json:"internalParameters,omitempty"`
+		ResolvedDependencies []resourceDescriptor `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+	RunDetails struct {
+		Builder struct {
+			ID      string            `json:"id"`
+			Version map[string]string `json:"version,omitempty"`
+		} `json:"builder"`
+		Metadata struct {
+			StartedOn time.Time `json:"startedOn,omitzero"`
+		} `json:"metadata"`
+	} `json:"runDetails"`
+}
+
+type resourceDescriptor struct {
+	Name   string            `json:"name,omitempty"`
+	URI    string            `json:"uri,omitempty"`
+	Digest map[string]string `json:"digest"`
+}
+
+// writeProvenance writes an in-toto statement with a SLSA provenance
+// predicate covering the schema digests into dir and reports whether its
+// content changed. Values that vary between runs, like run IDs, are omitted
+// so that regenerating identical output produces an identical statement.
+func writeProvenance(dir string, digests []fileDigest) (bool, error) {
+	b, err := os.ReadFile(filepath.Join(dir, "jsonschema", "metadata.json"))
+	if err != nil {
+		return false, fmt.Errorf("failed to read generation metadata: %w", err)
+	}
+	var meta generationMetadata
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return false, fmt.Errorf("failed to parse generation metadata: %w", err)
+	}
+
+	s := statement{
+		Type:          "https://in-toto.io/Statement/v1",
+		PredicateType: "https://slsa.dev/provenance/v1",
+	}
+	for _, d := range digests {
+		s.Subject = append(s.Subject, resourceDescriptor{
+			Name:   d.Path,
+			Digest: map[string]string{"sha256": d.SHA256},
+		})
+	}
+
+	def := &s.Predicate.BuildDefinition
+	def.BuildType = buildType
+	def.ExternalParameters = map[string]any{
+		"repository": meta.GitURL,
+		"ref":        meta.Tag,
+	}
+	def.InternalParameters = map[string]any{
+		"dialect": meta.Dialect,
+	}
+	def.ResolvedDependencies = []resourceDescriptor{{
+		URI:    "git+" + meta.GitURL,
+		Digest: map[string]string{"gitCommit": meta.Commit},
+	}}
+
+	run := &s.Predicate.RunDetails
+	run.Builder.ID = builderID()
+	run.Builder.Version = map[string]string{"generator": meta.GeneratorVersion}
+	run.Metadata.StartedOn = meta.Generated
+
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return false, err
+	}
+	return fsutil.WriteFileIfChanged(filepath.Join(dir, provenanceFile), append(out, '\n'))
+}
+
+// builderID identifies the workflow that ran the generator when running in
+// GitHub Actions.
+func builderID() string {
+	server, workflowRef := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_WORKFLOW_REF")
+	if server == "" || workflowRef == "" {
+		return localBuilderID
+	}
+	return server + "/" + workflowRef
+}
@@ -0,0 +1,64 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+// signatureSuffix is appended to the name of a signed file to form the name of
+// its Sigstore bundle.
+const signatureSuffix = ".sigstore.json"
+
+// signFile signs the file with cosign and writes a Sigstore bundle containing
+// the signature and certificate next to it. Keyless signing is used unless a
+// key reference is given. Because keyless signatures differ on every
+// invocation, files that already have a bundle are only re-signed when
+// force is true.
+func signFile(path, key string, force bool) error {
+	bundle := path + signatureSuffix
+	if !force {
+		if _, err := os.Stat(bundle); err == nil {
+			return nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	// https://docs.sigstore.dev/cosign/signing/signing_with_blobs/
+	args := []string{"sign-blob", "--yes", "--bundle", bundle}
+	if key != "" {
+		args = append(args, "--key", key)
+	}
+	args = append(args, path)
+
+	slog.Info("Signing file.", "path", path)
+	return cosignExec(args...)
+}
+
+func cosignExec(args ...string) error {
+	cmd := exec.Command("cosign", args...)
+	errBuf := new(bytes.Buffer)
+	cmd.Stderr = errBuf
+
+	if err := cmd.Run(); err != nil {
+		slog.Error("cosign failed.", "args", args, "stderr", strings.TrimSpace(errBuf.String()))
+		return fmt.Errorf("failed running cosign %s: %w", strings.Join(args, " "), err)
+	}
+	if errBuf.Len() > 0 {
+		slog.Log(context.Background(), logging.LevelTrace, "cosign output.", "args", args, "stderr", strings.TrimSpace(errBuf.String()))
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+)
+
+// versionConstraint selects release versions. It is a flag.Value holding
+// alternatives separated by "||", each a list of comparisons separated by
+// commas or spaces that must all hold, e.g. ">=3.0.0, <3.5.0 || =2.13.0".
+// Missing minor and patch numbers default to zero. The empty constraint
+// matches every version.
+type versionConstraint struct {
+	raw          string
+	alternatives [][]versionComparison
+}
+
+// versionComparison compares a version against a bound.
+type versionComparison struct {
+	op    string
+	bound *semver.Version
+}
+
+// comparisonOperators are ordered so that longer operators are matched first.
+var comparisonOperators = []string{">=", "<=", "!=", ">", "<", "="}
+
+func (c *versionConstraint) String() string {
+	return c.raw
+}
+
+func (c *versionConstraint) Set(s string) error {
+	if strings.TrimSpace(s) == "" {
+		c.raw, c.alternatives = "", nil
+		return nil
+	}
+	var alternatives [][]versionComparison
+	for alt := range strings.SplitSeq(s, "||") {
+		fields := strings.FieldsFunc(alt, func(r rune) bool { return r == ',' || r == ' ' })
+		if len(fields) == 0 {
+			return fmt.Errorf("empty alternative in version constraint %q", s)
+		}
+		var comparisons []versionComparison
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			op := "="
+			for _, candidate := range comparisonOperators {
+				if rest, found := strings.CutPrefix(field, candidate); found {
+					op, field = candidate, rest
+					break
+				}
+			}
+			// Allow a space between the operator and the version.
+			if field == "" && i+1 < len(fields) {
+				i++
+				field = fields[i]
+			}
+			bound, err := parseBound(field)
+			if err != nil {
+				return fmt.Errorf("invalid version constraint %q: %w", s, err)
+			}
+			comparisons = append(comparisons, versionComparison{op: op, bound: bound})
+		}
+		alternatives = append(alternatives, comparisons)
+	}
+	c.raw, c.alternatives = s, alternatives
+	return nil
+}
+
+// parseBound parses a version with an optional "v" prefix, completing
+// missing minor and patch numbers with zeros.
+func parseBound(s string) (*semver.Version, error) {
+	s = strings.TrimPrefix(s, "v")
+	if core, _, _ := strings.Cut(s, "-"); strings.Count(core, ".") < 2 && s != "" {
+		s = core + strings.Repeat(".0", 2-strings.Count(core, ".")) + strings.TrimPrefix(s, core)
+	}
+	return semver.NewVersion(s)
+}
+
+// match reports whether the version satisfies the constraint. Refs that
+// are not semantic versions only match the empty constraint.
+func (c *versionConstraint) match(v *semver.Version) bool {
+	if len(c.alternatives) == 0 {
+		return true
+	}
+	if v == nil {
+		return false
+	}
+	for _, comparisons := range c.alternatives {
+		ok := true
+		for _, cmp := range comparisons {
+			if !cmp.match(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c versionComparison) match(v *semver.Version) bool {
+	switch c.op {
+	case ">=":
+		return !v.LessThan(*c.bound)
+	case "<=":
+		return !c.bound.LessThan(*v)
+	case ">":
+		return c.bound.LessThan(*v)
+	case "<":
+		return v.LessThan(*c.bound)
+	case "!=":
+		return !v.Equal(*c.bound)
+	default:
+		return v.Equal(*c.bound)
+	}
+}
@@ -0,0 +1,87 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"github.com/andrewkroh/package-spec-schema/internal/jsondiff"
+	"github.com/andrewkroh/package-spec-schema/internal/layout"
+)
+
+// previewSchemas writes to w how writing the converted files and layout into
+// dir would change it: the files that would be added, removed, or changed,
+// with the changed JSON pointers of each. Paths are written relative to the
+// output directory, which dir is below as rel. It returns the number of
+// files that would change. The generation metadata is not compared because it
+// records the time of generation.
+func previewSchemas(w io.Writer, dir, rel string, files []schemaFile, layoutJSON []byte) (int, error) {
+	if layoutJSON != nil {
+		files = append(slices.Clip(files), schemaFile{Path: layout.FileName, Data: layoutJSON})
+	}
+	statuses, err := compareSchemas(dir, files)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for _, p := range slices.Sorted(maps.Keys(statuses)) {
+		d := jsondiff.FileDiff{Path: path.Join(rel, p)}
+		switch statuses[p] {
+		case fileUnchanged:
+			continue
+		case fileMissing:
+			d.Op = jsondiff.Added
+		case fileExtra:
+			d.Op = jsondiff.Removed
+		case fileChanged:
+			d.Op = jsondiff.Changed
+			i := slices.IndexFunc(files, func(f schemaFile) bool { return f.Path == p })
+			if d.Changes, err = diffFile(filepath.Join(dir, filepath.FromSlash(p)), files[i].Data); err != nil {
+				return 0, err
+			}
+			if len(d.Changes) == 0 {
+				// Only the formatting differs.
+				fmt.Fprintf(w, "*** %s (formatting)\n", d.Path)
+				n++
+				continue
+			}
+		}
+		d.Write(w, 0)
+		n++
+	}
+	return n, nil
+}
+
+// diffFile returns the changes that turn the JSON file into data.
+func diffFile(name string, data []byte) ([]jsondiff.Change, error) {
+	old, err := jsondiff.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	v, err := jsondiff.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode converted %s: %w", name, err)
+	}
+	return jsondiff.Diff(old, v), nil
+}
+
+// previewPrune writes to w the version directories in dir that pruning would
+// remove.
+func previewPrune(w io.Writer, dir, rel string, versions []string) error {
+	stale, err := staleVersionDirs(dir, versions)
+	if err != nil {
+		return err
+	}
+	for _, name := range stale {
+		fmt.Fprintf(w, "--- %s/ (removed)\n", path.Join(rel, name))
+	}
+	return nil
+}
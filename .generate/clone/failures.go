@@ -0,0 +1,85 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/andrewkroh/package-spec-schema/internal/summary"
+)
+
+// fileError is the failure to convert a single upstream file.
+type fileError struct {
+	Path string // Repository path of the file.
+	Err  error
+}
+
+func (e *fileError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *fileError) Unwrap() error {
+	return e.Err
+}
+
+// failure is a version, or a file of a version, that failed to generate.
+type failure struct {
+	Version string // $id path of the version.
+	Path    string // Repository path of the file, empty if not specific to one.
+	Err     error
+}
+
+// failureReport collects the failures of a run with -keep-going. It is
+// returned as the error of the run when it is not empty.
+type failureReport []failure
+
+// add records the failure of a version. Each error joined into err is
+// recorded as a failure of its own, with the path of its file if it is a
+// fileError.
+func (r *failureReport) add(version string, err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, err := range joined.Unwrap() {
+			r.add(version, err)
+		}
+		return
+	}
+
+	f := failure{Version: version, Err: err}
+	var fe *fileError
+	if errors.As(err, &fe) {
+		f.Path, f.Err = fe.Path, fe.Err
+	}
+	*r = append(*r, f)
+	summary.AddFailure(f.Version, f.Path, f.Err)
+	slog.Error("Failed to generate version, continuing.", "version", f.Version, "path", f.Path, "error", f.Err)
+}
+
+func (r failureReport) Error() string {
+	versions := map[string]bool{}
+	for _, f := range r {
+		versions[f.Version] = true
+	}
+	return fmt.Sprintf("%d versions failed to generate with %d failures", len(versions), len(r))
+}
+
+// write writes the failures as a table to w.
+func (r failureReport) write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tFILE\tERROR")
+	for _, f := range r {
+		file := f.Path
+		if file == "" {
+			file = "-"
+		}
+		// Keep multi-line errors, such as validation reports, in their row.
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Version, file, strings.ReplaceAll(f.Err.Error(), "\n", "; "))
+	}
+	return tw.Flush()
+}
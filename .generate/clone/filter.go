@@ -0,0 +1,184 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// globsFlag is a flag.Value accepting repeated glob patterns. A single value
+// may also contain a comma separated list, which allows setting multiple
+// patterns from an environment variable.
+type globsFlag []string
+
+func (f *globsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *globsFlag) Set(value string) error {
+	for pattern := range strings.SplitSeq(value, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		// Check the syntax of each segment now rather than on every match.
+		for segment := range strings.SplitSeq(pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid glob %q: %w", pattern, err)
+			}
+		}
+		*f = append(*f, pattern)
+	}
+	return nil
+}
+
+// matchGlob reports whether the slash-separated name matches the pattern.
+// A "**" segment matches any number of path segments, including none. Other
+// segments are matched with path.Match.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// selectedSchema reports whether the schema at the path relative to the
+// jsonschema directory matches an -only pattern, if any are given, and no
+// -exclude pattern.
+func selectedSchema(p string) bool {
+	for _, pattern := range excludeGlobs {
+		if matchGlob(pattern, p) {
+			return false
+		}
+	}
+	if len(onlyGlobs) == 0 {
+		return true
+	}
+	for _, pattern := range onlyGlobs {
+		if matchGlob(pattern, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterSchemas returns the files selected by -only and -exclude along with
+// the files they reference, directly or through other files, so that the
+// references of the result resolve. The order of files is kept.
+func filterSchemas(ver string, files []schemaFile) ([]schemaFile, error) {
+	if len(onlyGlobs) == 0 && len(excludeGlobs) == 0 {
+		return files, nil
+	}
+
+	paths := map[string]string{} // File path keyed by $id.
+	docs := map[string]any{}     // Decoded schema keyed by file path.
+	for _, f := range files {
+		var doc any
+		if err := json.Unmarshal(f.Data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", f.Path, err)
+		}
+		docs[f.Path] = doc
+		if obj, ok := doc.(map[string]any); ok {
+			if id, ok := obj["$id"].(string); ok {
+				paths[id] = f.Path
+			}
+		}
+	}
+
+	keep := map[string]bool{}
+	var queue []string
+	for _, f := range files {
+		if selectedSchema(f.Path) {
+			keep[f.Path] = true
+			queue = append(queue, f.Path)
+		}
+	}
+	if len(queue) == 0 {
+		return nil, errors.New("no schemas match -only and -exclude")
+	}
+
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		refs, err := referencedFiles(p, docs[p], paths)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			if !keep[ref] {
+				keep[ref] = true
+				queue = append(queue, ref)
+			}
+		}
+	}
+
+	out := make([]schemaFile, 0, len(keep))
+	for _, f := range files {
+		if keep[f.Path] {
+			out = append(out, f)
+		}
+	}
+	slog.Debug("Filtered schemas.", "version", ver, "schemas", len(out), "skipped", len(files)-len(out))
+	return out, nil
+}
+
+// referencedFiles returns the paths of the files in paths, keyed by $id,
+// that the schema doc of the file at p references. References are resolved
+// against the $id of doc.
+func referencedFiles(p string, doc any, paths map[string]string) ([]string, error) {
+	obj, _ := doc.(map[string]any)
+	id, _ := obj["$id"].(string)
+	base, err := url.Parse(id)
+	if err != nil || !base.IsAbs() {
+		return nil, fmt.Errorf("%s has an invalid $id %q", p, id)
+	}
+
+	var refs []string
+	var errs []error
+	walkSchemas(doc, "", func(schema map[string]any, ptr string) {
+		ref, ok := schema["$ref"].(string)
+		if !ok || strings.HasPrefix(ref, "#") {
+			return
+		}
+		u, err := url.Parse(ref)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s#%s/$ref: %w", p, ptr, err))
+			return
+		}
+		target := base.ResolveReference(u)
+		target.Fragment = ""
+		if file, found := paths[target.String()]; found {
+			refs = append(refs, file)
+		}
+	})
+	return refs, errors.Join(errs...)
+}
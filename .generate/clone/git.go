@@ -5,19 +5,27 @@
 package main
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"maps"
 	"net/url"
 	"os"
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/coreos/go-semver/semver"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"golang.org/x/net/http/httpproxy"
+
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
 )
 
 var slugSanitizer = strings.NewReplacer("/", "_", " ", "")
@@ -25,10 +33,47 @@ var slugSanitizer = strings.NewReplacer("/", "_", " ", "")
 // GitRepository wraps git repository operations.
 type GitRepository struct {
 	repo *git.Repository
+	url  string
+	opts GitOptions
+}
+
+// GitOptions configures network access to the remote repository.
+type GitOptions struct {
+	// Retry controls retries of clone and fetch operations.
+	Retry RetryPolicy
+
+	// ProxyURL is the proxy used for HTTP(S) remotes. Hosts matching the
+	// NO_PROXY environment variable bypass it. When empty, the HTTP_PROXY,
+	// HTTPS_PROXY, and NO_PROXY environment variables are used.
+	ProxyURL string
+}
+
+// proxyOptions returns the proxy configuration to use for repoURL.
+func (o GitOptions) proxyOptions(repoURL string) (transport.ProxyOptions, error) {
+	if o.ProxyURL == "" {
+		return transport.ProxyOptions{}, nil
+	}
+
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return transport.ProxyOptions{}, fmt.Errorf("failed to parse repository URL: %w", err)
+	}
+
+	cfg := &httpproxy.Config{
+		HTTPProxy:  o.ProxyURL,
+		HTTPSProxy: o.ProxyURL,
+		NoProxy:    cmp.Or(os.Getenv("NO_PROXY"), os.Getenv("no_proxy")),
+	}
+	proxy, err := cfg.ProxyFunc()(u)
+	if err != nil || proxy == nil {
+		return transport.ProxyOptions{}, err
+	}
+	return transport.ProxyOptions{URL: proxy.String()}, nil
 }
 
-// NewGitRepository opens or clones the remote repository.
-func NewGitRepository(githubURL, workDir string, fetch bool) (*GitRepository, error) {
+// NewGitRepository opens or clones the remote repository. When cloning, a
+// bare repository without a worktree is created if bare is true.
+func NewGitRepository(githubURL, workDir string, fetch, bare bool, opts GitOptions) (*GitRepository, error) {
 	repoURL, err := url.Parse(githubURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse repository URL: %w", err)
@@ -40,22 +85,35 @@ func NewGitRepository(githubURL, workDir string, fetch bool) (*GitRepository, er
 		slugSanitizer.Replace(strings.TrimSuffix(strings.TrimPrefix(repoURL.Path, "/"), ".git")),
 	)
 
+	proxy, err := opts.proxyOptions(githubURL)
+	if err != nil {
+		return nil, err
+	}
+
 	// Open or clone.
 	repo, err := git.PlainOpen(repoDir)
 	if errors.Is(err, git.ErrRepositoryNotExists) {
-		log.Printf("Cloning into %v.", repoDir)
-		if err := os.MkdirAll(repoDir, 0o700); err != nil {
-			return nil, fmt.Errorf("failed to create directory: %w", err)
-		}
-		repo, err = git.PlainClone(repoDir, false, &git.CloneOptions{
-			URL: githubURL,
+		slog.Info("Cloning repository.", "url", redactURL(githubURL), "dir", repoDir)
+		err = opts.Retry.Do("git clone", func() error {
+			if err := os.MkdirAll(repoDir, 0o700); err != nil {
+				return fmt.Errorf("failed to create directory: %w", err)
+			}
+			repo, err = git.PlainClone(repoDir, bare, &git.CloneOptions{
+				URL:          githubURL,
+				ProxyOptions: proxy,
+				Progress:     logging.Progress("git clone"),
+			})
+			return err
+		}, func() error {
+			// Remove the partial clone.
+			return os.RemoveAll(repoDir)
 		})
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to open/clone repository: %w", err)
 	}
 
-	gitRepo := &GitRepository{repo: repo}
+	gitRepo := &GitRepository{repo: repo, url: githubURL, opts: opts}
 
 	if fetch {
 		if err := gitRepo.Fetch(); err != nil {
@@ -66,14 +124,54 @@ func NewGitRepository(githubURL, workDir string, fetch bool) (*GitRepository, er
 	return gitRepo, nil
 }
 
+// NewInMemoryGitRepository clones the remote repository into memory as a bare
+// repository. Nothing is written to disk, which suits ephemeral environments
+// where a persistent work directory provides no benefit.
+func NewInMemoryGitRepository(githubURL string, opts GitOptions) (*GitRepository, error) {
+	proxy, err := opts.proxyOptions(githubURL)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Cloning repository into memory.", "url", redactURL(githubURL))
+	var repo *git.Repository
+	err = opts.Retry.Do("git clone", func() (err error) {
+		repo, err = git.Clone(memory.NewStorage(), nil, &git.CloneOptions{
+			URL:          githubURL,
+			ProxyOptions: proxy,
+			Progress:     logging.Progress("git clone"),
+		})
+		return err
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clone repository: %w", err)
+	}
+	return &GitRepository{repo: repo, url: githubURL, opts: opts}, nil
+}
+
 // Fetch retrieves the latest changes from the remote repository.
 func (g *GitRepository) Fetch() error {
-	log.Println("Fetching latest changes.")
-	err := g.repo.Fetch(&git.FetchOptions{})
-	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+	proxy, err := g.opts.proxyOptions(g.url)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	slog.Info("Fetching latest changes.", "url", redactURL(g.url))
+	err = g.opts.Retry.Do("git fetch", func() error {
+		err := g.repo.Fetch(&git.FetchOptions{
+			ProxyOptions: proxy,
+			Progress:     logging.Progress("git fetch"),
+		})
+		if errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return nil
+		}
+		return err
+	}, nil)
+	if err != nil {
 		return fmt.Errorf("failed in git fetch: %w", err)
 	}
-	log.Println("Fetch completed.")
+	slog.Info("Fetch completed.", "url", redactURL(g.url), "duration", time.Since(start))
 	return nil
 }
 
@@ -144,37 +242,52 @@ func (g *GitRepository) GetReleaseTags() ([]*plumbing.Reference, error) {
 	return out, nil
 }
 
-// Checkout checks out a specific git reference.
-func (g *GitRepository) Checkout(ref *plumbing.Reference) error {
-	wt, err := g.repo.Worktree()
-	if err != nil {
-		return fmt.Errorf("failed to get worktree: %w", err)
+// CommitHash returns the hash of the commit that ref points to. Annotated
+// tags are peeled to their target commit.
+func (g *GitRepository) CommitHash(ref *plumbing.Reference) (plumbing.Hash, error) {
+	tag, err := g.repo.TagObject(ref.Hash())
+	switch {
+	case err == nil:
+		commit, err := tag.Commit()
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("failed to get commit for tag %s: %w", ref.Name().Short(), err)
+		}
+		return commit.Hash, nil
+	case errors.Is(err, plumbing.ErrObjectNotFound):
+		return ref.Hash(), nil
+	default:
+		return plumbing.ZeroHash, fmt.Errorf("failed to get tag object for %s: %w", ref, err)
 	}
+}
 
-	log.Println("Cleaning repo.")
-	err = wt.Clean(&git.CleanOptions{
-		Dir: true,
-	})
+// Commit returns the commit that ref points to.
+func (g *GitRepository) Commit(ref *plumbing.Reference) (*object.Commit, error) {
+	hash, err := g.CommitHash(ref)
 	if err != nil {
-		return fmt.Errorf("clean failed: %w", err)
+		return nil, err
 	}
 
-	log.Printf("Checking out %v.", ref)
-	err = wt.Checkout(&git.CheckoutOptions{
-		Hash:  ref.Hash(),
-		Force: true,
-	})
+	commit, err := g.repo.CommitObject(hash)
 	if err != nil {
-		return fmt.Errorf("checkout failed for %s: %w", ref, err)
+		return nil, fmt.Errorf("failed to get commit %s: %w", hash, err)
 	}
-	log.Println("Checkout completed.")
-
-	return nil
+	return commit, nil
 }
 
-// Worktree returns the git worktree.
-func (g *GitRepository) Worktree() (*git.Worktree, error) {
-	return g.repo.Worktree()
+// CommitTree returns the tree of the commit that ref points to. Reading
+// files from the tree works for bare repositories and avoids checking out a
+// worktree.
+func (g *GitRepository) CommitTree(ref *plumbing.Reference) (*object.Tree, error) {
+	commit, err := g.Commit(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree for commit %s: %w", commit.Hash, err)
+	}
+	return tree, nil
 }
 
 // tagToSemver converts a git tag reference to a semantic version.
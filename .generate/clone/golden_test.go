@@ -0,0 +1,65 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/andrewkroh/package-spec-schema/internal/jsondiff"
+)
+
+const goldenDir = "../testdata/golden"
+
+// TestGolden converts the release of the golden output in testdata/golden and
+// compares the schemas with it. It uses the package-spec clone in the default
+// working directory and is skipped when there is none, e.g. before the first
+// run of the clone tool or `just update-golden`. The golden bundles are
+// compared by `just verify-golden`.
+func TestGolden(t *testing.T) {
+	const cloneDir = "../.package-spec-schema"
+	if _, err := os.Stat(filepath.Join(cloneDir, "git", "elastic_package-spec")); err != nil {
+		t.Skipf("no package-spec clone in %s: %v", cloneDir, err)
+	}
+
+	dirs, err := os.ReadDir(goldenDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 1 || !dirs[0].IsDir() {
+		t.Fatalf("%s must contain exactly one version directory", goldenDir)
+	}
+	version := dirs[0].Name()
+
+	defer func(w, o, ref string, r remotesFlag) {
+		workDir, outDir, gitRef, remotes = w, o, ref, r
+	}(workDir, outDir, gitRef, remotes)
+	workDir = cloneDir
+	outDir = t.TempDir()
+	gitRef = "v" + version
+	remotes = remotesFlag{{URL: defaultGitURL}}
+
+	if _, err := generateRemotes(false); err != nil {
+		t.Fatal(err)
+	}
+
+	rel := filepath.Join(version, "jsonschema")
+	diffs, err := jsondiff.CompareDirs(filepath.Join(goldenDir, rel), filepath.Join(outDir, rel), func(name string) bool {
+		return path.Base(name) != "metadata.json"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	for _, fd := range diffs {
+		fd.Write(&buf, 10)
+	}
+	if len(diffs) > 0 {
+		t.Errorf("%d files differ from the golden output, run `just update-golden` if the change is intended:\n%s", len(diffs), &buf)
+	}
+}
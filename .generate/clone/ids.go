@@ -0,0 +1,97 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/versionindex"
+)
+
+// checkUniqueIDs reports an error if two schema files in the output
+// directories of the remotes declare the same $id. Registries and resolvers
+// that look up schemas by $id otherwise silently pick one of them. The
+// checked files are the version manifest schema of each remote and the
+// schemas in the jsonschema directories of its indexed versions. Bundles are
+// not checked because with -preserve-id they embed the schemas they
+// reference, and neither are the alias directories, which are copies of
+// versions.
+func checkUniqueIDs() error {
+	files := map[string][]string{} // Files keyed by $id.
+	for _, r := range remotes {
+		dir := r.outDir(outDir)
+		index, err := versionindex.Read(dir)
+		if err != nil {
+			return fmt.Errorf("failed to read version index: %w", err)
+		}
+
+		paths := []string{filepath.Join(dir, "manifest.jsonschema.json")}
+		for _, e := range index.Versions {
+			err := filepath.WalkDir(filepath.Join(dir, e.Version), func(p string, d fs.DirEntry, err error) error {
+				switch {
+				case err != nil:
+					return err
+				case d.IsDir() && d.Name() == "bundles":
+					return fs.SkipDir
+				case d.IsDir() || !strings.HasSuffix(p, ".jsonschema.json"):
+					return nil
+				}
+				paths = append(paths, p)
+				return nil
+			})
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+
+		for _, p := range paths {
+			id, err := fileSchemaID(p)
+			if err != nil {
+				return err
+			}
+			if id != "" {
+				files[id] = append(files[id], p)
+			}
+		}
+	}
+
+	var errs []error
+	for _, id := range slices.Sorted(maps.Keys(files)) {
+		if len(files[id]) > 1 {
+			errs = append(errs, fmt.Errorf("$id %q is declared by %s", id, strings.Join(files[id], ", ")))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("found %d duplicate schema $ids in the output directory: %w", len(errs), errors.Join(errs...))
+	}
+	return nil
+}
+
+// fileSchemaID returns the top-level $id of the schema file, or an empty
+// string if the file does not exist.
+func fileSchemaID(name string) (string, error) {
+	b, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var doc struct {
+		ID string `json:"$id"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return "", fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return doc.ID, nil
+}
@@ -0,0 +1,272 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/andrewkroh/package-spec-schema/internal/layout"
+	"github.com/andrewkroh/package-spec-schema/internal/versionindex"
+)
+
+// Status of a schema file in the output directory compared with the schema
+// converted from upstream.
+const (
+	fileUnchanged = "unchanged"
+	fileChanged   = "changed"
+	fileMissing   = "missing" // Converted but not in the output directory.
+	fileExtra     = "extra"   // In the output directory but not converted.
+)
+
+// infoRemotes writes a report about a version of every remote: the upstream
+// tag and commit, the commit that the output directory was generated from,
+// and each spec file with the schema converted from it and whether the
+// schema in the output directory matches. Nothing is written to the output
+// directory.
+func infoRemotes(w io.Writer, version string) error {
+	for i, r := range remotes {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if err := infoRemote(w, r, version); err != nil {
+			if r.Name != "" {
+				return fmt.Errorf("failed to describe %q: %w", r.Name, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func infoRemote(w io.Writer, r remote, version string) error {
+	git, err := openRemote(r)
+	if err != nil {
+		return err
+	}
+	tags, err := git.GetReleaseTags()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(tags, func(ref *plumbing.Reference) bool { return refVersion(ref) == version })
+	if i < 0 {
+		return fmt.Errorf("no release tag found for version %s", version)
+	}
+	ref := tags[i]
+
+	commit, err := git.Commit(ref)
+	if err != nil {
+		return err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return fmt.Errorf("failed to get tree for commit %s: %w", commit.Hash, err)
+	}
+	specs, err := specTrees(tree)
+	if err != nil {
+		return err
+	}
+
+	dir := r.outDir(outDir)
+	recorded, err := recordedCommit(dir, version)
+	if err != nil {
+		return err
+	}
+	exists := versionExists(dir, version)
+
+	var source *schemaSource
+	if provenanceComment {
+		source = &schemaSource{GitURL: r.URL, Ref: ref.Name().Short(), Commit: commit.Hash.String()}
+	}
+
+	// Convert every spec before writing so that a failure leaves no partial
+	// report.
+	reports := make([]specReport, 0, len(specs))
+	for _, spec := range specs {
+		idPath := path.Join(r.idPath(version), spec.Dir)
+		files, _, err := convertSchemas(tree, spec, version, idPath, source)
+		if err != nil {
+			return err
+		}
+		layoutJSON, err := buildLayout(tree, spec)
+		if err != nil {
+			return err
+		}
+		if layoutJSON != nil {
+			files = append(files, schemaFile{Path: layout.FileName, Data: layoutJSON})
+		}
+		statuses, err := compareSchemas(filepath.Join(dir, version, spec.Dir, "jsonschema"), files)
+		if err != nil {
+			return err
+		}
+		specFiles, err := specFileNames(tree, spec)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, specReport{spec: spec, specFiles: specFiles, statuses: statuses})
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if r.Name != "" {
+		fmt.Fprintf(tw, "Remote:\t%s\n", r.Name)
+	}
+	fmt.Fprintf(tw, "Version:\t%s\n", version)
+	fmt.Fprintf(tw, "Tag:\t%s\n", ref.Name().Short())
+	fmt.Fprintf(tw, "Commit:\t%s\n", commit.Hash)
+	fmt.Fprintf(tw, "Date:\t%s\n", commit.Committer.When.UTC().Format(time.RFC3339))
+	switch {
+	case !exists:
+		fmt.Fprintf(tw, "Output:\t%s (not generated)\n", filepath.Join(dir, version))
+	case recorded == "":
+		fmt.Fprintf(tw, "Output:\t%s (commit not recorded)\n", filepath.Join(dir, version))
+	case recorded == commit.Hash.String():
+		fmt.Fprintf(tw, "Output:\t%s (generated from this commit)\n", filepath.Join(dir, version))
+	default:
+		fmt.Fprintf(tw, "Output:\t%s (generated from %s)\n", filepath.Join(dir, version), recorded)
+	}
+	if exists {
+		counts := map[string]int{}
+		for _, report := range reports {
+			for _, status := range report.statuses {
+				counts[status]++
+			}
+		}
+		if counts[fileChanged]+counts[fileMissing]+counts[fileExtra] == 0 {
+			fmt.Fprintln(tw, "Schemas:\tup to date")
+		} else {
+			fmt.Fprintf(tw, "Schemas:\t%d changed, %d missing, %d extra\n", counts[fileChanged], counts[fileMissing], counts[fileExtra])
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		fmt.Fprintf(w, "\nSpec %s (%d spec files):\n", report.spec.Path, len(report.specFiles))
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "SPEC FILE\tSCHEMA\tSTATUS")
+		for _, p := range slices.Sorted(maps.Keys(report.statuses)) {
+			// Combined manifests, the vocabulary, and the layout have no
+			// spec file of their own.
+			specFile := report.spec.Path + "/" + strings.Replace(p, ".jsonschema.json", ".spec.yml", 1)
+			if !slices.Contains(report.specFiles, specFile) {
+				specFile = "-"
+			}
+			status := report.statuses[p]
+			if !exists {
+				status = "new"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", specFile, path.Join(report.spec.Dir, p), status)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// specReport is the state of the schemas converted from one spec directory.
+type specReport struct {
+	spec      specTree
+	specFiles []string          // Repository paths of the spec files.
+	statuses  map[string]string // Status of each schema keyed by path.
+}
+
+// recordedCommit returns the upstream commit that version was generated from
+// according to its metadata.json, or else the version index of dir. It
+// returns the empty string if neither records the version.
+func recordedCommit(dir, version string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(dir, version, "jsonschema", metadataFile))
+	switch {
+	case err == nil:
+		var meta generationMetadata
+		if err := json.Unmarshal(b, &meta); err != nil {
+			return "", fmt.Errorf("failed to decode %s: %w", metadataFile, err)
+		}
+		return meta.Commit, nil
+	case !errors.Is(err, fs.ErrNotExist):
+		return "", err
+	}
+
+	index, err := versionindex.Read(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read version index: %w", err)
+	}
+	for _, e := range index.Versions {
+		if e.Version == version {
+			return e.Commit, nil
+		}
+	}
+	return "", nil
+}
+
+// compareSchemas returns the status of each converted file and of each
+// schema file in dir, keyed by path relative to dir. The files are compared
+// as written by writeSchemas. The generation metadata is not compared
+// because it records the time of generation.
+func compareSchemas(dir string, files []schemaFile) (map[string]string, error) {
+	statuses := map[string]string{}
+	for _, f := range files {
+		b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(f.Path)))
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			statuses[f.Path] = fileMissing
+		case err != nil:
+			return nil, err
+		case bytes.Equal(b, f.Data):
+			statuses[f.Path] = fileUnchanged
+		default:
+			statuses[f.Path] = fileChanged
+		}
+	}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == dir {
+			return fs.SkipAll
+		}
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".json") {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if _, found := statuses[rel]; !found && rel != metadataFile {
+			statuses[rel] = fileExtra
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// specFileNames returns the repository paths of the .spec.yml files of the
+// spec directory in tree.
+func specFileNames(tree *object.Tree, spec specTree) ([]string, error) {
+	var names []string
+	err := tree.Files().ForEach(func(f *object.File) error {
+		if strings.HasPrefix(f.Name, spec.Path+"/") && strings.HasSuffix(path.Base(f.Name), ".spec.yml") {
+			names = append(names, f.Name)
+		}
+		return nil
+	})
+	slices.Sort(names)
+	return names, err
+}
@@ -0,0 +1,228 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"gopkg.in/yaml.v3"
+
+	"github.com/andrewkroh/package-spec-schema/internal/layout"
+)
+
+// folderSpec is the content of a spec.yml file.
+type folderSpec struct {
+	Spec folderItemSpec `yaml:"spec"`
+}
+
+// folderItemSpec is an item of a folder spec. A folder spec itself is the
+// item of the folder that it describes.
+type folderItemSpec struct {
+	Description        string            `yaml:"description"`
+	Type               string            `yaml:"type"`
+	Name               string            `yaml:"name"`
+	Pattern            string            `yaml:"pattern"`
+	Required           bool              `yaml:"required"`
+	Ref                string            `yaml:"$ref"`
+	Visibility         string            `yaml:"visibility"`
+	DevelopmentFolder  bool              `yaml:"developmentFolder"`
+	ContentMediaType   string            `yaml:"contentMediaType"`
+	ForbiddenPatterns  []string          `yaml:"forbiddenPatterns"`
+	AdditionalContents bool              `yaml:"additionalContents"`
+	Contents           []*folderItemSpec `yaml:"contents"`
+
+	SizeLimit                string `yaml:"sizeLimit"`
+	TotalSizeLimit           string `yaml:"totalSizeLimit"`
+	TotalContentsLimit       int    `yaml:"totalContentsLimit"`
+	ConfigurationSizeLimit   string `yaml:"configurationSizeLimit"`
+	RelativePathSizeLimit    string `yaml:"relativePathSizeLimit"`
+	FieldsPerDataStreamLimit int    `yaml:"fieldsPerDataStreamLimit"`
+}
+
+// layoutRoots maps package types to the path of their root folder spec
+// relative to the spec directory. Releases before the introduction of
+// package types have a single folder spec at the root for integrations.
+var layoutRoots = []struct {
+	Type string
+	Path string
+}{
+	{"content", "content/spec.yml"},
+	{"input", "input/spec.yml"},
+	{"integration", "integration/spec.yml"},
+	{"integration", "spec.yml"},
+}
+
+// buildLayout extracts the package layout from the folder specs (spec.yml
+// files) in the spec directory of tree and returns it as the content of
+// layout.json. It returns nil if there are no folder specs.
+func buildLayout(tree *object.Tree, spec specTree) ([]byte, error) {
+	l := layout.Layout{Types: map[string]*layout.Item{}}
+	for _, root := range layoutRoots {
+		if _, found := l.Types[root.Type]; found {
+			continue
+		}
+		if _, err := tree.File(path.Join(spec.Path, root.Path)); err != nil {
+			continue
+		}
+		b := &layoutBuilder{tree: tree, specDir: spec.Path}
+		item, err := b.folder(root.Path, &folderItemSpec{Type: "folder"})
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s package layout: %w", root.Type, err)
+		}
+		l.Types[root.Type] = item
+	}
+	if len(l.Types) == 0 {
+		return nil, nil
+	}
+
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+type layoutBuilder struct {
+	tree    *object.Tree
+	specDir string
+	stack   []string // Folder specs being expanded, used to detect cycles.
+}
+
+// folder returns the layout of the folder described by the folder spec at
+// file, which is relative to the spec directory. The item describes the
+// folder within its parent.
+func (b *layoutBuilder) folder(file string, item *folderItemSpec) (*layout.Item, error) {
+	if slices.Contains(b.stack, file) {
+		return nil, fmt.Errorf("cyclic folder spec reference to %s", file)
+	}
+	b.stack = append(b.stack, file)
+	defer func() { b.stack = b.stack[:len(b.stack)-1] }()
+
+	f, err := b.tree.File(path.Join(b.specDir, file))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	content, err := f.Contents()
+	if err != nil {
+		return nil, err
+	}
+	var fs folderSpec
+	if err := yaml.Unmarshal([]byte(content), &fs); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", file, err)
+	}
+
+	// The folder spec describes the contents of the folder while the
+	// referencing item names it.
+	merged := *item
+	merged.Ref = ""
+	merged.Description = cmp.Or(item.Description, fs.Spec.Description)
+	merged.AdditionalContents = fs.Spec.AdditionalContents
+	merged.Contents = fs.Spec.Contents
+	merged.DevelopmentFolder = item.DevelopmentFolder || fs.Spec.DevelopmentFolder
+	merged.SizeLimit = cmp.Or(item.SizeLimit, fs.Spec.SizeLimit)
+	merged.TotalSizeLimit = cmp.Or(item.TotalSizeLimit, fs.Spec.TotalSizeLimit)
+	merged.TotalContentsLimit = cmp.Or(item.TotalContentsLimit, fs.Spec.TotalContentsLimit)
+	merged.ConfigurationSizeLimit = cmp.Or(item.ConfigurationSizeLimit, fs.Spec.ConfigurationSizeLimit)
+	merged.RelativePathSizeLimit = cmp.Or(item.RelativePathSizeLimit, fs.Spec.RelativePathSizeLimit)
+	merged.FieldsPerDataStreamLimit = cmp.Or(item.FieldsPerDataStreamLimit, fs.Spec.FieldsPerDataStreamLimit)
+	return b.item(path.Dir(file), &merged)
+}
+
+// item converts an item of the folder spec in dir.
+func (b *layoutBuilder) item(dir string, spec *folderItemSpec) (*layout.Item, error) {
+	if spec.Type == "folder" && spec.Ref != "" {
+		return b.folder(path.Join(dir, spec.Ref), spec)
+	}
+
+	limits, err := spec.limits()
+	if err != nil {
+		return nil, err
+	}
+	item := &layout.Item{
+		Name:               spec.Name,
+		Pattern:            spec.Pattern,
+		Type:               spec.Type,
+		Description:        strings.TrimSpace(spec.Description),
+		Required:           spec.Required,
+		Visibility:         spec.Visibility,
+		Development:        spec.DevelopmentFolder,
+		ContentMediaType:   spec.ContentMediaType,
+		ForbiddenPatterns:  spec.ForbiddenPatterns,
+		AdditionalContents: spec.AdditionalContents,
+		Limits:             limits,
+	}
+	if spec.Type == "file" && strings.HasSuffix(spec.Ref, ".spec.yml") {
+		item.Schema = strings.TrimSuffix(path.Join(dir, spec.Ref), ".spec.yml") + ".jsonschema.json"
+	}
+	for _, c := range spec.Contents {
+		child, err := b.item(dir, c)
+		if err != nil {
+			return nil, err
+		}
+		item.Contents = append(item.Contents, child)
+	}
+	return item, nil
+}
+
+// limits returns the limits of the item or nil if it has none.
+func (s *folderItemSpec) limits() (*layout.Limits, error) {
+	var l layout.Limits
+	for _, size := range []struct {
+		value string
+		dst   *int64
+	}{
+		{s.SizeLimit, &l.Size},
+		{s.TotalSizeLimit, &l.TotalSize},
+		{s.ConfigurationSizeLimit, &l.ConfigurationSize},
+		{s.RelativePathSizeLimit, &l.RelativePathSize},
+	} {
+		if size.value == "" {
+			continue
+		}
+		n, err := parseFileSize(size.value)
+		if err != nil {
+			return nil, err
+		}
+		*size.dst = n
+	}
+	l.TotalContents = s.TotalContentsLimit
+	l.FieldsPerDataStream = s.FieldsPerDataStreamLimit
+	if l == (layout.Limits{}) {
+		return nil, nil
+	}
+	return &l, nil
+}
+
+var fileSizeRegex = regexp.MustCompile(`^(\d+)\s*(B|KB|MB|GB)?$`)
+
+// parseFileSize parses a size of a folder spec, like 5MB, into bytes. Units
+// are powers of 1024 as in package-spec.
+func parseFileSize(s string) (int64, error) {
+	m := fileSizeRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+	switch m[2] {
+	case "KB":
+		n <<= 10
+	case "MB":
+		n <<= 20
+	case "GB":
+		n <<= 30
+	}
+	return n, nil
+}
@@ -0,0 +1,50 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// listRemotes writes a table of the release tags of every remote that match
+// -versions, with the commit each tag points to and the commit date, without
+// generating anything. The REMOTE column is only written when a remote is
+// named.
+func listRemotes(w io.Writer) error {
+	named := false
+	for _, r := range remotes {
+		named = named || r.Name != ""
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if named {
+		fmt.Fprint(tw, "REMOTE\t")
+	}
+	fmt.Fprintln(tw, "VERSION\tTAG\tCOMMIT\tDATE")
+	for _, r := range remotes {
+		git, err := openRemote(r)
+		if err != nil {
+			return err
+		}
+		refs, err := selectRefs(git)
+		if err != nil {
+			return err
+		}
+		for _, ref := range refs {
+			commit, err := git.Commit(ref)
+			if err != nil {
+				return err
+			}
+			if named {
+				fmt.Fprintf(tw, "%s\t", r.Name)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", refVersion(ref), ref.Name().Short(), commit.Hash, commit.Committer.When.UTC().Format(time.RFC3339))
+		}
+	}
+	return tw.Flush()
+}
@@ -11,165 +11,754 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"maps"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/go-git/go-billy/v5"
-	"github.com/go-git/go-billy/v5/util"
+	"github.com/coreos/go-semver/semver"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/google/jsonschema-go/jsonschema"
 	"gopkg.in/yaml.v3"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/layout"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+	"github.com/andrewkroh/package-spec-schema/internal/profiling"
+	"github.com/andrewkroh/package-spec-schema/internal/summary"
+	"github.com/andrewkroh/package-spec-schema/internal/versionindex"
 )
 
 var (
-	workDir  string // Directory where package-spec is stored.
-	outDir   string // Directory where versioned directories containing schemas are written.
-	dialect  string // JSON Schema dialect that the package-specs implement. Applied as $schema to all schemas.
-	baseURI  string // Base URI to apply to schema $ids.
-	gitURL   string // Git clone URL.
-	gitRef   string // Git reference from which schemas will be generated.
-	gitFetch bool   // Perform a git fetch when clone directory already exists.
+	workDir   string      // Directory where package-spec is stored.
+	outDir    string      // Directory where versioned directories containing schemas are written.
+	dialect   string      // JSON Schema dialect that the package-specs implement. Applied as $schema to all schemas.
+	baseURI   string      // Base URI to apply to schema $ids.
+	remotes   remotesFlag // Git clone URLs and their output namespaces.
+	gitRef    string      // Git reference from which schemas will be generated.
+	list      bool        // Print the release tags instead of generating schemas.
+	info      string      // Version to describe instead of generating schemas.
+	gitFetch  bool        // Perform a git fetch when clone directory already exists.
+	prune     bool        // Remove version directories that do not correspond to a release tag.
+	ndjson    bool        // Stream schemas to stdout as NDJSON instead of writing files.
+	dryRun    bool        // Print the changes to the output directory instead of writing files.
+	keepGoing bool        // Skip versions that fail and report the failures at the end.
+	inMemory  bool        // Clone into memory instead of the working directory.
+	bare      bool        // Create the clone in the working directory as a bare repository.
+	gitOpts   GitOptions  // Network options for git operations.
+	config    string      // YAML configuration file containing flag values.
+
+	versionRange versionConstraint // Release versions to generate or list.
+
+	metaschemaMode     string // How to handle schemas that violate their dialect metaschema.
+	specMetaschemaMode string // How to handle spec files that violate the spec file schema.
+	refCheckMode       string // How to handle $refs that do not resolve to a generated schema.
+	regexCompatMode    string // How to handle patterns that are not compatible with ECMA-262.
+	rewritePatterns    bool   // Rewrite patterns that have an equivalent ECMA-262 form.
+	strictYAML         bool   // Reject ambiguous YAML in spec files.
+	provenanceComment  bool   // Record the upstream source of each schema in $comment.
+	autoTitles         bool   // Add derived titles to schemas without one.
+	yamlComments       bool   // Fold YAML comments of spec files into the schemas.
+	normalizeYAML      bool   // Convert YAML scalars to the JSON type the author intended.
+	extensionKeywords  bool   // Rename custom keywords to x- extension keywords.
+	absoluteRefURLs    bool   // Rewrite $refs to other files as absolute URLs.
+
+	onlyGlobs    globsFlag // Generate only the schemas matching these globs.
+	excludeGlobs globsFlag // Skip the schemas matching these globs.
+
+	watch    bool          // Poll for new release tags instead of exiting.
+	interval time.Duration // Delay between polls in watch mode.
+	postHook string        // Command run after new versions are generated in watch mode.
 )
 
+// configAliases maps descriptive configuration file keys and environment
+// variable names to the short flag names.
+var configAliases = map[string]string{
+	"work-dir":   "w",
+	"output-dir": "o",
+	"dialect":    "d",
+}
+
 func init() {
+	flag.StringVar(&config, "config", "", "YAML configuration file whose keys are flag names; flags given on the command line take precedence")
 	flag.StringVar(&workDir, "w", ".package-spec-schema", "working directory")
 	flag.StringVar(&outDir, "o", ".", "output directory")
 	flag.StringVar(&dialect, "d", "https://json-schema.org/draft/2020-12/schema", "json schema dialect")
 	flag.StringVar(&baseURI, "base-uri", "https://schemas.elastic.dev/package-spec", "base URI to apply to schema $ids")
-	flag.StringVar(&gitURL, "git-url", "https://github.com/elastic/package-spec.git", "git clone URL")
+	flag.Var(&remotes, "git-url", "git clone URL as [name=]url, may be repeated; named URLs are written beneath a directory of the same name (default "+defaultGitURL+")")
 	flag.StringVar(&gitRef, "git-ref", "", "git ref of package-spec, defaults to all version tags")
+	flag.Var(&versionRange, "versions", "constraint on the release versions to generate or list, e.g. '>=3.0.0, <3.5.0 || =2.13.0' (default all)")
+	flag.BoolVar(&list, "list", false, "print the release tags matching -versions with their commit and date instead of generating schemas")
+	flag.StringVar(&info, "info", "", "print the upstream commit and spec files of a version and whether its schemas in the output directory are up to date instead of generating schemas")
 	flag.BoolVar(&gitFetch, "git-fetch", false, "git fetch new changes from package-spec")
+	flag.IntVar(&gitOpts.Retry.Attempts, "git-retries", 4, "maximum number of attempts for git clone and fetch")
+	flag.DurationVar(&gitOpts.Retry.Backoff, "git-retry-backoff", 2*time.Second, "initial delay between git clone and fetch attempts, doubled after each failure")
+	flag.DurationVar(&gitOpts.Retry.MaxBackoff, "git-retry-max-backoff", time.Minute, "maximum delay between git clone and fetch attempts")
+	flag.StringVar(&gitOpts.ProxyURL, "proxy", "", "proxy URL for HTTP(S) git remotes, defaults to the HTTPS_PROXY and NO_PROXY environment variables")
+	flag.BoolVar(&bare, "bare", false, "create a bare clone without a worktree in the working directory")
+	flag.BoolVar(&inMemory, "in-memory", false, "clone package-spec into memory instead of the working directory")
+	flag.BoolVar(&ndjson, "ndjson", false, "stream schemas to stdout as NDJSON records instead of writing files")
+	flag.BoolVar(&dryRun, "dry-run", false, "convert in memory and print the files that would be added, removed, or changed in the output directory with their changed JSON pointers instead of writing files")
+	flag.BoolVar(&keepGoing, "keep-going", false, "skip versions that fail to convert and continue, then report every failed version and file at the end and exit with an error")
+	flag.StringVar(&metaschemaMode, "metaschema", metaschemaWarn, "action when a generated schema violates its dialect metaschema: fail, warn, or off")
+	flag.StringVar(&specMetaschemaMode, "spec-metaschema", metaschemaOff, "action when an upstream .spec.yml file violates the spec file schema: fail, warn, or off")
+	flag.StringVar(&refCheckMode, "ref-check", metaschemaFail, "action when a $ref does not resolve to a generated schema or to a location within it: fail, warn, or off")
+	flag.StringVar(&regexCompatMode, "regex-compat", metaschemaOff, "action when a pattern fails to compile or behaves differently under ECMA-262: fail, warn, or off")
+	flag.BoolVar(&rewritePatterns, "rewrite-patterns", false, "rewrite patterns that have an equivalent ECMA-262 form when -regex-compat is enabled")
+	flag.BoolVar(&normalizeYAML, "normalize-scalars", false, "convert unquoted YAML scalars of spec files to the JSON type the author intended, such as keeping 1.10 in the enum of a string schema as a string")
+	flag.BoolVar(&yamlComments, "yaml-comments", false, "fold the leading YAML comments of spec files into the description or $comment of the schemas")
+	flag.BoolVar(&extensionKeywords, "extension-keywords", false, "rename the non-standard keywords of the spec files to x- prefixed extension keywords and write a vocabulary.jsonschema.json describing them")
+	flag.BoolVar(&absoluteRefURLs, "absolute-refs", false, "rewrite $refs that point to another schema file into absolute URLs derived from -base-uri and the version")
+	flag.Var(&onlyGlobs, "only", "generate only the schemas whose path relative to the jsonschema directory matches the glob, may be repeated; ** matches any number of directories")
+	flag.Var(&excludeGlobs, "exclude", "skip the schemas whose path relative to the jsonschema directory matches the glob, may be repeated; ** matches any number of directories")
+	flag.BoolVar(&autoTitles, "titles", false, "add titles derived from the file path and property names to schemas without one")
+	flag.BoolVar(&provenanceComment, "provenance-comment", false, "record the upstream file, ref, and commit of each schema in its $comment")
+	flag.BoolVar(&strictYAML, "strict-yaml", false, "reject .spec.yml files with duplicate mapping keys, non-string keys, or unresolvable aliases")
+	flag.BoolVar(&prune, "prune", false, "remove version directories from the output directory that do not match a release tag")
+	flag.BoolVar(&watch, "watch", false, "keep running, periodically fetching and generating release tags that are not present in the output directory")
+	flag.DurationVar(&interval, "interval", time.Hour, "delay between fetches in watch mode")
+	flag.StringVar(&postHook, "post-hook", "", "command to run in watch mode after new versions are generated, the paths of their version directories are appended as arguments")
+	logging.AddFlags(flag.CommandLine)
+	summary.AddFlags(flag.CommandLine)
+	profiling.AddFlags(flag.CommandLine)
 }
 
+const defaultGitURL = "https://github.com/elastic/package-spec.git"
+
 func main() {
 	flag.Parse()
 
-	if err := run(); err != nil {
-		log.Fatal(err)
+	if err := flagconfig.LoadEnv(flag.CommandLine, configAliases); err != nil {
+		logging.Fatal(err)
+	}
+	if config != "" {
+		if err := flagconfig.LoadFile(flag.CommandLine, config, configAliases); err != nil {
+			logging.Fatal(err)
+		}
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+	summary.Start("clone")
+	stopProfile, err := profiling.Start()
+	if err != nil {
+		logging.Fatal(err)
+	}
+
+	err = run()
+	if profErr := stopProfile(); profErr != nil {
+		err = errors.Join(err, profErr)
+	}
+	if sumErr := summary.Write(err); sumErr != nil {
+		err = errors.Join(err, sumErr)
+	}
+	if err != nil {
+		logging.Fatal(err)
 	}
 }
 
 func run() error {
-	git, err := NewGitRepository(gitURL, workDir, gitFetch)
+	if prune && gitRef != "" {
+		return errors.New("-prune cannot be used with -git-ref")
+	}
+	if prune && ndjson {
+		return errors.New("-prune cannot be used with -ndjson")
+	}
+	if versionRange.String() != "" {
+		switch {
+		case gitRef != "":
+			return errors.New("-versions cannot be used with -git-ref")
+		case prune:
+			return errors.New("-prune cannot be used with -versions")
+		}
+	}
+	if list && info != "" {
+		return errors.New("-list cannot be used with -info")
+	}
+	if list || info != "" {
+		switch {
+		case gitRef != "":
+			return errors.New("-list and -info cannot be used with -git-ref")
+		case ndjson, prune, watch, dryRun:
+			return errors.New("-list and -info cannot be used with -ndjson, -prune, -watch, or -dry-run")
+		}
+	}
+	if dryRun && (ndjson || watch) {
+		return errors.New("-dry-run cannot be used with -ndjson or -watch")
+	}
+	switch metaschemaMode {
+	case metaschemaFail, metaschemaWarn, metaschemaOff:
+	default:
+		return fmt.Errorf("invalid -metaschema value %q, must be fail, warn, or off", metaschemaMode)
+	}
+	switch specMetaschemaMode {
+	case metaschemaFail, metaschemaWarn, metaschemaOff:
+	default:
+		return fmt.Errorf("invalid -spec-metaschema value %q, must be fail, warn, or off", specMetaschemaMode)
+	}
+	switch refCheckMode {
+	case metaschemaFail, metaschemaWarn, metaschemaOff:
+	default:
+		return fmt.Errorf("invalid -ref-check value %q, must be fail, warn, or off", refCheckMode)
+	}
+	switch regexCompatMode {
+	case metaschemaFail, metaschemaWarn, metaschemaOff:
+	default:
+		return fmt.Errorf("invalid -regex-compat value %q, must be fail, warn, or off", regexCompatMode)
+	}
+	if watch {
+		switch {
+		case gitRef != "":
+			return errors.New("-watch cannot be used with -git-ref")
+		case ndjson:
+			return errors.New("-watch cannot be used with -ndjson")
+		case interval <= 0:
+			return errors.New("-interval must be positive")
+		}
+	} else if postHook != "" {
+		return errors.New("-post-hook requires -watch")
+	}
+
+	if len(remotes) == 0 {
+		remotes = remotesFlag{{URL: defaultGitURL}}
+	}
+
+	if list {
+		return listRemotes(os.Stdout)
+	}
+	if info != "" {
+		return infoRemotes(os.Stdout, info)
+	}
+	if watch {
+		return watchRemotes()
+	}
+
+	_, err := generateRemotes(false)
+	var failures failureReport
+	if errors.As(err, &failures) {
+		if writeErr := failures.write(os.Stderr); writeErr != nil {
+			return errors.Join(err, writeErr)
+		}
+	}
+	return err
+}
+
+// generateRemotes generates the schemas of every remote and returns the
+// generated version directories relative to the output directory. If onlyNew
+// is true then versions whose directory already exists are skipped. The
+// output directories are then checked for schemas with duplicate $ids. With
+// -keep-going a failureReport listing the failed versions and files is
+// returned along with the versions that were generated.
+func generateRemotes(onlyNew bool) ([]string, error) {
+	var generated []string
+	var failures failureReport
+	for _, r := range remotes {
+		versions, err := generateRemote(r, onlyNew, &failures)
+		if err != nil {
+			if r.Name != "" {
+				return nil, fmt.Errorf("failed generating %q: %w", r.Name, err)
+			}
+			return nil, err
+		}
+		generated = append(generated, versions...)
+	}
+	if !ndjson && !dryRun {
+		endCheck := summary.StartPhase("check")
+		err := checkUniqueIDs()
+		endCheck()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(failures) > 0 {
+		return generated, failures
+	}
+	return generated, nil
+}
+
+// generateRemote generates the schemas for every selected ref of a remote and
+// returns the generated version directories relative to the output directory.
+// If onlyNew is true then versions whose directory already exists are
+// skipped. With -keep-going the versions that fail are added to failures and
+// skipped.
+func generateRemote(r remote, onlyNew bool, failures *failureReport) ([]string, error) {
+	git, err := openRemote(r)
+	if err != nil {
+		return nil, err
+	}
+	gitRefs, err := selectRefs(git)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := r.outDir(outDir)
+	index, err := versionindex.Read(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read version index: %w", err)
+	}
+
+	var generated []string
+	for _, ref := range gitRefs {
+		ver := refVersion(ref)
+		if onlyNew && versionExists(dir, ver) {
+			continue
+		}
+		entries := slices.Clone(index.Versions)
+		if err := generateVersion(git, r, ref, dir, index); err != nil {
+			if !keepGoing {
+				return nil, err
+			}
+			// Keep the index entry of the version as it was.
+			index.Versions = entries
+			failures.add(r.idPath(ver), err)
+			continue
+		}
+		generated = append(generated, r.idPath(ver))
+	}
+	if ndjson {
+		return generated, nil
+	}
+
+	if prune {
+		versions := make([]string, 0, len(gitRefs))
+		for _, ref := range gitRefs {
+			versions = append(versions, refVersion(ref))
+		}
+		if dryRun {
+			return generated, previewPrune(os.Stdout, dir, r.Name, versions)
+		}
+		endPrune := summary.StartPhase("prune")
+		err := pruneVersionDirs(dir, versions)
+		endPrune()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if dryRun {
+		return generated, nil
+	}
+	if err := index.Write(dir); err != nil {
+		return nil, fmt.Errorf("failed to write version index: %w", err)
+	}
+	if !selectedSchema("manifest.jsonschema.json") {
+		// The versions lack the manifest schemas that the version manifest
+		// schema dispatches to.
+		return generated, nil
+	}
+	b, err := versionManifestSchema(dir, r.Name, index.Versions)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fsutil.WriteSchemaIfChanged(filepath.Join(dir, "manifest.jsonschema.json"), append(b, '\n')); err != nil {
+		return nil, err
+	}
+	return generated, nil
+}
+
+// convertedSpec holds the validated schemas of a spec directory.
+type convertedSpec struct {
+	spec    specTree
+	idPath  string
+	files   []schemaFile
+	renamed map[string]string
+}
+
+// generateVersion converts the specs of ref, validates the schemas, and
+// writes them into the version directory in dir, or streams or previews them.
+// The index entry of the version is updated with the commit of ref.
+func generateVersion(git *GitRepository, r remote, ref *plumbing.Reference, dir string, index *versionindex.Index) error {
+	ver := refVersion(ref)
+	tree, err := git.CommitTree(ref)
+	if err != nil {
+		return err
+	}
+	specs, err := specTrees(tree)
 	if err != nil {
 		return err
 	}
 
-	// Get release tags.
-	var gitRefs []*plumbing.Reference
-	if gitRef != "" {
-		hash, err := git.ResolveReference(gitRef)
+	commit, err := git.CommitHash(ref)
+	if err != nil {
+		return err
+	}
+	var source *schemaSource
+	if provenanceComment {
+		source = &schemaSource{GitURL: r.URL, Ref: ref.Name().Short(), Commit: commit.String()}
+	}
+
+	var meta generationMetadata
+	if !ndjson {
+		entry := index.Update(ver, commit.String(), time.Now())
+		meta = newGenerationMetadata(r.URL, ref, entry.Commit, entry.Generated)
+	}
+
+	// Convert every spec before writing any so that a failed version is not
+	// written in part. With -keep-going the failures of every spec are
+	// reported.
+	converted := make([]convertedSpec, 0, len(specs))
+	var errs []error
+	for _, spec := range specs {
+		idPath := path.Join(r.idPath(ver), spec.Dir)
+		start := time.Now()
+		endConvert := summary.StartPhase("convert")
+		files, renamed, err := convertSchemas(tree, spec, ver, idPath, source)
+		endConvert()
+		if err == nil {
+			endValidate := summary.StartPhase("validate")
+			err = validateSchemas(idPath, files)
+			if err == nil {
+				err = checkRefs(idPath, files)
+			}
+			endValidate()
+		}
 		if err != nil {
-			return err
+			if !keepGoing {
+				return err
+			}
+			errs = append(errs, err)
+			continue
 		}
-		gitRefs = append(gitRefs, plumbing.NewReferenceFromStrings(gitRef, hash.String()))
-	} else {
-		gitRefs, err = git.GetReleaseTags()
+		converted = append(converted, convertedSpec{spec: spec, idPath: idPath, files: files, renamed: renamed})
+		slog.Info("Converted schemas.", "version", ver, "ref", ref.Name().String(), "spec", spec.Path, "schemas", len(files), "duration", time.Since(start))
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	for _, c := range converted {
+		spec, idPath, files, renamed := c.spec, c.idPath, c.files, c.renamed
+		summary.AddVersion(idPath)
+
+		if ndjson {
+			if err := streamSchemas(os.Stdout, r.Name, path.Join(ver, spec.Dir), files); err != nil {
+				return err
+			}
+			continue
+		}
+
+		layoutJSON, err := buildLayout(tree, spec)
 		if err != nil {
 			return err
 		}
-	}
 
-	for _, ref := range gitRefs {
-		if err := writeSchemas(git, ref); err != nil {
+		if dryRun {
+			specDir := filepath.Join(dir, ver, spec.Dir, "jsonschema")
+			n, err := previewSchemas(os.Stdout, specDir, path.Join(idPath, "jsonschema"), files, layoutJSON)
+			if err != nil {
+				return err
+			}
+			slog.Info("Previewed schemas.", "version", ver, "spec", spec.Path, "changed_files", n)
+			continue
+		}
+
+		endWrite := summary.StartPhase("write")
+		specMeta := meta
+		specMeta.Keywords = renamed
+		err = writeSchemas(filepath.Join(dir, ver, spec.Dir, "jsonschema"), files, layoutJSON, specMeta)
+		endWrite()
+		if err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func writeSchemas(git *GitRepository, ref *plumbing.Reference) error {
-	ver := ref.Name().String()
-	if v := tagToSemver(ref); v != nil {
-		ver = v.String()
+// openRemote opens the git repository of the remote, cloning it into the
+// working directory or into memory, and fetching it if requested.
+func openRemote(r remote) (*GitRepository, error) {
+	endGit := summary.StartPhase("git")
+	defer endGit()
+	if inMemory {
+		return NewInMemoryGitRepository(r.URL, gitOpts)
 	}
-	dir := filepath.Join(outDir, ver, "jsonschema")
+	return NewGitRepository(r.URL, workDir, gitFetch || watch, bare, gitOpts)
+}
 
-	if err := git.Checkout(ref); err != nil {
-		return err
+// selectRefs returns the refs of the repository to generate, which are the
+// -git-ref if set, or else the release tags matching -versions.
+func selectRefs(git *GitRepository) ([]*plumbing.Reference, error) {
+	if gitRef != "" {
+		hash, err := git.ResolveReference(gitRef)
+		if err != nil {
+			return nil, err
+		}
+		return []*plumbing.Reference{plumbing.NewReferenceFromStrings(gitRef, hash.String())}, nil
 	}
 
-	wt, err := git.Worktree()
+	tags, err := git.GetReleaseTags()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return slices.DeleteFunc(tags, func(ref *plumbing.Reference) bool {
+		return !versionRange.match(tagToSemver(ref))
+	}), nil
+}
+
+// refVersion returns the name of the output directory for a git reference.
+func refVersion(ref *plumbing.Reference) string {
+	if v := tagToSemver(ref); v != nil {
+		return v.String()
+	}
+	return ref.Name().String()
+}
 
-	repoPath, err := getSpecPath(wt.Filesystem)
+// pruneVersionDirs removes directories in dir that are named like a semantic
+// version but are not in the list of versions. Other directories are ignored.
+func pruneVersionDirs(dir string, versions []string) error {
+	stale, err := staleVersionDirs(dir, versions)
 	if err != nil {
 		return err
 	}
 
-	if err := os.RemoveAll(dir); err != nil {
-		return err
+	for _, name := range stale {
+		slog.Info("Pruning stale version directory.", "version", name, "dir", dir)
+		if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// staleVersionDirs returns the directories in dir that are named like a
+// semantic version but are not in versions.
+func staleVersionDirs(dir string, versions []string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
 	}
 
-	var written []string
-	err = util.Walk(wt.Filesystem, repoPath, func(path string, info os.FileInfo, walkErr error) (err error) {
-		if walkErr != nil {
-			return walkErr
+	var stale []string
+	for _, e := range entries {
+		if !e.IsDir() || slices.Contains(versions, e.Name()) {
+			continue
+		}
+		if _, err := semver.NewVersion(e.Name()); err != nil {
+			continue
 		}
+		stale = append(stale, e.Name())
+	}
+	return stale, nil
+}
+
+// schemaFile is a converted schema and its slash-separated path relative to
+// the version's jsonschema directory.
+type schemaFile struct {
+	Path string
+	Data []byte
+}
+
+// convertSchemas converts every file of the spec directory in tree into a JSON
+// schema held in memory. The ver is the version of the release, and idPath is
+// the path segment used in the schema $ids. If source is not nil, each schema
+// records the upstream file it was converted from in $comment. It also returns
+// the custom keywords that were renamed to extension keywords.
+func convertSchemas(tree *object.Tree, spec specTree, ver, idPath string, source *schemaSource) ([]schemaFile, map[string]string, error) {
+	formatVersion := formatVersionSchema(ver, spec.Major)
+
+	var files []schemaFile
+	renamed := map[string]string{}
+	specFiles := map[string][]byte{} // Content of the spec files keyed by repository path.
+	var fileErrs []error
+	err := tree.Files().ForEach(func(f *object.File) (err error) {
 		// The pseudo JSON Schema files have a .spec.yml suffix.
-		if !strings.HasSuffix(filepath.Base(path), ".spec.yml") {
+		if !strings.HasPrefix(f.Name, spec.Path+"/") || !strings.HasSuffix(path.Base(f.Name), ".spec.yml") {
 			return nil
 		}
 
-		f, err := wt.Filesystem.Open(path)
+		r, err := f.Reader()
 		if err != nil {
 			return err
 		}
 		defer func() {
-			if closeErr := f.Close(); closeErr != nil {
+			if closeErr := r.Close(); closeErr != nil {
 				err = errors.Join(err, closeErr)
 			}
 		}()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		specFiles[f.Name] = data
 
 		// Get the schema file path relative directory containing the specs.
-		relPath := strings.TrimPrefix(filepath.ToSlash(path), filepath.ToSlash(repoPath)+"/")
+		relPath := strings.TrimPrefix(f.Name, spec.Path+"/")
 		relPath = strings.Replace(relPath, ".spec.yml", ".jsonschema.json", 1)
 
-		written = append(written, relPath)
-		return writeSchema(relPath, f, dir, ver)
+		// Convert the YAML to JSON with some necessary cleanup.
+		var comment string
+		if source != nil {
+			comment = source.comment(f.Name)
+		}
+		buf := new(bytes.Buffer)
+		if err := convertSpecYAMLToJSONSchema(relPath, bytes.NewReader(data), buf, idPath, comment, renamed); err != nil {
+			if keepGoing {
+				// Convert the remaining files to report all failures.
+				fileErrs = append(fileErrs, &fileError{Path: f.Name, Err: err})
+				return nil
+			}
+			return fmt.Errorf("failed converting spec.yml file to JSON schema for %q: %w", relPath, err)
+		}
+		files = append(files, schemaFile{Path: relPath, Data: buf.Bytes()})
+		slog.Debug("Converted schema.", "source", f.Name, "path", relPath)
+		return nil
 	})
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	if len(fileErrs) > 0 {
+		return nil, nil, errors.Join(fileErrs...)
+	}
+	if err := validateSpecFiles(idPath, specFiles); err != nil {
+		return nil, nil, err
 	}
 
 	// Don't overwrite the root manifest.jsonschema.json that exists in <=1.7.1.
-	if !slices.Contains(written, "manifest.jsonschema.json") {
-		b, err := combinedManifestSchema(written, ver)
+	if !slices.ContainsFunc(files, func(f schemaFile) bool { return f.Path == "manifest.jsonschema.json" }) {
+		b, err := combinedManifestSchema(files, idPath, formatVersion)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
-		return os.WriteFile(filepath.Join(dir, "manifest.jsonschema.json"), b, 0o600)
+		files = append(files, schemaFile{Path: "manifest.jsonschema.json", Data: b})
 	}
-	return nil
+
+	// Likewise for data_stream/manifest.jsonschema.json.
+	if !slices.ContainsFunc(files, func(f schemaFile) bool { return f.Path == "data_stream/manifest.jsonschema.json" }) {
+		b, err := combinedDataStreamManifestSchema(files, idPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, schemaFile{Path: "data_stream/manifest.jsonschema.json", Data: b})
+	}
+
+	if files, err = filterSchemas(idPath, files); err != nil {
+		return nil, nil, err
+	}
+	if files, err = checkPatterns(idPath, files); err != nil {
+		return nil, nil, err
+	}
+	if absoluteRefURLs {
+		if files, err = absoluteRefs(idPath, files); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(renamed) > 0 {
+		b, err := vocabularySchema(renamed, idPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, schemaFile{Path: vocabularyFile, Data: b})
+	}
+
+	return files, renamed, nil
 }
 
-func writeSchema(relPath string, r io.Reader, destDir, version string) error {
-	// Convert the YAML to JSON with some necessary cleanup.
-	buf := new(bytes.Buffer)
-	if err := convertSpecYAMLToJSONSchema(relPath, r, buf, version); err != nil {
-		return fmt.Errorf("failed converting spec.yml file to JSON schema for %q: %w", relPath, err)
+// writeSchemas writes the schemas, package layout, and generation metadata
+// for a version into its jsonschema directory.
+func writeSchemas(dir string, files []schemaFile, layoutJSON []byte, meta generationMetadata) (err error) {
+	// Write into a staging directory that replaces dir only after every
+	// schema for the version was written successfully.
+	staging, err := fsutil.StageDir(dir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Discard the staging directory if it was not committed.
+		if rmErr := os.RemoveAll(staging); rmErr != nil {
+			err = errors.Join(err, rmErr)
+		}
+	}()
+
+	for _, f := range files {
+		if err = fsutil.WriteFile(filepath.Join(staging, filepath.FromSlash(f.Path)), f.Data); err != nil {
+			return err
+		}
+	}
+
+	total := len(files) + 1 // Includes the metadata file.
+	if layoutJSON != nil {
+		if err = fsutil.WriteFile(filepath.Join(staging, layout.FileName), layoutJSON); err != nil {
+			return err
+		}
+		total++
+	}
+	b, err := meta.marshal()
+	if err != nil {
+		return err
+	}
+	if err = fsutil.WriteFile(filepath.Join(staging, metadataFile), b); err != nil {
+		return err
 	}
 
-	// Write to disk.
-	destFile := filepath.Join(destDir, relPath)
-	if err := os.MkdirAll(filepath.Dir(destFile), 0o700); err != nil {
+	// The schemas are reformatted by `just fmt`, which the generated bytes
+	// must not undo.
+	changed, err := fsutil.CommitSchemaDir(staging, dir)
+	if err != nil {
 		return err
 	}
-	return os.WriteFile(destFile, buf.Bytes(), 0o600)
+	summary.AddFiles(changed, total-changed)
+	return nil
+}
+
+// ndjsonRecord is a single line of NDJSON output.
+type ndjsonRecord struct {
+	Namespace string          `json:"namespace,omitempty"`
+	Version   string          `json:"version"`
+	Path      string          `json:"path"`
+	Schema    json.RawMessage `json:"schema"`
+}
+
+// streamSchemas writes each schema as a compact NDJSON record to w.
+func streamSchemas(w io.Writer, namespace, ver string, files []schemaFile) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+
+	for _, f := range files {
+		compact := new(bytes.Buffer)
+		if err := json.Compact(compact, f.Data); err != nil {
+			return fmt.Errorf("failed to compact %q: %w", f.Path, err)
+		}
+		rec := ndjsonRecord{Namespace: namespace, Version: ver, Path: f.Path, Schema: compact.Bytes()}
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func convertSpecYAMLToJSONSchema(path string, r io.Reader, w io.Writer, version string) error {
+func convertSpecYAMLToJSONSchema(path string, r io.Reader, w io.Writer, version, comment string, renamed map[string]string) error {
+	var node yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&node); err != nil {
+		return fmt.Errorf("failed to decode YAML: %w", err)
+	}
+	if strictYAML {
+		if err := checkStrictYAML(&node); err != nil {
+			return fmt.Errorf("strict YAML decoding failed: %w", err)
+		}
+	}
+	if len(node.Content) > 0 {
+		if spec := mappingValue(node.Content[0], "spec"); spec != nil {
+			if normalizeYAML {
+				normalizeScalars(spec)
+			}
+			if yamlComments {
+				foldComments(spec)
+			}
+		}
+	}
 	var m map[string]any
-	dec := yaml.NewDecoder(r)
-	if err := dec.Decode(&m); err != nil {
+	if err := node.Decode(&m); err != nil {
 		return fmt.Errorf("failed to decode YAML: %w", err)
 	}
 
@@ -182,9 +771,15 @@ func convertSpecYAMLToJSONSchema(path string, r io.Reader, w io.Writer, version
 		return fmt.Errorf("spec is not an object, got %T", v)
 	}
 
-	if err := patchSchema(version, path, spec); err != nil {
+	if err := patchSchema(version, path, spec, renamed); err != nil {
 		return fmt.Errorf("failed to patch schema: %w", err)
 	}
+	if comment != "" {
+		if upstream, ok := spec["$comment"].(string); ok && upstream != "" {
+			comment = upstream + "\n\n" + comment
+		}
+		spec["$comment"] = comment
+	}
 
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
@@ -192,8 +787,9 @@ func convertSpecYAMLToJSONSchema(path string, r io.Reader, w io.Writer, version
 	return enc.Encode(spec)
 }
 
-// patchSchema applies schema patches.
-func patchSchema(version, relativePath string, spec map[string]any) error {
+// patchSchema applies schema patches. Custom keywords that are renamed to
+// extension keywords are recorded in renamed.
+func patchSchema(version, relativePath string, spec map[string]any, renamed map[string]string) error {
 	// Add a schema dialect.
 	spec["$schema"] = dialect
 
@@ -205,7 +801,19 @@ func patchSchema(version, relativePath string, spec map[string]any) error {
 	spec["$id"] = id
 
 	// Apply all other schema patches in a single pass.
-	return patchSchemaInPlace(spec)
+	if err := patchSchemaInPlace(spec); err != nil {
+		return err
+	}
+
+	// Rename custom keywords that strict validators reject.
+	if extensionKeywords {
+		renameCustomKeywords(spec, renamed)
+	}
+
+	if autoTitles {
+		addTitles(spec, fileTitle(relativePath))
+	}
+	return nil
 }
 
 // patchSchemaInPlace applies all schema transformations in a single recursive pass:
@@ -289,43 +897,82 @@ func encodeURIFragment(ref string) (string, error) {
 	return base + "#" + encodedFragment, nil
 }
 
-// getSpecPath searches for the repository path that contains the specifications.
-// The location has varied over time, so this determines which of the two
-// locations to use.
-func getSpecPath(fs billy.Filesystem) (string, error) {
-	search := []string{
-		"spec",
-		"versions/1",
+// specTree is a directory of a release that contains specifications.
+type specTree struct {
+	Path  string // Repository path of the spec directory.
+	Dir   string // Subdirectory of the version directory that the schemas are written beneath.
+	Major int64  // Major version of the specs in a versions/N directory other than the primary one.
+}
+
+// specTrees returns the directories of tree that contain specifications. The
+// primary spec is in spec, or in versions/1 in older releases, and its
+// schemas are written to the version directory. Every other versions/N
+// directory holds the specs of another major version, whose schemas are
+// written beneath a spec-vN subdirectory.
+func specTrees(tree *object.Tree) ([]specTree, error) {
+	var specs []specTree
+	if _, err := tree.Tree("spec"); err == nil {
+		specs = append(specs, specTree{Path: "spec"})
 	}
 
-	for _, path := range search {
-		info, _ := fs.Stat(path)
-		if info != nil {
-			return path, nil
+	var majors []int64
+	if versions, err := tree.Tree("versions"); err == nil {
+		for _, e := range versions.Entries {
+			n, err := strconv.ParseInt(e.Name, 10, 64)
+			if e.Mode != filemode.Dir || err != nil || n < 1 || strconv.FormatInt(n, 10) != e.Name {
+				continue
+			}
+			majors = append(majors, n)
 		}
 	}
-	return "", errors.New("no spec found")
+	slices.Sort(majors)
+
+	for _, n := range majors {
+		p := "versions/" + strconv.FormatInt(n, 10)
+		if len(specs) == 0 {
+			specs = append(specs, specTree{Path: p})
+			continue
+		}
+		specs = append(specs, specTree{Path: p, Dir: "spec-v" + strconv.FormatInt(n, 10), Major: n})
+	}
+	if len(specs) == 0 {
+		return nil, errors.New("no spec found")
+	}
+	return specs, nil
 }
 
-func combinedManifestSchema(files []string, version string) ([]byte, error) {
+// combinedManifestSchema returns a schema that validates the manifest of a
+// package of any type by applying the manifest schema of its type. Packages
+// without a type are integrations in versions where type is optional.
+func combinedManifestSchema(files []schemaFile, idPath string, formatVersion *jsonschema.Schema) ([]byte, error) {
 	manifestTypes := map[string]string{
 		"content":     "content/manifest.jsonschema.json",
 		"input":       "input/manifest.jsonschema.json",
 		"integration": "integration/manifest.jsonschema.json",
 	}
 	maps.DeleteFunc(manifestTypes, func(typ, path string) bool {
-		return !slices.ContainsFunc(files, func(s string) bool {
-			return strings.HasSuffix(filepath.ToSlash(s), path)
+		return !slices.ContainsFunc(files, func(f schemaFile) bool {
+			return strings.HasSuffix(filepath.ToSlash(f.Path), path)
 		})
 	})
 	if len(manifestTypes) == 0 {
 		return nil, errors.New("no manifest types found")
 	}
 
-	id, err := schemaID(version, "manifest.jsonschema.json")
+	id, err := schemaID(idPath, "manifest.jsonschema.json")
 	if err != nil {
 		return nil, err
 	}
+	typeOptional, err := manifestTypeOptional(files, manifestTypes["integration"])
+	if err != nil {
+		return nil, err
+	}
+
+	types := slices.Sorted(maps.Keys(manifestTypes))
+	typeSchema := &jsonschema.Schema{Type: "string"}
+	for _, t := range types {
+		typeSchema.Enum = append(typeSchema.Enum, t)
+	}
 
 	s := jsonschema.Schema{
 		Schema:      "https://json-schema.org/draft/2020-12/schema",
@@ -333,20 +980,25 @@ func combinedManifestSchema(files []string, version string) ([]byte, error) {
 		Title:       "Package Manifest",
 		Description: "Schema for package manifests.",
 		Type:        "object",
-		Required:    []string{"type"},
 		Properties: map[string]*jsonschema.Schema{
-			"type": {
-				Type: "string",
-				Enum: []interface{}{slices.Sorted(maps.Keys(manifestTypes))},
-			},
+			"format_version": formatVersion,
+			"type":           typeSchema,
 		},
 		Defs: map[string]*jsonschema.Schema{},
 	}
+	if typeOptional {
+		typeSchema.Default = json.RawMessage(`"integration"`)
+	} else {
+		s.Required = []string{"type"}
+	}
 
-	for _, manifestType := range slices.Sorted(maps.Keys(manifestTypes)) {
+	for _, manifestType := range types {
 		definitionName := manifestType + "-manifest"
+		// The if schema must require type because properties is vacuously
+		// satisfied by a manifest without it.
 		s.AllOf = append(s.AllOf, &jsonschema.Schema{
 			If: &jsonschema.Schema{
+				Required: []string{"type"},
 				Properties: map[string]*jsonschema.Schema{
 					"type": {Const: jsonschema.Ptr(any(manifestType))},
 				},
@@ -357,10 +1009,224 @@ func combinedManifestSchema(files []string, version string) ([]byte, error) {
 		})
 		s.Defs[definitionName] = &jsonschema.Schema{Ref: "./" + manifestTypes[manifestType]}
 	}
+	if typeOptional {
+		s.AllOf = append(s.AllOf, &jsonschema.Schema{
+			If:   &jsonschema.Schema{Not: &jsonschema.Schema{Required: []string{"type"}}},
+			Then: &jsonschema.Schema{Ref: "#/$defs/integration-manifest"},
+		})
+	}
+
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// manifestTypeOptional reports whether the integration manifest schema at
+// path lets a manifest omit type.
+func manifestTypeOptional(files []schemaFile, path string) (bool, error) {
+	if path == "" {
+		return false, nil
+	}
+	i := slices.IndexFunc(files, func(f schemaFile) bool { return strings.HasSuffix(filepath.ToSlash(f.Path), path) })
+	var manifest struct {
+		Required []string `json:"required"`
+	}
+	if err := json.Unmarshal(files[i].Data, &manifest); err != nil {
+		return false, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return !slices.Contains(manifest.Required, "type"), nil
+}
+
+// dataStreamManifests maps the package types that contain data streams to
+// the path of their data stream manifest schema.
+var dataStreamManifests = map[string]string{
+	"integration": "integration/data_stream/manifest.jsonschema.json",
+}
+
+// combinedDataStreamManifestSchema returns a schema that validates the
+// manifest of a data stream of any package type by applying the data stream
+// manifest schema that defines its type. Data streams without a type are
+// validated by the integration data stream manifest schema.
+func combinedDataStreamManifestSchema(files []schemaFile, idPath string) ([]byte, error) {
+	id, err := schemaID(idPath, "data_stream/manifest.jsonschema.json")
+	if err != nil {
+		return nil, err
+	}
+
+	s := jsonschema.Schema{
+		Schema:      "https://json-schema.org/draft/2020-12/schema",
+		ID:          id,
+		Title:       "Data Stream Manifest",
+		Description: "Schema for data stream manifests.",
+		Type:        "object",
+		Defs:        map[string]*jsonschema.Schema{},
+	}
+
+	// Each data stream type is validated by the first package type, in
+	// sorted order, whose schema defines it.
+	dispatched := map[string]bool{}
+	typeSchema := &jsonschema.Schema{Type: "string"}
+	for _, packageType := range slices.Sorted(maps.Keys(dataStreamManifests)) {
+		p := dataStreamManifests[packageType]
+		i := slices.IndexFunc(files, func(f schemaFile) bool { return f.Path == p })
+		if i < 0 {
+			continue
+		}
+		var manifest struct {
+			Properties struct {
+				Type struct {
+					Enum []string `json:"enum"`
+				} `json:"type"`
+			} `json:"properties"`
+		}
+		if err := json.Unmarshal(files[i].Data, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", p, err)
+		}
+
+		definitionName := packageType + "-data-stream-manifest"
+		s.Defs[definitionName] = &jsonschema.Schema{Ref: "../" + p}
+		for _, dataStreamType := range manifest.Properties.Type.Enum {
+			if dispatched[dataStreamType] {
+				continue
+			}
+			dispatched[dataStreamType] = true
+			typeSchema.Enum = append(typeSchema.Enum, dataStreamType)
+			s.AllOf = append(s.AllOf, &jsonschema.Schema{
+				If: &jsonschema.Schema{
+					Required: []string{"type"},
+					Properties: map[string]*jsonschema.Schema{
+						"type": {Const: jsonschema.Ptr(any(dataStreamType))},
+					},
+				},
+				Then: &jsonschema.Schema{
+					Ref: "#/$defs/" + definitionName,
+				},
+			})
+		}
+	}
+	if _, found := s.Defs["integration-data-stream-manifest"]; !found {
+		return nil, errors.New("no data stream manifest types found")
+	}
+	s.Properties = map[string]*jsonschema.Schema{"type": typeSchema}
+	s.AllOf = append(s.AllOf, &jsonschema.Schema{
+		If:   &jsonschema.Schema{Not: &jsonschema.Schema{Required: []string{"type"}}},
+		Then: &jsonschema.Schema{Ref: "#/$defs/integration-data-stream-manifest"},
+	})
 
 	return json.MarshalIndent(s, "", "  ")
 }
 
+// versionManifestSchema returns a schema for the root of the output directory
+// that validates the manifest of a package of any spec version by applying
+// the manifest schema of the version named by its format_version. Prerelease
+// format versions are validated by the schemas of their release.
+func versionManifestSchema(dir, namespace string, versions []versionindex.Entry) ([]byte, error) {
+	id, err := schemaID(namespace, "manifest.jsonschema.json")
+	if err != nil {
+		return nil, err
+	}
+
+	s := jsonschema.Schema{
+		Schema:      "https://json-schema.org/draft/2020-12/schema",
+		ID:          id,
+		Title:       "Package Manifest",
+		Description: "Schema for package manifests of any package-spec version.",
+		Type:        "object",
+		Required:    []string{"format_version"},
+		Defs:        map[string]*jsonschema.Schema{},
+	}
+
+	var alts []string
+	for _, e := range versions {
+		v, err := semver.NewVersion(e.Version)
+		if err != nil || v.PreRelease != "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, e.Version, "jsonschema", "manifest.jsonschema.json")); err != nil {
+			continue
+		}
+
+		pattern := "^" + regexp.QuoteMeta(e.Version) + `(-[0-9A-Za-z.-]+)?$`
+		alts = append(alts, regexp.QuoteMeta(e.Version))
+		s.AllOf = append(s.AllOf, &jsonschema.Schema{
+			If: &jsonschema.Schema{
+				Required: []string{"format_version"},
+				Properties: map[string]*jsonschema.Schema{
+					"format_version": {Pattern: pattern},
+				},
+			},
+			Then: &jsonschema.Schema{
+				Ref: "#/$defs/" + e.Version,
+			},
+		})
+		ref := "./" + e.Version + "/manifest.jsonschema.json"
+		if absoluteRefURLs {
+			if ref, err = schemaID(namespace, ref); err != nil {
+				return nil, err
+			}
+		}
+		s.Defs[e.Version] = &jsonschema.Schema{Ref: ref}
+	}
+	if len(alts) == 0 {
+		return nil, fmt.Errorf("no version manifest schemas found in %s", dir)
+	}
+
+	s.Properties = map[string]*jsonschema.Schema{
+		"format_version": {
+			Type:        "string",
+			Description: "The version of the package specification format used by this package. It selects the schemas of that package-spec version.",
+			Pattern:     `^(` + strings.Join(alts, "|") + `)(-[0-9A-Za-z.-]+)?$`,
+		},
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// formatVersionSchema returns the schema of the format_version of manifests
+// that the schemas of version can validate. These are the versions of the
+// same major version up to version itself, so that a manifest written for a
+// newer or incompatible spec is not silently validated against older rules.
+// The specs of another major version in the release, identified by a non-zero
+// major, validate any version of that major. Refs that are not a semantic
+// version, like a branch, do not constrain the format_version.
+func formatVersionSchema(version string, major int64) *jsonschema.Schema {
+	const description = "The version of the package specification format used by this package."
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		if major == 0 {
+			return &jsonschema.Schema{Type: "string", Description: description}
+		}
+		v = &semver.Version{}
+	}
+	if major != 0 && major != v.Major {
+		return &jsonschema.Schema{
+			Type:        "string",
+			Description: fmt.Sprintf("%s The schemas of spec-v%d validate versions %d.x.", description, major, major),
+			Pattern:     fmt.Sprintf(`^%d\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(-[0-9A-Za-z.-]+)?$`, major),
+		}
+	}
+
+	// Versions with a lower minor may have any patch.
+	var alts []string
+	if v.Minor > 0 {
+		alts = append(alts, atMost(v.Minor-1)+`\.(0|[1-9][0-9]*)`)
+	}
+	alts = append(alts, fmt.Sprintf(`%d\.%s`, v.Minor, atMost(v.Patch)))
+
+	return &jsonschema.Schema{
+		Type:        "string",
+		Description: fmt.Sprintf("%s The schemas of package-spec %s validate versions %d.0.0 through %s.", description, version, v.Major, version),
+		Pattern:     fmt.Sprintf(`^%d\.(%s)(-[0-9A-Za-z.-]+)?$`, v.Major, strings.Join(alts, "|")),
+	}
+}
+
+// atMost returns a regular expression group matching the decimal numbers
+// from 0 to n.
+func atMost(n int64) string {
+	values := make([]string, 0, n+1)
+	for i := int64(0); i <= n; i++ {
+		values = append(values, strconv.FormatInt(i, 10))
+	}
+	return "(" + strings.Join(values, "|") + ")"
+}
+
 func schemaID(version, relativePath string) (string, error) {
 	u, err := url.Parse(baseURI)
 	if err != nil {
@@ -0,0 +1,106 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"golang.org/x/mod/module"
+)
+
+const metadataFile = "metadata.json"
+
+// generationMetadata records the provenance of a generated version. It is
+// written as metadata.json into each version's jsonschema directory.
+type generationMetadata struct {
+	GitURL           string    `json:"git_url"`
+	Commit           string    `json:"commit"`
+	Tag              string    `json:"tag,omitempty"`
+	Generated        time.Time `json:"generated"`
+	GeneratorVersion string    `json:"generator_version"`
+	Dialect          string    `json:"dialect"`
+
+	// Keywords maps the custom keywords of the upstream specs to the
+	// extension keywords that replace them in the schemas.
+	Keywords map[string]string `json:"keywords,omitempty"`
+}
+
+func newGenerationMetadata(gitURL string, ref *plumbing.Reference, commit string, generated time.Time) generationMetadata {
+	m := generationMetadata{
+		GitURL:           redactURL(gitURL),
+		Commit:           commit,
+		Generated:        generated,
+		GeneratorVersion: generatorVersion(),
+		Dialect:          dialect,
+	}
+	if ref.Name().IsTag() {
+		m.Tag = ref.Name().Short()
+	}
+	return m
+}
+
+func (m generationMetadata) marshal() ([]byte, error) {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// schemaSource identifies the upstream revision that schemas are converted
+// from.
+type schemaSource struct {
+	GitURL string
+	Ref    string // Short name of the tag or branch.
+	Commit string
+}
+
+// comment returns the $comment of a schema converted from the file, which is
+// a path within the repository. For GitHub repositories it links to the file
+// at the commit.
+func (s schemaSource) comment(file string) string {
+	gitURL := redactURL(s.GitURL)
+	c := fmt.Sprintf("Generated from %s in %s at %s (commit %s).", file, gitURL, s.Ref, s.Commit)
+	if u, err := url.Parse(gitURL); err == nil && u.Host == "github.com" {
+		u.Path = path.Join(strings.TrimSuffix(u.Path, ".git"), "blob", s.Commit, file)
+		c += " Source: " + u.String()
+	}
+	return c
+}
+
+// redactURL removes the user information from the URL so that neither
+// credentials nor user names are published.
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	u.User = nil
+	return u.String()
+}
+
+// generatorVersion returns the module version of this generator when it was
+// built from a release, and "devel" otherwise. The VCS revision and the
+// pseudo-version derived from it are not used because they change with every
+// commit to this repository, which would rewrite the metadata of every
+// version on each regeneration.
+func generatorVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	v := info.Main.Version
+	if v == "" || v == "(devel)" || module.IsPseudoVersion(v) || strings.HasSuffix(v, "+dirty") {
+		return "devel"
+	}
+	return v
+}
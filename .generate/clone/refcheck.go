@@ -0,0 +1,125 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// checkRefs checks that the $refs of the schemas of a version resolve before
+// they are written. References to schemas below -base-uri must point to one
+// of the files, and their fragments to a location or $anchor within it.
+// References to other schemas, such as the dialect metaschema, are not
+// checked. Depending on -ref-check, broken references are returned, logged as
+// warnings, or not checked at all.
+func checkRefs(ver string, files []schemaFile) error {
+	if refCheckMode == metaschemaOff {
+		return nil
+	}
+
+	docs := map[string]any{}                // Schemas keyed by $id.
+	anchors := map[string]map[string]bool{} // Anchors of each schema keyed by $id.
+	ids := make([]string, len(files))
+	for i, f := range files {
+		var doc any
+		if err := json.Unmarshal(f.Data, &doc); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", f.Path, err)
+		}
+		root, _ := doc.(map[string]any)
+		id, _ := root["$id"].(string)
+		ids[i] = id
+		docs[id] = doc
+		anchors[id] = map[string]bool{}
+		walkSchemas(doc, "", func(schema map[string]any, _ string) {
+			if anchor, ok := schema["$anchor"].(string); ok {
+				anchors[id][anchor] = true
+			}
+		})
+	}
+
+	var errs []error
+	for i, f := range files {
+		base, err := url.Parse(ids[i])
+		if err != nil {
+			return fmt.Errorf("%s has an invalid $id %q: %w", f.Path, ids[i], err)
+		}
+		walkSchemas(docs[ids[i]], "", func(schema map[string]any, ptr string) {
+			ref, ok := schema["$ref"].(string)
+			if !ok {
+				return
+			}
+			err := resolveRef(base, ref, docs, anchors)
+			switch {
+			case err == nil:
+			case refCheckMode == metaschemaWarn:
+				slog.Warn("Schema contains a broken reference.", "version", ver, "path", f.Path, "pointer", ptr+"/$ref", "ref", ref, "error", err)
+			default:
+				errs = append(errs, fmt.Errorf("%s#%s/$ref: %q %w", f.Path, ptr, ref, err))
+			}
+		})
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("broken references in %s: %w", ver, errors.Join(errs...))
+	}
+	return nil
+}
+
+// resolveRef returns an error if ref, relative to base, points to a missing
+// schema below -base-uri or to a missing location within a schema.
+func resolveRef(base *url.URL, ref string, docs map[string]any, anchors map[string]map[string]bool) error {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return fmt.Errorf("is not a valid URI reference: %w", err)
+	}
+	target := base.ResolveReference(u)
+	fragment := target.Fragment
+	target.Fragment = ""
+	target.RawFragment = ""
+
+	doc, found := docs[target.String()]
+	if !found {
+		if strings.HasPrefix(target.String(), strings.TrimSuffix(baseURI, "/")+"/") {
+			return errors.New("points to a schema file that is not generated")
+		}
+		return nil
+	}
+
+	switch {
+	case fragment == "":
+		return nil
+	case strings.HasPrefix(fragment, "/"):
+		node := doc
+		for _, tok := range strings.Split(fragment[1:], "/") {
+			tok = unescapePointer(tok)
+			switch n := node.(type) {
+			case map[string]any:
+				node, found = n[tok]
+			case []any:
+				i, err := strconv.Atoi(tok)
+				found = err == nil && i >= 0 && i < len(n)
+				if found {
+					node = n[i]
+				}
+			default:
+				found = false
+			}
+			if !found {
+				return fmt.Errorf("points to %q, which does not exist", fragment)
+			}
+		}
+		return nil
+	default:
+		if !anchors[target.String()][fragment] {
+			return fmt.Errorf("points to anchor %q, which does not exist", fragment)
+		}
+		return nil
+	}
+}
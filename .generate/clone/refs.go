@@ -0,0 +1,69 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// absoluteRefs rewrites the $refs of the schemas that point to another file
+// into absolute URLs resolved against the $id of the schema, so that
+// consumers can resolve them over HTTP without a copy of the schema tree.
+// References within the same file are left unchanged.
+func absoluteRefs(ver string, files []schemaFile) ([]schemaFile, error) {
+	for fi, f := range files {
+		var doc map[string]any
+		if err := json.Unmarshal(f.Data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", f.Path, err)
+		}
+		id, _ := doc["$id"].(string)
+		base, err := url.Parse(id)
+		if err != nil || !base.IsAbs() {
+			return nil, fmt.Errorf("%s has an invalid $id %q", f.Path, id)
+		}
+
+		var rewrote int
+		var errs []error
+		walkSchemas(doc, "", func(schema map[string]any, ptr string) {
+			ref, ok := schema["$ref"].(string)
+			if !ok || strings.HasPrefix(ref, "#") {
+				return
+			}
+			u, err := url.Parse(ref)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s#%s/$ref: %w", f.Path, ptr, err))
+				return
+			}
+			if u.IsAbs() {
+				return
+			}
+			schema["$ref"] = base.ResolveReference(u).String()
+			rewrote++
+		})
+		if len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+		if rewrote == 0 {
+			continue
+		}
+		slog.Debug("Rewrote relative references.", "version", ver, "path", f.Path, "refs", rewrote)
+
+		buf := new(bytes.Buffer)
+		enc := json.NewEncoder(buf)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+		files[fi].Data = buf.Bytes()
+	}
+	return files, nil
+}
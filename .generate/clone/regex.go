@@ -0,0 +1,330 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// patternIssue is a construct of an RE2 pattern that fails to compile or
+// behaves differently under ECMA-262, the regular expression dialect of JSON
+// Schema.
+type patternIssue struct {
+	Message string
+	Fixed   bool // The ECMA-262 rewrite of the pattern resolves the issue.
+}
+
+// ecmaSyntaxChars are the characters that may be escaped in ECMA-262 unicode
+// mode. Escaping any other punctuation is a syntax error.
+const ecmaSyntaxChars = `^$\.*+?()[]{}|/`
+
+// posixClasses are the ASCII ranges of the POSIX character classes of RE2,
+// which ECMA-262 does not support.
+var posixClasses = map[string]string{
+	"alnum":  `0-9A-Za-z`,
+	"alpha":  `A-Za-z`,
+	"ascii":  `\x00-\x7F`,
+	"blank":  `\t `,
+	"cntrl":  `\x00-\x1F\x7F`,
+	"digit":  `0-9`,
+	"graph":  `!-~`,
+	"lower":  `a-z`,
+	"print":  ` -~`,
+	"punct":  `!-\/:-@\[-\x60\{-~`,
+	"space":  `\t\n\v\f\r `,
+	"upper":  `A-Z`,
+	"word":   `0-9A-Za-z_`,
+	"xdigit": `0-9A-Fa-f`,
+}
+
+var (
+	inlineFlagsRegex = regexp.MustCompile(`^\(\?[imsU-]+[:)]`)
+	quantifierRegex  = regexp.MustCompile(`^\{[0-9]+(,[0-9]*)?\}`)
+)
+
+// ecmaPattern checks an RE2 pattern for constructs that fail to compile or
+// behave differently under ECMA-262, including its unicode mode. It returns
+// the pattern with the constructs that have an equivalent ECMA-262 form
+// rewritten, and an issue for each construct found.
+func ecmaPattern(pattern string) (string, []patternIssue) {
+	if _, err := syntax.Parse(pattern, syntax.Perl); err != nil {
+		return pattern, []patternIssue{{Message: fmt.Sprintf("does not compile under RE2: %v", err)}}
+	}
+
+	var (
+		b          strings.Builder
+		issues     []patternIssue
+		inClass    bool // Within a character class.
+		classStart bool // At the first character of a character class.
+	)
+	issue := func(fixed bool, format string, args ...any) {
+		issues = append(issues, patternIssue{Message: fmt.Sprintf(format, args...), Fixed: fixed})
+	}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		atClassStart := classStart
+		classStart = false
+
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			i = ecmaEscape(&b, pattern, i, inClass, issue)
+		case c == '[' && !inClass:
+			inClass, classStart = true, true
+			b.WriteByte(c)
+			if strings.HasPrefix(pattern[i+1:], "^") {
+				b.WriteByte('^')
+				i++
+			}
+		case c == ']' && inClass && atClassStart:
+			issue(true, "] at the start of a character class must be escaped")
+			b.WriteString(`\]`)
+		case c == ']' && inClass:
+			inClass = false
+			b.WriteByte(c)
+		case c == '[' && inClass && strings.HasPrefix(pattern[i:], "[:"):
+			end := strings.Index(pattern[i:], ":]")
+			if end < 0 {
+				b.WriteByte(c)
+				continue
+			}
+			name := pattern[i+2 : i+end]
+			ranges, found := posixClasses[name]
+			if found {
+				issue(true, "POSIX class [:%s:] is not supported", name)
+				b.WriteString(ranges)
+			} else {
+				issue(false, "POSIX class [:%s:] is not supported", name)
+				b.WriteString(pattern[i : i+end+2])
+			}
+			i += end + 1
+		case c == '(' && !inClass && strings.HasPrefix(pattern[i:], "(?P<"):
+			issue(true, "named group (?P<name>) must be written as (?<name>)")
+			b.WriteString("(?<")
+			i += len("(?P<") - 1
+		case c == '(' && !inClass && inlineFlagsRegex.MatchString(pattern[i:]):
+			issue(false, "inline flags %s are not supported", inlineFlagsRegex.FindString(pattern[i:]))
+			b.WriteByte(c)
+		case c == '{' && !inClass:
+			if q := quantifierRegex.FindString(pattern[i:]); q != "" {
+				b.WriteString(q)
+				i += len(q) - 1
+				continue
+			}
+			issue(true, "literal { must be escaped in unicode mode")
+			b.WriteString(`\{`)
+		case (c == '}' || c == ']') && !inClass:
+			issue(true, "literal %c must be escaped in unicode mode", c)
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	rewritten := b.String()
+	if rewritten != pattern && !sameRE2(pattern, rewritten) {
+		// Keep the original when the rewrite is not equivalent under RE2.
+		for i := range issues {
+			issues[i].Fixed = false
+		}
+		return pattern, issues
+	}
+	return rewritten, issues
+}
+
+// ecmaEscape writes the ECMA-262 form of the escape sequence whose character
+// after the backslash is at pattern[i]. It returns the index of the last
+// character of the sequence.
+func ecmaEscape(b *strings.Builder, pattern string, i int, inClass bool, issue func(bool, string, ...any)) int {
+	e := pattern[i]
+	switch {
+	case e == 'Q':
+		literal := pattern[i+1:]
+		end := len(pattern) - 1
+		if n := strings.Index(literal, `\E`); n >= 0 {
+			literal = literal[:n]
+			end = i + n + 2
+		}
+		issue(true, `quoted literal \Q...\E is not supported`)
+		for j := 0; j < len(literal); j++ {
+			if strings.IndexByte(ecmaSyntaxChars, literal[j]) >= 0 || (inClass && literal[j] == '-') {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(literal[j])
+		}
+		return end
+	case (e == 'A' || e == 'z') && !inClass:
+		anchor := map[byte]string{'A': "^", 'z': "$"}[e]
+		issue(true, `\%c is not supported, use %s`, e, anchor)
+		b.WriteString(anchor)
+	case e == 'C':
+		issue(false, `\C matching any byte is not supported`)
+		b.WriteString(`\C`)
+	case e == 'a':
+		issue(true, `\a is not supported`)
+		b.WriteString(`\x07`)
+	case e >= '0' && e <= '7':
+		end := i
+		for end+1 < len(pattern) && end-i < 2 && pattern[end+1] >= '0' && pattern[end+1] <= '7' {
+			end++
+		}
+		v, _ := strconv.ParseUint(pattern[i:end+1], 8, 8)
+		issue(true, `octal escape \%s is not supported`, pattern[i:end+1])
+		fmt.Fprintf(b, `\x%02X`, v)
+		return end
+	case e == 'x' && strings.HasPrefix(pattern[i+1:], "{"):
+		end := strings.IndexByte(pattern[i:], '}')
+		if end < 0 {
+			b.WriteString(`\x`)
+			return i
+		}
+		end += i
+		v, err := strconv.ParseUint(pattern[i+2:end], 16, 32)
+		switch {
+		case err != nil:
+			b.WriteString(pattern[i-1 : end+1])
+		case v <= 0xFF:
+			issue(true, `hex escape %s is not supported`, pattern[i-1:end+1])
+			fmt.Fprintf(b, `\x%02X`, v)
+		case v <= 0xFFFF && unicode.IsPrint(rune(v)):
+			// RE2 has no \u escape, so use the character itself.
+			issue(true, `hex escape %s is not supported`, pattern[i-1:end+1])
+			b.WriteRune(rune(v))
+		default:
+			issue(false, `hex escape %s requires the u flag`, pattern[i-1:end+1])
+			b.WriteString(pattern[i-1 : end+1])
+		}
+		return end
+	case e == 'p' || e == 'P':
+		end := i + 1
+		name := pattern[i+1 : min(i+2, len(pattern))]
+		if strings.HasPrefix(pattern[i+1:], "{") {
+			if n := strings.IndexByte(pattern[i:], '}'); n >= 0 {
+				end = i + n
+				name = pattern[i+2 : end]
+			}
+		}
+		issue(false, `unicode class \%c{%s} requires the u flag`, e, name)
+		fmt.Fprintf(b, `\%c{%s}`, e, name)
+		return end
+	case e < 0x80 && !isAlnum(e) && strings.IndexByte(ecmaSyntaxChars, e) < 0 && (!inClass || e != '-'):
+		issue(true, `unnecessary escape \%c is an error in unicode mode`, e)
+		b.WriteByte(e)
+	default:
+		b.WriteByte('\\')
+		b.WriteByte(e)
+	}
+	return i
+}
+
+func isAlnum(c byte) bool {
+	return '0' <= c && c <= '9' || 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z'
+}
+
+// sameRE2 reports whether two patterns compile to the same RE2 program.
+func sameRE2(a, b string) bool {
+	pa, err := compileRE2(a)
+	if err != nil {
+		return false
+	}
+	pb, err := compileRE2(b)
+	if err != nil {
+		return false
+	}
+	return pa.String() == pb.String()
+}
+
+func compileRE2(pattern string) (*syntax.Prog, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	return syntax.Compile(re.Simplify())
+}
+
+// checkPatterns checks the pattern keywords and patternProperties names of
+// the schemas against ECMA-262 as configured by -regex-compat. With
+// -rewrite-patterns, patterns that have an equivalent ECMA-262 form are
+// rewritten and only the remaining issues are reported.
+func checkPatterns(ver string, files []schemaFile) ([]schemaFile, error) {
+	if regexCompatMode == metaschemaOff {
+		return files, nil
+	}
+
+	var errs []error
+	for fi, f := range files {
+		var doc any
+		if err := json.Unmarshal(f.Data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", f.Path, err)
+		}
+
+		var rewrote bool
+		walkSchemas(doc, "", func(schema map[string]any, ptr string) {
+			check := func(pattern, ptr string) string {
+				rewritten, issues := ecmaPattern(pattern)
+				for _, issue := range issues {
+					if issue.Fixed && rewritePatterns {
+						continue
+					}
+					if regexCompatMode == metaschemaWarn {
+						slog.Warn("Pattern is not compatible with ECMA-262.", "version", ver, "path", f.Path, "pointer", ptr, "pattern", pattern, "error", issue.Message)
+						continue
+					}
+					errs = append(errs, fmt.Errorf("%s#%s: pattern %q: %s", f.Path, ptr, pattern, issue.Message))
+				}
+				if !rewritePatterns || rewritten == pattern {
+					return pattern
+				}
+				slog.Debug("Rewrote pattern.", "version", ver, "path", f.Path, "pointer", ptr, "pattern", pattern, "rewritten", rewritten)
+				rewrote = true
+				return rewritten
+			}
+
+			if pattern, ok := schema["pattern"].(string); ok {
+				schema["pattern"] = check(pattern, ptr+"/pattern")
+			}
+			if props, ok := schema["patternProperties"].(map[string]any); ok {
+				for _, pattern := range slices.Sorted(maps.Keys(props)) {
+					rewritten := check(pattern, ptr+"/patternProperties/"+escapePointer(pattern))
+					if rewritten != pattern {
+						if _, found := props[rewritten]; found {
+							continue
+						}
+						props[rewritten] = props[pattern]
+						delete(props, pattern)
+					}
+				}
+			}
+		})
+		if !rewrote {
+			continue
+		}
+
+		buf := new(bytes.Buffer)
+		enc := json.NewEncoder(buf)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+		files[fi].Data = buf.Bytes()
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("patterns incompatible with ECMA-262 in %s: %w", ver, errors.Join(errs...))
+	}
+	return files, nil
+}
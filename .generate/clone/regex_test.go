@@ -0,0 +1,193 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestECMAPatternRewrites(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+		issue   string
+	}{
+		{`\Qa.b\E`, `a\.b`, `quoted literal \Q...\E is not supported`},
+		{`\Q(x)`, `\(x\)`, `quoted literal \Q...\E is not supported`},
+		{`\Aabc\z`, `^abc$`, `\A is not supported, use ^`},
+		{`\a`, `\x07`, `\a is not supported`},
+		{`\012`, `\x0A`, `octal escape \012 is not supported`},
+		{`\x{41}`, `\x41`, `hex escape \x{41} is not supported`},
+		{`\x{e9}`, `\xE9`, `hex escape \x{e9} is not supported`},
+		{`\x{20AC}`, `€`, `hex escape \x{20AC} is not supported`},
+		{`\_`, `_`, `unnecessary escape \_ is an error in unicode mode`},
+		{`a\@b`, `a@b`, `unnecessary escape \@ is an error in unicode mode`},
+		{`[]a]`, `[\]a]`, `] at the start of a character class must be escaped`},
+		{`[^]a]`, `[^\]a]`, `] at the start of a character class must be escaped`},
+		{`[[:alpha:]_]`, `[A-Za-z_]`, `POSIX class [:alpha:] is not supported`},
+		{`[[:xdigit:]]+`, `[0-9A-Fa-f]+`, `POSIX class [:xdigit:] is not supported`},
+		{`(?P<x>a)`, `(?<x>a)`, `named group (?P<name>) must be written as (?<name>)`},
+		{`a{`, `a\{`, `literal { must be escaped in unicode mode`},
+		{`a{,2}`, `a\{,2\}`, `literal { must be escaped in unicode mode`},
+		{`a}`, `a\}`, `literal } must be escaped in unicode mode`},
+		{`a]`, `a\]`, `literal ] must be escaped in unicode mode`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.pattern, func(t *testing.T) {
+			got, issues := ecmaPattern(tc.pattern)
+			if got != tc.want {
+				t.Errorf("ecmaPattern(%q) = %q, want %q", tc.pattern, got, tc.want)
+			}
+			if len(issues) == 0 || issues[0].Message != tc.issue || !issues[0].Fixed {
+				t.Errorf("issues = %+v, want fixed %q", issues, tc.issue)
+			}
+			if !sameRE2(tc.pattern, got) {
+				t.Errorf("rewrite %q is not equivalent under RE2", got)
+			}
+			// The rewritten pattern needs no further rewriting.
+			again, issues := ecmaPattern(got)
+			if again != got || len(issues) != 0 {
+				t.Errorf("ecmaPattern(%q) = %q, %+v, want no change", got, again, issues)
+			}
+		})
+	}
+}
+
+func TestECMAPatternRejects(t *testing.T) {
+	tests := []struct {
+		pattern string
+		issue   string
+	}{
+		{`(?i)abc`, `inline flags (?i) are not supported`},
+		{`(?s:.)`, `inline flags (?s: are not supported`},
+		{`\p{Greek}`, `unicode class \p{Greek} requires the u flag`},
+		{`\PL`, `unicode class \P{L} requires the u flag`},
+		{`\x{1F600}`, `hex escape \x{1F600} requires the u flag`},
+		{`\x{2028}`, `hex escape \x{2028} requires the u flag`},
+		{`[a`, "does not compile under RE2"},
+		{`\C`, "does not compile under RE2"},
+		{`\8`, "does not compile under RE2"},
+		{`[[:foo:]]`, "does not compile under RE2"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.pattern, func(t *testing.T) {
+			_, issues := ecmaPattern(tc.pattern)
+			found := false
+			for _, issue := range issues {
+				if strings.HasPrefix(issue.Message, tc.issue) {
+					found = true
+					if issue.Fixed {
+						t.Errorf("issue %q is reported as fixed", issue.Message)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("issues = %+v, want %q", issues, tc.issue)
+			}
+		})
+	}
+}
+
+func TestECMAPatternMixedIssues(t *testing.T) {
+	// The fixable construct is rewritten even though another one is not.
+	got, issues := ecmaPattern(`(?i)a}`)
+	if got != `(?i)a\}` {
+		t.Errorf("ecmaPattern = %q, want %q", got, `(?i)a\}`)
+	}
+	if len(issues) != 2 || issues[0].Fixed || !issues[1].Fixed {
+		t.Errorf("issues = %+v, want one unfixed and one fixed issue", issues)
+	}
+}
+
+// upstreamPatterns are the pattern keywords of the committed schemas of every
+// release. They are compatible with ECMA-262 and must pass unchanged.
+var upstreamPatterns = []string{
+	`(_file|_url)$`,
+	`(access_key|api_key|passphrase|password|secret|token)`,
+	`^(([a-zA-Z0-9-]+)|([a-zA-Z0-9-]+\/[a-zA-Z0-9-]+))$`,
+	`^(([a-zA-Z0-9-_]+)|([a-zA-Z0-9-_]+\/[a-zA-Z0-9-_]+))$`,
+	`^([0-9]+)\.([0-9]+)\.([0-9]+)(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+[0-9A-Za-z-]+)?$`,
+	`^(\d+[smh]|\d+ms)+$`,
+	`^(http(s)?://|kbn:/)`,
+	`^.*$`,
+	`^2.3$`,
+	`^2\.3$`,
+	`^[A-Za-z0-9_]+=.+$`,
+	`^[\-*_@A-Za-z0-9]+(\.[\-*_@A-Za-z0-9]+)*$`,
+	`^[\-*_\/@A-Za-z0-9]+(\.[\-*_\/@A-Za-z0-9]+)*$`,
+	`^[a-z0-9_]+$`,
+	`^[a-zA-Z0-9]+[a-zA-Z0-9\._]*$`,
+	`^[a-zA-Z0-9_\-]+$`,
+	`^[a-z][a-z0-9_]*$`,
+	`^\d+\.\d+\.\d+(-[a-zA-Z0-9.]+)?$`,
+	`^git@.+`,
+}
+
+func TestECMAPatternUpstream(t *testing.T) {
+	for _, pattern := range upstreamPatterns {
+		got, issues := ecmaPattern(pattern)
+		if got != pattern || len(issues) != 0 {
+			t.Errorf("ecmaPattern(%q) = %q, %+v, want no change", pattern, got, issues)
+		}
+	}
+}
+
+func TestCheckPatterns(t *testing.T) {
+	defer func(mode string, rewrite bool) {
+		regexCompatMode, rewritePatterns = mode, rewrite
+	}(regexCompatMode, rewritePatterns)
+
+	schema := `{"properties": {"a": {"pattern": "^\\Aa{$"}}, "patternProperties": {"^[[:digit:]]+$": {}, "^ok$": {}}}`
+	tests := []struct {
+		name    string
+		mode    string
+		rewrite bool
+		want    string // Expected schema, empty if unchanged.
+		err     string
+	}{
+		{name: "off", mode: metaschemaOff},
+		{name: "warn", mode: metaschemaWarn},
+		{name: "fail", mode: metaschemaFail, err: `#/properties/a/pattern: pattern "^\\Aa{$": \A is not supported, use ^`},
+		{
+			name:    "rewrite",
+			mode:    metaschemaFail,
+			rewrite: true,
+			want:    `{"properties": {"a": {"pattern": "^^a\\{$"}}, "patternProperties": {"^[0-9]+$": {}, "^ok$": {}}}`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			regexCompatMode, rewritePatterns = tc.mode, tc.rewrite
+			files, err := checkPatterns("3.6.0", []schemaFile{{Path: "a.jsonschema.json", Data: []byte(schema)}})
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("error = %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tc.want
+			if want == "" {
+				want = schema
+			}
+			var got, wantDoc any
+			if err := json.Unmarshal(files[0].Data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(want), &wantDoc); err != nil {
+				t.Fatal(err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(wantDoc)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("schema = %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
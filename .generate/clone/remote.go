@@ -0,0 +1,89 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// remote is a package-spec git repository to generate schemas from.
+type remote struct {
+	// Name is the output namespace. Schemas from a named remote are written
+	// beneath a directory of the same name in the output directory, and the
+	// name is added to their $id. The empty name denotes the primary remote
+	// whose schemas are written directly to the output directory.
+	Name string
+	URL  string
+}
+
+// outDir returns the directory containing the remote's versioned directories.
+func (r remote) outDir(base string) string {
+	return filepath.Join(base, r.Name)
+}
+
+// idPath returns the $id path segment for version.
+func (r remote) idPath(version string) string {
+	return path.Join(r.Name, version)
+}
+
+var remoteNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// remotesFlag is a flag.Value accepting repeated [name=]url values. A single
+// value may also contain a comma separated list, which allows setting
+// multiple remotes from an environment variable.
+type remotesFlag []remote
+
+func (f *remotesFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	var parts []string
+	for _, r := range *f {
+		if r.Name == "" {
+			parts = append(parts, r.URL)
+			continue
+		}
+		parts = append(parts, r.Name+"="+r.URL)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f *remotesFlag) Set(value string) error {
+	for v := range strings.SplitSeq(value, ",") {
+		if err := f.add(strings.TrimSpace(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *remotesFlag) add(value string) error {
+	var r remote
+	if name, rawURL, found := strings.Cut(value, "="); found && remoteNameRegex.MatchString(name) {
+		r = remote{Name: name, URL: rawURL}
+	} else {
+		r = remote{URL: value}
+	}
+	if r.URL == "" {
+		return errors.New("git URL must not be empty")
+	}
+
+	for _, existing := range *f {
+		if existing.Name == r.Name {
+			if r.Name == "" {
+				return errors.New("only one git URL may be specified without a name=")
+			}
+			return fmt.Errorf("duplicate git URL name %q", r.Name)
+		}
+	}
+
+	*f = append(*f, r)
+	return nil
+}
@@ -0,0 +1,61 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// RetryPolicy controls how failed git network operations are retried.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts. Values less than one are
+	// treated as one.
+	Attempts int
+	// Backoff is the delay before the first retry. It doubles after each
+	// subsequent failure up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Do calls fn until it succeeds, returns a permanent error, or the attempts
+// are exhausted. The cleanup function, when non-nil, is called after each
+// failed attempt to undo partial work before retrying.
+func (p RetryPolicy) Do(op string, fn, cleanup func() error) error {
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.Attempts || !isRetryable(err) {
+			return err
+		}
+
+		if cleanup != nil {
+			if cleanupErr := cleanup(); cleanupErr != nil {
+				return errors.Join(err, cleanupErr)
+			}
+		}
+
+		slog.Warn("Retrying failed operation.", "op", op, "attempt", attempt, "attempts", p.Attempts, "backoff", backoff, "error", err)
+		time.Sleep(backoff)
+		backoff = min(2*backoff, p.MaxBackoff)
+	}
+}
+
+// isRetryable reports whether err may be caused by a transient condition.
+// Errors that will not change on retry, such as authentication failures or
+// a missing repository, are not retried.
+func isRetryable(err error) bool {
+	switch {
+	case errors.Is(err, transport.ErrAuthenticationRequired),
+		errors.Is(err, transport.ErrAuthorizationFailed),
+		errors.Is(err, transport.ErrRepositoryNotFound),
+		errors.Is(err, transport.ErrInvalidAuthMethod):
+		return false
+	}
+	return true
+}
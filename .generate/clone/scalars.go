@@ -0,0 +1,232 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// stringKeywords are the keywords whose value is a string, or an array of
+// strings for required.
+var stringKeywords = []string{
+	"$anchor", "$comment", "$id", "$ref", "contentEncoding", "contentMediaType",
+	"description", "format", "pattern", "required", "title",
+}
+
+// instanceKeywords are the keywords whose value is an instance, or an array of
+// instances for enum and examples.
+var instanceKeywords = []string{"const", "default", "enum", "example", "examples"}
+
+// normalizeScalars retags the plain scalars of the schema node whose YAML
+// type is not the type intended by the author, so that they decode to the
+// intended JSON value:
+//
+//   - timestamps, such as 2024-01-01, are strings because JSON has no
+//     timestamp type.
+//   - values of keywords that require strings, such as a title of 1.10, are
+//     strings.
+//   - numbers and booleans that are instances of a schema whose type is
+//     string, such as 1.10 or 0755 in an enum, are strings with the text
+//     written by the author.
+//
+// Quoted scalars are already strings and are left unchanged.
+func normalizeScalars(schema *yaml.Node) {
+	switch schema.Kind {
+	case yaml.SequenceNode:
+		// Draft-07 items may be an array of schemas.
+		for _, item := range schema.Content {
+			normalizeScalars(item)
+		}
+		return
+	case yaml.MappingNode:
+	default:
+		return
+	}
+
+	stringType := isStringType(mappingValue(schema, "type"))
+	for i := 0; i+1 < len(schema.Content); i += 2 {
+		keyword, value := schema.Content[i].Value, schema.Content[i+1]
+		switch {
+		case slices.Contains(stringKeywords, keyword):
+			eachScalar(value, func(n *yaml.Node) { retagString(n, "!!int", "!!float", "!!bool", "!!timestamp") })
+		case slices.Contains(instanceKeywords, keyword):
+			eachScalar(value, func(n *yaml.Node) {
+				if stringType {
+					retagString(n, "!!int", "!!float", "!!bool", "!!timestamp")
+					return
+				}
+				retagString(n, "!!timestamp")
+			})
+		case slices.Contains(schemaKeywords, keyword), keyword == "additionalItems":
+			normalizeScalars(value)
+		case slices.Contains(schemasKeywords, keyword), keyword == "definitions":
+			for _, subschema := range nodeChildren(value) {
+				normalizeScalars(subschema)
+			}
+		}
+	}
+}
+
+// isStringType reports whether the type node allows strings but no numbers.
+func isStringType(typ *yaml.Node) bool {
+	if typ == nil {
+		return false
+	}
+	var types []string
+	eachScalar(typ, func(n *yaml.Node) { types = append(types, n.Value) })
+	return slices.Contains(types, "string") && !slices.Contains(types, "number") && !slices.Contains(types, "integer")
+}
+
+// retagString makes the plain scalar a string if it has one of the tags.
+func retagString(n *yaml.Node, tags ...string) {
+	if n.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return
+	}
+	if slices.Contains(tags, n.ShortTag()) {
+		n.Tag = "!!str"
+	}
+}
+
+// eachScalar calls fn for the node if it is a scalar, or for each scalar item
+// if it is a sequence.
+func eachScalar(n *yaml.Node, fn func(*yaml.Node)) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		fn(n)
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			if item.Kind == yaml.ScalarNode {
+				fn(item)
+			}
+		}
+	}
+}
+
+// mappingValue returns the value of key in the mapping node, or nil.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// nodeChildren returns the values of a mapping node or the items of a
+// sequence node.
+func nodeChildren(n *yaml.Node) []*yaml.Node {
+	switch n.Kind {
+	case yaml.MappingNode:
+		var values []*yaml.Node
+		for i := 1; i < len(n.Content); i += 2 {
+			values = append(values, n.Content[i])
+		}
+		return values
+	case yaml.SequenceNode:
+		return n.Content
+	}
+	return nil
+}
+
+// foldComments moves the leading comments of the schema node into the
+// schemas. A comment above a property, definition, or other subschema becomes
+// the description of that subschema, or is appended to its $comment if it has
+// a description. Comments above other keywords are appended to the $comment
+// of the schema containing them.
+func foldComments(schema *yaml.Node) {
+	switch schema.Kind {
+	case yaml.SequenceNode:
+		// Draft-07 items may be an array of schemas.
+		for _, item := range schema.Content {
+			attachComment(item, item.HeadComment)
+			foldComments(item)
+		}
+		return
+	case yaml.MappingNode:
+	default:
+		return
+	}
+
+	var notes []string
+	for i := 0; i+1 < len(schema.Content); i += 2 {
+		key, value := schema.Content[i], schema.Content[i+1]
+		switch {
+		case slices.Contains(schemaKeywords, key.Value), key.Value == "additionalItems":
+			attachComment(value, key.HeadComment)
+			foldComments(value)
+		case slices.Contains(schemasKeywords, key.Value), key.Value == "definitions":
+			if text := commentText(key.HeadComment); text != "" {
+				notes = append(notes, text)
+			}
+			switch value.Kind {
+			case yaml.MappingNode:
+				for j := 0; j+1 < len(value.Content); j += 2 {
+					attachComment(value.Content[j+1], value.Content[j].HeadComment)
+					foldComments(value.Content[j+1])
+				}
+			case yaml.SequenceNode:
+				for _, item := range value.Content {
+					attachComment(item, item.HeadComment)
+					foldComments(item)
+				}
+			}
+		default:
+			if text := commentText(key.HeadComment); text != "" {
+				notes = append(notes, text)
+			}
+		}
+	}
+	for _, note := range notes {
+		appendComment(schema, "$comment", note)
+	}
+}
+
+// attachComment adds the comment to the schema node as its description, or
+// to its $comment if it already has a description.
+func attachComment(schema *yaml.Node, comment string) {
+	text := commentText(comment)
+	if text == "" || schema.Kind != yaml.MappingNode {
+		return
+	}
+	if mappingValue(schema, "description") == nil {
+		appendComment(schema, "description", text)
+		return
+	}
+	appendComment(schema, "$comment", text)
+}
+
+// appendComment sets the string keyword of the mapping node to text, or
+// appends text on a new line to its existing value.
+func appendComment(n *yaml.Node, keyword, text string) {
+	if v := mappingValue(n, keyword); v != nil {
+		if v.Kind == yaml.ScalarNode {
+			v.Value += "\n" + text
+		}
+		return
+	}
+	n.Content = append(n.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: keyword},
+		&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: text},
+	)
+}
+
+// commentText returns the text of a YAML comment without the comment markers.
+func commentText(comment string) string {
+	var lines []string
+	for _, line := range strings.Split(comment, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(strings.TrimLeft(line, "#"))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
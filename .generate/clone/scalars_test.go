@@ -0,0 +1,87 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestNormalizeScalars(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want string
+	}{
+		{
+			name: "timestamp default",
+			spec: `{default: 2024-01-01}`,
+			want: `{"default":"2024-01-01"}`,
+		},
+		{
+			name: "string keywords",
+			spec: `{title: 1.10, description: true, required: [1, false]}`,
+			want: `{"description":"true","required":["1","false"],"title":"1.10"}`,
+		},
+		{
+			name: "string instances keep their text",
+			spec: `{type: string, enum: [1.10, 0755, true, 0x1F, 2024-01-01], default: 1.0, const: 010}`,
+			want: `{"const":"010","default":"1.0","enum":["1.10","0755","true","0x1F","2024-01-01"],"type":"string"}`,
+		},
+		{
+			name: "number instances",
+			spec: `{type: number, enum: [1.10, 2]}`,
+			want: `{"enum":[1.1,2],"type":"number"}`,
+		},
+		{
+			name: "string or integer instances",
+			spec: `{type: [string, integer], enum: [1, a]}`,
+			want: `{"enum":[1,"a"],"type":["string","integer"]}`,
+		},
+		{
+			name: "quoted scalars",
+			spec: `{type: string, enum: ["1.10", '0755']}`,
+			want: `{"enum":["1.10","0755"],"type":"string"}`,
+		},
+		{
+			name: "YAML 1.1 booleans are strings",
+			spec: `{type: boolean, enum: [on, no, true]}`,
+			want: `{"enum":["on","no",true],"type":"boolean"}`,
+		},
+		{
+			name: "subschemas",
+			spec: `{properties: {a: {type: string, const: 1.10}, title: {type: string}}, items: [{type: string, examples: [1.20]}], definitions: {b: {title: 2}}}`,
+			want: `{"definitions":{"b":{"title":"2"}},"items":[{"examples":["1.20"],"type":"string"}],"properties":{"a":{"const":"1.10","type":"string"},"title":{"type":"string"}}}`,
+		},
+		{
+			name: "object instances are unchanged",
+			spec: `{type: string, default: {a: 1.10}}`,
+			want: `{"default":{"a":1.1},"type":"string"}`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(tc.spec), &doc); err != nil {
+				t.Fatal(err)
+			}
+			normalizeScalars(doc.Content[0])
+
+			var v any
+			if err := doc.Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("got  %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+	"gopkg.in/yaml.v3"
+
+	"github.com/andrewkroh/package-spec-schema/internal/metaschema"
+)
+
+// specFileSchema is the schema of the .spec.yml files of package-spec.
+//
+//go:embed spec-file.jsonschema.json
+var specFileSchema []byte
+
+// specFileProblem is a violation of the spec file schema.
+type specFileProblem struct {
+	Pointer string // JSON pointer to the invalid value.
+	Line    int    // Line of the invalid value in the spec file.
+	Message string
+}
+
+// specFileValidator validates spec files against the spec file schema and
+// locates the invalid value so that it can be reported with its line.
+type specFileValidator struct {
+	id       string                          // $id of the spec file schema.
+	doc      any                             // Decoded spec file schema.
+	resolved map[string]*jsonschema.Resolved // Resolved schemas keyed by URI.
+}
+
+func newSpecFileValidator() (*specFileValidator, error) {
+	v := &specFileValidator{resolved: map[string]*jsonschema.Resolved{}}
+	if err := json.Unmarshal(specFileSchema, &v.doc); err != nil {
+		return nil, fmt.Errorf("failed to decode spec file schema: %w", err)
+	}
+	v.id, _ = v.doc.(map[string]any)["$id"].(string)
+	return v, nil
+}
+
+// validateSpecFiles validates the spec files against the spec file schema
+// if enabled by -spec-metaschema. Depending on the mode, violations are
+// returned or logged as warnings.
+func validateSpecFiles(ver string, files map[string][]byte) error {
+	if specMetaschemaMode == metaschemaOff {
+		return nil
+	}
+	v, err := newSpecFileValidator()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		p, err := v.validate(files[name])
+		if err != nil {
+			return fmt.Errorf("failed validating %s: %w", name, err)
+		}
+		if p == nil {
+			continue
+		}
+		if specMetaschemaMode == metaschemaWarn {
+			slog.Warn("Spec file does not conform to the spec file schema.", "version", ver, "file", name, "line", p.Line, "pointer", p.Pointer, "error", p.Message)
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s:%d: #%s: %s", name, p.Line, p.Pointer, p.Message))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid spec files in %s: %w", ver, errors.Join(errs...))
+	}
+	return nil
+}
+
+// validate validates the YAML spec file in data. It returns nil if the file
+// is valid.
+func (v *specFileValidator) validate(data []byte) (*specFileProblem, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("failed to decode YAML: %w", err)
+	}
+	instance, err := jsonValue(&node)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := v.resolve(v.id)
+	if err != nil {
+		return nil, err
+	}
+	verr := root.Validate(instance)
+	if verr == nil {
+		return nil, nil
+	}
+
+	ptr, verr, err := v.locate(instance, "", "", verr)
+	if err != nil {
+		return nil, err
+	}
+	return &specFileProblem{Pointer: ptr, Line: nodeLine(&node, ptr), Message: trimValidating(verr)}, nil
+}
+
+// locate returns the pointer to the deepest value of instance, which is at
+// instancePtr, that is invalid on its own, and its error. The schemaPtr
+// points to the schema of the instance within the spec file schema.
+func (v *specFileValidator) locate(instance any, instancePtr, schemaPtr string, verr error) (string, error, error) {
+	schema, schemaPtr := v.schemaAt(schemaPtr)
+	if ref, ok := schema["$ref"].(string); ok {
+		// The only remote reference is the dialect metaschema.
+		return v.locateSchema(instance, instancePtr, ref, verr)
+	}
+
+	for key, child := range children(instance) {
+		var childSchema string
+		switch instance.(type) {
+		case map[string]any:
+			props, _ := schema["properties"].(map[string]any)
+			if _, found := props[key]; found {
+				childSchema = schemaPtr + "/properties/" + escapePointer(key)
+			} else if _, ok := schema["additionalProperties"].(map[string]any); ok {
+				childSchema = schemaPtr + "/additionalProperties"
+			}
+		case []any:
+			if _, ok := schema["items"].(map[string]any); ok {
+				childSchema = schemaPtr + "/items"
+			}
+		}
+		if childSchema == "" {
+			continue
+		}
+
+		rs, err := v.resolve(v.id + "#" + childSchema)
+		if err != nil {
+			return "", nil, err
+		}
+		if cerr := rs.Validate(child); cerr != nil {
+			return v.locate(child, instancePtr+"/"+escapePointer(key), childSchema, cerr)
+		}
+	}
+	return instancePtr, verr, nil
+}
+
+var (
+	// schemaKeywords are the keywords of a JSON Schema whose value is a schema.
+	schemaKeywords = []string{
+		"additionalProperties", "contains", "else", "if", "items", "not",
+		"propertyNames", "then", "unevaluatedItems", "unevaluatedProperties",
+	}
+	// schemasKeywords are the keywords of a JSON Schema whose value is an
+	// array or a map of schemas.
+	schemasKeywords = []string{
+		"$defs", "allOf", "anyOf", "dependentSchemas", "oneOf",
+		"patternProperties", "prefixItems", "properties",
+	}
+)
+
+// walkSchemas calls visit for the schema and each of its subschemas along
+// with their JSON pointers. It also descends into the draft-07 definitions
+// and additionalItems keywords. Subschemas are visited after their parent, so
+// visit may modify the keywords of the schema.
+func walkSchemas(schema any, ptr string, visit func(schema map[string]any, ptr string)) {
+	switch s := schema.(type) {
+	case []any:
+		// Draft-07 items may be an array of schemas.
+		for i, item := range s {
+			walkSchemas(item, ptr+"/"+strconv.Itoa(i), visit)
+		}
+	case map[string]any:
+		visit(s, ptr)
+		for _, keyword := range slices.Sorted(maps.Keys(s)) {
+			keywordPtr := ptr + "/" + escapePointer(keyword)
+			switch {
+			case slices.Contains(schemaKeywords, keyword), keyword == "additionalItems":
+				walkSchemas(s[keyword], keywordPtr, visit)
+			case slices.Contains(schemasKeywords, keyword), keyword == "definitions":
+				for key, subschema := range children(s[keyword]) {
+					walkSchemas(subschema, keywordPtr+"/"+escapePointer(key), visit)
+				}
+			}
+		}
+	}
+}
+
+// locateSchema is like locate for an instance that is a schema validated by
+// the dialect metaschema at metaURI. It descends into the subschemas of the
+// instance.
+func (v *specFileValidator) locateSchema(instance any, instancePtr, metaURI string, verr error) (string, error, error) {
+	obj, ok := instance.(map[string]any)
+	if !ok {
+		return instancePtr, verr, nil
+	}
+	meta, err := v.resolve(metaURI)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, keyword := range slices.Sorted(maps.Keys(obj)) {
+		keywordPtr := instancePtr + "/" + escapePointer(keyword)
+		var subschemas map[string]any // Keyed by pointer.
+		switch {
+		case slices.Contains(schemaKeywords, keyword):
+			subschemas = map[string]any{keywordPtr: obj[keyword]}
+		case slices.Contains(schemasKeywords, keyword):
+			subschemas = map[string]any{}
+			for key, value := range children(obj[keyword]) {
+				subschemas[keywordPtr+"/"+escapePointer(key)] = value
+			}
+		}
+		for _, ptr := range slices.Sorted(maps.Keys(subschemas)) {
+			if cerr := meta.Validate(subschemas[ptr]); cerr != nil {
+				return v.locateSchema(subschemas[ptr], ptr, metaURI, cerr)
+			}
+		}
+	}
+	return instancePtr, verr, nil
+}
+
+// schemaAt returns the object at the pointer within the spec file schema,
+// following local references, and its pointer.
+func (v *specFileValidator) schemaAt(ptr string) (map[string]any, string) {
+	for {
+		node := v.doc
+		for _, token := range strings.Split(ptr, "/")[1:] {
+			token = unescapePointer(token)
+			m, _ := node.(map[string]any)
+			node = m[token]
+		}
+		schema, _ := node.(map[string]any)
+		ref, _ := schema["$ref"].(string)
+		if !strings.HasPrefix(ref, "#") {
+			return schema, ptr
+		}
+		ptr = ref[1:]
+	}
+}
+
+// resolve returns the resolved schema at uri, which is either within the
+// spec file schema or an embedded metaschema.
+func (v *specFileValidator) resolve(uri string) (*jsonschema.Resolved, error) {
+	if rs, found := v.resolved[uri]; found {
+		return rs, nil
+	}
+	s := &jsonschema.Schema{Schema: "https://json-schema.org/draft/2020-12/schema", Ref: uri}
+	rs, err := s.Resolve(&jsonschema.ResolveOptions{Loader: v.load})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", uri, err)
+	}
+	v.resolved[uri] = rs
+	return rs, nil
+}
+
+// load is a jsonschema.Loader that reads the spec file schema and the
+// embedded metaschemas.
+func (v *specFileValidator) load(u *url.URL) (*jsonschema.Schema, error) {
+	if u.String() != v.id {
+		return metaschema.Load(u)
+	}
+	s := new(jsonschema.Schema)
+	if err := json.Unmarshal(specFileSchema, s); err != nil {
+		return nil, fmt.Errorf("failed to decode spec file schema: %w", err)
+	}
+	return s, nil
+}
+
+// children returns the keys and values of a JSON object or array. Array
+// indexes are returned as decimal strings.
+func children(instance any) func(yield func(string, any) bool) {
+	return func(yield func(string, any) bool) {
+		switch x := instance.(type) {
+		case map[string]any:
+			for _, k := range slices.Sorted(maps.Keys(x)) {
+				if !yield(k, x[k]) {
+					return
+				}
+			}
+		case []any:
+			for i, item := range x {
+				if !yield(strconv.Itoa(i), item) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// jsonValue decodes the YAML node into the JSON data model.
+func jsonValue(node *yaml.Node) (any, error) {
+	var v any
+	if err := node.Decode(&v); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// nodeLine returns the line of the value at the JSON pointer in the YAML
+// document. Values of objects are located by the line of their key. It
+// returns the line of the deepest value found if the pointer does not exist.
+func nodeLine(doc *yaml.Node, ptr string) int {
+	node := doc
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	line := node.Line
+	for _, token := range strings.Split(ptr, "/")[1:] {
+		token = unescapePointer(token)
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == token {
+					line = node.Content[i].Line
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if i, err := strconv.Atoi(token); err == nil && i < len(node.Content) {
+				next = node.Content[i]
+				line = next.Line
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return line
+}
+
+var validatingPrefix = regexp.MustCompile(`^(validating \S+: )+`)
+
+// escapePointer escapes a JSON pointer reference token.
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// unescapePointer unescapes a JSON pointer reference token.
+func unescapePointer(token string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+}
+
+// trimValidating removes the schema locations that prefix validation errors.
+func trimValidating(err error) string {
+	return validatingPrefix.ReplaceAllString(err.Error(), "")
+}
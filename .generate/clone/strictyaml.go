@@ -0,0 +1,52 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// checkStrictYAML reports the constructs of a YAML document whose decoding is
+// lenient or ambiguous: duplicate mapping keys, keys that are not strings,
+// and aliases that do not resolve to an anchor.
+func checkStrictYAML(node *yaml.Node) error {
+	var errs []error
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		switch n.Kind {
+		case yaml.AliasNode:
+			if n.Alias == nil {
+				errs = append(errs, fmt.Errorf("line %d: alias *%s does not refer to an anchor", n.Line, n.Value))
+			}
+			// The anchored node is checked where it is defined.
+			return
+		case yaml.MappingNode:
+			keys := map[string]int{} // Line of each key.
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := n.Content[i]
+				switch {
+				case key.ShortTag() == "!!merge":
+					// Merge keys insert the keys of another mapping.
+				case key.Kind != yaml.ScalarNode || key.ShortTag() != "!!str":
+					errs = append(errs, fmt.Errorf("line %d: mapping key %q is not a string (%s)", key.Line, key.Value, key.ShortTag()))
+				default:
+					if line, found := keys[key.Value]; found {
+						errs = append(errs, fmt.Errorf("line %d: duplicate mapping key %q, first defined at line %d", key.Line, key.Value, line))
+						continue
+					}
+					keys[key.Value] = key.Line
+				}
+			}
+		}
+		for _, c := range n.Content {
+			walk(c)
+		}
+	}
+	walk(node)
+	return errors.Join(errs...)
+}
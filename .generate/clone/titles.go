@@ -0,0 +1,109 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// acronyms are the words of names that are written in upper case in titles.
+var acronyms = map[string]string{
+	"api": "API", "cpu": "CPU", "csv": "CSV", "ecs": "ECS", "fips": "FIPS",
+	"html": "HTML", "http": "HTTP", "id": "ID", "ids": "IDs", "ilm": "ILM",
+	"ip": "IP", "json": "JSON", "kv": "KV", "ml": "ML", "pid": "PID",
+	"slo": "SLO", "ssl": "SSL", "tf": "TF", "tls": "TLS", "ui": "UI", "uri": "URI",
+	"url": "URL",
+}
+
+// packageTypes are the directories of the spec that contain the files of a
+// package type.
+var packageTypes = []string{"content", "input", "integration"}
+
+var wordSeparatorRegex = regexp.MustCompile(`[_\-. ]+|([a-z0-9])([A-Z])`)
+
+// titleWords splits a file or property name into the words of its title.
+func titleWords(name string) []string {
+	name = wordSeparatorRegex.ReplaceAllString(name, "$1 $2")
+	words := strings.Fields(name)
+	for i, w := range words {
+		if acronym, found := acronyms[strings.ToLower(w)]; found {
+			words[i] = acronym
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(w)
+		words[i] = string(unicode.ToUpper(r)) + w[size:]
+	}
+	return words
+}
+
+// fileTitle derives the title of the root schema of a file from its path,
+// e.g. "Data Stream Manifest" for integration/data_stream/manifest.jsonschema.json.
+// The title names the file and the directory containing it, leaving out the
+// _dev directories and the package type when there is a more specific
+// directory.
+func fileTitle(relativePath string) string {
+	var segments []string
+	for _, s := range strings.Split(strings.TrimSuffix(relativePath, ".jsonschema.json"), "/") {
+		if s == "_dev" || (len(segments) > 0 && segments[len(segments)-1] == s) {
+			continue
+		}
+		segments = append(segments, s)
+	}
+	if len(segments) > 2 && slices.Contains(packageTypes, segments[0]) {
+		segments = segments[1:]
+	}
+
+	words := titleWords(segments[len(segments)-1])
+	if len(segments) > 1 {
+		parent := titleWords(segments[len(segments)-2])
+		// Omit a directory that the file name already contains, like
+		// agent in agent/custom-agent.
+		if !slices.ContainsFunc(parent, func(w string) bool { return !slices.Contains(words, w) }) {
+			return strings.Join(words, " ")
+		}
+		words = append(parent, words...)
+	}
+	return strings.Join(words, " ")
+}
+
+// addTitles adds a title to the schema and to the subschemas of its
+// properties and definitions that have none. The schema is titled with title
+// and the subschemas with their property or definition name. Schemas with a
+// $ref are not titled so that editors show the title of the referenced
+// schema.
+func addTitles(schema any, title string) {
+	switch s := schema.(type) {
+	case []any:
+		// Draft-07 items may be an array of schemas.
+		for _, item := range s {
+			addTitles(item, "")
+		}
+	case map[string]any:
+		_, hasTitle := s["title"]
+		_, hasRef := s["$ref"]
+		if title != "" && !hasTitle && !hasRef {
+			s["title"] = title
+		}
+		for keyword, value := range s {
+			switch {
+			case keyword == "properties" || keyword == "$defs" || keyword == "definitions":
+				subschemas, _ := value.(map[string]any)
+				for name, subschema := range subschemas {
+					addTitles(subschema, strings.Join(titleWords(name), " "))
+				}
+			case slices.Contains(schemaKeywords, keyword), keyword == "additionalItems":
+				addTitles(value, "")
+			case slices.Contains(schemasKeywords, keyword):
+				for _, subschema := range children(value) {
+					addTitles(subschema, "")
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/andrewkroh/package-spec-schema/internal/metaschema"
+)
+
+// Values of the -metaschema flag.
+const (
+	metaschemaFail = "fail" // Fail the run on violations.
+	metaschemaWarn = "warn" // Log a warning for each violation.
+	metaschemaOff  = "off"  // Skip validation.
+)
+
+var metaschemas = metaschema.New()
+
+// validateSchemas validates each converted schema against the metaschema of
+// its dialect so that invalid upstream specs are detected before they reach
+// consumers. Depending on -metaschema, violations are returned, logged as
+// warnings, or not checked at all.
+func validateSchemas(ver string, files []schemaFile) error {
+	if metaschemaMode == metaschemaOff {
+		return nil
+	}
+
+	var errs []error
+	for _, f := range files {
+		err := metaschemas.Validate(f.Data)
+		if err == nil {
+			continue
+		}
+		if metaschemaMode == metaschemaWarn {
+			slog.Warn("Schema does not conform to its metaschema.", "version", ver, "path", f.Path, "error", err)
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%s does not conform to its metaschema: %w", f.Path, err))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid schemas in %s: %w", ver, errors.Join(errs...))
+	}
+	return nil
+}
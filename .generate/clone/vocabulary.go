@@ -0,0 +1,119 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// vocabularyFile is the metaschema written into each jsonschema directory
+// that describes the extension keywords of the schemas.
+const vocabularyFile = "vocabulary.jsonschema.json"
+
+// draft202012 is the dialect that supports declaring vocabularies.
+const draft202012 = "https://json-schema.org/draft/2020-12/schema"
+
+// customKeyword is a non-standard keyword used by the upstream specs.
+type customKeyword struct {
+	Keyword     string
+	Description string
+	Schema      *jsonschema.Schema // Schema of the keyword value.
+}
+
+// customKeywords are the non-standard keywords of the upstream specs. They are
+// renamed to extension keywords so that strict validators do not reject them.
+var customKeywords = []customKeyword{
+	{
+		Keyword:     "example",
+		Description: "Example value of the instance. It is equivalent to a single item of the standard examples keyword.",
+		Schema:      &jsonschema.Schema{},
+	},
+	{
+		Keyword:     "minContent",
+		Description: "Minimum number of items of the instance. Validators do not enforce it.",
+		Schema:      &jsonschema.Schema{Type: "integer", Minimum: jsonschema.Ptr(0.0)},
+	},
+	{
+		Keyword:     "min_items",
+		Description: "Minimum number of items of the instance, misspelling minItems. Validators do not enforce it.",
+		Schema:      &jsonschema.Schema{Type: "integer", Minimum: jsonschema.Ptr(0.0)},
+	},
+}
+
+// extensionKeyword returns the extension keyword that replaces a custom
+// keyword.
+func extensionKeyword(keyword string) string {
+	return "x-" + keyword
+}
+
+// renameCustomKeywords renames the custom keywords of the schema and its
+// subschemas to their extension keywords. Each renamed keyword is recorded in
+// renamed. Values that are not schemas, like the property names under
+// properties or the values of enum, are left unchanged.
+func renameCustomKeywords(schema any, renamed map[string]string) {
+	walkSchemas(schema, "", func(s map[string]any, _ string) {
+		for _, k := range customKeywords {
+			value, found := s[k.Keyword]
+			if !found {
+				continue
+			}
+			delete(s, k.Keyword)
+			s[extensionKeyword(k.Keyword)] = value
+			renamed[k.Keyword] = extensionKeyword(k.Keyword)
+		}
+	})
+}
+
+// vocabularySchema returns the metaschema that describes the extension
+// keywords in renamed. Schemas of the 2020-12 dialect declare the extension
+// keywords as an optional vocabulary that validators may ignore.
+func vocabularySchema(renamed map[string]string, idPath string) ([]byte, error) {
+	id, err := schemaID(idPath, vocabularyFile)
+	if err != nil {
+		return nil, err
+	}
+	s := &jsonschema.Schema{
+		Schema:      dialect,
+		ID:          id,
+		Title:       "Package Spec Extension Keywords",
+		Description: "Metaschema of the extension keywords that replace the custom keywords of the package-spec schemas.",
+		AllOf:       []*jsonschema.Schema{{Ref: dialect}},
+		Properties:  map[string]*jsonschema.Schema{},
+	}
+	if dialect == draft202012 {
+		vocabularyURI, err := schemaID("vocab", "extensions")
+		if err != nil {
+			return nil, err
+		}
+		s.DynamicAnchor = "meta"
+		s.Vocabulary = map[string]bool{
+			"https://json-schema.org/draft/2020-12/vocab/core":              true,
+			"https://json-schema.org/draft/2020-12/vocab/applicator":        true,
+			"https://json-schema.org/draft/2020-12/vocab/unevaluated":       true,
+			"https://json-schema.org/draft/2020-12/vocab/validation":        true,
+			"https://json-schema.org/draft/2020-12/vocab/meta-data":         true,
+			"https://json-schema.org/draft/2020-12/vocab/format-annotation": true,
+			"https://json-schema.org/draft/2020-12/vocab/content":           true,
+			vocabularyURI: false,
+		}
+	}
+	for _, k := range customKeywords {
+		ext, found := renamed[k.Keyword]
+		if !found {
+			continue
+		}
+		keyword := *k.Schema
+		keyword.Description = k.Description
+		s.Properties[ext] = &keyword
+	}
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,128 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"maps"
+	"reflect"
+	"testing"
+)
+
+func TestRenameCustomKeywords(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		want    string
+		renamed map[string]string
+	}{
+		{
+			name:    "root keyword",
+			schema:  `{"type": "string", "example": "foo"}`,
+			want:    `{"type": "string", "x-example": "foo"}`,
+			renamed: map[string]string{"example": "x-example"},
+		},
+		{
+			name: "nested subschemas",
+			schema: `{
+				"properties": {"a": {"type": "array", "min_items": 1, "items": {"minContent": 2}}},
+				"definitions": {"b": {"example": 1}},
+				"allOf": [{"example": 2}]
+			}`,
+			want: `{
+				"properties": {"a": {"type": "array", "x-min_items": 1, "items": {"x-minContent": 2}}},
+				"definitions": {"b": {"x-example": 1}},
+				"allOf": [{"x-example": 2}]
+			}`,
+			renamed: map[string]string{"example": "x-example", "minContent": "x-minContent", "min_items": "x-min_items"},
+		},
+		{
+			name: "property names and values are not keywords",
+			schema: `{
+				"properties": {"example": {"type": "string"}},
+				"required": ["example"],
+				"enum": [{"example": 1}],
+				"default": {"min_items": 1}
+			}`,
+			want: `{
+				"properties": {"example": {"type": "string"}},
+				"required": ["example"],
+				"enum": [{"example": 1}],
+				"default": {"min_items": 1}
+			}`,
+			renamed: map[string]string{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var schema, want any
+			if err := json.Unmarshal([]byte(tc.schema), &schema); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tc.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			renamed := map[string]string{}
+			renameCustomKeywords(schema, renamed)
+			if !reflect.DeepEqual(schema, want) {
+				got, _ := json.Marshal(schema)
+				t.Errorf("schema = %s", got)
+			}
+			if !maps.Equal(renamed, tc.renamed) {
+				t.Errorf("renamed = %v, want %v", renamed, tc.renamed)
+			}
+		})
+	}
+}
+
+func TestPatchSchemaExtensionKeywords(t *testing.T) {
+	defer func(old bool) { extensionKeywords = old }(extensionKeywords)
+
+	for _, enabled := range []bool{false, true} {
+		extensionKeywords = enabled
+		spec := map[string]any{"type": "string", "example": "foo"}
+		renamed := map[string]string{}
+		if err := patchSchema("3.6.0", "manifest.spec.yml", spec, renamed); err != nil {
+			t.Fatal(err)
+		}
+		_, renamedKeyword := spec["x-example"]
+		_, kept := spec["example"]
+		if renamedKeyword != enabled || kept == enabled || (len(renamed) > 0) != enabled {
+			t.Errorf("extensionKeywords=%v: schema %v, renamed %v", enabled, spec, renamed)
+		}
+	}
+}
+
+func TestVocabularySchema(t *testing.T) {
+	defer func(old string) { dialect = old }(dialect)
+
+	for _, d := range []string{draft202012, "http://json-schema.org/draft-07/schema#"} {
+		t.Run(d, func(t *testing.T) {
+			dialect = d
+			b, err := vocabularySchema(map[string]string{"example": "x-example"}, "3.6.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var s struct {
+				ID         string                     `json:"$id"`
+				Vocabulary map[string]bool            `json:"$vocabulary"`
+				Properties map[string]json.RawMessage `json:"properties"`
+			}
+			if err := json.Unmarshal(b, &s); err != nil {
+				t.Fatal(err)
+			}
+			if want := "https://schemas.elastic.dev/package-spec/3.6.0/vocabulary.jsonschema.json"; s.ID != want {
+				t.Errorf("$id = %q, want %q", s.ID, want)
+			}
+			if len(s.Properties) != 1 || s.Properties["x-example"] == nil {
+				t.Errorf("properties = %v, want only x-example", s.Properties)
+			}
+			required, declared := s.Vocabulary["https://schemas.elastic.dev/package-spec/vocab/extensions"]
+			if declared != (d == draft202012) || required {
+				t.Errorf("$vocabulary = %v", s.Vocabulary)
+			}
+		})
+	}
+}
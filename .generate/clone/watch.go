@@ -0,0 +1,96 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+// watchRemotes periodically fetches the remotes and generates release tags
+// whose version directory does not exist yet, running the post hook after
+// each poll that generated new versions. A failed poll is logged and retried
+// at the next interval. It returns when interrupted.
+func watchRemotes() error {
+	hook := strings.Fields(postHook)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	slog.Info("Watching for new release tags.", "interval", interval)
+	for {
+		// With -keep-going the versions generated before a failure are
+		// returned along with the error.
+		generated, err := generateRemotes(true)
+		if err != nil {
+			slog.Error("Failed to generate new release tags.", "error", err)
+		}
+		switch {
+		case len(generated) == 0:
+			if err == nil {
+				slog.Info("No new release tags.")
+			}
+		default:
+			slog.Info("Generated new release tags.", "versions", generated)
+			if len(hook) > 0 {
+				if err := runPostHook(ctx, hook, generated); err != nil {
+					slog.Error("Post hook failed.", "error", err)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("Stopped watching.")
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// versionExists reports whether the schemas of version were already
+// generated into dir.
+func versionExists(dir, version string) bool {
+	_, err := os.Stat(filepath.Join(dir, version, "jsonschema"))
+	return err == nil
+}
+
+// runPostHook runs the hook command with the paths of the generated version
+// directories appended as arguments.
+func runPostHook(ctx context.Context, hook, versions []string) error {
+	args := slices.Clone(hook[1:])
+	for _, v := range versions {
+		args = append(args, filepath.Join(outDir, v))
+	}
+
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, hook[0], args...)
+	out := new(bytes.Buffer)
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return ctx.Err()
+		}
+		return fmt.Errorf("failed running %s: %w: %s", strings.Join(hook, " "), err, strings.TrimSpace(out.String()))
+	}
+	slog.Log(ctx, logging.LevelTrace, "Post hook output.", "output", strings.TrimSpace(out.String()))
+	slog.Info("Ran post hook.", "command", strings.Join(hook, " "), "duration", time.Since(start))
+	return nil
+}
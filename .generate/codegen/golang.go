@@ -0,0 +1,217 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+)
+
+// generateGo returns a Go source file declaring the types of the model.
+// Properties become struct fields with json and yaml tags, and string enums
+// become named string types with a constant per value.
+func generateGo(m *model, version string) ([]byte, error) {
+	g := &goGenerator{idents: map[string]bool{}}
+	for _, t := range m.Types {
+		g.idents[t.Name] = true
+	}
+
+	fmt.Fprintf(&g.buf, "// Code generated by codegen from package-spec %s. DO NOT EDIT.\n\n", version)
+	fmt.Fprintf(&g.buf, "// Package %s contains the types of package-spec %s packages.\n", packageName, version)
+	fmt.Fprintf(&g.buf, "package %s\n", packageName)
+
+	// Root types come first, followed by the types that they use.
+	for _, t := range m.Roots {
+		g.writeType(t)
+	}
+	for _, t := range m.Types {
+		if !isRoot(m, t) {
+			g.writeType(t)
+		}
+	}
+
+	src, err := format.Source([]byte(g.buf.String()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated Go code: %w", err)
+	}
+	return src, nil
+}
+
+func isRoot(m *model, t *namedType) bool {
+	for _, r := range m.Roots {
+		if r == t {
+			return true
+		}
+	}
+	return false
+}
+
+type goGenerator struct {
+	buf    strings.Builder
+	idents map[string]bool // Package level identifiers that are taken.
+}
+
+func (g *goGenerator) writeType(t *namedType) {
+	g.buf.WriteString("\n")
+	writeComment(&g.buf, "", fmt.Sprintf("%s is generated from %s.", t.Name, t.Source))
+	if t.Description != "" {
+		g.buf.WriteString("//\n")
+		writeComment(&g.buf, "", t.Description)
+	}
+
+	switch {
+	case t.Alias != nil:
+		fmt.Fprintf(&g.buf, "type %s %s\n", t.Name, goType(t.Alias, true))
+	case len(t.Enum) > 0:
+		fmt.Fprintf(&g.buf, "type %s string\n\n", t.Name)
+		fmt.Fprintf(&g.buf, "// Values of %s.\nconst (\n", t.Name)
+		for _, v := range t.Enum {
+			fmt.Fprintf(&g.buf, "\t%s %s = %s\n", g.enumConst(t.Name, v), t.Name, strconv.Quote(v))
+		}
+		g.buf.WriteString(")\n")
+	default:
+		fmt.Fprintf(&g.buf, "type %s struct {\n", t.Name)
+		names := map[string]bool{}
+		for i, f := range t.Fields {
+			if i > 0 && (f.Description != "" || f.Deprecated) {
+				g.buf.WriteString("\n")
+			}
+			if f.Description != "" {
+				writeComment(&g.buf, "\t", f.Description)
+			}
+			if f.Deprecated {
+				if f.Description != "" {
+					g.buf.WriteString("\t//\n")
+				}
+				writeComment(&g.buf, "\t", "Deprecated: This property is deprecated by the package-spec.")
+			}
+			tag := f.Name
+			if !f.Required {
+				tag += ",omitempty"
+			}
+			fmt.Fprintf(&g.buf, "\t%s %s `json:%q yaml:%q`\n", uniqueIdent(names, goFieldName(f.Name)), goType(f.Type, f.Required), tag, tag)
+		}
+		g.buf.WriteString("}\n")
+	}
+}
+
+// enumConst returns a unique name for the constant of an enum value.
+func (g *goGenerator) enumConst(typeName, value string) string {
+	name := pascalCase(value)
+	if name == "" {
+		name = "Empty"
+	}
+	return uniqueIdent(g.idents, typeName+name)
+}
+
+// goType returns the Go type expression of t. Optional structs and booleans
+// are pointers so that absent values can be told apart from zero values.
+func goType(t *typeRef, required bool) string {
+	switch t.Kind {
+	case kindString:
+		return "string"
+	case kindInteger:
+		return "int"
+	case kindNumber:
+		return "float64"
+	case kindBoolean:
+		if !required {
+			return "*bool"
+		}
+		return "bool"
+	case kindArray:
+		return "[]" + goType(t.Elem, true)
+	case kindMap:
+		return "map[string]" + goType(t.Elem, true)
+	case kindObject:
+		return "map[string]any"
+	case kindNamed:
+		if t.Named.Struct && !required {
+			return "*" + t.Named.Name
+		}
+		return t.Named.Name
+	default:
+		return "any"
+	}
+}
+
+func goFieldName(prop string) string {
+	name := pascalCase(prop)
+	if name == "" {
+		return "Field"
+	}
+	return name
+}
+
+// uniqueIdent returns name, or name with a numeric suffix if it is taken,
+// and marks the result as taken.
+func uniqueIdent(taken map[string]bool, name string) string {
+	unique := name
+	for i := 2; taken[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	taken[unique] = true
+	return unique
+}
+
+// writeComment writes text as a line comment wrapped at 80 columns. The line
+// breaks of descriptions are not significant except between paragraphs and
+// before list items.
+func writeComment(sb *strings.Builder, indent, text string) {
+	for i, para := range paragraphs(text) {
+		if i > 0 {
+			sb.WriteString(indent + "//\n")
+		}
+		for _, line := range para {
+			for _, l := range wrap(line, 80-len(indent)-3) {
+				sb.WriteString(indent + "// " + l + "\n")
+			}
+		}
+	}
+}
+
+// paragraphs splits text into paragraphs of lines, where each list item
+// starts a new line and other lines are joined.
+func paragraphs(text string) [][]string {
+	var out [][]string
+	var para []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			if len(para) > 0 {
+				out = append(out, para)
+				para = nil
+			}
+		case len(para) == 0 || strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* "):
+			para = append(para, line)
+		default:
+			para[len(para)-1] += " " + line
+		}
+	}
+	if len(para) > 0 {
+		out = append(out, para)
+	}
+	return out
+}
+
+// wrap splits line into lines of at most width bytes where possible.
+func wrap(line string, width int) []string {
+	var out []string
+	var cur string
+	for _, word := range strings.Fields(line) {
+		if cur != "" && len(cur)+1+len(word) > width {
+			out = append(out, cur)
+			cur = ""
+		}
+		if cur != "" {
+			cur += " "
+		}
+		cur += word
+	}
+	return append(out, cur)
+}
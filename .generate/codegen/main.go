@@ -0,0 +1,168 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// codegen generates types for package manifests, data stream manifests, and
+// changelogs from the JSON schemas of a version so that tooling parsing
+// packages does not need to hand-maintain types that drift from the spec. Go
+// structs and TypeScript declarations are supported.
+//
+// Usage:
+//
+//	codegen [flags] go|ts
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+var (
+	outDir      string // Directory containing the versioned directories.
+	version     string // Version to generate types for.
+	outFile     string // File where the generated code is written.
+	destDir     string // Directory where code is generated for every version.
+	packageName string // Name of the generated Go package.
+)
+
+// generator produces source code from the model.
+type generator struct {
+	generate func(m *model, version string) ([]byte, error)
+	fileName string // Name of the file written per version with -d.
+}
+
+// generators keyed by language.
+var generators = map[string]generator{
+	"go": {generate: generateGo, fileName: "types.go"},
+	"ts": {generate: generateTypeScript, fileName: "types.d.ts"},
+}
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	flag.StringVar(&version, "version", "", "version to generate types for (defaults to the newest version)")
+	flag.StringVar(&outFile, "out", "", "file where the generated code is written (defaults to stdout)")
+	flag.StringVar(&destDir, "d", "", "directory where code is generated for every version into <version>/ (overrides -version and -out)")
+	flag.StringVar(&packageName, "package", "packagespec", "name of the generated Go package")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] go|ts\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+func run() error {
+	if flag.NArg() != 1 {
+		flag.Usage()
+		return errors.New("expected exactly one language argument")
+	}
+	gen, ok := generators[flag.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unsupported language %q", flag.Arg(0))
+	}
+
+	if destDir != "" {
+		versions, err := versionDirs(outDir)
+		if err != nil {
+			return err
+		}
+		for _, v := range versions {
+			if err := generateVersion(gen, v, filepath.Join(destDir, v, gen.fileName)); err != nil {
+				return fmt.Errorf("failed generating %s: %w", v, err)
+			}
+		}
+		return nil
+	}
+
+	if version == "" {
+		versions, err := versionDirs(outDir)
+		if err != nil {
+			return err
+		}
+		version = versions[len(versions)-1]
+	}
+	if _, err := semver.NewVersion(version); err != nil {
+		return fmt.Errorf("invalid -version: %w", err)
+	}
+	return generateVersion(gen, version, outFile)
+}
+
+// generateVersion writes the code generated for version to path, or to
+// stdout if path is empty.
+func generateVersion(gen generator, version, path string) error {
+	m, err := buildModel(filepath.Join(outDir, version, "jsonschema"))
+	if err != nil {
+		return err
+	}
+	src, err := gen.generate(m, version)
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		_, err := os.Stdout.Write(src)
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	changed, err := fsutil.WriteFileIfChanged(path, src)
+	if err != nil {
+		return err
+	}
+	slog.Info("Generated types.", "language", flag.Arg(0), "version", version, "path", path, "types", len(m.Types), "changed", changed)
+	return nil
+}
+
+// versionDirs returns the non-prerelease version directories containing
+// JSON schemas in ascending order.
+func versionDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var versions []*semver.Version
+	for _, e := range entries {
+		v, err := semver.NewVersion(e.Name())
+		if !e.IsDir() || err != nil || v.PreRelease != "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, e.Name(), "jsonschema")); err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no version directories found in %s", dir)
+	}
+	semver.Sort(versions)
+
+	out := make([]string, len(versions))
+	for i, v := range versions {
+		out[i] = v.String()
+	}
+	return out, nil
+}
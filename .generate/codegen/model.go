@@ -0,0 +1,731 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// kind is the kind of a type in the language independent model.
+type kind int
+
+const (
+	kindAny kind = iota
+	kindString
+	kindInteger
+	kindNumber
+	kindBoolean
+	kindArray  // Elem is the item type.
+	kindMap    // Elem is the value type, keys are strings.
+	kindNamed  // Named is a struct or enum.
+	kindObject // An object without known properties.
+)
+
+// typeRef is a reference to a type.
+type typeRef struct {
+	Kind  kind
+	Elem  *typeRef
+	Named *namedType
+}
+
+// namedType is a struct, an enum, or an alias of another type.
+type namedType struct {
+	Name        string
+	Source      string // Schema location that the type was generated from.
+	Description string
+
+	Fields []*field // Fields of a struct.
+	Enum   []string // Values of a string enum.
+	Alias  *typeRef // Aliased type, e.g. of a root array schema.
+	Struct bool     // Whether the type is a struct, even without fields.
+
+	hints []string // Candidate names in order of preference.
+}
+
+// field is a property of a struct.
+type field struct {
+	Name        string // Property name.
+	Type        *typeRef
+	Required    bool
+	Description string
+	Deprecated  bool
+}
+
+// root is a schema for which a top-level type is generated.
+type root struct {
+	Paths []string // Paths relative to the jsonschema directory, the first that exists is used.
+	Name  string   // Type name.
+	Item  string   // Type name of the items of an array schema.
+}
+
+// roots are the schemas that types are generated for. Roots that do not
+// exist in a version are skipped. Versions before 2.0.0 only describe
+// integration packages, whose schemas are not within an integration
+// directory.
+var roots = []root{
+	{Paths: []string{"integration/manifest.jsonschema.json", "manifest.jsonschema.json"}, Name: "IntegrationManifest"},
+	{Paths: []string{"integration/data_stream/manifest.jsonschema.json", "data_stream/manifest.jsonschema.json"}, Name: "DataStreamManifest"},
+	{Paths: []string{"integration/changelog.jsonschema.json", "changelog.jsonschema.json"}, Name: "Changelog", Item: "ChangelogEntry"},
+	{Paths: []string{"input/manifest.jsonschema.json"}, Name: "InputManifest"},
+	{Paths: []string{"content/manifest.jsonschema.json"}, Name: "ContentManifest"},
+}
+
+// model is the set of types generated from the schemas of a version.
+type model struct {
+	Roots []*namedType
+	Types []*namedType // Every named type in order of creation.
+}
+
+// builder converts schemas to the model.
+type builder struct {
+	dir   string
+	docs  map[string]any        // Decoded schema files keyed by slash-separated path.
+	named map[string]*namedType // Types generated from a $ref target keyed by location.
+	types []*namedType
+}
+
+// buildModel generates the types of the root schemas in dir.
+func buildModel(dir string) (*model, error) {
+	b := &builder{dir: dir, docs: map[string]any{}, named: map[string]*namedType{}}
+	m := &model{}
+	for _, r := range roots {
+		file, doc, err := b.loadFirst(r.Paths)
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			continue
+		}
+
+		node, _ := doc.(map[string]any)
+		ref, err := b.typeOf(file, "", node, r.Name, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed generating types of %s: %w", file, err)
+		}
+		t := ref.Named
+		if ref.Kind != kindNamed || !t.Struct {
+			t = b.newType(file, r.Name)
+			t.Description = description(node)
+			t.Alias = ref
+			if ref.Kind == kindArray && ref.Elem.Kind == kindNamed && r.Item != "" {
+				ref.Elem.Named.hints = []string{r.Item}
+			}
+		}
+		m.Roots = append(m.Roots, t)
+	}
+	if len(m.Roots) == 0 {
+		return nil, fmt.Errorf("no manifest schemas found in %s", dir)
+	}
+	m.Types = b.types
+	m.dedup()
+	m.assignNames()
+	return m, nil
+}
+
+// loadFirst loads the first of files that exists. It returns a nil document
+// if none exists.
+func (b *builder) loadFirst(files []string) (string, any, error) {
+	for _, file := range files {
+		doc, err := b.load(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		return file, doc, err
+	}
+	return "", nil, nil
+}
+
+func (b *builder) load(file string) (any, error) {
+	if doc, ok := b.docs[file]; ok {
+		return doc, nil
+	}
+	data, err := os.ReadFile(filepath.Join(b.dir, filepath.FromSlash(file)))
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", file, err)
+	}
+	b.docs[file] = doc
+	return doc, nil
+}
+
+// newType allocates a type generated from the schema at source. Its name is
+// assigned from the hints once every type is known.
+func (b *builder) newType(source string, hints ...string) *namedType {
+	t := &namedType{Source: source, hints: hints}
+	b.types = append(b.types, t)
+	return t
+}
+
+// typeOf returns the type of the schema node located at ptr in file. The
+// hint names a struct or enum generated for the node, and parent is the hint
+// of the enclosing type that qualifies the name if it is taken.
+func (b *builder) typeOf(file, ptr string, node map[string]any, hint, parent string) (*typeRef, error) {
+	if node == nil {
+		return &typeRef{Kind: kindAny}, nil
+	}
+	if ref, ok := node["$ref"].(string); ok {
+		return b.refType(file, ref, parent)
+	}
+
+	// A single subschema of allOf, anyOf, or oneOf is equivalent to the
+	// subschema itself.
+	for _, kw := range []string{"allOf", "anyOf", "oneOf"} {
+		if subs, ok := node[kw].([]any); ok && len(subs) == 1 && len(objectProperties(node)) == 0 {
+			if sub, ok := subs[0].(map[string]any); ok {
+				if _, hasType := node["type"]; !hasType {
+					return b.typeOf(file, ptr+"/"+kw+"/0", sub, hint, parent)
+				}
+			}
+		}
+	}
+	// Alternatives of different types can only be represented as any.
+	for _, kw := range []string{"anyOf", "oneOf"} {
+		if subs, ok := node[kw].([]any); ok && alternativeTypes(subs) {
+			return &typeRef{Kind: kindAny}, nil
+		}
+	}
+
+	switch schemaType(node) {
+	case "string":
+		values := stringEnum(node)
+		if len(values) == 0 {
+			return &typeRef{Kind: kindString}, nil
+		}
+		t := b.newType(location(file, ptr), typeHints(hint, parent)...)
+		t.Description = description(node)
+		t.Enum = values
+		return &typeRef{Kind: kindNamed, Named: t}, nil
+	case "integer":
+		return &typeRef{Kind: kindInteger}, nil
+	case "number":
+		return &typeRef{Kind: kindNumber}, nil
+	case "boolean":
+		return &typeRef{Kind: kindBoolean}, nil
+	case "array":
+		items, _ := node["items"].(map[string]any)
+		elem, err := b.typeOf(file, ptr+"/items", items, singular(hint), parent)
+		if err != nil {
+			return nil, err
+		}
+		return &typeRef{Kind: kindArray, Elem: elem}, nil
+	case "object":
+		return b.objectType(file, ptr, node, hint, parent)
+	default:
+		return &typeRef{Kind: kindAny}, nil
+	}
+}
+
+// objectType returns a struct for an object with properties, a map for an
+// object whose values share a schema, or a generic object.
+func (b *builder) objectType(file, ptr string, node map[string]any, hint, parent string) (*typeRef, error) {
+	props := objectProperties(node)
+	if len(props) == 0 {
+		values, valuesPtr := mapValues(node)
+		if values == nil {
+			return &typeRef{Kind: kindObject}, nil
+		}
+		elem, err := b.typeOf(file, ptr+valuesPtr, values, singular(hint), parent)
+		if err != nil {
+			return nil, err
+		}
+		return &typeRef{Kind: kindMap, Elem: elem}, nil
+	}
+
+	t := b.newType(location(file, ptr), typeHints(hint, parent)...)
+	if err := b.structFields(t, file, ptr, node, props); err != nil {
+		return nil, err
+	}
+	return &typeRef{Kind: kindNamed, Named: t}, nil
+}
+
+// structFields sets the description and fields of struct t from the node
+// located at ptr in file.
+func (b *builder) structFields(t *namedType, file, ptr string, node map[string]any, props map[string]property) error {
+	t.Description = description(node)
+	t.Struct = true
+	required := requiredProperties(node)
+	for _, name := range slices.Sorted(maps.Keys(props)) {
+		p := props[name]
+		ft, err := b.typeOf(file, ptr+p.ptr, p.schema, pascalCase(name), t.hints[0])
+		if err != nil {
+			return err
+		}
+		deprecated, _ := p.schema["deprecated"].(bool)
+		t.Fields = append(t.Fields, &field{
+			Name:        name,
+			Type:        ft,
+			Required:    slices.Contains(required, name),
+			Description: description(p.schema),
+			Deprecated:  deprecated,
+		})
+	}
+	return nil
+}
+
+// refType returns the type of the target of a $ref. Structs and enums of a
+// target are generated once and named after the target.
+func (b *builder) refType(file, ref, parent string) (*typeRef, error) {
+	refPath, fragment, _ := strings.Cut(ref, "#")
+	target := file
+	if refPath != "" {
+		target = path.Join(path.Dir(file), refPath)
+	}
+	fragment, err := url.PathUnescape(fragment)
+	if err != nil {
+		return nil, fmt.Errorf("invalid $ref %q: %w", ref, err)
+	}
+
+	loc := location(target, fragment)
+	if t, ok := b.named[loc]; ok {
+		return &typeRef{Kind: kindNamed, Named: t}, nil
+	}
+
+	doc, err := b.load(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve $ref %q in %s: %w", ref, file, err)
+	}
+	node, err := resolvePointer(doc, fragment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve $ref %q in %s: %w", ref, file, err)
+	}
+	schema, _ := node.(map[string]any)
+
+	name := pascalCase(path.Base(fragment))
+	if fragment == "" {
+		name = pascalCase(strings.TrimSuffix(path.Base(target), ".jsonschema.json"))
+	}
+
+	// Register struct targets before generating their fields so that
+	// recursive references resolve to the type being generated.
+	if props := objectProperties(schema); schemaType(schema) == "object" && len(props) > 0 {
+		t := b.newType(loc, typeHints(name, parent)...)
+		b.named[loc] = t
+		if err := b.structFields(t, target, fragment, schema, props); err != nil {
+			return nil, err
+		}
+		return &typeRef{Kind: kindNamed, Named: t}, nil
+	}
+
+	rt, err := b.typeOf(target, fragment, schema, name, parent)
+	if err != nil {
+		return nil, err
+	}
+	if rt.Kind == kindNamed {
+		b.named[loc] = rt.Named
+	}
+	return rt, nil
+}
+
+// property is a property schema and its location.
+type property struct {
+	schema map[string]any
+	ptr    string
+}
+
+// objectProperties returns the properties of an object schema, including
+// properties declared by its allOf, anyOf, oneOf, and conditional
+// subschemas. Properties of the schema itself take precedence.
+func objectProperties(node map[string]any) map[string]property {
+	props := map[string]property{}
+	var collect func(n map[string]any, ptr string)
+	collect = func(n map[string]any, ptr string) {
+		if p, ok := n["properties"].(map[string]any); ok {
+			for name, s := range p {
+				schema, ok := s.(map[string]any)
+				if _, exists := props[name]; !exists && ok {
+					props[name] = property{schema: schema, ptr: ptr + "/properties/" + escapePointer(name)}
+				}
+			}
+		}
+		for _, kw := range []string{"allOf", "anyOf", "oneOf"} {
+			subs, _ := n[kw].([]any)
+			for i, s := range subs {
+				if sub, ok := s.(map[string]any); ok {
+					collect(sub, ptr+"/"+kw+"/"+strconv.Itoa(i))
+				}
+			}
+		}
+		for _, kw := range []string{"then", "else"} {
+			if sub, ok := n[kw].(map[string]any); ok {
+				collect(sub, ptr+"/"+kw)
+			}
+		}
+	}
+	collect(node, "")
+
+	// Conditional schemas may only constrain properties of the object
+	// itself, e.g. with an enum, which must not replace their declaration.
+	own, _ := node["properties"].(map[string]any)
+	for name, p := range props {
+		if _, ok := own[name]; !ok && isConstraintOnly(p.schema) {
+			delete(props, name)
+		}
+	}
+	return props
+}
+
+// isConstraintOnly reports whether a property schema in a subschema only
+// constrains a value, such as a const in an if condition.
+func isConstraintOnly(s map[string]any) bool {
+	_, hasType := s["type"]
+	_, hasRef := s["$ref"]
+	return !hasType && !hasRef
+}
+
+// requiredProperties returns the unconditionally required properties.
+func requiredProperties(node map[string]any) []string {
+	var out []string
+	for _, r := range asSlice(node["required"]) {
+		if s, ok := r.(string); ok {
+			out = append(out, s)
+		}
+	}
+	for _, s := range asSlice(node["allOf"]) {
+		if sub, ok := s.(map[string]any); ok {
+			out = append(out, requiredProperties(sub)...)
+		}
+	}
+	return out
+}
+
+// mapValues returns the schema shared by the values of an object and its
+// location relative to the object, or nil if there is none.
+func mapValues(node map[string]any) (map[string]any, string) {
+	if ap, ok := node["additionalProperties"].(map[string]any); ok {
+		return ap, "/additionalProperties"
+	}
+	if pp, ok := node["patternProperties"].(map[string]any); ok && len(pp) == 1 {
+		for pattern, s := range pp {
+			if schema, ok := s.(map[string]any); ok {
+				return schema, "/patternProperties/" + escapePointer(pattern)
+			}
+		}
+	}
+	return nil, ""
+}
+
+// schemaType returns the JSON type of a schema, inferring it from other
+// keywords if type is absent. A nullable type is treated as its non-null
+// type. Schemas allowing several types return an empty string.
+func schemaType(node map[string]any) string {
+	switch t := node["type"].(type) {
+	case string:
+		return t
+	case []any:
+		var types []string
+		for _, v := range t {
+			if s, ok := v.(string); ok && s != "null" {
+				types = append(types, s)
+			}
+		}
+		if len(types) == 1 {
+			return types[0]
+		}
+		return ""
+	}
+	if _, ok := node["properties"]; ok {
+		return "object"
+	}
+	if _, ok := node["items"]; ok {
+		return "array"
+	}
+	if len(stringEnum(node)) > 0 {
+		return "string"
+	}
+	if c, ok := node["const"]; ok {
+		switch c.(type) {
+		case string:
+			return "string"
+		case bool:
+			return "boolean"
+		case float64:
+			return "number"
+		}
+	}
+	return ""
+}
+
+// alternativeTypes reports whether the alternatives of an anyOf or oneOf
+// have different types, or allow several types themselves.
+func alternativeTypes(subs []any) bool {
+	seen := map[string]bool{}
+	for _, s := range subs {
+		sub, ok := s.(map[string]any)
+		if !ok {
+			return true
+		}
+		if _, hasType := sub["type"]; !hasType {
+			if _, hasRef := sub["$ref"]; !hasRef {
+				// Alternatives without a type only constrain the schema,
+				// e.g. with required.
+				continue
+			}
+		}
+		t := schemaType(sub)
+		if t == "" {
+			return true
+		}
+		seen[t] = true
+	}
+	return len(seen) > 1
+}
+
+// stringEnum returns the values of an enum or const of strings.
+func stringEnum(node map[string]any) []string {
+	var values []string
+	for _, v := range asSlice(node["enum"]) {
+		s, ok := v.(string)
+		if !ok {
+			return nil
+		}
+		values = append(values, s)
+	}
+	return values
+}
+
+func description(node map[string]any) string {
+	d, _ := node["description"].(string)
+	return strings.TrimSpace(d)
+}
+
+func asSlice(v any) []any {
+	s, _ := v.([]any)
+	return s
+}
+
+// location returns the location of the schema at ptr in file.
+func location(file, ptr string) string {
+	if ptr == "" {
+		return file
+	}
+	return file + "#" + ptr
+}
+
+// genericNames are names that say little about a type on their own.
+var genericNames = []string{"Default", "Kind", "Mode", "Name", "Type", "Value"}
+
+// typeHints returns the candidate names of a type named hint within the type
+// named parent.
+func typeHints(hint, parent string) []string {
+	if parent == "" || parent == hint {
+		return []string{hint}
+	}
+	if slices.Contains(genericNames, hint) {
+		return []string{parent + hint, hint}
+	}
+	return []string{hint, parent + hint}
+}
+
+// singular returns the name of an item of a collection named name.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "ss"):
+		return name + "Item"
+	case strings.HasSuffix(name, "s") && len(name) > 1:
+		return strings.TrimSuffix(name, "s")
+	}
+	return name + "Item"
+}
+
+// initialisms are words written in upper case within identifiers.
+var initialisms = map[string]bool{
+	"api": true, "cpu": true, "css": true, "dns": true, "ecs": true, "html": true,
+	"http": true, "https": true, "id": true, "ilm": true, "ip": true, "json": true,
+	"os": true, "sql": true, "ssl": true, "tls": true, "ui": true, "uri": true,
+	"url": true, "uuid": true, "yaml": true, "xml": true,
+}
+
+// pascalCase converts a property name or value to an identifier.
+func pascalCase(s string) string {
+	var sb strings.Builder
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if initialisms[strings.ToLower(word)] {
+			sb.WriteString(strings.ToUpper(word))
+			continue
+		}
+		r := []rune(word)
+		r[0] = unicode.ToUpper(r[0])
+		sb.WriteString(string(r))
+	}
+	out := sb.String()
+	if out != "" && unicode.IsDigit([]rune(out)[0]) {
+		out = "V" + out
+	}
+	return out
+}
+
+// resolvePointer returns the value that the JSON pointer refers to in doc.
+func resolvePointer(doc any, pointer string) (any, error) {
+	if pointer == "" {
+		return doc, nil
+	}
+	node := doc
+	for tok := range strings.SplitSeq(strings.TrimPrefix(pointer, "/"), "/") {
+		tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+		switch n := node.(type) {
+		case map[string]any:
+			child, ok := n[tok]
+			if !ok {
+				return nil, fmt.Errorf("pointer %q not found", pointer)
+			}
+			node = child
+		case []any:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, fmt.Errorf("pointer %q not found", pointer)
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("pointer %q not found", pointer)
+		}
+	}
+	return node, nil
+}
+
+// escapePointer escapes a JSON pointer reference token.
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// dedup merges structurally identical types. The schema of each manifest
+// contains its own copy of shared definitions, which would otherwise result
+// in a type per copy. Merging repeats until no identical types remain because
+// merging types can make the types using them identical.
+func (m *model) dedup() {
+	merged := map[*namedType]*namedType{}
+	rep := func(t *namedType) *namedType {
+		for merged[t] != nil {
+			t = merged[t]
+		}
+		return t
+	}
+	ids := map[*namedType]int{}
+	for i, t := range m.Types {
+		ids[t] = i
+	}
+
+	var sig func(r *typeRef) string
+	sig = func(r *typeRef) string {
+		switch r.Kind {
+		case kindArray, kindMap:
+			return fmt.Sprintf("%d[%s]", r.Kind, sig(r.Elem))
+		case kindNamed:
+			return fmt.Sprintf("#%d", ids[rep(r.Named)])
+		default:
+			return strconv.Itoa(int(r.Kind))
+		}
+	}
+	typeSig := func(t *namedType) string {
+		var sb strings.Builder
+		switch {
+		case t.Alias != nil:
+			sb.WriteString("alias " + sig(t.Alias))
+		case t.Struct:
+			sb.WriteString("struct")
+			for _, f := range t.Fields {
+				fmt.Fprintf(&sb, " %q:%s:%t:%t", f.Name, sig(f.Type), f.Required, f.Deprecated)
+			}
+		default:
+			sb.WriteString("enum")
+			for _, v := range t.Enum {
+				fmt.Fprintf(&sb, " %q", v)
+			}
+		}
+		return sb.String()
+	}
+
+	// Roots are considered first so that they are never merged into others.
+	order := slices.Concat(m.Roots, slices.DeleteFunc(slices.Clone(m.Types), func(t *namedType) bool {
+		return slices.Contains(m.Roots, t)
+	}))
+	for {
+		kept := map[string]*namedType{}
+		var changed bool
+		for _, t := range order {
+			if merged[t] != nil {
+				continue
+			}
+			s := typeSig(t)
+			if k, ok := kept[s]; ok && !slices.Contains(m.Roots, t) {
+				merged[t] = k
+				changed = true
+				continue
+			}
+			if _, ok := kept[s]; !ok {
+				kept[s] = t
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	var rewrite func(r *typeRef)
+	rewrite = func(r *typeRef) {
+		if r == nil {
+			return
+		}
+		if r.Named != nil {
+			r.Named = rep(r.Named)
+		}
+		rewrite(r.Elem)
+	}
+	m.Types = slices.DeleteFunc(m.Types, func(t *namedType) bool { return merged[t] != nil })
+	for _, t := range m.Types {
+		rewrite(t.Alias)
+		for _, f := range t.Fields {
+			rewrite(f.Type)
+		}
+	}
+}
+
+// assignNames names every type with its first hint that is not taken,
+// falling back to a numeric suffix. Roots are named first.
+func (m *model) assignNames() {
+	taken := map[string]bool{}
+	for _, t := range m.Roots {
+		t.Name = t.hints[0]
+		taken[t.Name] = true
+	}
+	for _, t := range m.Types {
+		if t.Name != "" {
+			continue
+		}
+		for _, h := range t.hints {
+			if h != "" && !taken[h] {
+				t.Name = h
+				break
+			}
+		}
+		if t.Name == "" {
+			base := cmp.Or(t.hints[0], "Object")
+			t.Name = base
+			for i := 2; taken[t.Name]; i++ {
+				t.Name = base + strconv.Itoa(i)
+			}
+		}
+		taken[t.Name] = true
+	}
+}
@@ -0,0 +1,118 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// generateTypeScript returns a TypeScript declaration file declaring the
+// types of the model. Structs become interfaces with optional members for
+// properties that are not required, and enums become unions of literals.
+func generateTypeScript(m *model, version string) ([]byte, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// Code generated by codegen from package-spec %s. DO NOT EDIT.\n", version)
+
+	for _, t := range m.Roots {
+		writeTSType(&sb, t)
+	}
+	for _, t := range m.Types {
+		if !isRoot(m, t) {
+			writeTSType(&sb, t)
+		}
+	}
+	return []byte(sb.String()), nil
+}
+
+func writeTSType(sb *strings.Builder, t *namedType) {
+	sb.WriteString("\n")
+	doc := fmt.Sprintf("%s is generated from %s.", t.Name, t.Source)
+	if t.Description != "" {
+		doc += "\n\n" + t.Description
+	}
+	writeJSDoc(sb, "", doc, false)
+
+	switch {
+	case t.Alias != nil:
+		fmt.Fprintf(sb, "export type %s = %s;\n", t.Name, tsType(t.Alias))
+	case len(t.Enum) > 0:
+		values := make([]string, len(t.Enum))
+		for i, v := range t.Enum {
+			values[i] = strconv.Quote(v)
+		}
+		fmt.Fprintf(sb, "export type %s =\n  | %s;\n", t.Name, strings.Join(values, "\n  | "))
+	default:
+		fmt.Fprintf(sb, "export interface %s {\n", t.Name)
+		for _, f := range t.Fields {
+			if f.Description != "" || f.Deprecated {
+				writeJSDoc(sb, "  ", f.Description, f.Deprecated)
+			}
+			optional := "?"
+			if f.Required {
+				optional = ""
+			}
+			fmt.Fprintf(sb, "  %s%s: %s;\n", tsPropertyName(f.Name), optional, tsType(f.Type))
+		}
+		sb.WriteString("}\n")
+	}
+}
+
+// tsType returns the TypeScript type expression of t.
+func tsType(t *typeRef) string {
+	switch t.Kind {
+	case kindString:
+		return "string"
+	case kindInteger, kindNumber:
+		return "number"
+	case kindBoolean:
+		return "boolean"
+	case kindArray:
+		return tsType(t.Elem) + "[]"
+	case kindMap:
+		return "Record<string, " + tsType(t.Elem) + ">"
+	case kindObject:
+		return "Record<string, unknown>"
+	case kindNamed:
+		return t.Named.Name
+	default:
+		return "unknown"
+	}
+}
+
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// tsPropertyName returns the property name, quoted unless it is an
+// identifier.
+func tsPropertyName(name string) string {
+	if tsIdentifier.MatchString(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+// writeJSDoc writes text as a JSDoc comment wrapped at 80 columns.
+func writeJSDoc(sb *strings.Builder, indent, text string, deprecated bool) {
+	// The comment must not be terminated by its text.
+	text = strings.ReplaceAll(text, "*/", "*\\/")
+
+	sb.WriteString(indent + "/**\n")
+	for i, para := range paragraphs(text) {
+		if i > 0 {
+			sb.WriteString(indent + " *\n")
+		}
+		for _, line := range para {
+			for _, l := range wrap(line, 80-len(indent)-3) {
+				sb.WriteString(indent + " * " + l + "\n")
+			}
+		}
+	}
+	if deprecated {
+		sb.WriteString(indent + " * @deprecated\n")
+	}
+	sb.WriteString(indent + " */\n")
+}
@@ -0,0 +1,156 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// compat reports breaking changes between the schemas of two generated
+// versions, such as removed properties, newly required fields, narrowed
+// enums, and tightened patterns. Integration maintainers can use it to assess
+// the cost of bumping format_version.
+//
+//	go run ./compat [flags] <oldVersionDir> <newVersionDir>
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+const schemaSuffix = ".jsonschema.json"
+
+var (
+	jsonOutput bool // Write the report as JSON.
+	fail       bool // Exit with an error if any breaking change is found.
+)
+
+func init() {
+	flag.BoolVar(&jsonOutput, "json", false, "write the report as JSON instead of text")
+	flag.BoolVar(&fail, "fail", false, "exit with an error if any breaking change is found")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <oldVersionDir> <newVersionDir>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+// report lists the breaking changes from Old to New.
+type report struct {
+	Old     string           `json:"old"`
+	New     string           `json:"new"`
+	Changes []breakingChange `json:"changes"`
+}
+
+func run() error {
+	if flag.NArg() != 2 {
+		flag.Usage()
+		return errors.New("expected two version directories to compare")
+	}
+	oldDir, newDir := flag.Arg(0), flag.Arg(1)
+
+	oldSchemas, err := readSchemas(filepath.Join(oldDir, "jsonschema"))
+	if err != nil {
+		return err
+	}
+	newSchemas, err := readSchemas(filepath.Join(newDir, "jsonschema"))
+	if err != nil {
+		return err
+	}
+
+	r := report{Old: filepath.Base(oldDir), New: filepath.Base(newDir), Changes: []breakingChange{}}
+	for _, file := range slices.Sorted(maps.Keys(oldSchemas)) {
+		after, ok := newSchemas[file]
+		if !ok {
+			r.Changes = append(r.Changes, breakingChange{Kind: "removed_schema", File: file, Message: "schema was removed"})
+			continue
+		}
+		r.Changes = append(r.Changes, compareSchemas(file, "", oldSchemas[file], after)...)
+	}
+
+	if err := writeReport(r); err != nil {
+		return err
+	}
+	slog.Info("Compared versions.", "old", r.Old, "new", r.New, "breaking_changes", len(r.Changes))
+
+	if fail && len(r.Changes) > 0 {
+		return fmt.Errorf("found %d breaking changes from %s to %s", len(r.Changes), r.Old, r.New)
+	}
+	return nil
+}
+
+// readSchemas decodes each schema in dir keyed by its slash separated path
+// relative to dir.
+func readSchemas(dir string) (map[string]map[string]any, error) {
+	schemas := map[string]map[string]any{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, schemaSuffix) {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var schema map[string]any
+		if err := json.Unmarshal(b, &schema); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+		schemas[filepath.ToSlash(rel)] = schema
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return schemas, nil
+}
+
+func writeReport(r report) error {
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	}
+
+	var file string
+	for _, c := range r.Changes {
+		if c.File != file {
+			file = c.File
+			fmt.Printf("%s\n", file)
+		}
+		ptr := c.Pointer
+		if ptr == "" {
+			ptr = "(root)"
+		}
+		fmt.Printf("  %s: %s [%s]\n", ptr, c.Message, c.Kind)
+	}
+	return nil
+}
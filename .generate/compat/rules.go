@@ -0,0 +1,258 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// breakingChange is a change to a schema that can make documents that were
+// valid under the old schema invalid under the new one.
+type breakingChange struct {
+	Kind    string `json:"kind"`
+	File    string `json:"file"`
+	Pointer string `json:"pointer"` // Location in the new schema.
+	Message string `json:"message"`
+}
+
+// check compares a subschema of the old and new versions and returns a
+// message for each breaking change.
+type check struct {
+	kind string
+	fn   func(before, after map[string]any) []string
+}
+
+var checks = []check{
+	{"removed_property", removedProperties},
+	{"newly_required", newlyRequired},
+	{"narrowed_enum", narrowedEnum},
+	{"tightened_pattern", tightenedPattern},
+	{"narrowed_type", narrowedType},
+	{"closed_object", closedObject},
+	{"tightened_bound", tightenedBounds},
+}
+
+func removedProperties(before, after map[string]any) []string {
+	oldProps, _ := before["properties"].(map[string]any)
+	newProps, _ := after["properties"].(map[string]any)
+	var msgs []string
+	for _, name := range slices.Sorted(maps.Keys(oldProps)) {
+		if _, ok := newProps[name]; !ok {
+			msgs = append(msgs, fmt.Sprintf("property %q was removed", name))
+		}
+	}
+	return msgs
+}
+
+func newlyRequired(before, after map[string]any) []string {
+	oldRequired := stringSet(before["required"])
+	var msgs []string
+	for _, name := range slices.Sorted(maps.Keys(stringSet(after["required"]))) {
+		if !oldRequired[name] {
+			msgs = append(msgs, fmt.Sprintf("property %q is now required", name))
+		}
+	}
+	return msgs
+}
+
+func narrowedEnum(before, after map[string]any) []string {
+	newValues, ok := after["enum"].([]any)
+	if !ok {
+		return nil
+	}
+	oldValues, ok := before["enum"].([]any)
+	if !ok {
+		return []string{fmt.Sprintf("values are now restricted to %s", encode(newValues))}
+	}
+
+	allowed := map[string]bool{}
+	for _, v := range newValues {
+		allowed[encode(v)] = true
+	}
+	var msgs []string
+	for _, v := range oldValues {
+		if !allowed[encode(v)] {
+			msgs = append(msgs, fmt.Sprintf("enum value %s was removed", encode(v)))
+		}
+	}
+	return msgs
+}
+
+// tightenedPattern reports added or changed patterns. Whether a changed
+// pattern accepts fewer strings cannot be decided in general, so every change
+// is reported for review.
+func tightenedPattern(before, after map[string]any) []string {
+	newPattern, ok := after["pattern"].(string)
+	if !ok {
+		return nil
+	}
+	oldPattern, ok := before["pattern"].(string)
+	switch {
+	case !ok:
+		return []string{fmt.Sprintf("pattern %q was added", newPattern)}
+	case oldPattern != newPattern:
+		return []string{fmt.Sprintf("pattern changed from %q to %q", oldPattern, newPattern)}
+	}
+	return nil
+}
+
+func narrowedType(before, after map[string]any) []string {
+	newTypes := schemaTypes(after)
+	if len(newTypes) == 0 {
+		return nil
+	}
+	oldTypes := schemaTypes(before)
+	if len(oldTypes) == 0 {
+		return []string{fmt.Sprintf("type is now restricted to %s", strings.Join(newTypes, ", "))}
+	}
+	var msgs []string
+	for _, t := range oldTypes {
+		// Every integer is also a number.
+		if slices.Contains(newTypes, t) || (t == "integer" && slices.Contains(newTypes, "number")) {
+			continue
+		}
+		msgs = append(msgs, fmt.Sprintf("type %q is no longer allowed", t))
+	}
+	return msgs
+}
+
+func closedObject(before, after map[string]any) []string {
+	if after["additionalProperties"] == false && before["additionalProperties"] != false {
+		return []string{"additional properties are no longer allowed"}
+	}
+	return nil
+}
+
+// Lower and upper bound keywords. A larger lower bound or a smaller upper
+// bound accepts fewer values.
+var (
+	lowerBounds = []string{"exclusiveMinimum", "minimum", "minItems", "minLength", "minProperties"}
+	upperBounds = []string{"exclusiveMaximum", "maximum", "maxItems", "maxLength", "maxProperties"}
+)
+
+func tightenedBounds(before, after map[string]any) []string {
+	var msgs []string
+	compare := func(keyword string, tighter func(o, n float64) bool) {
+		n, ok := after[keyword].(float64)
+		if !ok {
+			return
+		}
+		o, ok := before[keyword].(float64)
+		switch {
+		case !ok:
+			msgs = append(msgs, fmt.Sprintf("%s %v was added", keyword, n))
+		case tighter(o, n):
+			msgs = append(msgs, fmt.Sprintf("%s changed from %v to %v", keyword, o, n))
+		}
+	}
+	for _, k := range lowerBounds {
+		compare(k, func(o, n float64) bool { return n > o })
+	}
+	for _, k := range upperBounds {
+		compare(k, func(o, n float64) bool { return n < o })
+	}
+	return msgs
+}
+
+// Keywords whose values are subschemas, maps of subschemas, or arrays of
+// subschemas. The not keyword is excluded because it inverts the meaning of
+// its subschema.
+var (
+	schemaKeywords = []string{
+		"additionalItems", "additionalProperties", "contains", "else", "if",
+		"items", "propertyNames", "then", "unevaluatedItems",
+		"unevaluatedProperties",
+	}
+	schemaMapKeywords   = []string{"$defs", "definitions", "dependentSchemas", "patternProperties", "properties"}
+	schemaArrayKeywords = []string{"allOf", "anyOf", "items", "oneOf", "prefixItems"}
+)
+
+// compareSchemas applies the checks to the old and new schema and to each
+// pair of subschemas found at the same location in both.
+func compareSchemas(file, ptr string, before, after map[string]any) []breakingChange {
+	var changes []breakingChange
+	for _, c := range checks {
+		for _, msg := range c.fn(before, after) {
+			changes = append(changes, breakingChange{Kind: c.kind, File: file, Pointer: ptr, Message: msg})
+		}
+	}
+
+	for _, k := range schemaKeywords {
+		o, okOld := before[k].(map[string]any)
+		n, okNew := after[k].(map[string]any)
+		if okOld && okNew {
+			changes = append(changes, compareSchemas(file, ptr+"/"+escapePointer(k), o, n)...)
+		}
+	}
+	for _, k := range schemaMapKeywords {
+		o, _ := before[k].(map[string]any)
+		n, _ := after[k].(map[string]any)
+		for _, name := range slices.Sorted(maps.Keys(n)) {
+			bs, okOld := o[name].(map[string]any)
+			as, okNew := n[name].(map[string]any)
+			if okOld && okNew {
+				changes = append(changes, compareSchemas(file, ptr+"/"+escapePointer(k)+"/"+escapePointer(name), bs, as)...)
+			}
+		}
+	}
+	for _, k := range schemaArrayKeywords {
+		o, _ := before[k].([]any)
+		n, _ := after[k].([]any)
+		// Branches can only be matched by position if none were added or
+		// removed.
+		if len(o) != len(n) {
+			continue
+		}
+		for i := range n {
+			bs, okOld := o[i].(map[string]any)
+			as, okNew := n[i].(map[string]any)
+			if okOld && okNew {
+				changes = append(changes, compareSchemas(file, ptr+"/"+k+"/"+strconv.Itoa(i), bs, as)...)
+			}
+		}
+	}
+	return changes
+}
+
+func schemaTypes(schema map[string]any) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []any:
+		return slices.Sorted(maps.Keys(stringSet(t)))
+	}
+	return nil
+}
+
+func stringSet(v any) map[string]bool {
+	values, _ := v.([]any)
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			set[s] = true
+		}
+	}
+	return set
+}
+
+// encode returns the compact JSON encoding of v. Maps are encoded with sorted
+// keys, so equal values have equal encodings.
+func encode(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// escapePointer escapes a JSON pointer reference token.
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
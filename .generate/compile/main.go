@@ -0,0 +1,160 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// compile precompiles the bundled schemas of each version directory with
+// `jsonschema compile` and writes the resulting validation templates into a
+// compiled/ directory alongside bundles/. High-throughput validators can load
+// the templates directly instead of compiling the schemas at startup.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+const (
+	schemaSuffix   = ".jsonschema.json"
+	templateSuffix = ".template.json"
+)
+
+var (
+	outDir string // Directory containing the versioned directories.
+	fast   bool   // Compile for speed instead of error reporting.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	flag.BoolVar(&fast, "fast", false, "compile templates optimized for speed rather than detailed error output")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+func run() error {
+	if _, err := exec.LookPath("jsonschema"); err != nil {
+		return errors.New("jsonschema tool not found in $PATH")
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := semver.NewVersion(e.Name()); err != nil {
+			continue
+		}
+
+		bundles := filepath.Join(outDir, e.Name(), "bundles")
+		if _, err := os.Stat(bundles); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err := compileDir(bundles, filepath.Join(outDir, e.Name(), "compiled")); err != nil {
+			return fmt.Errorf("failed compiling %s: %w", e.Name(), err)
+		}
+	}
+	return nil
+}
+
+// compileDir compiles every schema in srcDir and replaces dstDir with the
+// templates. Templates of schemas that no longer exist are removed.
+func compileDir(srcDir, dstDir string) (err error) {
+	start := time.Now()
+	staging, err := fsutil.StageDir(dstDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Discard the staging directory if it was not committed.
+		if rmErr := os.RemoveAll(staging); rmErr != nil {
+			err = errors.Join(err, rmErr)
+		}
+	}()
+
+	var count int
+	err = filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, schemaSuffix) {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+
+		args := []string{"compile", path}
+		if fast {
+			args = append(args, "--fast")
+		}
+		out, err := jsonschemaExec(args...)
+		if err != nil {
+			return err
+		}
+
+		dst := filepath.Join(staging, strings.TrimSuffix(rel, schemaSuffix)+templateSuffix)
+		if err := fsutil.WriteFile(dst, out); err != nil {
+			return err
+		}
+		count++
+		slog.Debug("Compiled schema.", "path", path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	changed, err := fsutil.CommitDir(staging, dstDir)
+	if err != nil {
+		return err
+	}
+	slog.Info("Compiled schemas.", "dir", dstDir, "schemas", count, "changed", changed, "duration", time.Since(start))
+	return nil
+}
+
+func jsonschemaExec(args ...string) (stdout []byte, err error) {
+	cmd := exec.Command("jsonschema", args...)
+	outBuf := new(bytes.Buffer)
+	cmd.Stdout = outBuf
+	errBuf := new(bytes.Buffer)
+	cmd.Stderr = errBuf
+
+	if err := cmd.Run(); err != nil {
+		slog.Error("jsonschema failed.", "args", args, "stderr", strings.TrimSpace(errBuf.String()))
+		return nil, fmt.Errorf("failed running jsonschema %s: %w", strings.Join(args, " "), err)
+	}
+	if errBuf.Len() > 0 {
+		slog.Log(context.Background(), logging.LevelTrace, "jsonschema output.", "args", args, "stderr", strings.TrimSpace(errBuf.String()))
+	}
+	return outBuf.Bytes(), nil
+}
@@ -0,0 +1,163 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// compress writes a gzip-compressed <name>.gz sibling of every schema and
+// bundle in each version directory so that static hosting can serve
+// precompressed content. The output is reproducible: the gzip header has no
+// name or modification time. Siblings whose schema no longer exists are
+// removed, and the versions.json index is updated to list the encoding of
+// each version that has a sibling for every schema.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+	"github.com/andrewkroh/package-spec-schema/internal/versionindex"
+)
+
+const (
+	schemaSuffix = ".jsonschema.json"
+	gzipSuffix   = ".gz"
+)
+
+var (
+	outDir string // Directory containing the versioned directories.
+	remove bool   // Remove the compressed siblings instead of writing them.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	flag.BoolVar(&remove, "remove", false, "remove the compressed siblings instead of writing them")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+func run() error {
+	dirs, err := versionDirs(outDir)
+	if err != nil {
+		return err
+	}
+
+	var written, removed int
+	for _, dir := range dirs {
+		w, r, err := compressDir(dir)
+		if err != nil {
+			return fmt.Errorf("failed compressing %s: %w", dir, err)
+		}
+		written += w
+		removed += r
+	}
+
+	// The index records the encodings found in each version directory.
+	index, err := versionindex.Read(outDir)
+	if err != nil {
+		return fmt.Errorf("failed to read version index: %w", err)
+	}
+	if err := index.Write(outDir); err != nil {
+		return fmt.Errorf("failed to write version index: %w", err)
+	}
+	slog.Info("Compressed schemas.", "dirs", len(dirs), "written", written, "removed", removed)
+	return nil
+}
+
+// versionDirs returns the directories in dir that contain a jsonschema
+// directory.
+func versionDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []string
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if info, err := os.Stat(filepath.Join(path, "jsonschema")); err == nil && info.IsDir() {
+			out = append(out, path)
+		}
+	}
+	return out, nil
+}
+
+// compressDir writes the compressed sibling of each schema beneath dir and
+// removes stale siblings. It returns the number of files written and
+// removed.
+func compressDir(dir string) (written, removed int, err error) {
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch {
+		case strings.HasSuffix(path, schemaSuffix+gzipSuffix):
+			if !remove {
+				if _, err := os.Stat(strings.TrimSuffix(path, gzipSuffix)); !errors.Is(err, fs.ErrNotExist) {
+					return err
+				}
+			}
+			slog.Debug("Removing compressed schema.", "path", path)
+			removed++
+			return os.Remove(path)
+		case strings.HasSuffix(path, schemaSuffix) && !remove:
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			gz, err := gzipBytes(data)
+			if err != nil {
+				return err
+			}
+			changed, err := fsutil.WriteFileIfChanged(path+gzipSuffix, gz)
+			if changed {
+				written++
+			}
+			return err
+		}
+		return nil
+	})
+	return written, removed, err
+}
+
+// gzipBytes compresses data at the best compression level. The header is
+// left empty so that the output only depends on data.
+func gzipBytes(data []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w, err := gzip.NewWriterLevel(buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,649 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// unsupportedKeywords cannot be expressed in CUE. They are listed in a
+// comment so that readers know the constraint is not enforced.
+var unsupportedKeywords = []string{
+	"contains", "dependencies", "dependentRequired", "dependentSchemas", "if",
+	"not", "propertyNames", "unevaluatedItems", "unevaluatedProperties",
+}
+
+// converter converts a schema file and the schemas it references to CUE.
+type converter struct {
+	dir     string
+	version string
+	docs    map[string]any    // Decoded schema files keyed by slash-separated path.
+	defs    map[string]string // Definition names keyed by schema location.
+	names   map[string]bool   // Definition names that are taken.
+	pending []definition      // Definitions that are referenced but not yet written.
+	imports map[string]bool   // CUE packages used by the written expressions.
+	notes   []string          // Unsupported keywords of the value being written.
+}
+
+// definition is a schema written as a CUE definition.
+type definition struct {
+	name string
+	file string
+	node any
+}
+
+func newConverter(dir, version string) *converter {
+	return &converter{
+		dir:     dir,
+		version: version,
+		docs:    map[string]any{},
+		defs:    map[string]string{},
+		names:   map[string]bool{},
+		imports: map[string]bool{},
+	}
+}
+
+// convert returns a CUE file declaring the schema in file as a definition
+// named after the file, followed by the definitions that it references.
+func (c *converter) convert(file string) ([]byte, error) {
+	doc, err := c.load(file)
+	if err != nil {
+		return nil, err
+	}
+	stem := strings.TrimSuffix(path.Base(file), schemaSuffix)
+	root := c.define(file, "", "#"+identifier(stem))
+
+	var body strings.Builder
+	c.pending = append(c.pending, definition{name: root, file: file, node: doc})
+	for len(c.pending) > 0 {
+		d := c.pending[0]
+		c.pending = c.pending[1:]
+
+		c.notes = nil
+		value, err := c.expr(d.file, d.node, 0)
+		if err != nil {
+			return nil, err
+		}
+		body.WriteString("\n")
+		c.writeDoc(&body, "", withNotes(description(d.node), c.notes))
+		fmt.Fprintf(&body, "%s: %s\n", d.name, value)
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "// Code generated from %s of package-spec %s. DO NOT EDIT.\n\n", file, c.version)
+	fmt.Fprintf(&out, "package %s\n", strings.ToLower(strings.TrimPrefix(identifier(stem), "_")))
+	if len(c.imports) > 0 {
+		out.WriteString("\nimport (\n")
+		for _, pkg := range slices.Sorted(maps.Keys(c.imports)) {
+			fmt.Fprintf(&out, "\t%q\n", pkg)
+		}
+		out.WriteString(")\n")
+	}
+	out.WriteString(body.String())
+	return []byte(out.String()), nil
+}
+
+func (c *converter) load(file string) (any, error) {
+	if doc, ok := c.docs[file]; ok {
+		return doc, nil
+	}
+	data, err := os.ReadFile(filepath.Join(c.dir, filepath.FromSlash(file)))
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", file, err)
+	}
+	c.docs[file] = doc
+	return doc, nil
+}
+
+// define returns the definition name of the schema at ptr in file, choosing
+// a unique name derived from name if it has none yet.
+func (c *converter) define(file, ptr, name string) string {
+	loc := file + "#" + ptr
+	if n, ok := c.defs[loc]; ok {
+		return n
+	}
+	unique := name
+	for i := 2; c.names[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	c.names[unique] = true
+	c.defs[loc] = unique
+	return unique
+}
+
+// ref returns the definition name of the target of a $ref and queues the
+// target to be written if it is new.
+func (c *converter) ref(file, ref string) (string, error) {
+	refPath, fragment, _ := strings.Cut(ref, "#")
+	target := file
+	if refPath != "" {
+		target = path.Join(path.Dir(file), refPath)
+	}
+	fragment, err := url.PathUnescape(fragment)
+	if err != nil {
+		return "", fmt.Errorf("invalid $ref %q: %w", ref, err)
+	}
+	if name, ok := c.defs[target+"#"+fragment]; ok {
+		return name, nil
+	}
+
+	doc, err := c.load(target)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve $ref %q in %s: %w", ref, file, err)
+	}
+	node, err := resolvePointer(doc, fragment)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve $ref %q in %s: %w", ref, file, err)
+	}
+
+	name := strings.TrimSuffix(path.Base(target), schemaSuffix)
+	if fragment != "" {
+		name = path.Base(fragment)
+	}
+	name = c.define(target, fragment, "#"+identifier(name))
+	c.pending = append(c.pending, definition{name: name, file: target, node: node})
+	return name, nil
+}
+
+// expr returns the CUE expression of a schema. Nested structs are indented
+// by depth tabs.
+func (c *converter) expr(file string, node any, depth int) (string, error) {
+	switch node := node.(type) {
+	case bool:
+		if node {
+			return "_", nil
+		}
+		return "_|_", nil
+	case map[string]any:
+		return c.schemaExpr(file, node, depth)
+	default:
+		return "", fmt.Errorf("invalid schema of type %T", node)
+	}
+}
+
+func (c *converter) schemaExpr(file string, node map[string]any, depth int) (string, error) {
+	var conjuncts []string
+	for _, kw := range unsupportedKeywords {
+		if _, ok := node[kw]; ok {
+			if kw == "if" {
+				kw = "if/then/else"
+			}
+			c.notes = append(c.notes, kw)
+		}
+	}
+
+	if ref, ok := node["$ref"].(string); ok {
+		name, err := c.ref(file, ref)
+		if err != nil {
+			return "", err
+		}
+		conjuncts = append(conjuncts, name)
+	}
+
+	switch {
+	case node["const"] != nil:
+		conjuncts = append(conjuncts, literal(node["const"], depth))
+	case node["enum"] != nil:
+		values, _ := node["enum"].([]any)
+		alts := make([]string, len(values))
+		for i, v := range values {
+			alts[i] = literal(v, depth)
+		}
+		conjuncts = append(conjuncts, disjunction(alts))
+	default:
+		var alts []string
+		for _, t := range schemaTypes(node) {
+			e, err := c.typeExpr(file, t, node, depth)
+			if err != nil {
+				return "", err
+			}
+			alts = append(alts, e)
+		}
+		if len(alts) > 0 {
+			conjuncts = append(conjuncts, disjunction(alts))
+		}
+	}
+
+	if subs, ok := node["allOf"].([]any); ok {
+		for _, s := range subs {
+			e, err := c.expr(file, s, depth)
+			if err != nil {
+				return "", err
+			}
+			if e != "_" {
+				conjuncts = append(conjuncts, e)
+			}
+		}
+	}
+	for _, kw := range []string{"anyOf", "oneOf"} {
+		subs, ok := node[kw].([]any)
+		if !ok {
+			continue
+		}
+		var alts []string
+		for _, s := range subs {
+			e, err := c.expr(file, s, depth)
+			if err != nil {
+				return "", err
+			}
+			alts = append(alts, e)
+		}
+		if !slices.Contains(alts, "_") {
+			conjuncts = append(conjuncts, disjunction(alts))
+		}
+	}
+
+	if len(conjuncts) == 0 {
+		return "_", nil
+	}
+	if len(conjuncts) > 1 {
+		for i, e := range conjuncts {
+			if isDisjunction(e) {
+				conjuncts[i] = "(" + e + ")"
+			}
+		}
+	}
+	return strings.Join(conjuncts, " & "), nil
+}
+
+// schemaTypes returns the types allowed by a schema. Without a type keyword
+// the type is inferred from the keywords that only apply to one type.
+func schemaTypes(node map[string]any) []string {
+	switch t := node["type"].(type) {
+	case string:
+		return []string{t}
+	case []any:
+		var types []string
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+
+	keywords := map[string][]string{
+		"object": {"properties", "patternProperties", "additionalProperties", "required", "minProperties", "maxProperties"},
+		"array":  {"items", "prefixItems", "minItems", "maxItems", "uniqueItems"},
+		"string": {"pattern", "minLength", "maxLength"},
+		"number": {"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf"},
+	}
+	var types []string
+	for _, t := range slices.Sorted(maps.Keys(keywords)) {
+		for _, kw := range keywords[t] {
+			if _, ok := node[kw]; ok {
+				types = append(types, t)
+				break
+			}
+		}
+	}
+	if len(types) != 1 {
+		return nil
+	}
+	return types
+}
+
+// typeExpr returns the CUE expression of values of type t constrained by the
+// keywords of the schema that apply to the type.
+func (c *converter) typeExpr(file, t string, node map[string]any, depth int) (string, error) {
+	switch t {
+	case "string":
+		parts := []string{"string"}
+		if p, ok := node["pattern"].(string); ok {
+			parts = append(parts, "=~"+quote(p))
+		}
+		parts = append(parts, c.validator(node, "minLength", "strings", "MinRunes")...)
+		parts = append(parts, c.validator(node, "maxLength", "strings", "MaxRunes")...)
+		return strings.Join(parts, " & "), nil
+	case "integer", "number":
+		parts := []string{"number"}
+		if t == "integer" {
+			parts[0] = "int"
+		}
+		for kw, op := range map[string]string{"minimum": ">=", "maximum": "<=", "exclusiveMinimum": ">", "exclusiveMaximum": "<"} {
+			if n, ok := node[kw].(json.Number); ok {
+				parts = append(parts, op+n.String())
+			}
+		}
+		slices.Sort(parts[1:])
+		parts = append(parts, c.validator(node, "multipleOf", "math", "MultipleOf")...)
+		return strings.Join(parts, " & "), nil
+	case "boolean":
+		return "bool", nil
+	case "null":
+		return "null", nil
+	case "array":
+		return c.arrayExpr(file, node, depth)
+	case "object":
+		return c.objectExpr(file, node, depth)
+	default:
+		return "", fmt.Errorf("unknown type %q", t)
+	}
+}
+
+// validator returns a call of the validator fn of the CUE package pkg with
+// the value of the numeric keyword kw, if it is set.
+func (c *converter) validator(node map[string]any, kw, pkg, fn string) []string {
+	n, ok := node[kw].(json.Number)
+	if !ok {
+		return nil
+	}
+	c.imports[pkg] = true
+	return []string{fmt.Sprintf("%s.%s(%s)", pkg, fn, n)}
+}
+
+func (c *converter) arrayExpr(file string, node map[string]any, depth int) (string, error) {
+	// Draft 2020-12 declares tuples with prefixItems, earlier drafts with an
+	// array of items and additionalItems.
+	prefix, _ := node["prefixItems"].([]any)
+	rest := node["items"]
+	if tuple, ok := node["items"].([]any); ok {
+		prefix, rest = tuple, node["additionalItems"]
+	}
+
+	var elems []string
+	for _, s := range prefix {
+		e, err := c.expr(file, s, depth)
+		if err != nil {
+			return "", err
+		}
+		elems = append(elems, e)
+	}
+	switch rest := rest.(type) {
+	case nil:
+		elems = append(elems, "...")
+	case bool:
+		if rest {
+			elems = append(elems, "...")
+		}
+	default:
+		e, err := c.expr(file, rest, depth)
+		if err != nil {
+			return "", err
+		}
+		if isDisjunction(e) {
+			e = "(" + e + ")"
+		}
+		elems = append(elems, "..."+e)
+	}
+
+	parts := []string{"[" + strings.Join(elems, ", ") + "]"}
+	parts = append(parts, c.validator(node, "minItems", "list", "MinItems")...)
+	parts = append(parts, c.validator(node, "maxItems", "list", "MaxItems")...)
+	if unique, _ := node["uniqueItems"].(bool); unique {
+		c.imports["list"] = true
+		parts = append(parts, "list.UniqueItems()")
+	}
+	return strings.Join(parts, " & "), nil
+}
+
+// objectExpr returns a struct with a field per property. Required
+// properties are required fields. Structs are closed unless the schema
+// allows additional properties.
+func (c *converter) objectExpr(file string, node map[string]any, depth int) (string, error) {
+	indent := strings.Repeat("\t", depth+1)
+	props, _ := node["properties"].(map[string]any)
+	var required []string
+	for _, r := range asSlice(node["required"]) {
+		if s, ok := r.(string); ok {
+			required = append(required, s)
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	for _, name := range slices.Sorted(maps.Keys(props)) {
+		start := len(c.notes)
+		value, err := c.expr(file, props[name], depth+1)
+		if err != nil {
+			return "", err
+		}
+		notes := slices.Clone(c.notes[start:])
+		c.notes = c.notes[:start]
+
+		c.writeDoc(&sb, indent, withNotes(description(props[name]), notes))
+
+		marker := "?"
+		if slices.Contains(required, name) {
+			marker = "!"
+		}
+		fmt.Fprintf(&sb, "%s%s%s: %s\n", indent, label(name), marker, value)
+	}
+	for _, name := range required {
+		if _, ok := props[name]; !ok {
+			fmt.Fprintf(&sb, "%s%s!: _\n", indent, label(name))
+		}
+	}
+
+	// Additional properties are those matching neither a property name nor
+	// a pattern.
+	patterns, _ := node["patternProperties"].(map[string]any)
+	var exclude []string
+	if len(props) > 0 {
+		names := make([]string, 0, len(props))
+		for _, name := range slices.Sorted(maps.Keys(props)) {
+			names = append(names, regexp.QuoteMeta(name))
+		}
+		exclude = append(exclude, "!~"+quote("^("+strings.Join(names, "|")+")$"))
+	}
+	for _, p := range slices.Sorted(maps.Keys(patterns)) {
+		value, err := c.expr(file, patterns[p], depth+1)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "%s[=~%s]: %s\n", indent, quote(p), value)
+		exclude = append(exclude, "!~"+quote(p))
+	}
+	switch ap := node["additionalProperties"].(type) {
+	case nil:
+		sb.WriteString(indent + "...\n")
+	case bool:
+		if ap {
+			sb.WriteString(indent + "...\n")
+		}
+	default:
+		value, err := c.expr(file, ap, depth+1)
+		if err != nil {
+			return "", err
+		}
+		if len(exclude) == 0 {
+			exclude = []string{"string"}
+		}
+		fmt.Fprintf(&sb, "%s[%s]: %s\n", indent, strings.Join(exclude, " & "), value)
+	}
+	sb.WriteString(strings.Repeat("\t", depth) + "}")
+
+	parts := []string{sb.String()}
+	parts = append(parts, c.validator(node, "minProperties", "struct", "MinFields")...)
+	parts = append(parts, c.validator(node, "maxProperties", "struct", "MaxFields")...)
+	return strings.Join(parts, " & "), nil
+}
+
+// writeDoc writes text as a line comment.
+func (c *converter) writeDoc(sb *strings.Builder, indent, text string) {
+	if text == "" {
+		return
+	}
+	for line := range strings.SplitSeq(text, "\n") {
+		if line = strings.TrimRight(line, " \t"); line == "" {
+			sb.WriteString(indent + "//\n")
+			continue
+		}
+		sb.WriteString(indent + "// " + line + "\n")
+	}
+}
+
+// withNotes appends the unsupported keywords of a schema to its description.
+func withNotes(doc string, notes []string) string {
+	if len(notes) == 0 {
+		return doc
+	}
+	slices.Sort(notes)
+	notes = slices.Compact(notes)
+	return strings.TrimSpace(doc + "\n\nNot enforced: " + strings.Join(notes, ", ") + ".")
+}
+
+func description(node any) string {
+	m, _ := node.(map[string]any)
+	d, _ := m["description"].(string)
+	return strings.TrimSpace(d)
+}
+
+func asSlice(v any) []any {
+	s, _ := v.([]any)
+	return s
+}
+
+// disjunction joins alternatives with |.
+func disjunction(alts []string) string {
+	if len(alts) == 1 {
+		return alts[0]
+	}
+	for i, a := range alts {
+		if strings.Contains(a, " & ") {
+			alts[i] = "(" + a + ")"
+		}
+	}
+	return strings.Join(alts, " | ")
+}
+
+// isDisjunction reports whether e has a top-level |.
+func isDisjunction(e string) bool {
+	var nesting int
+	var inString bool
+	for i := 0; i < len(e); i++ {
+		switch ch := e[i]; {
+		case inString && ch == '\\':
+			i++
+		case ch == '"':
+			inString = !inString
+		case inString:
+		case ch == '(' || ch == '{' || ch == '[':
+			nesting++
+		case ch == ')' || ch == '}' || ch == ']':
+			nesting--
+		case ch == '|' && nesting == 0 && !strings.HasPrefix(e[i:], "|_"):
+			return true
+		}
+	}
+	return false
+}
+
+// literal returns the CUE literal of a JSON value.
+func literal(v any, depth int) string {
+	switch v := v.(type) {
+	case string:
+		return quote(v)
+	case map[string]any:
+		if len(v) == 0 {
+			return "{}"
+		}
+		indent := strings.Repeat("\t", depth+1)
+		var sb strings.Builder
+		sb.WriteString("{\n")
+		for _, k := range slices.Sorted(maps.Keys(v)) {
+			fmt.Fprintf(&sb, "%s%s: %s\n", indent, quote(k), literal(v[k], depth+1))
+		}
+		sb.WriteString(strings.Repeat("\t", depth) + "}")
+		return sb.String()
+	case []any:
+		elems := make([]string, len(v))
+		for i, e := range v {
+			elems[i] = literal(e, depth)
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// quote returns a CUE string literal. JSON escapes are valid in CUE.
+func quote(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+var cueIdentifier = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+
+// cueKeywords cannot be used as unquoted labels.
+var cueKeywords = []string{"package", "import", "for", "in", "if", "let", "true", "false", "null", "func"}
+
+// label returns the label of a field named name, quoted unless it is an
+// identifier. Identifiers starting with _ or # declare hidden fields and
+// definitions, so such names are quoted.
+func label(name string) string {
+	if cueIdentifier.MatchString(name) && !slices.Contains(cueKeywords, name) {
+		return name
+	}
+	return quote(name)
+}
+
+// identifier converts a name to a CUE identifier in PascalCase.
+func identifier(s string) string {
+	var sb strings.Builder
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		r := []rune(word)
+		r[0] = unicode.ToUpper(r[0])
+		sb.WriteString(string(r))
+	}
+	out := sb.String()
+	if out == "" || unicode.IsDigit([]rune(out)[0]) {
+		out = "S" + out
+	}
+	return out
+}
+
+// resolvePointer returns the value that the JSON pointer refers to in doc.
+func resolvePointer(doc any, pointer string) (any, error) {
+	if pointer == "" {
+		return doc, nil
+	}
+	node := doc
+	for tok := range strings.SplitSeq(strings.TrimPrefix(pointer, "/"), "/") {
+		tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+		switch n := node.(type) {
+		case map[string]any:
+			child, ok := n[tok]
+			if !ok {
+				return nil, fmt.Errorf("pointer %q not found", pointer)
+			}
+			node = child
+		case []any:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, fmt.Errorf("pointer %q not found", pointer)
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("pointer %q not found", pointer)
+		}
+	}
+	return node, nil
+}
@@ -0,0 +1,132 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// cue exports the JSON schemas of each version as CUE definitions so that
+// packages can be validated with `cue vet` without re-transcribing the
+// constraints of the spec. Each schema becomes a self-contained CUE file
+// declaring the schema and the definitions that it references.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+const (
+	schemaSuffix = ".jsonschema.json"
+	cueSuffix    = ".cue"
+)
+
+var (
+	outDir  string // Directory containing the versioned directories.
+	destDir string // Directory where the CUE files of each version are written.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	flag.StringVar(&destDir, "d", "dist/cue", "directory where the CUE files of each version are written")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+func run() error {
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := semver.NewVersion(e.Name()); err != nil {
+			continue
+		}
+
+		src := filepath.Join(outDir, e.Name(), "jsonschema")
+		if _, err := os.Stat(src); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err := exportDir(src, filepath.Join(destDir, e.Name()), e.Name()); err != nil {
+			return fmt.Errorf("failed exporting %s: %w", e.Name(), err)
+		}
+	}
+	return nil
+}
+
+// exportDir converts every schema in srcDir and replaces dstDir with the CUE
+// files. Files of schemas that no longer exist are removed.
+func exportDir(srcDir, dstDir, version string) (err error) {
+	start := time.Now()
+	staging, err := fsutil.StageDir(dstDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Discard the staging directory if it was not committed.
+		if rmErr := os.RemoveAll(staging); rmErr != nil {
+			err = errors.Join(err, rmErr)
+		}
+	}()
+
+	var count int
+	err = filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, schemaSuffix) {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+
+		out, err := newConverter(srcDir, version).convert(filepath.ToSlash(rel))
+		if err != nil {
+			return fmt.Errorf("failed converting %s: %w", rel, err)
+		}
+
+		dst := filepath.Join(staging, strings.TrimSuffix(rel, schemaSuffix)+cueSuffix)
+		if err := fsutil.WriteFile(dst, out); err != nil {
+			return err
+		}
+		count++
+		slog.Debug("Exported schema.", "path", path)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	changed, err := fsutil.CommitDir(staging, dstDir)
+	if err != nil {
+		return err
+	}
+	slog.Info("Exported schemas to CUE.", "dir", dstDir, "schemas", count, "changed", changed, "duration", time.Since(start))
+	return nil
+}
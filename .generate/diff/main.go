@@ -0,0 +1,93 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// diff compares two trees of schemas semantically, ignoring formatting and
+// key order, and reports the keywords that were added, removed, or changed in
+// each file. It is an aid for reviewing regenerated output where raw JSON
+// diffs are dominated by noise.
+//
+//	go run ./diff [flags] <dirA> <dirB>
+//
+// Like diff(1) it exits with a non-zero status when the trees differ.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/jsondiff"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+var (
+	jsonOutput bool // Write the differences as JSON.
+	maxChanges int  // Maximum number of changes to print per file.
+)
+
+func init() {
+	flag.BoolVar(&jsonOutput, "json", false, "write the differences as a JSON array instead of text")
+	flag.IntVar(&maxChanges, "max-changes", 0, "maximum number of changes to print for each file, 0 for no limit")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <dirA> <dirB>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+func run() error {
+	if flag.NArg() != 2 {
+		flag.Usage()
+		return errors.New("expected two directories to compare")
+	}
+	dirA, dirB := flag.Arg(0), flag.Arg(1)
+	for _, dir := range []string{dirA, dirB} {
+		if _, err := os.Stat(dir); err != nil {
+			return err
+		}
+	}
+
+	diffs, err := jsondiff.CompareDirs(dirA, dirB, nil)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if diffs == nil {
+			diffs = []jsondiff.FileDiff{}
+		}
+		if err := enc.Encode(diffs); err != nil {
+			return err
+		}
+	} else {
+		for _, d := range diffs {
+			d.Write(os.Stdout, maxChanges)
+		}
+	}
+
+	if len(diffs) > 0 {
+		return fmt.Errorf("%d files differ", len(diffs))
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	pageTemplate  = template.Must(template.ParseFS(templateFS, "templates/layout.html", "templates/page.html"))
+	indexTemplate = template.Must(template.ParseFS(templateFS, "templates/layout.html", "templates/index.html"))
+)
+
+// layoutView contains the fields of the layout shared by every page.
+type layoutView struct {
+	Title    string
+	Root     string // Relative path from the page to the root of the site.
+	Version  string
+	Switcher []versionOption
+}
+
+// versionOption is an entry of the version switcher.
+type versionOption struct {
+	Version  string
+	Href     string
+	Selected bool
+}
+
+type pageView struct {
+	layoutView
+	Heading  string
+	Page     *page
+	Sections []sectionView
+}
+
+type sectionView struct {
+	*section
+	ID               string       // Anchor of the section, its JSON pointer.
+	Href             template.URL // Link to the anchor.
+	Definition       bool
+	DefinitionsStart bool // Whether the section is the first definition.
+	Type             linkView
+	Properties       []propertyView
+}
+
+type propertyView struct {
+	*property
+	ID   string
+	Href template.URL
+	Type linkView
+}
+
+type linkView struct {
+	Text        string
+	Href        string
+	Description string
+}
+
+type indexView struct {
+	layoutView
+	Links []linkView
+}
+
+// htmlPage renders the page of a schema as HTML. Sections and property rows
+// have the JSON pointer of their schema as id.
+func htmlPage(p *page, s *site) ([]byte, error) {
+	v := pageView{
+		layoutView: layoutView{
+			Title:   pageTitle(p.Path) + " - package-spec " + p.Version,
+			Root:    rootPath(p.Path),
+			Version: p.Version,
+		},
+		Heading: pageTitle(p.Path),
+		Page:    p,
+	}
+	v.Switcher = switcher(s, p.Version, p.Path, v.Root)
+
+	var inDefs bool
+	for _, sec := range p.Sections {
+		sv := sectionView{
+			section:    sec,
+			ID:         sec.Pointer,
+			Href:       anchorHref(sec.Pointer),
+			Definition: isDefinition(sec.Pointer),
+			Type:       htmlType(p, sec.Type),
+		}
+		if sv.Definition && !inDefs {
+			sv.DefinitionsStart, inDefs = true, true
+		}
+		for _, prop := range sec.Properties {
+			sv.Properties = append(sv.Properties, propertyView{
+				property: prop,
+				ID:       prop.Pointer,
+				Href:     anchorHref(prop.Pointer),
+				Type:     htmlType(p, prop.Type),
+			})
+		}
+		v.Sections = append(v.Sections, sv)
+	}
+	return execute(pageTemplate, v)
+}
+
+// htmlIndex renders the index of the pages of a version.
+func htmlIndex(version string, pages []*page, s *site) ([]byte, error) {
+	v := indexView{layoutView: layoutView{
+		Title:   "package-spec " + version,
+		Root:    "../",
+		Version: version,
+	}}
+	v.Switcher = switcher(s, version, "", v.Root)
+	for _, p := range pages {
+		v.Links = append(v.Links, linkView{
+			Text:        pageTitle(p.Path),
+			Href:        pageFile(p.Path, ".html"),
+			Description: firstSentence(p.Description),
+		})
+	}
+	return execute(indexTemplate, v)
+}
+
+// htmlVersions renders the index of every version.
+func htmlVersions(s *site) ([]byte, error) {
+	v := indexView{layoutView: layoutView{Title: "package-spec schemas"}}
+	for _, version := range s.Versions {
+		v.Links = append(v.Links, linkView{Text: version, Href: version + "/index.html"})
+	}
+	return execute(indexTemplate, v)
+}
+
+// switcher returns the version switcher of a page. Other versions link to
+// the page of the same schema if they have it, or to their index.
+func switcher(s *site, version, file, root string) []versionOption {
+	opts := make([]versionOption, 0, len(s.Versions))
+	for _, other := range s.Versions {
+		href := root + other + "/index.html"
+		if file != "" && slices.Contains(s.Schemas[other], file) {
+			href = root + other + "/" + pageFile(file, ".html")
+		}
+		opts = append(opts, versionOption{Version: other, Href: href, Selected: other == version})
+	}
+	return opts
+}
+
+func htmlType(p *page, t typeDesc) linkView {
+	if t.Link == "" {
+		return linkView{Text: t.Text}
+	}
+	return linkView{Text: t.Text, Href: relativeLink(p.Path, t.Link, ".html")}
+}
+
+// anchorHref returns the link to the anchor of a JSON pointer. The pointer
+// is escaped as a URL fragment, which browsers unescape to find the anchor.
+func anchorHref(ptr string) template.URL {
+	return template.URL("#" + (&url.URL{Fragment: ptr}).EscapedFragment())
+}
+
+// rootPath returns the relative path from the page of a schema to the root
+// of the site, which contains a directory per version.
+func rootPath(file string) string {
+	return strings.Repeat("../", strings.Count(file, "/")+1)
+}
+
+func execute(t *template.Template, data any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "layout", data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
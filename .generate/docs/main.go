@@ -0,0 +1,221 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// docs renders the JSON schemas of each version as Markdown reference
+// documentation or as a static HTML site. Each schema gets a page with a
+// property table for the schema and for each object and definition within
+// it, so integration authors can browse documentation that is guaranteed to
+// match the schemas.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+var (
+	outDir     string // Directory containing the versioned directories.
+	destDir    string // Directory where the documentation is written.
+	formatName string // Output format.
+)
+
+// format renders pages and indexes.
+type format struct {
+	ext       string // Extension of pages.
+	indexFile string // Name of index files.
+	page      func(p *page, s *site) ([]byte, error)
+	index     func(version string, pages []*page, s *site) ([]byte, error)
+	versions  func(s *site) ([]byte, error)
+}
+
+var formats = map[string]format{
+	"markdown": {
+		ext:       ".md",
+		indexFile: "README.md",
+		page:      func(p *page, _ *site) ([]byte, error) { return markdownPage(p), nil },
+		index:     func(v string, pages []*page, _ *site) ([]byte, error) { return markdownIndex(v, pages), nil },
+		versions:  func(s *site) ([]byte, error) { return markdownVersions(s.Versions), nil },
+	},
+	"html": {
+		ext:       ".html",
+		indexFile: "index.html",
+		page:      htmlPage,
+		index:     htmlIndex,
+		versions:  htmlVersions,
+	},
+}
+
+// site lists the documented versions and their schemas.
+type site struct {
+	Versions []string            // Newest first.
+	Schemas  map[string][]string // Schema paths of each version.
+}
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	flag.StringVar(&destDir, "d", "dist/docs", "directory where the documentation of each version is written")
+	flag.StringVar(&formatName, "format", "markdown", "output format (markdown or html)")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+func run() error {
+	f, ok := formats[formatName]
+	if !ok {
+		return fmt.Errorf("unsupported -format %q", formatName)
+	}
+
+	versions, err := versionDirs(outDir)
+	if err != nil {
+		return err
+	}
+	s := &site{Schemas: map[string][]string{}}
+	for _, v := range versions {
+		if s.Schemas[v], err = schemaFiles(filepath.Join(outDir, v, "jsonschema")); err != nil {
+			return err
+		}
+	}
+	// Indexes and the version switcher list the newest version first.
+	s.Versions = slices.Clone(versions)
+	slices.Reverse(s.Versions)
+
+	for _, v := range versions {
+		if err := renderVersion(f, s, v); err != nil {
+			return fmt.Errorf("failed rendering %s: %w", v, err)
+		}
+	}
+
+	out, err := f.versions(s)
+	if err != nil {
+		return err
+	}
+	_, err = fsutil.WriteFileIfChanged(filepath.Join(destDir, f.indexFile), out)
+	return err
+}
+
+// renderVersion replaces the documentation of version with pages rendered
+// from its schemas.
+func renderVersion(f format, s *site, version string) (err error) {
+	start := time.Now()
+	srcDir := filepath.Join(outDir, version, "jsonschema")
+	dstDir := filepath.Join(destDir, version)
+
+	staging, err := fsutil.StageDir(dstDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Discard the staging directory if it was not committed.
+		if rmErr := os.RemoveAll(staging); rmErr != nil {
+			err = errors.Join(err, rmErr)
+		}
+	}()
+
+	var pages []*page
+	for _, file := range s.Schemas[version] {
+		p, err := buildPage(srcDir, file, version)
+		if err != nil {
+			return fmt.Errorf("failed documenting %s: %w", file, err)
+		}
+		pages = append(pages, p)
+
+		out, err := f.page(p, s)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(staging, filepath.FromSlash(pageFile(p.Path, f.ext)))
+		if err := fsutil.WriteFile(dst, out); err != nil {
+			return err
+		}
+	}
+
+	out, err := f.index(version, pages, s)
+	if err != nil {
+		return err
+	}
+	if err := fsutil.WriteFile(filepath.Join(staging, f.indexFile), out); err != nil {
+		return err
+	}
+
+	changed, err := fsutil.CommitDir(staging, dstDir)
+	if err != nil {
+		return err
+	}
+	slog.Info("Rendered documentation.", "dir", dstDir, "format", formatName, "pages", len(pages), "changed", changed, "duration", time.Since(start))
+	return nil
+}
+
+// schemaFiles returns the slash-separated paths of the schemas in dir.
+func schemaFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, schemaSuffix) {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
+
+// versionDirs returns the non-prerelease version directories containing
+// JSON schemas in ascending order.
+func versionDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var versions []*semver.Version
+	for _, e := range entries {
+		v, err := semver.NewVersion(e.Name())
+		if !e.IsDir() || err != nil || v.PreRelease != "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, e.Name(), "jsonschema")); err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no version directories found in %s", dir)
+	}
+	semver.Sort(versions)
+
+	out := make([]string, len(versions))
+	for i, v := range versions {
+		out[i] = v.String()
+	}
+	return out, nil
+}
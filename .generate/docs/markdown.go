@@ -0,0 +1,165 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// markdownPage renders the page of a schema as Markdown. Each section is
+// preceded by an anchor whose name is its JSON pointer so that links can
+// address a location the same way a $ref does.
+func markdownPage(p *page) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", pageTitle(p.Path))
+	fmt.Fprintf(&sb, "Schema `%s` of package-spec %s.\n", cmp.Or(p.ID, p.Path), p.Version)
+
+	var inDefs bool
+	for i, s := range p.Sections {
+		level := "##"
+		if isDefinition(s.Pointer) {
+			if !inDefs {
+				sb.WriteString("\n## Definitions\n")
+				inDefs = true
+			}
+			level = "###"
+		}
+
+		sb.WriteString("\n")
+		if i > 0 {
+			fmt.Fprintf(&sb, "<a id=\"%s\"></a>\n\n", s.Pointer)
+			fmt.Fprintf(&sb, "%s `%s`\n\n", level, s.Title)
+		}
+		if s.Description != "" {
+			sb.WriteString(s.Description + "\n\n")
+		}
+		fmt.Fprintf(&sb, "Type: %s\n", markdownType(p, s.Type))
+		for _, c := range s.Constraints {
+			fmt.Fprintf(&sb, "\n%s\n", markdownConstraint(c))
+		}
+		if len(s.Enum) > 0 {
+			fmt.Fprintf(&sb, "\nAllowed values: %s\n", codeList(s.Enum))
+		}
+
+		if len(s.Properties) == 0 {
+			continue
+		}
+		sb.WriteString("\n| Property | Type | Required | Description |\n")
+		sb.WriteString("|----------|------|----------|-------------|\n")
+		for _, prop := range s.Properties {
+			var details []string
+			if prop.Deprecated {
+				details = append(details, "**Deprecated.**")
+			}
+			if prop.Description != "" {
+				details = append(details, prop.Description)
+			}
+			for _, c := range prop.Constraints {
+				details = append(details, markdownConstraint(c))
+			}
+			if len(prop.Enum) > 0 {
+				details = append(details, "Allowed values: "+codeList(prop.Enum))
+			}
+			required := ""
+			if prop.Required {
+				required = "yes"
+			}
+			fmt.Fprintf(&sb, "| `%s` | %s | %s | %s |\n",
+				tableCell(prop.Name), tableCell(markdownType(p, prop.Type)), required, tableCell(strings.Join(details, "\n\n")))
+		}
+	}
+	return []byte(sb.String())
+}
+
+// markdownIndex renders the index of the pages of a version.
+func markdownIndex(version string, pages []*page) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# package-spec %s\n\n", version)
+	for _, p := range pages {
+		fmt.Fprintf(&sb, "- [%s](%s)", pageTitle(p.Path), pageFile(p.Path, ".md"))
+		if d := firstSentence(p.Description); d != "" {
+			sb.WriteString(" - " + d)
+		}
+		sb.WriteString("\n")
+	}
+	return []byte(sb.String())
+}
+
+// markdownVersions renders the index of every version, newest first.
+func markdownVersions(versions []string) []byte {
+	var sb strings.Builder
+	sb.WriteString("# package-spec schemas\n\n")
+	for _, v := range versions {
+		fmt.Fprintf(&sb, "- [%s](%s/README.md)\n", v, v)
+	}
+	return []byte(sb.String())
+}
+
+// markdownType renders a type, linking to the section documenting it.
+func markdownType(p *page, t typeDesc) string {
+	if t.Link == "" {
+		return t.Text
+	}
+	return fmt.Sprintf("[%s](%s)", t.Text, relativeLink(p.Path, t.Link, ".md"))
+}
+
+// relativeLink converts a link to a schema location, relative to the schema
+// directory, to a link from the page of the schema at from to the page with
+// extension ext that documents the location.
+func relativeLink(from, link, ext string) string {
+	file, ptr, _ := strings.Cut(link, "#")
+	var target string
+	if file != from {
+		rel, err := filepath.Rel(path.Dir(from), pageFile(file, ext))
+		if err != nil {
+			rel = pageFile(file, ext)
+		}
+		target = filepath.ToSlash(rel)
+	}
+	if ptr != "" {
+		target += "#" + (&url.URL{Fragment: ptr}).EscapedFragment()
+	}
+	return target
+}
+
+func markdownConstraint(c constraint) string {
+	if len(c.Values) == 0 {
+		return c.Label
+	}
+	return c.Label + ": " + codeList(c.Values)
+}
+
+func isDefinition(ptr string) bool {
+	return strings.HasPrefix(ptr, "/definitions/") || strings.HasPrefix(ptr, "/$defs/")
+}
+
+func codeList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = "`" + v + "`"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// tableCell escapes text for use in a Markdown table cell.
+func tableCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	s = strings.ReplaceAll(s, "\n\n", "<br><br>")
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// firstSentence returns the first sentence of a description.
+func firstSentence(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if i := strings.Index(s, ". "); i >= 0 {
+		return s[:i+1]
+	}
+	return s
+}
@@ -0,0 +1,448 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const schemaSuffix = ".jsonschema.json"
+
+// page documents a schema file.
+type page struct {
+	Version     string
+	Path        string // Path of the schema relative to the jsonschema directory.
+	ID          string // $id of the schema.
+	Description string
+	Sections    []*section
+}
+
+// section documents an object, array, or definition within a schema. The
+// first section documents the schema itself.
+type section struct {
+	Pointer     string // JSON pointer of the schema within the file.
+	Title       string
+	Description string
+	Type        typeDesc
+	Constraints []constraint
+	Enum        []string
+	Properties  []*property
+}
+
+// property is a row of the property table of a section.
+type property struct {
+	Name        string
+	Pointer     string // JSON pointer of the property schema within the file.
+	Type        typeDesc
+	Required    bool
+	Deprecated  bool
+	Description string
+	Constraints []constraint
+	Enum        []string
+}
+
+// typeDesc describes a type, optionally linking to where it is documented.
+type typeDesc struct {
+	Text string
+	Link string // Page path relative to the schema directory and a fragment.
+}
+
+// pageBuilder documents the schema of one file.
+type pageBuilder struct {
+	file string
+	page *page
+	seen map[string]bool // Pointers with a section.
+}
+
+// buildPage documents the schema at file in the jsonschema directory dir.
+func buildPage(dir, file, version string) (*page, error) {
+	doc, err := loadSchema(dir, file)
+	if err != nil {
+		return nil, err
+	}
+	root, _ := doc.(map[string]any)
+	id, _ := root["$id"].(string)
+
+	b := &pageBuilder{file: file, seen: map[string]bool{}}
+	b.page = &page{Version: version, Path: file, ID: id, Description: description(root)}
+	b.section("", pageTitle(file), root)
+
+	// Definitions are documented after the schema in name order.
+	for _, kw := range []string{"$defs", "definitions"} {
+		defs, _ := root[kw].(map[string]any)
+		for _, name := range slices.Sorted(maps.Keys(defs)) {
+			def, _ := defs[name].(map[string]any)
+			b.section("/"+kw+"/"+escapePointer(name), name, def)
+		}
+	}
+	return b.page, nil
+}
+
+// pageTitle returns the title of the page of a schema file.
+func pageTitle(file string) string {
+	return strings.TrimSuffix(file, schemaSuffix)
+}
+
+// pageFile returns the path of the page of a schema file with ext.
+func pageFile(file, ext string) string {
+	return strings.TrimSuffix(file, schemaSuffix) + ext
+}
+
+// section documents the schema at ptr and the inline objects within it.
+func (b *pageBuilder) section(ptr, title string, node map[string]any) {
+	if b.seen[ptr] {
+		return
+	}
+	b.seen[ptr] = true
+	s := &section{
+		Pointer:     ptr,
+		Title:       title,
+		Description: description(node),
+		Type:        b.typeOf(ptr, node),
+		Constraints: constraints(node),
+		Enum:        enumValues(node),
+	}
+	if s.Type.Link == b.link(b.file, ptr) {
+		// The section documents the type itself.
+		s.Type.Link = ""
+	}
+	b.page.Sections = append(b.page.Sections, s)
+
+	// Arrays and maps are documented by the section of their values.
+	if values, valuesPtr, suffix := containedSchema(node); values != nil && len(objectProperties(node)) == 0 {
+		if len(objectProperties(values)) > 0 && values["$ref"] == nil {
+			b.section(ptr+valuesPtr, title+suffix, values)
+		}
+		return
+	}
+
+	props := objectProperties(node)
+	required := requiredProperties(node)
+	var nested []func()
+	for _, name := range slices.Sorted(maps.Keys(props)) {
+		p := props[name]
+		deprecated, _ := p.schema["deprecated"].(bool)
+		s.Properties = append(s.Properties, &property{
+			Name:        name,
+			Pointer:     ptr + p.ptr,
+			Type:        b.typeOf(ptr+p.ptr, p.schema),
+			Required:    slices.Contains(required, name),
+			Deprecated:  deprecated,
+			Description: description(p.schema),
+			Constraints: constraints(p.schema),
+			Enum:        enumValues(p.schema),
+		})
+		if target, targetPtr, suffix := b.documented(p.schema); target != nil {
+			childTitle := title + "." + name
+			if ptr == "" {
+				childTitle = name
+			}
+			nested = append(nested, func() { b.section(ptr+p.ptr+targetPtr, childTitle+suffix, target) })
+		}
+	}
+	// Nested sections follow the section that contains them.
+	for _, fn := range nested {
+		fn()
+	}
+}
+
+// documented returns the inline object, or object within an array or map,
+// that gets its own section, along with its pointer relative to node and a
+// suffix of its title.
+func (b *pageBuilder) documented(node map[string]any) (map[string]any, string, string) {
+	if node["$ref"] != nil {
+		return nil, "", ""
+	}
+	if len(objectProperties(node)) > 0 {
+		return node, "", ""
+	}
+	values, valuesPtr, suffix := containedSchema(node)
+	if values == nil || values["$ref"] != nil {
+		return nil, "", ""
+	}
+	if len(objectProperties(values)) > 0 {
+		return values, valuesPtr, suffix
+	}
+	return nil, "", ""
+}
+
+// containedSchema returns the schema of the items of an array or the values
+// of a map, with its relative pointer and a title suffix.
+func containedSchema(node map[string]any) (map[string]any, string, string) {
+	if items, ok := node["items"].(map[string]any); ok {
+		return items, "/items", "[]"
+	}
+	if ap, ok := node["additionalProperties"].(map[string]any); ok {
+		return ap, "/additionalProperties", ".*"
+	}
+	if pp, ok := node["patternProperties"].(map[string]any); ok && len(pp) == 1 {
+		for pattern, s := range pp {
+			if schema, ok := s.(map[string]any); ok {
+				return schema, "/patternProperties/" + escapePointer(pattern), ".<" + pattern + ">"
+			}
+		}
+	}
+	return nil, "", ""
+}
+
+// typeOf describes the type of the schema at ptr.
+func (b *pageBuilder) typeOf(ptr string, node map[string]any) typeDesc {
+	if node == nil {
+		return typeDesc{Text: "any"}
+	}
+	if ref, ok := node["$ref"].(string); ok {
+		return b.refType(ref)
+	}
+	if c, ok := node["const"]; ok {
+		return typeDesc{Text: "`" + jsonText(c) + "`"}
+	}
+
+	if len(objectProperties(node)) > 0 {
+		return typeDesc{Text: "object", Link: b.link(b.file, ptr)}
+	}
+	if values, valuesPtr, _ := containedSchema(node); values != nil {
+		elem := b.typeOf(ptr+valuesPtr, values)
+		prefix := "array of "
+		if valuesPtr != "/items" {
+			prefix = "map of "
+		}
+		return typeDesc{Text: prefix + elem.Text, Link: elem.Link}
+	}
+
+	var alts []string
+	for _, kw := range []string{"anyOf", "oneOf"} {
+		subs, _ := node[kw].([]any)
+		for i, s := range subs {
+			sub, _ := s.(map[string]any)
+			t := b.typeOf(ptr+"/"+kw+"/"+strconv.Itoa(i), sub)
+			if t.Text != "any" && !slices.Contains(alts, t.Text) {
+				alts = append(alts, t.Text)
+			}
+		}
+	}
+	if len(alts) == 0 {
+		alts = schemaTypes(node)
+	}
+	if len(alts) == 0 {
+		return typeDesc{Text: "any"}
+	}
+	return typeDesc{Text: strings.Join(alts, " or ")}
+}
+
+// refType describes the target of a $ref and links to its section.
+func (b *pageBuilder) refType(ref string) typeDesc {
+	refPath, fragment, _ := strings.Cut(ref, "#")
+	target := b.file
+	if refPath != "" {
+		target = path.Join(path.Dir(b.file), refPath)
+	}
+	if f, err := url.PathUnescape(fragment); err == nil {
+		fragment = f
+	}
+
+	name := pageTitle(target)
+	if fragment != "" {
+		name = strings.NewReplacer("~1", "/", "~0", "~").Replace(path.Base(fragment))
+	}
+	if target != b.file {
+		name = pageTitle(path.Base(target)) + "#" + name
+		if fragment == "" {
+			name = pageTitle(path.Base(target))
+		}
+	}
+	return typeDesc{Text: name, Link: b.link(target, fragment)}
+}
+
+// link returns the link to the section at ptr of the page of file, relative
+// to the schema directory.
+func (b *pageBuilder) link(file, ptr string) string {
+	if ptr == "" {
+		return file
+	}
+	return file + "#" + ptr
+}
+
+// schemaTypes returns the types of the type keyword.
+func schemaTypes(node map[string]any) []string {
+	switch t := node["type"].(type) {
+	case string:
+		return []string{t}
+	case []any:
+		var types []string
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	if _, ok := node["enum"]; ok {
+		return []string{"enum"}
+	}
+	return nil
+}
+
+// constraint is a validation keyword of a schema and its values.
+type constraint struct {
+	Label  string
+	Values []string
+}
+
+// constraints describes the validation keywords of a schema.
+func constraints(node map[string]any) []constraint {
+	var out []constraint
+	add := func(kw, label string) {
+		if v, ok := node[kw]; ok {
+			out = append(out, constraint{Label: label, Values: []string{jsonText(v)}})
+		}
+	}
+	add("pattern", "Pattern")
+	add("format", "Format")
+	add("minLength", "Minimum length")
+	add("maxLength", "Maximum length")
+	add("minimum", "Minimum")
+	add("maximum", "Maximum")
+	add("exclusiveMinimum", "Exclusive minimum")
+	add("exclusiveMaximum", "Exclusive maximum")
+	add("minItems", "Minimum items")
+	add("maxItems", "Maximum items")
+	if unique, _ := node["uniqueItems"].(bool); unique {
+		out = append(out, constraint{Label: "Items must be unique"})
+	}
+	add("default", "Default")
+	if examples, ok := node["examples"].([]any); ok {
+		c := constraint{Label: "Examples"}
+		for _, e := range examples {
+			c.Values = append(c.Values, jsonText(e))
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// enumValues returns the allowed values of an enum.
+func enumValues(node map[string]any) []string {
+	values, _ := node["enum"].([]any)
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		out = append(out, jsonText(v))
+	}
+	return out
+}
+
+// jsonText returns the JSON encoding of v, or v itself if it is a string.
+func jsonText(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// schemaProperty is a property schema and its pointer relative to the
+// object schema.
+type schemaProperty struct {
+	schema map[string]any
+	ptr    string
+}
+
+// objectProperties returns the properties of an object schema, including
+// properties declared by its allOf, anyOf, oneOf, and conditional
+// subschemas. Properties of the schema itself take precedence.
+func objectProperties(node map[string]any) map[string]schemaProperty {
+	props := map[string]schemaProperty{}
+	var collect func(n map[string]any, ptr string)
+	collect = func(n map[string]any, ptr string) {
+		if p, ok := n["properties"].(map[string]any); ok {
+			for name, s := range p {
+				schema, ok := s.(map[string]any)
+				if _, exists := props[name]; !exists && ok {
+					props[name] = schemaProperty{schema: schema, ptr: ptr + "/properties/" + escapePointer(name)}
+				}
+			}
+		}
+		for _, kw := range []string{"allOf", "anyOf", "oneOf"} {
+			subs, _ := n[kw].([]any)
+			for i, s := range subs {
+				if sub, ok := s.(map[string]any); ok {
+					collect(sub, ptr+"/"+kw+"/"+strconv.Itoa(i))
+				}
+			}
+		}
+		for _, kw := range []string{"then", "else"} {
+			if sub, ok := n[kw].(map[string]any); ok {
+				collect(sub, ptr+"/"+kw)
+			}
+		}
+	}
+	collect(node, "")
+
+	// Conditional schemas may only constrain properties of the object
+	// itself, e.g. with an enum, which must not replace their declaration.
+	own, _ := node["properties"].(map[string]any)
+	for name, p := range props {
+		_, hasType := p.schema["type"]
+		_, hasRef := p.schema["$ref"]
+		if _, ok := own[name]; !ok && !hasType && !hasRef {
+			delete(props, name)
+		}
+	}
+	return props
+}
+
+// requiredProperties returns the unconditionally required properties.
+func requiredProperties(node map[string]any) []string {
+	var out []string
+	req, _ := node["required"].([]any)
+	for _, r := range req {
+		if s, ok := r.(string); ok {
+			out = append(out, s)
+		}
+	}
+	all, _ := node["allOf"].([]any)
+	for _, s := range all {
+		if sub, ok := s.(map[string]any); ok {
+			out = append(out, requiredProperties(sub)...)
+		}
+	}
+	return out
+}
+
+func description(node map[string]any) string {
+	d, _ := node["description"].(string)
+	return strings.TrimSpace(d)
+}
+
+func loadSchema(dir, file string) (any, error) {
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(file)))
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", file, err)
+	}
+	return doc, nil
+}
+
+// escapePointer escapes a JSON pointer reference token.
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
@@ -0,0 +1,243 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// explode is the inverse of bundle. It splits the $defs of a bundled or
+// monolithic schema that embed other schema files back into separate files
+// laid out by their URI below a base URI, and rewrites the references to them
+// into relative references between the files. The result can be compared
+// with the source tree or edited and bundled again.
+//
+//	go run ./explode [flags] <bundle>
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+	"github.com/andrewkroh/package-spec-schema/internal/schemawalk"
+)
+
+var (
+	outDir   string // Directory where the schema files are written.
+	baseURI  string // URI below which the $defs are split into files.
+	rootPath string // Path of the root schema relative to baseURI.
+)
+
+func init() {
+	flag.StringVar(&outDir, "d", "dist/explode", "directory where the schema files are written")
+	flag.StringVar(&baseURI, "base-uri", "https://schemas.elastic.dev/package-spec", "base URI of the schemas; $defs named by or identified with a URI below it are written to the path below it")
+	flag.StringVar(&rootPath, "root", "", "path relative to -base-uri where the root schema is written, defaults to the path of its $id")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <bundle>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+func run() error {
+	if flag.NArg() != 1 {
+		flag.Usage()
+		return errors.New("expected one bundle to explode")
+	}
+	bundle := flag.Arg(0)
+
+	data, err := os.ReadFile(bundle)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var root map[string]any
+	if err := dec.Decode(&root); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", bundle, err)
+	}
+	prefix := strings.TrimSuffix(baseURI, "/") + "/"
+
+	if rootPath == "" {
+		id, _ := root["$id"].(string)
+		p, ok := schemaPath(prefix, id)
+		if !ok {
+			return fmt.Errorf("the $id %q of %s is not below -base-uri, pass -root to name the root schema file", id, bundle)
+		}
+		rootPath = p
+	}
+
+	e := &exploder{root: rootPath, files: map[string]map[string]any{rootPath: root}, names: map[string]string{}}
+	if err := e.split(root, prefix); err != nil {
+		return err
+	}
+
+	dialect, _ := root["$schema"].(string)
+	for _, p := range slices.Sorted(maps.Keys(e.files)) {
+		schema := e.files[p]
+		if _, found := schema["$schema"]; !found && dialect != "" {
+			schema["$schema"] = dialect
+		}
+		if _, found := schema["$id"]; !found {
+			schema["$id"] = prefix + p
+		}
+
+		buf := new(bytes.Buffer)
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(schema); err != nil {
+			return err
+		}
+		if _, err := fsutil.WriteFileIfChanged(filepath.Join(outDir, filepath.FromSlash(p)), buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	slog.Info("Exploded schema.", "path", bundle, "dir", outDir, "files", len(e.files))
+	return nil
+}
+
+// exploder splits the $defs of a bundle into files.
+type exploder struct {
+	root  string                    // Path of the root schema file.
+	files map[string]map[string]any // Schema of each file keyed by path.
+	names map[string]string         // File path keyed by the name of the $defs entry it came from.
+}
+
+// split moves the $defs of the root schema whose name or $id is a URI below
+// prefix into files, then rewrites the references of every file.
+func (e *exploder) split(root map[string]any, prefix string) error {
+	defs, _ := root["$defs"].(map[string]any)
+	// Entries without their own $id are resolved against the bundle root, so
+	// their fragment references point into the bundle.
+	bundleScoped := map[string]bool{e.root: true}
+	for _, name := range slices.Sorted(maps.Keys(defs)) {
+		schema, ok := defs[name].(map[string]any)
+		if !ok {
+			continue
+		}
+		id, hasID := schema["$id"].(string)
+		if !hasID {
+			id = name
+		}
+		p, ok := schemaPath(prefix, id)
+		if !ok {
+			continue
+		}
+		if _, found := e.files[p]; found {
+			return fmt.Errorf("$defs entry %q is written to %s, which is already in use", name, p)
+		}
+		e.files[p] = schema
+		e.names[name] = p
+		bundleScoped[p] = !hasID
+		delete(defs, name)
+	}
+	if len(defs) == 0 {
+		delete(root, "$defs")
+	}
+
+	for p, schema := range e.files {
+		if bundleScoped[p] {
+			if err := e.rewriteRefs(schema, p); err != nil {
+				return fmt.Errorf("failed to rewrite references of %s: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// rewriteRefs rewrites the fragment references in schema, the root of the
+// file at path p, to the files that the bundle locations they point to were
+// written to.
+func (e *exploder) rewriteRefs(schema map[string]any, p string) error {
+	var err error
+	schemawalk.Walk(schema, "", func(node map[string]any, ptr string) bool {
+		if _, found := node["$id"]; (found && ptr != "") || err != nil {
+			// Embedded resources resolve references against their own $id.
+			return false
+		}
+		if ref, ok := node["$ref"].(string); ok && strings.HasPrefix(ref, "#") {
+			var rewritten string
+			if rewritten, err = e.rewriteRef(ref, p); err != nil {
+				return false
+			}
+			node["$ref"] = rewritten
+		}
+		return true
+	})
+	return err
+}
+
+// rewriteRef returns the reference from the file at path p to the location
+// that ref points to in the bundle.
+func (e *exploder) rewriteRef(ref, p string) (string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	target, fragment := e.root, u.Fragment
+	if rest, found := strings.CutPrefix(u.Fragment, "/$defs/"); found {
+		token, pointer, _ := strings.Cut(rest, "/")
+		name := strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		if file, found := e.names[name]; found {
+			target = file
+			fragment = ""
+			if pointer != "" {
+				fragment = "/" + pointer
+			}
+		}
+	}
+
+	out := url.URL{Fragment: fragment}
+	if target != p {
+		rel, err := filepath.Rel(filepath.FromSlash(path.Dir(p)), filepath.FromSlash(target))
+		if err != nil {
+			return "", err
+		}
+		out.Path = filepath.ToSlash(rel)
+		if !strings.HasPrefix(out.Path, "../") {
+			out.Path = "./" + out.Path
+		}
+	}
+	if out.Path == "" && fragment == "" {
+		return "#", nil
+	}
+	return out.String(), nil
+}
+
+// schemaPath returns the path of the URI relative to prefix if the URI is a
+// schema file below it.
+func schemaPath(prefix, uri string) (string, bool) {
+	rel, found := strings.CutPrefix(uri, prefix)
+	if !found || rel == "" || strings.ContainsAny(rel, "#?") {
+		return "", false
+	}
+	if clean := path.Clean(rel); clean != rel || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", false
+	}
+	return rel, true
+}
@@ -0,0 +1,63 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	const prefix = "https://example.com/spec/"
+	var root map[string]any
+	if err := json.Unmarshal([]byte(`{
+		"properties": {
+			"vars": {"$ref": "#/$defs/https:~1~1example.com~1spec~1vars.json"},
+			"default": {"$ref": "#/$defs/https:~1~1example.com~1spec~1vars.json/definitions/value"}
+		},
+		"default": {"$ref": "#/$defs/literal"},
+		"$defs": {
+			"https://example.com/spec/vars.json": {
+				"properties": {"default": {"$ref": "#/$defs/https:~1~1example.com~1spec~1vars.json/definitions/value"}},
+				"definitions": {"value": {"type": "string"}}
+			}
+		}
+	}`), &root); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &exploder{root: "root.json", files: map[string]map[string]any{"root.json": root}, names: map[string]string{}}
+	if err := e.split(root, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"root.json": `{
+			"properties": {
+				"vars": {"$ref": "./vars.json"},
+				"default": {"$ref": "./vars.json#/definitions/value"}
+			},
+			"default": {"$ref": "#/$defs/literal"}
+		}`,
+		"vars.json": `{
+			"properties": {"default": {"$ref": "#/definitions/value"}},
+			"definitions": {"value": {"type": "string"}}
+		}`,
+	}
+	if len(e.files) != len(want) {
+		t.Fatalf("files = %v, want %d", e.files, len(want))
+	}
+	for p, w := range want {
+		var wantSchema map[string]any
+		if err := json.Unmarshal([]byte(w), &wantSchema); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(e.files[p], wantSchema) {
+			got, _ := json.Marshal(e.files[p])
+			t.Errorf("%s = %s", p, got)
+		}
+	}
+}
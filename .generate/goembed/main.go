@@ -0,0 +1,299 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// goembed writes a Go module that embeds the JSON schemas of every version so
+// that Go programs can depend on it and access the schemas at compile time.
+// The schemas of each major version are embedded by their own package (v1,
+// v2, ...) so that programs only carry the major versions that they import.
+// The root package lists the available versions.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+	"github.com/andrewkroh/package-spec-schema/internal/specversion"
+)
+
+var (
+	outDir     string // Directory containing the versioned directories.
+	destDir    string // Directory where the Go module is written.
+	modulePath string // Import path of the Go module.
+	goVersion  string // Go version declared in go.mod.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	flag.StringVar(&destDir, "d", "schemas", "directory where the Go module is written")
+	flag.StringVar(&modulePath, "module", "github.com/andrewkroh/package-spec-schema/schemas", "import path of the Go module")
+	flag.StringVar(&goVersion, "go", "1.21", "Go version declared in go.mod")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+// major is a major version package.
+type major struct {
+	Major    int64
+	Versions []string // Sorted ascending.
+}
+
+func (m major) Package() string { return "v" + strconv.FormatInt(m.Major, 10) }
+func (m major) Latest() string  { return m.Versions[len(m.Versions)-1] }
+
+// Const returns the name of the constant of version.
+func (major) Const(version string) string {
+	return "Version" + strings.ReplaceAll(version, ".", "_")
+}
+
+func run() (err error) {
+	majors, err := majorVersions(outDir)
+	if err != nil {
+		return err
+	}
+	if len(majors) == 0 {
+		return fmt.Errorf("no version directories found in %s", outDir)
+	}
+
+	start := time.Now()
+	staging, err := fsutil.StageDir(destDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Discard the staging directory if it was not committed.
+		if rmErr := os.RemoveAll(staging); rmErr != nil {
+			err = errors.Join(err, rmErr)
+		}
+	}()
+
+	goMod := fmt.Sprintf("module %s\n\ngo %s\n", modulePath, goVersion)
+	if err := fsutil.WriteFile(filepath.Join(staging, "go.mod"), []byte(goMod)); err != nil {
+		return err
+	}
+	if err := writeSource(filepath.Join(staging, "schemas.go"), rootTemplate, map[string]any{
+		"Module":  modulePath,
+		"Majors":  majors,
+		"Latest":  majors[len(majors)-1].Latest(),
+		"Example": majors[len(majors)-1].Package(),
+	}); err != nil {
+		return err
+	}
+
+	if err := writeSpecVersion(filepath.Join(staging, "internal", "specversion", "specversion.go")); err != nil {
+		return err
+	}
+
+	for _, m := range majors {
+		pkgDir := filepath.Join(staging, m.Package())
+		for _, v := range m.Versions {
+			if err := fsutil.CopyDir(filepath.Join(outDir, v, "jsonschema"), filepath.Join(pkgDir, v, "jsonschema")); err != nil {
+				return fmt.Errorf("failed copying %s: %w", v, err)
+			}
+		}
+		if err := writeSource(filepath.Join(pkgDir, m.Package()+".go"), majorTemplate, m); err != nil {
+			return err
+		}
+	}
+
+	changed, err := fsutil.CommitDir(staging, destDir)
+	if err != nil {
+		return err
+	}
+	slog.Info("Wrote Go module.", "dir", destDir, "module", modulePath, "majors", len(majors), "changed", changed, "duration", time.Since(start))
+	return nil
+}
+
+// majorVersions groups the non-prerelease versions with a jsonschema
+// directory in dir by major version.
+func majorVersions(dir string) ([]major, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var versions []*semver.Version
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		v, err := semver.NewVersion(e.Name())
+		if err != nil || v.PreRelease != "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, e.Name(), "jsonschema")); err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	semver.Sort(versions)
+
+	var majors []major
+	for _, v := range versions {
+		if len(majors) == 0 || majors[len(majors)-1].Major != v.Major {
+			majors = append(majors, major{Major: v.Major})
+		}
+		m := &majors[len(majors)-1]
+		m.Versions = append(m.Versions, v.String())
+	}
+	return majors, nil
+}
+
+// writeSource executes the template and writes the formatted Go source.
+func writeSource(name string, tmpl *template.Template, data any) error {
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, data); err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format %s: %w", name, err)
+	}
+	return fsutil.WriteFile(name, src)
+}
+
+// writeSpecVersion writes the version resolution code shared with the tools
+// of this repository, marked as generated, so that the module resolves
+// format_versions in the same way without depending on this repository.
+func writeSpecVersion(name string) error {
+	// Replace the license header, which the generated header repeats.
+	_, body, ok := strings.Cut(specversion.Source, "\n\n")
+	if !ok {
+		return errors.New("specversion source has no license header")
+	}
+	src, err := format.Source([]byte(header + "\n" + body))
+	if err != nil {
+		return fmt.Errorf("failed to format %s: %w", name, err)
+	}
+	return fsutil.WriteFile(name, src)
+}
+
+const header = `// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Code generated by goembed. DO NOT EDIT.
+`
+
+var rootTemplate = template.Must(template.New("root").Parse(header + `
+// Package schemas lists the package-spec versions whose JSON Schemas are
+// embedded by the major version packages of this module. Import only the
+// major versions that you need, e.g. {{ .Module }}/{{ .Example }}.
+package schemas
+
+import "{{ .Module }}/internal/specversion"
+
+// Latest is the newest package-spec version.
+const Latest = "{{ .Latest }}"
+
+// LatestByMajor maps each major version to its newest version.
+var LatestByMajor = map[int]string{
+{{- range .Majors }}
+	{{ .Major }}: "{{ .Latest }}",
+{{- end }}
+}
+
+// Versions lists every embedded version in ascending order.
+var Versions = []string{
+{{- range .Majors }}{{ range .Versions }}
+	"{{ . }}",
+{{- end }}{{ end }}
+}
+
+// Resolve returns the embedded version whose schemas validate packages with
+// the requested format_version. See ResolveSchemaVersion.
+func Resolve(requested string) (string, error) {
+	return ResolveSchemaVersion(requested, Versions)
+}
+
+// ResolveSchemaVersion returns the highest version from available with the
+// major of the requested format_version that is not newer than it, so 3.4.2
+// resolves to 3.4.1 and 3.5.0-next to 3.5.0. It returns an error if requested
+// is not a semantic version or no version of its major is available.
+func ResolveSchemaVersion(requested string, available []string) (string, error) {
+	return specversion.Resolve(requested, available)
+}
+`))
+
+var majorTemplate = template.Must(template.New("major").Parse(header + `
+// Package {{ .Package }} embeds the JSON Schemas of the package-spec
+// {{ .Major }}.x versions. Schema paths are relative to the jsonschema directory of a
+// version, e.g. integration/manifest.jsonschema.json, and relative $refs
+// between schemas resolve within the same file system.
+package {{ .Package }}
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"slices"
+)
+
+// Embedded package-spec versions.
+const (
+{{- range .Versions }}
+	{{ $.Const . }} = "{{ . }}"
+{{- end }}
+)
+
+// Latest is the newest {{ .Major }}.x version.
+const Latest = {{ .Const .Latest }}
+
+// Versions lists the embedded versions in ascending order.
+var Versions = []string{
+{{- range .Versions }}
+	{{ $.Const . }},
+{{- end }}
+}
+
+// The all: prefix includes the _dev directories.
+//
+{{- range .Versions }}
+//go:embed all:{{ . }}
+{{- end }}
+var files embed.FS
+
+// FS returns a file system containing the schemas of version.
+func FS(version string) (fs.FS, error) {
+	if !slices.Contains(Versions, version) {
+		return nil, fmt.Errorf("package-spec version %q is not embedded in {{ .Package }}", version)
+	}
+	return fs.Sub(files, version+"/jsonschema")
+}
+
+// Schema returns the schema at path of version.
+func Schema(version, path string) ([]byte, error) {
+	fsys, err := FS(version)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(fsys, path)
+}
+`))
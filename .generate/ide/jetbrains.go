@@ -0,0 +1,85 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+)
+
+// jetbrainsProject is the root of the .idea/jsonSchemas.xml file that holds
+// the JSON schema mappings of a JetBrains IDE project.
+type jetbrainsProject struct {
+	XMLName   xml.Name `xml:"project"`
+	Version   string   `xml:"version,attr"`
+	Component struct {
+		Name    string           `xml:"name,attr"`
+		Entries []jetbrainsEntry `xml:"state>map>entry"`
+	} `xml:"component"`
+}
+
+type jetbrainsEntry struct {
+	Key     string            `xml:"key,attr"`
+	Options []jetbrainsOption `xml:"value>SchemaInfo>option"`
+}
+
+type jetbrainsOption struct {
+	Name  string         `xml:"name,attr"`
+	Value string         `xml:"value,attr,omitempty"`
+	List  *jetbrainsList `xml:"list,omitempty"`
+}
+
+type jetbrainsList struct {
+	Items []jetbrainsItem `xml:"Item"`
+}
+
+type jetbrainsItem struct {
+	Options []jetbrainsOption `xml:"option"`
+}
+
+// writeJetBrains writes the jsonSchemas.xml project file of JetBrains IDEs
+// such as IntelliJ IDEA and GoLand.
+func writeJetBrains(mappings []mapping) error {
+	var p jetbrainsProject
+	p.Version = "4"
+	p.Component.Name = "JsonSchemaMappingsProjectConfiguration"
+	for _, m := range mappings {
+		patterns := jetbrainsOption{Name: "patterns", List: &jetbrainsList{}}
+		for _, pattern := range m.patterns {
+			patterns.List.Items = append(patterns.List.Items, jetbrainsItem{Options: []jetbrainsOption{
+				{Name: "pattern", Value: "true"},
+				{Name: "path", Value: pattern},
+				{Name: "mappingKind", Value: "Pattern"},
+			}})
+		}
+		p.Component.Entries = append(p.Component.Entries, jetbrainsEntry{
+			Key: m.file.Name,
+			Options: []jetbrainsOption{
+				{Name: "name", Value: m.file.Name},
+				{Name: "relativePathToSchema", Value: m.schema},
+				patterns,
+			},
+		})
+	}
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(p); err != nil {
+		return err
+	}
+	buf.WriteString("\n")
+
+	if settings == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	_, err := fsutil.WriteFileIfChanged(settings, buf.Bytes())
+	return err
+}
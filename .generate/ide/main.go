@@ -0,0 +1,206 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// ide writes editor settings that associate package files with the bundled
+// schemas of a version so that developers get validation and completion
+// without writing the mappings by hand. Schemas are referenced either by their
+// published URLs or, with -local, by their paths in the generated tree.
+//
+// For VS Code the output is a yaml.schemas block for the YAML extension. It is
+// written to stdout, or merged into an existing settings file with -settings.
+//
+// For JetBrains IDEs the output is a jsonSchemas.xml project file. It is
+// written to stdout, or replaces the file given by -settings (typically
+// .idea/jsonSchemas.xml).
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+	"github.com/andrewkroh/package-spec-schema/internal/packagefiles"
+)
+
+var (
+	outDir   string // Directory containing the versioned directories.
+	format   string // Editor settings format.
+	version  string // Version whose schemas are referenced.
+	baseURL  string // URL at which the output directory is published.
+	local    bool   // Reference schemas by local path instead of URL.
+	prefix   string // Glob prepended to each file pattern.
+	settings string // Settings file to update.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	flag.StringVar(&format, "format", "vscode", "editor settings format: vscode or jetbrains")
+	flag.StringVar(&version, "version", "latest", "version whose schemas are referenced, latest selects the newest release")
+	flag.StringVar(&baseURL, "base-url", "https://raw.githubusercontent.com/andrewkroh/package-spec-schema/refs/heads/main", "URL at which the output directory is published")
+	flag.BoolVar(&local, "local", false, "reference schemas by their absolute path in the output directory instead of by URL")
+	flag.StringVar(&prefix, "prefix", "packages/*/", "glob prepended to each file pattern to locate package roots, e.g. empty for a repository containing a single package")
+	flag.StringVar(&settings, "settings", "", "settings file to update (e.g. .vscode/settings.json or .idea/jsonSchemas.xml), defaults to writing the settings to stdout")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+// mapping associates the file patterns of a package file with its schema.
+type mapping struct {
+	file     packagefiles.File
+	schema   string   // URL or path of the schema.
+	patterns []string // File patterns including the prefix.
+}
+
+func run() error {
+	ver, err := resolveVersion(outDir, version)
+	if err != nil {
+		return err
+	}
+	mappings, err := schemaMappings(ver)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "vscode":
+		return writeVSCode(mappings)
+	case "jetbrains":
+		return writeJetBrains(mappings)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// resolveVersion returns the directory name of ver, resolving latest to the
+// newest release that has bundles.
+func resolveVersion(dir, ver string) (string, error) {
+	if ver != "latest" {
+		if _, err := os.Stat(filepath.Join(dir, ver, "bundles")); err != nil {
+			return "", fmt.Errorf("version %s has no bundles: %w", ver, err)
+		}
+		return ver, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var latest *semver.Version
+	for _, e := range entries {
+		v, err := semver.NewVersion(e.Name())
+		if err != nil || v.PreRelease != "" || !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, e.Name(), "bundles")); err != nil {
+			continue
+		}
+		if latest == nil || latest.LessThan(*v) {
+			latest = v
+		}
+	}
+	if latest == nil {
+		return "", fmt.Errorf("no released version with bundles found in %s", dir)
+	}
+	return latest.String(), nil
+}
+
+// schemaMappings returns the mappings of the package files whose schema
+// exists in the version.
+func schemaMappings(ver string) ([]mapping, error) {
+	var mappings []mapping
+	for _, f := range packagefiles.Files {
+		file := filepath.Join(outDir, ver, "bundles", filepath.FromSlash(f.Schema))
+		if _, err := os.Stat(file); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+
+		m := mapping{file: f}
+		if local {
+			abs, err := filepath.Abs(file)
+			if err != nil {
+				return nil, err
+			}
+			m.schema = abs
+		} else {
+			m.schema = strings.TrimSuffix(baseURL, "/") + "/" + path.Join(ver, "bundles", f.Schema)
+		}
+		for _, p := range f.Patterns {
+			m.patterns = append(m.patterns, prefix+p)
+		}
+		mappings = append(mappings, m)
+	}
+	if len(mappings) == 0 {
+		return nil, fmt.Errorf("no schemas found for version %s", ver)
+	}
+	return mappings, nil
+}
+
+// writeVSCode writes the yaml.schemas setting of the VS Code YAML extension.
+func writeVSCode(mappings []mapping) error {
+	schemas := map[string][]string{}
+	for _, m := range mappings {
+		schemas[m.schema] = m.patterns
+	}
+
+	if settings == "" {
+		return encodeJSON(os.Stdout, map[string]any{"yaml.schemas": schemas})
+	}
+
+	// Merge into the existing settings. Comments are not supported because
+	// the file is decoded as plain JSON.
+	current := map[string]any{}
+	b, err := os.ReadFile(settings)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if len(bytes.TrimSpace(b)) > 0 {
+		if err := json.Unmarshal(b, &current); err != nil {
+			return fmt.Errorf("failed to decode %s, it must be JSON without comments: %w", settings, err)
+		}
+	}
+	current["yaml.schemas"] = schemas
+
+	buf := new(bytes.Buffer)
+	if err := encodeJSON(buf, current); err != nil {
+		return err
+	}
+	_, err = fsutil.WriteFileIfChanged(settings, buf.Bytes())
+	return err
+}
+
+func encodeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
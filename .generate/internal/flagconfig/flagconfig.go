@@ -0,0 +1,158 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package flagconfig sets command line flags from environment variables and
+// a YAML configuration file.
+package flagconfig
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFile applies the settings from the YAML file at path to the flags in
+// fs. The file must contain a mapping whose keys are flag names (or aliases
+// of flag names) and whose values are scalars or, for flags that may be
+// repeated, lists of scalars. Flags that were explicitly set on the command
+// line take precedence and are left unchanged. It must be called after
+// fs.Parse.
+func LoadFile(fs *flag.FlagSet, path string, aliases map[string]string) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+	}()
+
+	var doc yaml.Node
+	if err := yaml.NewDecoder(f).Decode(&doc); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: configuration must be a mapping", path)
+	}
+
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	var errs []error
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+
+		name := key.Value
+		if alias, found := aliases[name]; found {
+			name = alias
+		}
+		f := fs.Lookup(name)
+		if f == nil {
+			errs = append(errs, fmt.Errorf("%s:%d: unknown option %q", path, key.Line, key.Value))
+			continue
+		}
+		if seen[name] {
+			errs = append(errs, fmt.Errorf("%s:%d: option %q specified more than once", path, key.Line, key.Value))
+			continue
+		}
+		seen[name] = true
+
+		if explicit[name] {
+			continue
+		}
+
+		values, err := scalars(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: option %q: %w", path, value.Line, key.Value, err))
+			continue
+		}
+		for _, v := range values {
+			if err := fs.Set(name, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s:%d: option %q: %w", path, value.Line, key.Value, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// scalars returns the string values of a scalar node or a sequence of scalar
+// nodes.
+func scalars(n *yaml.Node) ([]string, error) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		return []string{n.Value}, nil
+	case yaml.SequenceNode:
+		out := make([]string, 0, len(n.Content))
+		for _, item := range n.Content {
+			if item.Kind != yaml.ScalarNode {
+				return nil, errors.New("list items must be scalars")
+			}
+			out = append(out, item.Value)
+		}
+		return out, nil
+	default:
+		return nil, errors.New("value must be a scalar or a list of scalars")
+	}
+}
+
+// EnvPrefix is the prefix of environment variables that set flags.
+const EnvPrefix = "PACKAGE_SPEC_SCHEMA_"
+
+// EnvName returns the environment variable name for a flag or alias name.
+// For example, git-url becomes PACKAGE_SPEC_SCHEMA_GIT_URL.
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// LoadEnv sets each flag in fs from its environment variable (see [EnvName]).
+// Aliases map alternative names to flag names and are also looked up. Flags
+// that were explicitly set on the command line take precedence and are left
+// unchanged. It must be called after fs.Parse and before [LoadFile] so that
+// environment variables take precedence over the configuration file.
+func LoadEnv(fs *flag.FlagSet, aliases map[string]string) error {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	envNames := map[string][]string{}
+	fs.VisitAll(func(f *flag.Flag) { envNames[f.Name] = append(envNames[f.Name], EnvName(f.Name)) })
+	for alias, name := range aliases {
+		envNames[name] = append(envNames[name], EnvName(alias))
+	}
+
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(envNames)) {
+		var setBy string
+		for _, env := range envNames[name] {
+			value, found := os.LookupEnv(env)
+			if !found {
+				continue
+			}
+			if setBy != "" {
+				errs = append(errs, fmt.Errorf("%s and %s set the same option", setBy, env))
+				continue
+			}
+			setBy = env
+
+			if explicit[name] {
+				continue
+			}
+			if err := fs.Set(name, value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value for %s: %w", env, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
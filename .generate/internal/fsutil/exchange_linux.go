@@ -0,0 +1,26 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package fsutil
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// exchange atomically swaps the directories at paths a and b. It returns an
+// error wrapping errors.ErrUnsupported if the kernel or file system cannot
+// exchange them.
+func exchange(a, b string) error {
+	err := unix.Renameat2(unix.AT_FDCWD, a, unix.AT_FDCWD, b, unix.RENAME_EXCHANGE)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, unix.ENOSYS), errors.Is(err, unix.EINVAL):
+		return errors.ErrUnsupported
+	}
+	return &os.LinkError{Op: "renameat2", Old: a, New: b, Err: err}
+}
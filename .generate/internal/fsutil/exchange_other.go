@@ -0,0 +1,14 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+//go:build !linux
+
+package fsutil
+
+import "errors"
+
+// exchange is not supported on this platform.
+func exchange(a, b string) error {
+	return errors.ErrUnsupported
+}
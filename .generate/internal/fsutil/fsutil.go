@@ -0,0 +1,257 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package fsutil contains file system helpers shared by the generator tools.
+package fsutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/jsondiff"
+)
+
+// WriteFileIfChanged writes data to the named file unless the file already
+// exists with the same bytes. Skipping identical writes keeps file
+// modification times stable so that tools which sync or commit the output
+// tree only see files whose content changed. Parent directories are created
+// as needed. It reports whether the file was written.
+func WriteFileIfChanged(name string, data []byte) (bool, error) {
+	return writeFileIf(name, data, sameBytes)
+}
+
+// WriteSchemaIfChanged is like [WriteFileIfChanged] but compares schema files
+// with [SameSchemaContent], keeping an existing file that differs only in
+// formatting.
+func WriteSchemaIfChanged(name string, data []byte) (bool, error) {
+	return writeFileIf(name, data, SameSchemaContent)
+}
+
+func writeFileIf(name string, data []byte, same func(name string, existing, data []byte) bool) (bool, error) {
+	existing, err := os.ReadFile(name)
+	switch {
+	case err == nil:
+		if same(name, existing, data) {
+			return false, nil
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return false, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o700); err != nil {
+		return false, err
+	}
+	if err := os.WriteFile(name, data, 0o600); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func sameBytes(_ string, existing, data []byte) bool {
+	return bytes.Equal(existing, data)
+}
+
+// SameSchemaContent reports whether data has the same content as existing,
+// the current content of the named file. Schema files (*.jsonschema.json)
+// that clone writes are reformatted by `just fmt` afterwards, so they are
+// compared as JSON ignoring formatting and key order, like the diff tool
+// does. Other files are compared byte for byte.
+func SameSchemaContent(name string, existing, data []byte) bool {
+	if bytes.Equal(existing, data) {
+		return true
+	}
+	if !strings.HasSuffix(name, ".jsonschema.json") {
+		return false
+	}
+	a, err := jsondiff.Decode(existing)
+	if err != nil {
+		return false
+	}
+	b, err := jsondiff.Decode(data)
+	if err != nil {
+		return false
+	}
+	return len(jsondiff.Diff(a, b)) == 0
+}
+
+// WriteFile writes data to the named file, creating parent directories as
+// needed. Use it for files in a staging directory, which has no previous
+// content to compare against.
+func WriteFile(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, 0o600)
+}
+
+// StageDir creates an empty staging directory alongside dir. Content written
+// to the staging directory can be swapped into place with [CommitDir]. The
+// staging directory name is hidden so that it is not mistaken for output. If
+// a previous [CommitDir] of dir was interrupted, dir is first restored from
+// its backup.
+func StageDir(dir string) (string, error) {
+	parent := filepath.Dir(dir)
+	if err := os.MkdirAll(parent, 0o700); err != nil {
+		return "", err
+	}
+	if err := recoverDir(dir); err != nil {
+		return "", fmt.Errorf("failed to recover %s from an interrupted commit: %w", dir, err)
+	}
+	return os.MkdirTemp(parent, "."+filepath.Base(dir)+".staging-")
+}
+
+// CommitDir replaces dir with the contents of the staging directory. Files
+// in staging that have the same bytes as their counterpart in dir keep the
+// existing modification time so that unchanged files appear untouched. It
+// returns the number of files that are new or whose contents changed.
+//
+// On Linux an existing dir is exchanged with staging in a single atomic
+// renameat2(RENAME_EXCHANGE), so readers see either the previous or the new
+// contents. Where exchanging is unsupported, dir is renamed to a backup
+// before staging is renamed into its place. If the process dies between the
+// two renames, dir is missing until the next [StageDir] of dir restores the
+// backup.
+func CommitDir(staging, dir string) (changed int, err error) {
+	return commitDir(staging, dir, sameBytes)
+}
+
+// CommitSchemaDir is like [CommitDir] but compares schema files with
+// [SameSchemaContent]. Schema files that differ only in formatting keep the
+// bytes of the existing file.
+func CommitSchemaDir(staging, dir string) (changed int, err error) {
+	return commitDir(staging, dir, SameSchemaContent)
+}
+
+func commitDir(staging, dir string, same func(name string, existing, data []byte) bool) (changed int, err error) {
+	changed, err = preserveModTimes(staging, dir, same)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return changed, os.Rename(staging, dir)
+	} else if err != nil {
+		return 0, err
+	}
+
+	switch err := exchange(staging, dir); {
+	case err == nil:
+		// The staging path now holds the previous contents of dir.
+		return changed, os.RemoveAll(staging)
+	case !errors.Is(err, errors.ErrUnsupported):
+		return 0, err
+	}
+
+	backup := backupDir(dir)
+	if err := os.Rename(dir, backup); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		return 0, errors.Join(err, os.Rename(backup, dir))
+	}
+	return changed, os.RemoveAll(backup)
+}
+
+// backupDir returns the path to which dir is renamed while it is replaced.
+func backupDir(dir string) string {
+	return filepath.Join(filepath.Dir(dir), "."+filepath.Base(dir)+".old")
+}
+
+// recoverDir cleans up after a [CommitDir] of dir that was interrupted
+// between renaming dir to its backup and renaming staging into its place. A
+// missing dir is restored from the backup, and a backup left next to dir is
+// removed.
+func recoverDir(dir string) error {
+	backup := backupDir(dir)
+	if _, err := os.Stat(backup); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return os.Rename(backup, dir)
+	} else if err != nil {
+		return err
+	}
+	return os.RemoveAll(backup)
+}
+
+// preserveModTimes copies the bytes and modification time of each file in dst
+// onto the corresponding file in src when same reports that both have the
+// same content. It returns the number of files in src that have no
+// counterpart with the same content in dst.
+func preserveModTimes(src, dst string, same func(name string, existing, data []byte) bool) (changed int, err error) {
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		existing := filepath.Join(dst, rel)
+
+		info, err := os.Stat(existing)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				changed++
+				return nil
+			}
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			changed++
+			return nil
+		}
+
+		a, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		b, err := os.ReadFile(existing)
+		if err != nil {
+			return err
+		}
+		if !same(path, b, a) {
+			changed++
+			return nil
+		}
+		if !bytes.Equal(a, b) {
+			// Keep the formatting of the existing file.
+			if err := os.WriteFile(path, b, 0o600); err != nil {
+				return err
+			}
+		}
+		return os.Chtimes(path, info.ModTime(), info.ModTime())
+	})
+	return changed, err
+}
+
+// CopyDir recursively copies the files in src into dst.
+func CopyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o700)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, b, 0o600)
+	})
+}
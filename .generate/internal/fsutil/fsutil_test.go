@@ -0,0 +1,244 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSameSchemaContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		existing string
+		data     string
+		same     bool
+	}{
+		{"identical", "a.json", `{"a":1}`, `{"a":1}`, true},
+		{"reformatted schema", "a.jsonschema.json", "{\n  \"b\": [1, 2],\n  \"a\": 1\n}\n", `{"a":1,"b":[1,2]}`, true},
+		{"changed schema", "a.jsonschema.json", `{"a":1}`, `{"a":2}`, false},
+		{"reordered array", "a.jsonschema.json", `{"a":[1,2]}`, `{"a":[2,1]}`, false},
+		{"number precision", "a.jsonschema.json", `{"a":1}`, `{"a":1.0}`, false},
+		{"reformatted other JSON", "a.json", "{\n  \"a\": 1\n}\n", `{"a":1}`, false},
+		{"invalid schema", "a.jsonschema.json", `{`, `{}`, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SameSchemaContent(tc.file, []byte(tc.existing), []byte(tc.data)); got != tc.same {
+				t.Errorf("SameSchemaContent() = %v, want %v", got, tc.same)
+			}
+		})
+	}
+}
+
+func TestWriteFileIfChanged(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "a.jsonschema.json")
+	formatted := "{\n  \"type\": \"object\"\n}\n"
+	minified := `{"type":"object"}`
+	writeFile(t, name, formatted)
+
+	changed, err := WriteFileIfChanged(name, []byte(formatted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("identical file was written")
+	}
+
+	// Changes to the formatting alone are written, e.g. by bundle -m.
+	changed, err = WriteFileIfChanged(name, []byte(minified))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("reformatted schema was not written")
+	}
+	if got := readFile(t, name); got != minified {
+		t.Errorf("content = %q, want %q", got, minified)
+	}
+}
+
+func TestWriteSchemaIfChangedKeepsFormattedSchema(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "a.jsonschema.json")
+	formatted := "{\n  \"type\": \"object\"\n}\n"
+	writeFile(t, name, formatted)
+
+	changed, err := WriteSchemaIfChanged(name, []byte(`{"type":"object"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("reformatted schema was written")
+	}
+	if got := readFile(t, name); got != formatted {
+		t.Errorf("content = %q, want %q", got, formatted)
+	}
+}
+
+func TestCommitSchemaDirKeepsFormattedSchema(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "out")
+	formatted := "{\n  \"type\": \"object\"\n}\n"
+	writeFile(t, filepath.Join(dir, "a.jsonschema.json"), formatted)
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "a.jsonschema.json"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	staging, err := StageDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(staging, "a.jsonschema.json"), `{"type":"object"}`)
+	writeFile(t, filepath.Join(staging, "b.jsonschema.json"), `{}`)
+
+	changed, err := CommitSchemaDir(staging, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 1 {
+		t.Errorf("changed = %d, want 1", changed)
+	}
+	if got := readFile(t, filepath.Join(dir, "a.jsonschema.json")); got != formatted {
+		t.Errorf("content = %q, want %q", got, formatted)
+	}
+	info, err := os.Stat(filepath.Join(dir, "a.jsonschema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("modification time = %v, want %v", info.ModTime(), old)
+	}
+}
+
+func TestCommitDirComparesBytes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	writeFile(t, filepath.Join(dir, "a.jsonschema.json"), "{\n  \"type\": \"object\"\n}\n")
+	writeFile(t, filepath.Join(dir, "b.json"), "{}")
+
+	staging, err := StageDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(staging, "a.jsonschema.json"), `{"type":"object"}`)
+	writeFile(t, filepath.Join(staging, "b.json"), "{}")
+
+	changed, err := CommitDir(staging, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 1 {
+		t.Errorf("changed = %d, want 1", changed)
+	}
+	if got := readFile(t, filepath.Join(dir, "a.jsonschema.json")); got != `{"type":"object"}` {
+		t.Errorf("content = %q, want the staged bytes", got)
+	}
+}
+
+func TestCommitDirReplacesDir(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "out")
+	writeFile(t, filepath.Join(dir, "old.json"), "{}")
+
+	staging, err := StageDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(staging, "new.json"), "{}")
+	if _, err := CommitDir(staging, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "old.json")); !os.IsNotExist(err) {
+		t.Errorf("old.json still exists: %v", err)
+	}
+	if got := readFile(t, filepath.Join(dir, "new.json")); got != "{}" {
+		t.Errorf("new.json = %q", got)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("entries left next to dir: %v", entries)
+	}
+}
+
+func TestCommitDirCreatesDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	staging, err := StageDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(staging, "a.json"), "{}")
+
+	changed, err := CommitDir(staging, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed != 1 {
+		t.Errorf("changed = %d, want 1", changed)
+	}
+	if got := readFile(t, filepath.Join(dir, "a.json")); got != "{}" {
+		t.Errorf("a.json = %q", got)
+	}
+}
+
+func TestStageDirRecoversInterruptedCommit(t *testing.T) {
+	tests := []struct {
+		name      string
+		dirExists bool
+		want      string // Content of dir/a.json after StageDir.
+	}{
+		// The process died after dir was renamed to its backup.
+		{"dir missing", false, "backup"},
+		// The process died before the backup was removed.
+		{"backup left over", true, "current"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "out")
+			writeFile(t, filepath.Join(backupDir(dir), "a.json"), "backup")
+			if tc.dirExists {
+				writeFile(t, filepath.Join(dir, "a.json"), "current")
+			}
+
+			staging, err := StageDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(staging)
+
+			if got := readFile(t, filepath.Join(dir, "a.json")); got != tc.want {
+				t.Errorf("a.json = %q, want %q", got, tc.want)
+			}
+			if _, err := os.Stat(backupDir(dir)); !os.IsNotExist(err) {
+				t.Errorf("backup still exists: %v", err)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, name, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,254 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package jsondiff compares JSON documents and trees of JSON files
+// semantically, ignoring formatting and key order.
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Op is the kind of a change.
+type Op string
+
+const (
+	Added   Op = "added"
+	Removed Op = "removed"
+	Changed Op = "changed"
+)
+
+// Change is a single difference between two JSON documents.
+type Change struct {
+	Op      Op     `json:"op"`
+	Pointer string `json:"pointer"`       // JSON pointer to the value.
+	Old     any    `json:"old,omitempty"` // Value in the first document. Unset for additions.
+	New     any    `json:"new,omitempty"` // Value in the second document. Unset for removals.
+}
+
+func (c Change) String() string {
+	ptr := c.Pointer
+	if ptr == "" {
+		ptr = "(root)"
+	}
+	switch c.Op {
+	case Added:
+		return fmt.Sprintf("added %s: %s", ptr, encode(c.New))
+	case Removed:
+		return fmt.Sprintf("removed %s: %s", ptr, encode(c.Old))
+	default:
+		return fmt.Sprintf("changed %s: %s -> %s", ptr, encode(c.Old), encode(c.New))
+	}
+}
+
+// Diff returns the changes that turn a into b ordered by pointer. Arrays are
+// compared element by element.
+func Diff(a, b any) []Change {
+	var changes []Change
+	diffValue("", a, b, &changes)
+	return changes
+}
+
+func diffValue(ptr string, a, b any, changes *[]Change) {
+	switch a := a.(type) {
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			keys := slices.Collect(maps.Keys(a))
+			for k := range b {
+				if _, ok := a[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				p := ptr + "/" + escapePointer(k)
+				av, inA := a[k]
+				bv, inB := b[k]
+				switch {
+				case !inA:
+					*changes = append(*changes, Change{Op: Added, Pointer: p, New: bv})
+				case !inB:
+					*changes = append(*changes, Change{Op: Removed, Pointer: p, Old: av})
+				default:
+					diffValue(p, av, bv, changes)
+				}
+			}
+			return
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			for i := range max(len(a), len(b)) {
+				p := ptr + "/" + strconv.Itoa(i)
+				switch {
+				case i >= len(a):
+					*changes = append(*changes, Change{Op: Added, Pointer: p, New: b[i]})
+				case i >= len(b):
+					*changes = append(*changes, Change{Op: Removed, Pointer: p, Old: a[i]})
+				default:
+					diffValue(p, a[i], b[i], changes)
+				}
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		*changes = append(*changes, Change{Op: Changed, Pointer: ptr, Old: a, New: b})
+	}
+}
+
+// FileDiff describes how a file differs between two trees.
+type FileDiff struct {
+	Path    string   `json:"path"`              // Slash separated path relative to the tree root.
+	Op      Op       `json:"op"`                // Added if only in the second tree, Removed if only in the first.
+	Changes []Change `json:"changes,omitempty"` // Changes of a file present in both trees.
+}
+
+// Write writes a readable description of the file differences to w. At most
+// maxChanges changes are listed unless maxChanges is zero.
+func (d FileDiff) Write(w io.Writer, maxChanges int) {
+	switch d.Op {
+	case Added:
+		fmt.Fprintf(w, "+++ %s (added)\n", d.Path)
+		return
+	case Removed:
+		fmt.Fprintf(w, "--- %s (removed)\n", d.Path)
+		return
+	}
+	fmt.Fprintf(w, "*** %s\n", d.Path)
+	for i, c := range d.Changes {
+		if maxChanges > 0 && i == maxChanges {
+			fmt.Fprintf(w, "  ... and %d more changes\n", len(d.Changes)-i)
+			break
+		}
+		fmt.Fprintf(w, "  %s\n", c)
+	}
+}
+
+// CompareDirs compares the JSON files in dirA and dirB that match include and
+// returns a FileDiff for each file that differs, ordered by path. A nil
+// include matches every file ending in .json. A missing directory is treated
+// as empty.
+func CompareDirs(dirA, dirB string, include func(rel string) bool) ([]FileDiff, error) {
+	if include == nil {
+		include = func(string) bool { return true }
+	}
+	filesA, err := jsonFiles(dirA, include)
+	if err != nil {
+		return nil, err
+	}
+	filesB, err := jsonFiles(dirB, include)
+	if err != nil {
+		return nil, err
+	}
+
+	names := slices.Concat(filesA, filesB)
+	slices.Sort(names)
+	names = slices.Compact(names)
+
+	var diffs []FileDiff
+	for _, name := range names {
+		path := filepath.ToSlash(name)
+		switch {
+		case !slices.Contains(filesA, name):
+			diffs = append(diffs, FileDiff{Path: path, Op: Added})
+		case !slices.Contains(filesB, name):
+			diffs = append(diffs, FileDiff{Path: path, Op: Removed})
+		default:
+			a, err := ReadFile(filepath.Join(dirA, name))
+			if err != nil {
+				return nil, err
+			}
+			b, err := ReadFile(filepath.Join(dirB, name))
+			if err != nil {
+				return nil, err
+			}
+			if changes := Diff(a, b); len(changes) > 0 {
+				diffs = append(diffs, FileDiff{Path: path, Op: Changed, Changes: changes})
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// jsonFiles returns the sorted paths relative to dir of the JSON files it
+// contains that match include.
+func jsonFiles(dir string, include func(string) bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if include(filepath.ToSlash(rel)) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// ReadFile decodes the JSON document in the named file. Numbers are decoded
+// as json.Number to compare them exactly.
+func ReadFile(name string) (any, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	v, err := Decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return v, nil
+}
+
+// Decode decodes the JSON document b like ReadFile.
+func Decode(b []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// encode returns the compact JSON encoding of v for display, truncated if
+// it is long.
+func encode(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	const maxLen = 120
+	if len(b) > maxLen {
+		return string(b[:maxLen]) + "..."
+	}
+	return string(b)
+}
+
+// escapePointer escapes a JSON pointer reference token.
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
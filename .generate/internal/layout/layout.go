@@ -0,0 +1,254 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package layout reads the layout.json files that describe the file structure
+// of packages and checks package directories against them.
+package layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+)
+
+// FileName is the name of the layout file in each jsonschema directory.
+const FileName = "layout.json"
+
+// Layout describes the file structure of packages.
+type Layout struct {
+	// Types maps each package type to the layout of its root directory.
+	Types map[string]*Item `json:"types"`
+}
+
+// Item describes a file or folder of a package. Items are identified by a
+// fixed name or by a regular expression pattern.
+type Item struct {
+	Name               string   `json:"name,omitempty"`
+	Pattern            string   `json:"pattern,omitempty"`
+	Type               string   `json:"type"` // file or folder.
+	Description        string   `json:"description,omitempty"`
+	Required           bool     `json:"required,omitempty"`
+	Visibility         string   `json:"visibility,omitempty"`
+	Development        bool     `json:"development,omitempty"`
+	ContentMediaType   string   `json:"content_media_type,omitempty"`
+	ForbiddenPatterns  []string `json:"forbidden_patterns,omitempty"`
+	Schema             string   `json:"schema,omitempty"` // Schema path relative to the jsonschema directory.
+	AdditionalContents bool     `json:"additional_contents,omitempty"`
+	Limits             *Limits  `json:"limits,omitempty"`
+	Contents           []*Item  `json:"contents,omitempty"`
+}
+
+// Limits are the limits of an item. Sizes are in bytes.
+type Limits struct {
+	Size                int64 `json:"size,omitempty"`
+	TotalSize           int64 `json:"total_size,omitempty"`
+	TotalContents       int   `json:"total_contents,omitempty"`
+	ConfigurationSize   int64 `json:"configuration_size,omitempty"`
+	RelativePathSize    int64 `json:"relative_path_size,omitempty"`
+	FieldsPerDataStream int   `json:"fields_per_data_stream,omitempty"`
+}
+
+// Read reads a layout file.
+func Read(name string) (*Layout, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	l := &Layout{}
+	if err := json.Unmarshal(b, l); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return l, nil
+}
+
+// Problem is a violation of the layout by a package.
+type Problem struct {
+	Path    string `json:"path"` // Slash-separated path relative to the package root, "." for the root.
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return p.Path + ": " + p.Message
+}
+
+// Check checks the file structure of the package of packageType at the root
+// of fsys. It reports missing required files, files that are not allowed or
+// forbidden, and files and folders exceeding their size or count limits. The
+// configuration size, relative path size, and fields per data stream limits
+// are not checked because they depend on the content of the files.
+func (l *Layout) Check(fsys fs.FS, packageType string) ([]Problem, error) {
+	root, found := l.Types[packageType]
+	if !found {
+		return nil, fmt.Errorf("layout does not describe %q packages", packageType)
+	}
+	c := &checker{fsys: fsys, patterns: map[string]*regexp.Regexp{}}
+	if err := c.folder(".", root); err != nil {
+		return nil, err
+	}
+	return c.problems, nil
+}
+
+type checker struct {
+	fsys     fs.FS
+	patterns map[string]*regexp.Regexp // Compiled patterns keyed by expression.
+	problems []Problem
+}
+
+func (c *checker) report(p, format string, args ...any) {
+	c.problems = append(c.problems, Problem{Path: p, Message: fmt.Sprintf(format, args...)})
+}
+
+// folder checks the folder at dir against its item.
+func (c *checker) folder(dir string, item *Item) error {
+	entries, err := fs.ReadDir(c.fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	matched := make([]bool, len(item.Contents))
+	for _, e := range entries {
+		p := path.Join(dir, e.Name())
+		i, err := c.match(e, item.Contents)
+		if err != nil {
+			return err
+		}
+		if i < 0 {
+			if !item.AdditionalContents {
+				c.report(p, "%s is not allowed here", entryType(e))
+			}
+			continue
+		}
+		matched[i] = true
+		child := item.Contents[i]
+
+		forbidden, err := c.matchAny(e.Name(), child.ForbiddenPatterns)
+		if err != nil {
+			return err
+		}
+		if forbidden != "" {
+			c.report(p, "name matches forbidden pattern %s", forbidden)
+			continue
+		}
+
+		if child.Type == "folder" {
+			if err := c.folder(p, child); err != nil {
+				return err
+			}
+			continue
+		}
+		if child.Limits != nil && child.Limits.Size > 0 {
+			info, err := e.Info()
+			if err != nil {
+				return err
+			}
+			if info.Size() > child.Limits.Size {
+				c.report(p, "file size %d exceeds the limit of %d bytes", info.Size(), child.Limits.Size)
+			}
+		}
+	}
+
+	for i, child := range item.Contents {
+		if child.Required && !matched[i] {
+			c.report(path.Join(dir, displayName(child)), "required %s is missing", child.Type)
+		}
+	}
+
+	if item.Limits == nil || (item.Limits.TotalContents == 0 && item.Limits.TotalSize == 0) {
+		return nil
+	}
+	var files int
+	var size int64
+	err = fs.WalkDir(c.fsys, dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files++
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if limit := item.Limits.TotalContents; limit > 0 && files > limit {
+		c.report(dir, "folder contains %d files, exceeding the limit of %d", files, limit)
+	}
+	if limit := item.Limits.TotalSize; limit > 0 && size > limit {
+		c.report(dir, "folder size %d exceeds the limit of %d bytes", size, limit)
+	}
+	return nil
+}
+
+// match returns the index of the item that describes the entry, or -1.
+func (c *checker) match(e fs.DirEntry, items []*Item) (int, error) {
+	for i, item := range items {
+		if (item.Type == "folder") != e.IsDir() {
+			continue
+		}
+		if item.Name != "" {
+			if item.Name == e.Name() {
+				return i, nil
+			}
+			continue
+		}
+		if item.Pattern == "" {
+			continue
+		}
+		re, err := c.compile(item.Pattern)
+		if err != nil {
+			return -1, err
+		}
+		if re.MatchString(e.Name()) {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// matchAny returns the first pattern that matches name, or an empty string.
+func (c *checker) matchAny(name string, patterns []string) (string, error) {
+	for _, pattern := range patterns {
+		re, err := c.compile(pattern)
+		if err != nil {
+			return "", err
+		}
+		if re.MatchString(name) {
+			return pattern, nil
+		}
+	}
+	return "", nil
+}
+
+func (c *checker) compile(pattern string) (*regexp.Regexp, error) {
+	if re, found := c.patterns[pattern]; found {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid layout pattern %q: %w", pattern, err)
+	}
+	c.patterns[pattern] = re
+	return re, nil
+}
+
+// displayName returns the name of an item, or its pattern if it has no fixed name.
+func displayName(item *Item) string {
+	if item.Name != "" {
+		return item.Name
+	}
+	return item.Pattern
+}
+
+func entryType(e fs.DirEntry) string {
+	if e.IsDir() {
+		return "folder"
+	}
+	return "file"
+}
@@ -0,0 +1,127 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package logging configures structured logging for the generator tools.
+package logging
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// LevelTrace is the level used for the most detailed output, such as git
+// progress and the stderr of external tools. It is enabled by -vv.
+const LevelTrace = slog.LevelDebug - 4
+
+var (
+	format   string // Log output format.
+	verbose  bool   // Log per-file messages.
+	verbose2 bool   // Log per-file messages, git progress, and external tool output.
+	quiet    bool   // Log only warnings and errors.
+)
+
+// level is the minimum level that is logged. It is set by Setup.
+var level slog.Level
+
+// AddFlags registers the logging flags with fs.
+func AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&format, "log-format", "text", "log output format (text or json)")
+	fs.BoolVar(&verbose, "v", false, "verbose output, log a message for each file")
+	fs.BoolVar(&verbose2, "vv", false, "very verbose output, additionally log git progress and external tool output")
+	fs.BoolVar(&quiet, "quiet", false, "log only warnings and errors")
+}
+
+// Setup installs the default slog logger according to the flags. It must be
+// called after the flags are parsed. Output from the standard log package is
+// routed through the same logger.
+func Setup() error {
+	switch {
+	case quiet && (verbose || verbose2):
+		return errors.New("-quiet cannot be used with -v or -vv")
+	case quiet:
+		level = slog.LevelWarn
+	case verbose2:
+		level = LevelTrace
+	case verbose:
+		level = slog.LevelDebug
+	default:
+		level = slog.LevelInfo
+	}
+
+	h, err := newHandler(os.Stderr, format, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// Enabled reports whether messages at the given level are logged.
+func Enabled(l slog.Level) bool {
+	return l >= level
+}
+
+// Progress returns a writer for the progress output of long-running
+// operations, such as git sideband messages, or nil if the output should be
+// discarded. Each completed line is logged at LevelTrace. Intermediate updates
+// of a line that are separated by carriage returns are dropped.
+func Progress(msg string) io.Writer {
+	if !Enabled(LevelTrace) {
+		return nil
+	}
+	return &progressWriter{msg: msg}
+}
+
+type progressWriter struct {
+	msg  string
+	line []byte
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		switch b {
+		case '\r':
+			w.line = w.line[:0]
+		case '\n':
+			if len(w.line) > 0 {
+				slog.Log(context.Background(), LevelTrace, w.msg, "progress", string(w.line))
+			}
+			w.line = w.line[:0]
+		default:
+			w.line = append(w.line, b)
+		}
+	}
+	return len(p), nil
+}
+
+func newHandler(w io.Writer, format string, level slog.Level) (slog.Handler, error) {
+	opts := &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.LevelKey && a.Value.Any() == LevelTrace {
+				a.Value = slog.StringValue("TRACE")
+			}
+			return a
+		},
+	}
+	switch format {
+	case "text":
+		return slog.NewTextHandler(w, opts), nil
+	case "json":
+		return slog.NewJSONHandler(w, opts), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q, must be text or json", format)
+	}
+}
+
+// Fatal logs err and exits with a non-zero status.
+func Fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
@@ -0,0 +1,149 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package metaschema validates JSON Schemas against the metaschema of the
+// dialect declared by their $schema. The metaschemas of draft 2020-12 and
+// draft-07 are embedded so validation works offline. It also lists the
+// keywords that each dialect defines.
+package metaschema
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// schemas contains the metaschemas laid out by their json-schema.org URL path.
+//
+//go:embed schemas
+var schemas embed.FS
+
+// Validator validates schemas against their dialect metaschema. Metaschemas
+// are resolved once on first use. It is safe for concurrent use.
+type Validator struct {
+	mu       sync.Mutex
+	resolved map[string]*jsonschema.Resolved // Resolved metaschemas keyed by dialect.
+}
+
+// New returns a Validator.
+func New() *Validator {
+	return &Validator{resolved: map[string]*jsonschema.Resolved{}}
+}
+
+// Validate decodes the JSON Schema in data and validates it against the
+// metaschema of the dialect named by its $schema.
+func (v *Validator) Validate(data []byte) error {
+	var schema any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return fmt.Errorf("failed to decode schema: %w", err)
+	}
+	obj, ok := schema.(map[string]any)
+	if !ok {
+		// Boolean schemas are always valid.
+		if _, ok := schema.(bool); ok {
+			return nil
+		}
+		return errors.New("schema must be an object or a boolean")
+	}
+	dialect, ok := obj["$schema"].(string)
+	if !ok {
+		return errors.New("schema does not declare its dialect with $schema")
+	}
+
+	meta, err := v.metaschema(dialect)
+	if err != nil {
+		return err
+	}
+	return meta.Validate(schema)
+}
+
+// metaschema returns the resolved metaschema of dialect.
+func (v *Validator) metaschema(dialect string) (*jsonschema.Resolved, error) {
+	// Draft-07 is commonly referenced with a trailing empty fragment.
+	dialect = strings.TrimSuffix(dialect, "#")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if rs, ok := v.resolved[dialect]; ok {
+		return rs, nil
+	}
+
+	u, err := url.Parse(dialect)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dialect %q: %w", dialect, err)
+	}
+	s, err := Load(u)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported dialect %q: %w", dialect, err)
+	}
+	rs, err := s.Resolve(&jsonschema.ResolveOptions{BaseURI: dialect, Loader: Load})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve metaschema of %q: %w", dialect, err)
+	}
+	v.resolved[dialect] = rs
+	return rs, nil
+}
+
+// Load is a jsonschema.Loader that reads the embedded metaschemas. It lets
+// schemas that reference a metaschema be resolved offline.
+func Load(u *url.URL) (*jsonschema.Schema, error) {
+	if u.Host != "json-schema.org" {
+		return nil, fmt.Errorf("no embedded metaschema for %s", u)
+	}
+	b, err := schemas.ReadFile(path.Join("schemas", path.Clean(u.Path)) + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("no embedded metaschema for %s", u)
+	}
+	s := new(jsonschema.Schema)
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("failed to decode metaschema %s: %w", u, err)
+	}
+	return s, nil
+}
+
+// Keywords returns the sorted keywords defined by the metaschema of dialect.
+// These are the properties of the metaschema and of the vocabulary
+// metaschemas that it references from allOf.
+func Keywords(dialect string) ([]string, error) {
+	dialect = strings.TrimSuffix(dialect, "#")
+	base, err := url.Parse(dialect)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dialect %q: %w", dialect, err)
+	}
+	root, err := Load(base)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported dialect %q: %w", dialect, err)
+	}
+
+	keywords := map[string]bool{}
+	for k := range root.Properties {
+		keywords[k] = true
+	}
+	for _, s := range root.AllOf {
+		if s.Ref == "" {
+			continue
+		}
+		ref, err := base.Parse(s.Ref)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference %q in metaschema of %q: %w", s.Ref, dialect, err)
+		}
+		vocabulary, err := Load(ref)
+		if err != nil {
+			return nil, err
+		}
+		for k := range vocabulary.Properties {
+			keywords[k] = true
+		}
+	}
+	return slices.Sorted(maps.Keys(keywords)), nil
+}
@@ -0,0 +1,39 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package packagefiles associates the files of an Elastic package with the
+// schemas that describe them. It drives the generation of schema catalogs
+// and editor settings.
+package packagefiles
+
+// File describes a package file and the schema that applies to it.
+type File struct {
+	Schema      string   // Schema path relative to a bundles or jsonschema directory.
+	Name        string   // Human readable name.
+	Description string   // Short description of the file.
+	Patterns    []string // Globs matched against the path of a file relative to the package root.
+}
+
+// Files lists the package files that have a schema. Files without a fixed
+// name or location, such as test configurations, are omitted.
+var Files = []File{
+	{"manifest.jsonschema.json", "Elastic package manifest", "Manifest of an Elastic integration, input, or content package", []string{"manifest.yml"}},
+	{"integration/changelog.jsonschema.json", "Elastic package changelog", "Changelog of an Elastic package", []string{"changelog.yml"}},
+	{"integration/validation.jsonschema.json", "Elastic package validation", "Validation settings of an Elastic package", []string{"validation.yml"}},
+	{"integration/data_stream/manifest.jsonschema.json", "Elastic package data stream manifest", "Manifest of a data stream in an Elastic integration package", []string{"data_stream/*/manifest.yml"}},
+	{"integration/data_stream/fields/fields.jsonschema.json", "Elastic package fields", "Field definitions of an Elastic package", []string{"data_stream/*/fields/*.yml", "fields/*.yml"}},
+	{"integration/data_stream/lifecycle.jsonschema.json", "Elastic package data stream lifecycle", "Data stream lifecycle of an Elastic integration package", []string{"data_stream/*/lifecycle.yml"}},
+	{"integration/data_stream/routing_rules.jsonschema.json", "Elastic package routing rules", "Routing rules of a data stream in an Elastic integration package", []string{"data_stream/*/routing_rules.yml"}},
+	{"integration/elasticsearch/pipeline.jsonschema.json", "Elastic package ingest pipeline", "Elasticsearch ingest pipeline of an Elastic package", []string{"data_stream/*/elasticsearch/ingest_pipeline/*.yml", "elasticsearch/ingest_pipeline/*.yml"}},
+	{"integration/elasticsearch/transform/manifest.jsonschema.json", "Elastic package transform manifest", "Manifest of a transform in an Elastic integration package", []string{"elasticsearch/transform/*/manifest.yml"}},
+	{"integration/elasticsearch/transform/transform.jsonschema.json", "Elastic package transform", "Elasticsearch transform of an Elastic integration package", []string{"elasticsearch/transform/*/transform.yml"}},
+	{"integration/kibana/tags.jsonschema.json", "Elastic package Kibana tags", "Kibana tags of an Elastic package", []string{"kibana/tags.yml"}},
+	{"integration/_dev/build/build.jsonschema.json", "Elastic package build configuration", "Build configuration of an Elastic package", []string{"_dev/build/build.yml"}},
+	{"integration/_dev/deploy/variants.jsonschema.json", "Elastic package deployment variants", "Service deployment variants of an Elastic package", []string{"_dev/deploy/variants.yml"}},
+	{"integration/_dev/deploy/tf/env.jsonschema.json", "Elastic package Terraform environment", "Terraform deployer environment of an Elastic package", []string{"_dev/deploy/tf/env.yml"}},
+	{"integration/_dev/test/config.jsonschema.json", "Elastic package test configuration", "Package level test configuration of an Elastic package", []string{"_dev/test/config.yml"}},
+	{"integration/data_stream/_dev/test/config.jsonschema.json", "Elastic package data stream test configuration", "Data stream test configuration of an Elastic integration package", []string{"data_stream/*/_dev/test/config.yml"}},
+	{"integration/_dev/benchmark/rally.scenario.jsonschema.json", "Elastic package Rally benchmark", "Rally benchmark scenario of an Elastic package", []string{"_dev/benchmark/rally/*.yml"}},
+	{"integration/_dev/benchmark/system.scenario.jsonschema.json", "Elastic package system benchmark", "System benchmark scenario of an Elastic package", []string{"_dev/benchmark/system/*.yml"}},
+}
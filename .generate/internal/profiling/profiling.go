@@ -0,0 +1,73 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package profiling writes pprof CPU and heap profiles of a tool run.
+package profiling
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+var (
+	cpuProfile string // File where the CPU profile is written.
+	memProfile string // File where the heap profile is written.
+)
+
+// AddFlags registers the profiling flags with fs.
+func AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cpuProfile, "cpuprofile", "", "write a CPU profile to this file")
+	fs.StringVar(&memProfile, "memprofile", "", "write a heap profile to this file when the run completes")
+}
+
+// Start begins CPU profiling if requested by the flags. The returned function
+// stops CPU profiling and writes the heap profile. It must be called once the
+// work to be profiled is complete.
+func Start() (stop func() error, err error) {
+	var cpu *os.File
+	if cpuProfile != "" {
+		cpu, err = os.Create(cpuProfile)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(cpu); err != nil {
+			return nil, errors.Join(fmt.Errorf("failed to start CPU profile: %w", err), cpu.Close())
+		}
+	}
+
+	return func() error {
+		var errs []error
+		if cpu != nil {
+			pprof.StopCPUProfile()
+			errs = append(errs, cpu.Close())
+		}
+		if memProfile != "" {
+			errs = append(errs, writeHeapProfile(memProfile))
+		}
+		return errors.Join(errs...)
+	}, nil
+}
+
+func writeHeapProfile(name string) (err error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+	}()
+
+	// Collect garbage to get up-to-date statistics.
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		return fmt.Errorf("failed to write heap profile: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package schemawalk finds the subschemas of JSON Schema documents decoded
+// into the JSON data model. Every keyword is assumed to hold subschemas
+// except those holding instance values, so that references in unknown
+// keywords are found as well. Members of keywords that map names to
+// subschemas are subschemas whatever their names, so a property named
+// "default" is searched while the default keyword is not.
+package schemawalk
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// LiteralKeywords contain instance values rather than subschemas.
+var LiteralKeywords = []string{"const", "default", "enum", "examples"}
+
+// MapKeywords contain an object whose members are subschemas keyed by name
+// rather than keywords.
+var MapKeywords = []string{"$defs", "definitions", "dependencies", "dependentSchemas", "patternProperties", "properties"}
+
+// Subschemas returns the objects that are immediate subschemas of schema
+// along with their JSON pointers relative to schema, in the order of their
+// pointers. The objects within arrays, such as the members of allOf, are
+// subschemas.
+func Subschemas(schema map[string]any) iter.Seq2[string, map[string]any] {
+	return func(yield func(string, map[string]any) bool) {
+		for _, k := range slices.Sorted(maps.Keys(schema)) {
+			if slices.Contains(LiteralKeywords, k) {
+				continue
+			}
+			ptr := "/" + escapePointer(k)
+			members, ok := schema[k].(map[string]any)
+			if !ok || !slices.Contains(MapKeywords, k) {
+				if !objects(ptr, schema[k], yield) {
+					return
+				}
+				continue
+			}
+			for _, name := range slices.Sorted(maps.Keys(members)) {
+				if !objects(ptr+"/"+escapePointer(name), members[name], yield) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Walk calls visit for node, if it is an object, and for each of its
+// subschemas, with their JSON pointers below ptr. The subschemas of an object
+// are skipped if visit returns false.
+func Walk(node any, ptr string, visit func(schema map[string]any, ptr string) bool) {
+	schema, ok := node.(map[string]any)
+	if !ok || !visit(schema, ptr) {
+		return
+	}
+	for rel, sub := range Subschemas(schema) {
+		Walk(sub, ptr+rel, visit)
+	}
+}
+
+// objects yields v if it is an object, or the objects of v if it is an
+// array. It returns false if yield did.
+func objects(ptr string, v any, yield func(string, map[string]any) bool) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		return yield(ptr, v)
+	case []any:
+		for i, child := range v {
+			if !objects(ptr+"/"+strconv.Itoa(i), child, yield) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// escapePointer escapes a JSON pointer reference token.
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
@@ -0,0 +1,67 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package schemawalk
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	var schema any
+	if err := json.Unmarshal([]byte(`{
+		"properties": {
+			"default": {"$ref": "#/$defs/a"},
+			"enum": {"items": {"type": "string"}},
+			"a/b": {}
+		},
+		"default": {"$ref": "#/$defs/literal"},
+		"enum": [{"$ref": "#/$defs/literal"}],
+		"allOf": [{"not": {}}, [{}]],
+		"$defs": {"const": {"type": "string"}},
+		"x-custom": {"nested": {}},
+		"required": ["default"]
+	}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	Walk(schema, "", func(_ map[string]any, ptr string) bool {
+		got = append(got, ptr)
+		return true
+	})
+	want := []string{
+		"",
+		"/$defs/const",
+		"/allOf/0",
+		"/allOf/0/not",
+		"/allOf/1/0",
+		"/properties/a~1b",
+		"/properties/default",
+		"/properties/enum",
+		"/properties/enum/items",
+		"/x-custom",
+		"/x-custom/nested",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("visited %q, want %q", got, want)
+	}
+}
+
+func TestWalkSkip(t *testing.T) {
+	schema := map[string]any{
+		"properties": map[string]any{"a": map[string]any{"items": map[string]any{}}},
+		"not":        map[string]any{},
+	}
+	var got []string
+	Walk(schema, "", func(_ map[string]any, ptr string) bool {
+		got = append(got, ptr)
+		return ptr != "/properties/a"
+	})
+	if want := []string{"", "/not", "/properties/a"}; !slices.Equal(got, want) {
+		t.Errorf("visited %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package specversion
+
+import (
+	_ "embed"
+	"io/fs"
+	"path"
+)
+
+// Source is the source of Resolve, which goembed copies into the generated
+// schemas module so that it resolves versions in the same way.
+//
+//go:embed specversion.go
+var Source string
+
+// Available returns the names of the directories in the root of fsys that
+// are semantic versions and contain a jsonschema directory, such as those of
+// an output directory.
+func Available(fsys fs.FS) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, e := range entries {
+		if _, err := parse(e.Name()); err != nil || !e.IsDir() {
+			continue
+		}
+		if _, err := fs.Stat(fsys, path.Join(e.Name(), "jsonschema")); err != nil {
+			continue
+		}
+		versions = append(versions, e.Name())
+	}
+	return versions, nil
+}
+
+// ResolveFS resolves requested against the versions available in fsys.
+func ResolveFS(fsys fs.FS, requested string) (string, error) {
+	available, err := Available(fsys)
+	if err != nil {
+		return "", err
+	}
+	return Resolve(requested, available)
+}
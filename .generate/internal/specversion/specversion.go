@@ -0,0 +1,93 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package specversion resolves the format_version of a package to the
+// package-spec version whose schemas validate it. It only depends on the
+// standard library because goembed copies it into the generated schemas
+// module.
+package specversion
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Resolve returns the version from available whose schemas validate packages
+// with the requested format_version. Versions of a major are backwards
+// compatible, so it returns the highest available version with the same
+// major that is not newer than requested, such as 3.4.1 for 3.4.2 when no
+// schemas were generated for 3.4.2. A prerelease such as 3.5.0-next resolves
+// like its release, 3.5.0, because the schemas of a release describe its
+// prereleases. Available versions that are not semantic versions are
+// ignored. An error is returned if requested is not a semantic version or no
+// version of its major is available.
+func Resolve(requested string, available []string) (string, error) {
+	req, err := parse(requested)
+	if err != nil {
+		return "", err
+	}
+	req.pre = ""
+
+	var best string
+	var bestVer version
+	for _, a := range available {
+		v, err := parse(a)
+		if err != nil || v.major != req.major || v.compare(req) > 0 {
+			continue
+		}
+		if best == "" || v.compare(bestVer) > 0 {
+			best, bestVer = a, v
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no package-spec %d.x version at or below %s is available", req.major, requested)
+	}
+	return best, nil
+}
+
+// version is a semantic version without build metadata.
+type version struct {
+	major, minor, patch int
+	pre                 string
+}
+
+func parse(s string) (version, error) {
+	core, _, _ := strings.Cut(s, "+")
+	core, pre, _ := strings.Cut(core, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return version{}, fmt.Errorf("invalid semantic version %q", s)
+	}
+	var nums [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || (len(p) > 1 && p[0] == '0') {
+			return version{}, fmt.Errorf("invalid semantic version %q", s)
+		}
+		nums[i] = n
+	}
+	return version{major: nums[0], minor: nums[1], patch: nums[2], pre: pre}, nil
+}
+
+// compare orders versions by precedence. Prereleases are compared as strings,
+// which suffices to order them before their release.
+func (v version) compare(o version) int {
+	switch {
+	case v.major != o.major:
+		return cmp.Compare(v.major, o.major)
+	case v.minor != o.minor:
+		return cmp.Compare(v.minor, o.minor)
+	case v.patch != o.patch:
+		return cmp.Compare(v.patch, o.patch)
+	case v.pre == o.pre:
+		return 0
+	case v.pre == "":
+		return 1
+	case o.pre == "":
+		return -1
+	}
+	return strings.Compare(v.pre, o.pre)
+}
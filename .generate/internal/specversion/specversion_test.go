@@ -0,0 +1,79 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package specversion
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestResolve(t *testing.T) {
+	available := []string{"2.13.0", "3.0.0", "3.3.5", "3.4.0", "3.4.1", "3.5.0-rc1", "latest"}
+	tests := []struct {
+		name      string
+		requested string
+		want      string // Empty if an error is expected.
+	}{
+		{"exact", "3.4.0", "3.4.0"},
+		{"newer patch", "3.4.2", "3.4.1"},
+		{"missing minor", "3.2.0", "3.0.0"},
+		{"newer than available", "3.9.0", "3.5.0-rc1"},
+		{"prerelease", "3.4.1-next", "3.4.1"},
+		{"prerelease of a missing release", "3.4.3-next", "3.4.1"},
+		{"prerelease of an available prerelease", "3.5.0-next", "3.5.0-rc1"},
+		{"build metadata", "3.4.1+build.1", "3.4.1"},
+		{"older major", "2.14.0", "2.13.0"},
+		{"other major", "1.0.0", ""},
+		{"newer major", "4.0.0", ""},
+		{"older than available", "2.12.0", ""},
+		{"missing patch", "3.4", ""},
+		{"leading zero", "3.04.0", ""},
+		{"not a version", "latest", ""},
+		{"empty", "", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Resolve(tc.requested, available)
+			if tc.want == "" {
+				if err == nil {
+					t.Fatalf("Resolve(%q) = %q, want an error", tc.requested, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tc.requested, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolveFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"3.3.5/jsonschema/manifest.jsonschema.json": {},
+		"3.4.1/bundles/manifest.jsonschema.json":    {},
+		"3.4.2/bundles/manifest.jsonschema.json":    {},
+		"3.4.2/jsonschema/manifest.jsonschema.json": {},
+		"versions.json": {},
+		"dist/jsonschema/manifest.jsonschema.json": {},
+	}
+	available, err := Available(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(available) != 2 || available[0] != "3.3.5" || available[1] != "3.4.2" {
+		t.Errorf("Available = %v, want [3.3.5 3.4.2]", available)
+	}
+	// 3.4.1 has no jsonschema directory.
+	got, err := ResolveFS(fsys, "3.4.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "3.3.5" {
+		t.Errorf("ResolveFS(3.4.1) = %q, want 3.3.5", got)
+	}
+}
@@ -0,0 +1,182 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package summary records statistics about a generator run and writes them as
+// a JSON document so that CI jobs can assert on and archive the results.
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+)
+
+var path string // File where the summary is written.
+
+// AddFlags registers the summary flags with fs.
+func AddFlags(fs *flag.FlagSet) {
+	fs.StringVar(&path, "summary-json", "", "write a JSON summary of the run to this file")
+}
+
+// Summary is the JSON document describing a run.
+type Summary struct {
+	Tool         string    `json:"tool"`
+	Versions     []string  `json:"versions"`
+	FilesWritten int       `json:"files_written"`
+	FilesSkipped int       `json:"files_skipped"`
+	Warnings     []string  `json:"warnings"`
+	Failures     []Failure `json:"failures"`
+	Phases       []Phase   `json:"phases"`
+	Duration     float64   `json:"duration_seconds"`
+	Error        string    `json:"error,omitempty"`
+	start        time.Time // Start of the run.
+}
+
+// Failure is a file or version that could not be processed in a run that
+// continued past errors.
+type Failure struct {
+	Version string `json:"version,omitempty"`
+	Path    string `json:"path,omitempty"`
+	Error   string `json:"error"`
+}
+
+// Phase is the cumulative time spent in one phase of a run.
+type Phase struct {
+	Name     string  `json:"name"`
+	Duration float64 `json:"duration_seconds"`
+}
+
+var (
+	mu  sync.Mutex
+	run = Summary{Versions: []string{}, Warnings: []string{}, Failures: []Failure{}, Phases: []Phase{}}
+)
+
+// Start begins recording a run of the named tool. It must be called after
+// the default logger is configured because it wraps the default handler to
+// capture warnings.
+func Start(tool string) {
+	mu.Lock()
+	defer mu.Unlock()
+	run.Tool = tool
+	run.start = time.Now()
+	slog.SetDefault(slog.New(&warningRecorder{Handler: slog.Default().Handler()}))
+}
+
+// AddVersion records that a version was processed.
+func AddVersion(version string) {
+	mu.Lock()
+	defer mu.Unlock()
+	run.Versions = append(run.Versions, version)
+}
+
+// AddFailure records that the file, or the whole version if file is empty,
+// could not be processed.
+func AddFailure(version, file string, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	run.Failures = append(run.Failures, Failure{Version: version, Path: file, Error: err.Error()})
+}
+
+// AddFiles records the number of files that were written and the number that
+// were skipped because their content was unchanged.
+func AddFiles(written, skipped int) {
+	mu.Lock()
+	defer mu.Unlock()
+	run.FilesWritten += written
+	run.FilesSkipped += skipped
+}
+
+// AddFile records a single file write as reported by
+// [fsutil.WriteFileIfChanged].
+func AddFile(written bool) {
+	if written {
+		AddFiles(1, 0)
+	} else {
+		AddFiles(0, 1)
+	}
+}
+
+// StartPhase begins timing the named phase and returns a function that ends
+// it. Time spent in phases of the same name is accumulated.
+func StartPhase(name string) (end func()) {
+	start := time.Now()
+	return func() {
+		d := time.Since(start).Seconds()
+
+		mu.Lock()
+		defer mu.Unlock()
+		if i := slices.IndexFunc(run.Phases, func(p Phase) bool { return p.Name == name }); i >= 0 {
+			run.Phases[i].Duration += d
+			return
+		}
+		run.Phases = append(run.Phases, Phase{Name: name, Duration: d})
+	}
+}
+
+// Write writes the summary to the file given by -summary-json, if set. The
+// error returned by the run, if any, is included in the summary.
+func Write(runErr error) error {
+	if path == "" {
+		return nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	run.Duration = time.Since(run.start).Seconds()
+	if runErr != nil {
+		run.Error = runErr.Error()
+	}
+
+	b, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := fsutil.WriteFileIfChanged(path, append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write summary: %w", err)
+	}
+	return nil
+}
+
+// warningRecorder is a slog.Handler that records the warnings passing through
+// it in the summary. Errors are not recorded because the error that ends a
+// run is reported separately.
+type warningRecorder struct {
+	slog.Handler
+	attrs []slog.Attr
+}
+
+func (h *warningRecorder) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelWarn && r.Level < slog.LevelError {
+		var sb strings.Builder
+		sb.WriteString(r.Message)
+		for _, a := range h.attrs {
+			fmt.Fprintf(&sb, " %s=%v", a.Key, a.Value)
+		}
+		r.Attrs(func(a slog.Attr) bool {
+			fmt.Fprintf(&sb, " %s=%v", a.Key, a.Value)
+			return true
+		})
+
+		mu.Lock()
+		run.Warnings = append(run.Warnings, sb.String())
+		mu.Unlock()
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *warningRecorder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &warningRecorder{Handler: h.Handler.WithAttrs(attrs), attrs: append(slices.Clip(h.attrs), attrs...)}
+}
+
+func (h *warningRecorder) WithGroup(name string) slog.Handler {
+	return &warningRecorder{Handler: h.Handler.WithGroup(name), attrs: h.attrs}
+}
@@ -0,0 +1,358 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package validator
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Formats asserted by the validator in addition to the standard formats.
+// relative-path and data-stream-name are used by the package-spec schemas.
+// The schemas describe version constraints and durations only in prose, so
+// the validator adds version-constraint and duration to the values listed in
+// semanticFormats.
+const (
+	formatRelativePath      = "relative-path"      // Path of a file relative to the file being validated.
+	formatDataStreamName    = "data-stream-name"   // Name of a data stream of the package.
+	formatVersionConstraint = "version-constraint" // Semantic version constraint, e.g. "^8.13.0 || ^9.0.0".
+	formatDuration          = "duration"           // Duration such as "30s", "2h45m", or "7d".
+)
+
+// semanticFormats lists the format of values whose schemas do not declare
+// one, keyed by schema path and then by the JSON pointer of the value's
+// schema. Pointers that do not exist in a version are ignored.
+var semanticFormats = map[string]map[string]string{
+	"integration/manifest.jsonschema.json": {
+		"/definitions/conditions/properties/kibana/properties/version": formatVersionConstraint,
+		"/definitions/conditions/properties/agent/properties/version":  formatVersionConstraint,
+		"/definitions/package_dependency/properties/version":           formatVersionConstraint,
+	},
+	"integration/data_stream/manifest.jsonschema.json": {
+		"/definitions/vars/items/properties/min_duration": formatDuration,
+		"/definitions/vars/items/properties/max_duration": formatDuration,
+	},
+	"integration/data_stream/lifecycle.jsonschema.json": {
+		"/properties/data_retention": formatDuration,
+	},
+	"integration/_dev/benchmark/system.scenario.jsonschema.json": {
+		"/definitions/benchmark_time_period": formatDuration,
+		"/definitions/warmup_time_period":    formatDuration,
+	},
+}
+
+// fileContext is the package file being validated, which formats that
+// refer to other files of the package resolve against.
+type fileContext struct {
+	pkg  fs.FS  // Package root.
+	name string // Slash separated path of the file relative to pkg.
+}
+
+// registerFormats registers the format checkers with the compiler and
+// enables format assertion.
+func registerFormats(c *jsonschema.Compiler) {
+	c.AssertFormat = true
+	c.Formats[formatRelativePath] = isRelativePath
+	c.Formats[formatDataStreamName] = isDataStreamName
+	c.Formats[formatVersionConstraint] = isVersionConstraint
+	c.Formats[formatDuration] = isDuration
+}
+
+// isRelativePath reports whether the value is syntactically a relative path.
+// fileContext.hasPath checks that it refers to files of the package.
+func isRelativePath(value any) bool {
+	s, ok := value.(string)
+	return !ok || (s != "" && !strings.Contains(s, `\`))
+}
+
+// isDataStreamName reports whether the value is syntactically a data stream
+// name. fileContext.hasDataStream checks that the package has the data
+// stream.
+func isDataStreamName(value any) bool {
+	s, ok := value.(string)
+	return !ok || (s != "" && !strings.ContainsAny(s, `/\`) && s != "." && s != "..")
+}
+
+// hasPath reports whether the relative path names a file or, if it is a
+// glob, matches files relative to the directory of the file within the
+// package. Like package-spec, a leading slash is ignored, so that
+// "/img/icon.svg" in the package manifest refers to img/icon.svg.
+func (f fileContext) hasPath(s string) bool {
+	p := path.Join(path.Dir(f.name), s)
+	if !fs.ValidPath(p) {
+		// Outside of the package.
+		return false
+	}
+	matches, err := fs.Glob(f.pkg, p)
+	return err == nil && len(matches) > 0
+}
+
+// hasDataStream reports whether the package has a data stream named s.
+func (f fileContext) hasDataStream(s string) bool {
+	info, err := fs.Stat(f.pkg, path.Join("data_stream", s))
+	return err == nil && info.IsDir()
+}
+
+// checkFormats returns a problem for each value of the instance whose schema
+// has the relative-path or data-stream-name format and that does not refer
+// to a file or data stream of the package. It follows the subschemas that
+// apply to each value, where the branches of anyOf, oneOf, and if are those
+// that the value validates against. Values with an invalid syntax are
+// reported by the format checkers during validation and skipped.
+func (f fileContext) checkFormats(s *jsonschema.Schema, instance any) []Problem {
+	c := formatCheck{file: f, seen: map[formatVisit]bool{}, reported: map[Problem]bool{}}
+	c.walk(s, instance, "")
+	return c.problems
+}
+
+// formatCheck is the state of fileContext.checkFormats.
+type formatCheck struct {
+	file     fileContext
+	seen     map[formatVisit]bool // Schemas already applied to a location.
+	reported map[Problem]bool
+	problems []Problem
+}
+
+type formatVisit struct {
+	schema  *jsonschema.Schema
+	pointer string
+}
+
+func (c *formatCheck) walk(s *jsonschema.Schema, v any, ptr string) {
+	if s == nil || c.seen[formatVisit{s, ptr}] {
+		return
+	}
+	c.seen[formatVisit{s, ptr}] = true
+
+	if str, ok := v.(string); ok {
+		switch {
+		case s.Format == formatRelativePath && isRelativePath(str) && !c.file.hasPath(str),
+			s.Format == formatDataStreamName && isDataStreamName(str) && !c.file.hasDataStream(str):
+			c.report(Problem{Pointer: ptr, Message: quote(str) + " is not valid " + quote(s.Format)})
+		}
+	}
+
+	c.walk(s.Ref, v, ptr)
+	c.walk(s.RecursiveRef, v, ptr)
+	c.walk(s.DynamicRef, v, ptr)
+	for _, sub := range s.AllOf {
+		c.walk(sub, v, ptr)
+	}
+	for _, sub := range slices.Concat(s.AnyOf, s.OneOf) {
+		if sub.Validate(v) == nil {
+			c.walk(sub, v, ptr)
+		}
+	}
+	if s.If != nil {
+		if s.If.Validate(v) == nil {
+			c.walk(s.If, v, ptr)
+			c.walk(s.Then, v, ptr)
+		} else {
+			c.walk(s.Else, v, ptr)
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			child := ptr + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+			matched := false
+			if sub, found := s.Properties[key]; found {
+				matched = true
+				c.walk(sub, value, child)
+			}
+			for re, sub := range s.PatternProperties {
+				if re.MatchString(key) {
+					matched = true
+					c.walk(sub, value, child)
+				}
+			}
+			if sub, ok := s.AdditionalProperties.(*jsonschema.Schema); ok && !matched {
+				c.walk(sub, value, child)
+			}
+			c.walk(s.DependentSchemas[key], v, ptr)
+			if sub, ok := s.Dependencies[key].(*jsonschema.Schema); ok {
+				c.walk(sub, v, ptr)
+			}
+		}
+	case []any:
+		// items is a list of schemas before draft 2020-12 and prefixItems
+		// since.
+		prefix := s.PrefixItems
+		rest := s.Items2020
+		switch items := s.Items.(type) {
+		case *jsonschema.Schema:
+			rest = items
+		case []*jsonschema.Schema:
+			prefix = items
+			rest, _ = s.AdditionalItems.(*jsonschema.Schema)
+		}
+		for i, item := range v {
+			child := ptr + "/" + strconv.Itoa(i)
+			if i < len(prefix) {
+				c.walk(prefix[i], item, child)
+			} else {
+				c.walk(rest, item, child)
+			}
+			if s.Contains != nil && s.Contains.Validate(item) == nil {
+				c.walk(s.Contains, item, child)
+			}
+		}
+	}
+}
+
+func (c *formatCheck) report(p Problem) {
+	if !c.reported[p] {
+		c.reported[p] = true
+		c.problems = append(c.problems, p)
+	}
+}
+
+// quote quotes s like the messages of the jsonschema package.
+func quote(s string) string {
+	s = strconv.Quote(s)
+	s = strings.ReplaceAll(s, `\"`, `"`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s[1:len(s)-1] + "'"
+}
+
+var (
+	constraintComparison = regexp.MustCompile(`^(\^|~>?|>=|<=|!=|>|<|=)?\s*v?([0-9]+|[xX*])(\.([0-9]+|[xX*])){0,2}(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+	constraintSeparator  = regexp.MustCompile(`\s*,\s*|\s+`)
+	constraintOperator   = regexp.MustCompile(`(\^|~>?|>=|<=|!=|>|<|=)\s+`)
+)
+
+// isVersionConstraint reports whether the value is a semantic version
+// constraint: alternatives separated by "||", each a list of comparisons
+// separated by commas or spaces, or a hyphen range such as "1.2 - 1.4".
+func isVersionConstraint(value any) bool {
+	s, ok := value.(string)
+	if !ok {
+		return true
+	}
+	for alt := range strings.SplitSeq(s, "||") {
+		alt = strings.TrimSpace(alt)
+		if alt == "" {
+			return false
+		}
+		if lo, hi, found := strings.Cut(alt, " - "); found {
+			if !isBareVersion(lo) || !isBareVersion(hi) {
+				return false
+			}
+			continue
+		}
+		// Join operators to their version, e.g. ">= 1.0" to ">=1.0".
+		alt = constraintOperator.ReplaceAllString(alt, "$1")
+		for _, cmp := range constraintSeparator.Split(alt, -1) {
+			if !constraintComparison.MatchString(cmp) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isBareVersion reports whether s is a version without an operator.
+func isBareVersion(s string) bool {
+	s = strings.TrimSpace(s)
+	return s != "" && !strings.ContainsAny(s[:1], "^~<>=!") && constraintComparison.MatchString(s)
+}
+
+var durationUnits = []string{"nanos", "micros", "ns", "us", "µs", "ms", "s", "m", "h", "d"}
+
+// isDuration reports whether the value is a duration: a sequence of numbers
+// each with a unit such as "1m30s", as accepted by Go and Elastic Agent, or a
+// single Elasticsearch time unit value such as "7d". A bare "0" is allowed.
+func isDuration(value any) bool {
+	s, ok := value.(string)
+	if !ok {
+		return true
+	}
+	if s == "0" {
+		return true
+	}
+	if s == "" {
+		return false
+	}
+	for s != "" {
+		i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if i <= 0 {
+			return false
+		}
+		if _, err := strconv.ParseFloat(s[:i], 64); err != nil {
+			return false
+		}
+		s = s[i:]
+		unit := ""
+		for _, u := range durationUnits {
+			if strings.HasPrefix(s, u) && len(u) > len(unit) {
+				unit = u
+			}
+		}
+		if unit == "" {
+			return false
+		}
+		s = s[len(unit):]
+	}
+	return true
+}
+
+// addSemanticFormats adds the formats of semanticFormats to the schema file
+// at the path relative to a jsonschema directory. It returns the file
+// unchanged if it has no semantic formats.
+func addSemanticFormats(schema string, r io.ReadCloser) (io.ReadCloser, error) {
+	formats, found := semanticFormats[schema]
+	if !found {
+		return r, nil
+	}
+	defer r.Close()
+
+	var doc any
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	for ptr, format := range formats {
+		if obj, ok := lookupPointer(doc, ptr).(map[string]any); ok {
+			if _, declared := obj["format"]; !declared {
+				obj["format"] = format
+			}
+		}
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+// lookupPointer returns the value at the JSON pointer in doc, or nil if
+// there is none.
+func lookupPointer(doc any, ptr string) any {
+	for _, token := range strings.Split(ptr, "/")[1:] {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		switch v := doc.(type) {
+		case map[string]any:
+			doc = v[token]
+		case []any:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			doc = v[i]
+		default:
+			return nil
+		}
+	}
+	return doc
+}
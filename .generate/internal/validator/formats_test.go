@@ -0,0 +1,321 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package validator
+
+import (
+	"encoding/json"
+	"maps"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+const testBaseURL = "https://schemas.example.com/package-spec"
+
+// testSchemas is an output directory with a version whose package and data
+// stream manifests use the formats that refer to other files of a package.
+var testSchemas = fstest.MapFS{
+	"9.9.9/jsonschema/manifest.jsonschema.json": {Data: []byte(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id": "` + testBaseURL + `/9.9.9/manifest.jsonschema.json",
+		"type": "object",
+		"properties": {
+			"icon": {"type": "string", "format": "relative-path"},
+			"data_stream": {"type": "string", "format": "data-stream-name"}
+		}
+	}`)},
+	"9.9.9/jsonschema/integration/data_stream/manifest.jsonschema.json": {Data: []byte(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id": "` + testBaseURL + `/9.9.9/integration/data_stream/manifest.jsonschema.json",
+		"type": "object",
+		"properties": {
+			"template": {"$ref": "#/$defs/path"}
+		},
+		"$defs": {
+			"path": {"type": "string", "format": "relative-path"}
+		}
+	}`)},
+}
+
+// testPackage is a package with a data stream named logs.
+var testPackage = fstest.MapFS{
+	"manifest.yml":                  {},
+	"img/icon.svg":                  {},
+	"img/logo.svg":                  {},
+	"data_stream/logs/manifest.yml": {},
+	"data_stream/logs/agent/stream/stream.yml.hbs": {},
+}
+
+func TestRelativePathFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string // Package file whose property is set to value.
+		value string
+		valid bool
+	}{
+		{"file", "manifest.yml", "img/icon.svg", true},
+		{"leading slash", "manifest.yml", "/img/icon.svg", true},
+		{"glob", "manifest.yml", "img/*.svg", true},
+		{"missing file", "manifest.yml", "img/missing.svg", false},
+		{"glob without matches", "manifest.yml", "img/*.png", false},
+		{"parent outside package", "manifest.yml", "../icon.svg", false},
+		{"empty", "manifest.yml", "", false},
+		{"backslash", "manifest.yml", `img\icon.svg`, false},
+		{"relative to file", "data_stream/logs/manifest.yml", "agent/stream/stream.yml.hbs", true},
+		{"not relative to root", "data_stream/logs/manifest.yml", "img/icon.svg", false},
+		{"parent within package", "data_stream/logs/manifest.yml", "../../img/icon.svg", true},
+		{"parent outside package from data stream", "data_stream/logs/manifest.yml", "../../../img/icon.svg", false},
+		{"absolute from data stream", "data_stream/logs/manifest.yml", "/agent/stream/stream.yml.hbs", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			key := "icon"
+			if tc.file != "manifest.yml" {
+				key = "template"
+			}
+			problems := validateValue(t, tc.file, key, tc.value)
+			checkFormatProblems(t, problems, tc.valid, formatRelativePath)
+		})
+	}
+}
+
+func TestDataStreamNameFormat(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"logs", true},
+		{"metrics", false},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../logs", false},
+		{"logs/agent", false},
+		{`logs\agent`, false},
+		// A file, not a data stream directory.
+		{"manifest.yml", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			problems := validateValue(t, "manifest.yml", "data_stream", tc.value)
+			checkFormatProblems(t, problems, tc.valid, formatDataStreamName)
+		})
+	}
+}
+
+func TestFileFormatsWithoutPackage(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		valid bool
+	}{
+		// Without a package only the syntax is checked.
+		{"icon", "img/missing.svg", true},
+		{"icon", "../icon.svg", true},
+		{"icon", "", false},
+		{"icon", `img\icon.svg`, false},
+		{"data_stream", "metrics", true},
+		{"data_stream", "", false},
+		{"data_stream", "..", false},
+		{"data_stream", "logs/agent", false},
+	}
+	v := New(testSchemas, testBaseURL)
+	for _, tc := range tests {
+		t.Run(tc.key+"="+tc.value, func(t *testing.T) {
+			problems, err := v.ValidateFile("9.9.9", "manifest.jsonschema.json", yamlObject(t, tc.key, tc.value))
+			if err != nil {
+				t.Fatal(err)
+			}
+			format := formatRelativePath
+			if tc.key == "data_stream" {
+				format = formatDataStreamName
+			}
+			checkFormatProblems(t, problems, tc.valid, format)
+		})
+	}
+}
+
+func TestFileFormatsConcurrent(t *testing.T) {
+	// The packages differ in whether the icon exists, so a file context
+	// shared between the goroutines would produce wrong results.
+	v := New(testSchemas, testBaseURL)
+	data := yamlObject(t, "icon", "img/icon.svg")
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			pkg := fstest.MapFS{"manifest.yml": {Data: data}}
+			valid := i%2 == 0
+			if valid {
+				pkg["img/icon.svg"] = &fstest.MapFile{}
+			}
+			for range 50 {
+				problems, _, err := v.ValidateFiles(pkg, "9.9.9", []string{"manifest.yml"})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if (len(problems) == 0) != valid {
+					t.Errorf("package %d: problems = %+v, want valid %v", i, problems, valid)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+func TestCheckFormats(t *testing.T) {
+	compiler := jsonschema.NewCompiler()
+	registerFormats(compiler)
+	if err := compiler.AddResource("test.json", strings.NewReader(`{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"properties": {
+			"any": {"anyOf": [{"type": "integer"}, {"type": "string", "format": "relative-path"}]},
+			"cond": {
+				"if": {"required": ["kind"], "properties": {"kind": {"const": "file"}}},
+				"then": {"properties": {"name": {"format": "relative-path"}}},
+				"else": {"properties": {"name": {"format": "data-stream-name"}}}
+			},
+			"list": {"prefixItems": [{"format": "data-stream-name"}], "items": {"format": "relative-path"}}
+		},
+		"patternProperties": {"^stream_": {"format": "data-stream-name"}},
+		"additionalProperties": {"$ref": "#/$defs/path"},
+		"$defs": {"path": {"format": "relative-path"}}
+	}`)); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := compiler.Compile("test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var instance any
+	if err := json.Unmarshal([]byte(`{
+		"any": "img/missing.svg",
+		"cond": {"kind": "file", "name": "logs"},
+		"other": {"kind": "stream"},
+		"list": ["logs", "img/icon.svg", "img/logs", "metrics"],
+		"stream_a": "metrics",
+		"stream_b": "logs",
+		"file": "img/*.svg",
+		"bad~/key": ""
+	}`), &instance); err != nil {
+		t.Fatal(err)
+	}
+	file := fileContext{pkg: testPackage, name: "manifest.yml"}
+	got := map[string]string{}
+	for _, p := range file.checkFormats(schema, instance) {
+		got[p.Pointer] = p.Message
+	}
+	want := map[string]string{
+		"/any":       `'img/missing.svg' is not valid 'relative-path'`,
+		"/cond/name": `'logs' is not valid 'relative-path'`,
+		"/list/2":    `'img/logs' is not valid 'relative-path'`,
+		"/list/3":    `'metrics' is not valid 'relative-path'`,
+		"/stream_a":  `'metrics' is not valid 'data-stream-name'`,
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("problems = %v, want %v", got, want)
+	}
+}
+
+// validateValue validates the package file of testPackage containing an
+// object with key set to value and returns the problems found.
+func validateValue(t *testing.T, file, key, value string) []Problem {
+	t.Helper()
+	pkg := fstest.MapFS{}
+	for name, f := range testPackage {
+		pkg[name] = f
+	}
+	pkg[file] = &fstest.MapFile{Data: yamlObject(t, key, value)}
+
+	v := New(testSchemas, testBaseURL)
+	problems, validated, err := v.ValidateFiles(pkg, "9.9.9", []string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(validated) != 1 {
+		t.Fatalf("validated %v, want %s", validated, file)
+	}
+	return problems
+}
+
+// yamlObject returns a YAML document of an object with key set to value.
+func yamlObject(t *testing.T, key, value string) []byte {
+	t.Helper()
+	// JSON is valid YAML, and encoding it avoids quoting issues.
+	b, err := json.Marshal(map[string]string{key: value})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func checkFormatProblems(t *testing.T, problems []Problem, valid bool, format string) {
+	t.Helper()
+	if valid {
+		if len(problems) != 0 {
+			t.Errorf("unexpected problems: %+v", problems)
+		}
+		return
+	}
+	if len(problems) != 1 {
+		t.Fatalf("problems = %+v, want one", problems)
+	}
+	if want := "is not valid '" + format + "'"; !strings.Contains(problems[0].Message, want) {
+		t.Errorf("message %q does not contain %q", problems[0].Message, want)
+	}
+	if problems[0].Line != 1 {
+		t.Errorf("line = %d, want 1", problems[0].Line)
+	}
+}
+
+func TestIsVersionConstraint(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"^8.13.0 || ^9.0.0", true},
+		{">= 1.0, < 2.0", true},
+		{"1.2 - 1.4", true},
+		{"~7.x", true},
+		{"8.13.0", true},
+		{"", false},
+		{"^8.13.0 ||", false},
+		{"latest", false},
+		{"^1.2 - 1.4", false},
+	}
+	for _, tc := range tests {
+		if got := isVersionConstraint(tc.value); got != tc.valid {
+			t.Errorf("isVersionConstraint(%q) = %v, want %v", tc.value, got, tc.valid)
+		}
+	}
+}
+
+func TestIsDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"30s", true},
+		{"2h45m", true},
+		{"7d", true},
+		{"1.5h", true},
+		{"100ms", true},
+		{"0", true},
+		{"", false},
+		{"10", false},
+		{"s", false},
+		{"10x", false},
+	}
+	for _, tc := range tests {
+		if got := isDuration(tc.value); got != tc.valid {
+			t.Errorf("isDuration(%q) = %v, want %v", tc.value, got, tc.valid)
+		}
+	}
+}
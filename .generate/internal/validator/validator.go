@@ -0,0 +1,361 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package validator validates the files of Elastic packages against the
+// generated JSON schemas of their format_version.
+package validator
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+
+	"github.com/andrewkroh/package-spec-schema/internal/packagefiles"
+)
+
+// DefaultBaseURL is the base of the $id of the generated schemas.
+const DefaultBaseURL = "https://schemas.elastic.dev/package-spec"
+
+// Problem is a value of a package file that does not validate against the
+// schema of the file.
+type Problem struct {
+	Path    string `json:"path"`    // Slash separated path of the file relative to the package root.
+	Pointer string `json:"pointer"` // JSON pointer to the invalid value.
+	Line    int    `json:"line"`    // Line of the invalid value in the file.
+	Column  int    `json:"column"`  // Column of the invalid value in the file.
+	Message string `json:"message"`
+}
+
+// Validator validates package files against the schemas in the versioned
+// directories of an output directory. Each schema is compiled once and the
+// compiled schema is reused for every file and package validated. It is safe
+// for concurrent use, so a single Validator should be shared by all the
+// goroutines of a process.
+type Validator struct {
+	fsys    fs.FS  // Output directory containing the versioned directories.
+	baseURL string // Base of the $id of the schemas, without a trailing slash.
+
+	mu       sync.Mutex // Guards compiler and schemas.
+	compiler *jsonschema.Compiler
+	schemas  map[string]compiled // Compiled schemas keyed by URL.
+}
+
+// compiled is a compiled schema.
+type compiled struct {
+	schema *jsonschema.Schema
+	// fileFormats reports whether the schema uses formats that refer to
+	// other files of the package, which checkFormats checks after validation.
+	fileFormats bool
+}
+
+// New returns a Validator of the schemas in fsys, an output directory
+// containing versioned directories. References to URLs below baseURL are
+// loaded from fsys, and all other references fail to resolve.
+func New(fsys fs.FS, baseURL string) *Validator {
+	v := &Validator{
+		fsys:     fsys,
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		compiler: jsonschema.NewCompiler(),
+		schemas:  map[string]compiled{},
+	}
+	v.compiler.LoadURL = v.load
+	registerFormats(v.compiler)
+	return v
+}
+
+// load opens the schema file of a URL of the form
+// <base>/<version>/<schema path>.
+func (v *Validator) load(url string) (io.ReadCloser, error) {
+	rel, found := strings.CutPrefix(url, v.baseURL+"/")
+	if !found {
+		return nil, fmt.Errorf("cannot load %s: not below %s", url, v.baseURL)
+	}
+	ver, schema, _ := strings.Cut(rel, "/")
+	f, err := v.fsys.Open(path.Join(ver, "jsonschema", schema))
+	if err != nil {
+		return nil, err
+	}
+	return addSemanticFormats(schema, f)
+}
+
+// schema returns the compiled schema at the slash separated path relative to
+// the jsonschema directory of version, compiling it on first use. The error
+// wraps fs.ErrNotExist if the version has no such schema.
+func (v *Validator) schema(version, schema string) (compiled, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	url := v.baseURL + "/" + path.Join(version, schema)
+	if c, found := v.schemas[url]; found {
+		return c, nil
+	}
+	if _, err := fs.Stat(v.fsys, path.Join(version, "jsonschema", schema)); err != nil {
+		return compiled{}, err
+	}
+	s, err := v.compiler.Compile(url)
+	if err != nil {
+		return compiled{}, fmt.Errorf("failed to compile %s: %w", url, err)
+	}
+	c := compiled{schema: s, fileFormats: usesFileFormats(s)}
+	v.schemas[url] = c
+	return c, nil
+}
+
+// usesFileFormats reports whether s or any schema it applies uses the
+// relative-path or data-stream-name format.
+func usesFileFormats(s *jsonschema.Schema) bool {
+	seen := map[*jsonschema.Schema]bool{}
+	var walk func(s *jsonschema.Schema) bool
+	walk = func(s *jsonschema.Schema) bool {
+		if s == nil || seen[s] {
+			return false
+		}
+		seen[s] = true
+		if s.Format == formatRelativePath || s.Format == formatDataStreamName {
+			return true
+		}
+		children := []*jsonschema.Schema{
+			s.Ref, s.RecursiveRef, s.DynamicRef, s.Not, s.If, s.Then, s.Else,
+			s.PropertyNames, s.UnevaluatedProperties, s.Items2020, s.Contains,
+			s.UnevaluatedItems, s.ContentSchema,
+		}
+		children = append(children, s.AllOf...)
+		children = append(children, s.AnyOf...)
+		children = append(children, s.OneOf...)
+		children = append(children, s.PrefixItems...)
+		for _, c := range s.Properties {
+			children = append(children, c)
+		}
+		for _, c := range s.PatternProperties {
+			children = append(children, c)
+		}
+		for _, c := range s.DependentSchemas {
+			children = append(children, c)
+		}
+		for _, d := range s.Dependencies {
+			if c, ok := d.(*jsonschema.Schema); ok {
+				children = append(children, c)
+			}
+		}
+		for _, x := range []any{s.AdditionalProperties, s.AdditionalItems, s.Items} {
+			switch x := x.(type) {
+			case *jsonschema.Schema:
+				children = append(children, x)
+			case []*jsonschema.Schema:
+				children = append(children, x...)
+			}
+		}
+		return slices.ContainsFunc(children, walk)
+	}
+	return walk(s)
+}
+
+// ValidateFile validates the YAML data of a package file against the schema
+// at the path relative to the jsonschema directory of version. The problems
+// have no Path. An error is returned if the data is not valid YAML or the
+// schema cannot be compiled. Without the rest of the package, formats that
+// refer to other files of the package are only checked for their syntax.
+func (v *Validator) ValidateFile(version, schema string, data []byte) ([]Problem, error) {
+	return v.validateFile(version, schema, data, fileContext{})
+}
+
+func (v *Validator) validateFile(version, schema string, data []byte, file fileContext) ([]Problem, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind == 0 {
+		// An empty file is a null document.
+		doc = yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}
+	}
+	instance, err := jsonValue(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := v.schema(version, schema)
+	if err != nil {
+		return nil, err
+	}
+	var problems []Problem
+	var ve *jsonschema.ValidationError
+	if err := c.schema.Validate(instance); errors.As(err, &ve) {
+		problems = leafProblems(ve)
+	} else if err != nil {
+		return nil, err
+	}
+	if c.fileFormats && file.pkg != nil {
+		// The format checkers are shared by every file, so they only check
+		// the syntax and the package is consulted here.
+		problems = append(problems, file.checkFormats(c.schema, instance)...)
+	}
+	if len(problems) == 0 {
+		return nil, nil
+	}
+	for i := range problems {
+		problems[i].Line, problems[i].Column = nodePosition(&doc, problems[i].Pointer)
+	}
+	slices.SortStableFunc(problems, func(a, b Problem) int {
+		return cmp.Or(cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+	return problems, nil
+}
+
+// ValidatePackage validates each file of the package in pkg that has a
+// schema in version. It returns the problems found and the paths of the files
+// validated. Files whose schema does not exist in version are skipped.
+func (v *Validator) ValidatePackage(pkg fs.FS, version string) ([]Problem, []string, error) {
+	var names []string
+	err := fs.WalkDir(pkg, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if _, ok := MatchSchema(p); ok {
+			names = append(names, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return v.ValidateFiles(pkg, version, names)
+}
+
+// ValidateFiles validates the files at the slash separated paths names of the
+// package in pkg against their schemas in version. Formats that refer to
+// other files of the package are checked against pkg. It returns the problems
+// found and the paths of the files validated. Files without a schema, or
+// whose schema does not exist in version, are skipped.
+func (v *Validator) ValidateFiles(pkg fs.FS, version string, names []string) ([]Problem, []string, error) {
+	var problems []Problem
+	var validated []string
+	for _, p := range names {
+		schema, ok := MatchSchema(p)
+		if !ok {
+			continue
+		}
+		data, err := fs.ReadFile(pkg, p)
+		if err != nil {
+			return problems, validated, err
+		}
+		found, err := v.validateFile(version, schema, data, fileContext{pkg: pkg, name: p})
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return problems, validated, fmt.Errorf("failed to validate %s: %w", p, err)
+		}
+		validated = append(validated, p)
+		for _, problem := range found {
+			problem.Path = p
+			problems = append(problems, problem)
+		}
+	}
+	return problems, validated, nil
+}
+
+// MatchSchema returns the schema of the package file at rel, a slash
+// separated path relative to the package root.
+func MatchSchema(rel string) (string, bool) {
+	for _, f := range packagefiles.Files {
+		for _, pattern := range f.Patterns {
+			if ok, _ := path.Match(pattern, rel); ok {
+				return f.Schema, true
+			}
+		}
+	}
+	return "", false
+}
+
+// leafProblems returns a problem for each leaf cause of the validation
+// error. Causes with the same location and message, which subschemas of
+// anyOf and oneOf may produce, are reported once.
+func leafProblems(ve *jsonschema.ValidationError) []Problem {
+	var problems []Problem
+	seen := map[Problem]bool{}
+	var walk func(*jsonschema.ValidationError)
+	walk = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) == 0 {
+			p := Problem{Pointer: ve.InstanceLocation, Message: ve.Message}
+			if !seen[p] {
+				seen[p] = true
+				problems = append(problems, p)
+			}
+			return
+		}
+		for _, cause := range ve.Causes {
+			walk(cause)
+		}
+	}
+	walk(ve)
+	return problems
+}
+
+// jsonValue decodes the YAML node into the JSON data model.
+func jsonValue(node *yaml.Node) (any, error) {
+	var v any
+	if err := node.Decode(&v); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// nodePosition returns the line and column of the value at the JSON pointer
+// in the YAML document. Values of objects are located by the position of their
+// key so that, for example, /policy_templates/0/inputs/2/vars points at the
+// "vars:" key. It returns the position of the deepest value found if the
+// pointer does not exist, such as the object missing a required property.
+func nodePosition(doc *yaml.Node, ptr string) (line, column int) {
+	node := doc
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	line, column = node.Line, node.Column
+	for _, token := range strings.Split(ptr, "/")[1:] {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+		// The values of an alias are those of its anchor.
+		for node.Kind == yaml.AliasNode && node.Alias != nil {
+			node = node.Alias
+		}
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == token {
+					line, column = node.Content[i].Line, node.Content[i].Column
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if i, err := strconv.Atoi(token); err == nil && i < len(node.Content) {
+				next = node.Content[i]
+				line, column = next.Line, next.Column
+			}
+		}
+		if next == nil {
+			break
+		}
+		node = next
+	}
+	return line, column
+}
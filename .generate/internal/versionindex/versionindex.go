@@ -0,0 +1,175 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// Package versionindex reads and writes the versions.json index that lists
+// the versions present in an output directory.
+package versionindex
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+)
+
+// FileName is the name of the index file at the root of the output directory.
+const FileName = "versions.json"
+
+// Index is the machine-readable index of generated versions that is written
+// to the root of the output directory.
+type Index struct {
+	// Latest is the newest non-prerelease version.
+	Latest string `json:"latest,omitempty"`
+	// LatestByMajor maps each major version number to its newest
+	// non-prerelease version.
+	LatestByMajor map[string]string `json:"latest_by_major"`
+	// Versions lists every generated version sorted by semantic version.
+	Versions []Entry `json:"versions"`
+}
+
+// Entry describes a single generated version.
+type Entry struct {
+	Version string `json:"version"`
+	// Commit is the package-spec commit SHA from which the version was generated.
+	Commit string `json:"commit,omitempty"`
+	// Generated is the time at which the commit was first generated. It is
+	// not updated by regenerating the same commit so that the index stays
+	// stable across runs.
+	Generated time.Time `json:"generated,omitzero"`
+	// Encodings lists the content encodings, such as "gzip", for which every
+	// schema of the version has a precompressed sibling (e.g.
+	// manifest.jsonschema.json.gz).
+	Encodings []string `json:"encodings,omitempty"`
+}
+
+// encodingSuffixes maps the content encodings of precompressed schemas to
+// the suffix of their file names.
+var encodingSuffixes = map[string]string{
+	"gzip": ".gz",
+}
+
+// Read reads the index from dir. A missing index yields an empty index.
+func Read(dir string) (*Index, error) {
+	idx := &Index{}
+	b, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return idx, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// Update records that version was generated from commit and returns the
+// updated entry.
+func (idx *Index) Update(version, commit string, now time.Time) Entry {
+	i := slices.IndexFunc(idx.Versions, func(e Entry) bool { return e.Version == version })
+	if i < 0 {
+		idx.Versions = append(idx.Versions, Entry{Version: version})
+		i = len(idx.Versions) - 1
+	}
+	e := &idx.Versions[i]
+	if e.Commit != commit || e.Generated.IsZero() {
+		e.Commit = commit
+		e.Generated = now.UTC().Truncate(time.Second)
+	}
+	return *e
+}
+
+// Write synchronizes the index with the version directories that exist in
+// dir, recomputes the latest pointers, and writes the index to dir.
+func (idx *Index) Write(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	byVersion := map[string]Entry{}
+	for _, e := range idx.Versions {
+		byVersion[e.Version] = e
+	}
+
+	var versions []*semver.Version
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		v, err := semver.NewVersion(e.Name())
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, e.Name(), "jsonschema")); err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	semver.Sort(versions)
+
+	idx.Latest = ""
+	idx.LatestByMajor = map[string]string{}
+	idx.Versions = make([]Entry, 0, len(versions))
+	for _, v := range versions {
+		e, found := byVersion[v.String()]
+		if !found {
+			e = Entry{Version: v.String()}
+		}
+		if e.Encodings, err = encodings(filepath.Join(dir, v.String())); err != nil {
+			return err
+		}
+		idx.Versions = append(idx.Versions, e)
+
+		if v.PreRelease == "" {
+			idx.Latest = v.String()
+			idx.LatestByMajor[strconv.FormatInt(v.Major, 10)] = v.String()
+		}
+	}
+
+	b, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fsutil.WriteFileIfChanged(filepath.Join(dir, FileName), append(b, '\n'))
+	return err
+}
+
+// encodings returns the content encodings for which every schema beneath dir
+// has a precompressed sibling, sorted.
+func encodings(dir string) ([]string, error) {
+	var schemas []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && strings.HasSuffix(path, ".jsonschema.json") {
+			schemas = append(schemas, path)
+		}
+		return err
+	})
+	if err != nil || len(schemas) == 0 {
+		return nil, err
+	}
+
+	var out []string
+	for _, enc := range slices.Sorted(maps.Keys(encodingSuffixes)) {
+		complete := !slices.ContainsFunc(schemas, func(path string) bool {
+			_, err := os.Stat(path + encodingSuffixes[enc])
+			return err != nil
+		})
+		if complete {
+			out = append(out, enc)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,126 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/metaschema"
+)
+
+// keywordsReportFile is the name of the unknown keyword inventory written to
+// the report directory.
+const keywordsReportFile = "unknown-keywords.json"
+
+// keywordInventory lists the keywords of the schemas that are not defined by
+// their dialect.
+type keywordInventory struct {
+	// Counts is the number of occurrences of each keyword across versions.
+	Counts map[string]int `json:"counts"`
+	// Versions maps version, file, and keyword to the JSON pointers of the
+	// schemas that use the keyword.
+	Versions map[string]map[string]map[string][]string `json:"versions"`
+
+	dialects map[string][]string // Keywords of each dialect.
+}
+
+func newKeywordInventory() *keywordInventory {
+	return &keywordInventory{
+		Counts:   map[string]int{},
+		Versions: map[string]map[string]map[string][]string{},
+		dialects: map[string][]string{},
+	}
+}
+
+// addVersion records the unknown keywords of every schema in dir. It returns
+// the number of occurrences found. Extension keywords, which have an x-
+// prefix, are described by the vocabulary of the generated schemas and are
+// not reported.
+func (inv *keywordInventory) addVersion(version, dir string) (int, error) {
+	var found int
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".jsonschema.json") {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var doc any
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+		root, ok := doc.(map[string]any)
+		if !ok {
+			return nil
+		}
+		dialect, ok := root["$schema"].(string)
+		if !ok {
+			return fmt.Errorf("%s does not declare its dialect with $schema", path)
+		}
+		known, err := inv.keywords(dialect)
+		if err != nil {
+			return err
+		}
+
+		unknown := map[string][]string{}
+		walkSchema(doc, "", func(schema map[string]any, ptr string) {
+			for k := range schema {
+				if strings.HasPrefix(k, "x-") || slices.Contains(known, k) {
+					continue
+				}
+				unknown[k] = append(unknown[k], ptr)
+			}
+		})
+		if len(unknown) == 0 {
+			return nil
+		}
+		if inv.Versions[version] == nil {
+			inv.Versions[version] = map[string]map[string][]string{}
+		}
+		inv.Versions[version][filepath.ToSlash(rel)] = unknown
+		for k, ptrs := range unknown {
+			slices.Sort(ptrs)
+			inv.Counts[k] += len(ptrs)
+			found += len(ptrs)
+		}
+		return nil
+	})
+	return found, err
+}
+
+// keywords returns the keywords defined by dialect.
+func (inv *keywordInventory) keywords(dialect string) ([]string, error) {
+	if k, found := inv.dialects[dialect]; found {
+		return k, nil
+	}
+	k, err := metaschema.Keywords(dialect)
+	if err != nil {
+		return nil, err
+	}
+	inv.dialects[dialect] = k
+	return k, nil
+}
+
+func (inv *keywordInventory) write() error {
+	b, err := json.MarshalIndent(inv, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fsutil.WriteFileIfChanged(filepath.Join(reportDir, keywordsReportFile), append(b, '\n'))
+	return err
+}
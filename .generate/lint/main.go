@@ -0,0 +1,200 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// lint checks the generated schemas of each version directory for problems
+// such as unnecessary allOf wrappers or unreachable branches and writes a
+// JSON report per version. The issues typically originate from the upstream
+// package-spec and the reports help drive fixes there.
+//
+// With -keywords it instead writes an inventory of the keywords that are not
+// defined by the dialect of each schema, grouped by version and file. These
+// upstream constructs need patching or an extension vocabulary before strict
+// validators accept the schemas.
+//
+// With -orphans it instead writes a report of the schemas of each version
+// that no other schema references and that do not describe a known package
+// file. These are candidates for dead upstream spec files.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+var (
+	outDir    string // Directory containing the versioned directories.
+	reportDir string // Directory where reports are written.
+	fail      bool   // Exit with an error if any issue is found.
+	keywords  bool   // Write an inventory of unknown keywords instead of linting.
+	orphans   bool   // Write a report of orphan schemas instead of linting.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	flag.StringVar(&reportDir, "d", "lint-report", "directory where a <version>.json report is written for each version")
+	flag.BoolVar(&fail, "fail", false, "exit with an error if any issue is found")
+	flag.BoolVar(&keywords, "keywords", false, "write an inventory of keywords not defined by the schema dialect to "+keywordsReportFile+" instead of linting")
+	flag.BoolVar(&orphans, "orphans", false, "write a report of schemas that nothing references and that do not describe a known package file to "+orphansReportFile+" instead of linting")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+// report is the lint result of a version.
+type report struct {
+	Version string         `json:"version"`
+	Counts  map[string]int `json:"counts"` // Number of issues by rule.
+	Issues  []issue        `json:"issues"`
+}
+
+func run() error {
+	if keywords && orphans {
+		return errors.New("-keywords and -orphans cannot be combined")
+	}
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		return err
+	}
+
+	var total int
+	inv := newKeywordInventory()
+	orphanRep := newOrphanReport()
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := semver.NewVersion(e.Name()); err != nil {
+			continue
+		}
+		dir := filepath.Join(outDir, e.Name(), "jsonschema")
+		if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if keywords {
+			n, err := inv.addVersion(e.Name(), dir)
+			if err != nil {
+				return fmt.Errorf("failed listing keywords of %s: %w", e.Name(), err)
+			}
+			total += n
+			slog.Info("Listed unknown keywords.", "version", e.Name(), "occurrences", n)
+			continue
+		}
+		if orphans {
+			n, err := orphanRep.addVersion(e.Name(), dir)
+			if err != nil {
+				return fmt.Errorf("failed finding orphan schemas of %s: %w", e.Name(), err)
+			}
+			total += n
+			slog.Info("Found orphan schemas.", "version", e.Name(), "orphans", n)
+			continue
+		}
+
+		r, err := lintVersion(e.Name(), dir)
+		if err != nil {
+			return fmt.Errorf("failed linting %s: %w", e.Name(), err)
+		}
+		if err := writeReport(r); err != nil {
+			return err
+		}
+		total += len(r.Issues)
+		args := []any{"version", r.Version, "issues", len(r.Issues)}
+		for _, rule := range slices.Sorted(maps.Keys(r.Counts)) {
+			args = append(args, rule, r.Counts[rule])
+		}
+		slog.Info("Linted schemas.", args...)
+	}
+
+	if keywords {
+		if err := inv.write(); err != nil {
+			return err
+		}
+		if fail && total > 0 {
+			return fmt.Errorf("found %d unknown keywords, see %s", total, filepath.Join(reportDir, keywordsReportFile))
+		}
+		return nil
+	}
+	if orphans {
+		if err := orphanRep.write(); err != nil {
+			return err
+		}
+		if fail && total > 0 {
+			return fmt.Errorf("found %d orphan schemas, see %s", total, filepath.Join(reportDir, orphansReportFile))
+		}
+		return nil
+	}
+
+	if fail && total > 0 {
+		return fmt.Errorf("found %d lint issues, see the reports in %s", total, reportDir)
+	}
+	return nil
+}
+
+// lintVersion lints every schema in dir.
+func lintVersion(version, dir string) (*report, error) {
+	r := &report{Version: version, Counts: map[string]int{}, Issues: []issue{}}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".jsonschema.json") {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var doc any
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+		for _, i := range lintSchema(filepath.ToSlash(rel), doc) {
+			r.Issues = append(r.Issues, i)
+			r.Counts[i.Rule]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func writeReport(r *report) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fsutil.WriteFileIfChanged(filepath.Join(reportDir, r.Version+".json"), append(b, '\n'))
+	return err
+}
@@ -0,0 +1,189 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/layout"
+	"github.com/andrewkroh/package-spec-schema/internal/packagefiles"
+)
+
+// orphansReportFile is the name of the orphan schema report written to the
+// report directory.
+const orphansReportFile = "orphan-schemas.json"
+
+// generatedRoots are schemas of each version that are entry points without
+// describing a single package file.
+var generatedRoots = []string{"manifest.jsonschema.json", "vocabulary.jsonschema.json"}
+
+// orphanReport lists the schemas of each version that no other schema
+// references and that do not describe a known package file.
+type orphanReport struct {
+	// Counts is the number of orphan schemas of each version.
+	Counts map[string]int `json:"counts"`
+	// Versions maps each version to its orphan schemas and the schemas they
+	// reference.
+	Versions map[string]map[string][]string `json:"versions"`
+}
+
+func newOrphanReport() *orphanReport {
+	return &orphanReport{Counts: map[string]int{}, Versions: map[string]map[string][]string{}}
+}
+
+// addVersion builds the reference graph of the schemas in dir and records
+// the orphans. It returns the number of orphans found. Schemas are known
+// package files if packagefiles lists them, or their path without the
+// integration directory in releases before package types, or if the
+// layout.json of the version associates them with a file.
+func (r *orphanReport) addVersion(version, dir string) (int, error) {
+	refs := map[string][]string{} // Schemas referenced by each schema, keyed by path.
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, ".jsonschema.json") {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		var doc any
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return fmt.Errorf("failed to decode %s: %w", p, err)
+		}
+		refs[rel], err = fileRefs(rel, doc)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	known, err := knownSchemas(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	referenced := map[string]bool{}
+	for from, targets := range refs {
+		for _, to := range targets {
+			if to != from {
+				referenced[to] = true
+			}
+		}
+	}
+
+	orphans := map[string][]string{}
+	for _, p := range slices.Sorted(maps.Keys(refs)) {
+		if referenced[p] || known[p] || slices.Contains(generatedRoots, p) {
+			continue
+		}
+		orphans[p] = append([]string{}, slices.DeleteFunc(refs[p], func(to string) bool { return to == p })...)
+	}
+	if len(orphans) == 0 {
+		return 0, nil
+	}
+	r.Versions[version] = orphans
+	r.Counts[version] = len(orphans)
+	return len(orphans), nil
+}
+
+// fileRefs returns the sorted paths of the schema files that the schema doc
+// at the slash-separated path rel references. Fragment-only references and
+// references to other hosts are ignored. Absolute references are resolved
+// to paths relative to the $id of doc.
+func fileRefs(rel string, doc any) ([]string, error) {
+	var id *url.URL
+	if root, ok := doc.(map[string]any); ok {
+		if s, ok := root["$id"].(string); ok {
+			id, _ = url.Parse(s)
+		}
+	}
+
+	targets := map[string]bool{}
+	var errs []error
+	walkSchema(doc, "", func(schema map[string]any, ptr string) {
+		ref, ok := schema["$ref"].(string)
+		if !ok || strings.HasPrefix(ref, "#") {
+			return
+		}
+		u, err := url.Parse(ref)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s#%s/$ref: %w", rel, ptr, err))
+			return
+		}
+		var target string
+		switch {
+		case !u.IsAbs() && u.Host == "":
+			target = path.Join(path.Dir(rel), u.Path)
+		case id != nil && u.Scheme == id.Scheme && u.Host == id.Host:
+			// Absolute references written by clone -absolute-refs.
+			versionDir := strings.TrimSuffix(id.Path, rel)
+			p, found := strings.CutPrefix(u.Path, versionDir)
+			if !found {
+				return
+			}
+			target = p
+		default:
+			return
+		}
+		targets[target] = true
+	})
+	return slices.Sorted(maps.Keys(targets)), errors.Join(errs...)
+}
+
+// knownSchemas returns the schemas in dir that describe a package file.
+func knownSchemas(dir string) (map[string]bool, error) {
+	known := map[string]bool{}
+	for _, f := range packagefiles.Files {
+		known[f.Schema] = true
+		known[strings.TrimPrefix(f.Schema, "integration/")] = true
+	}
+
+	l, err := layout.Read(filepath.Join(dir, layout.FileName))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return known, nil
+	case err != nil:
+		return nil, err
+	}
+	var visit func(item *layout.Item)
+	visit = func(item *layout.Item) {
+		if item.Schema != "" {
+			known[item.Schema] = true
+		}
+		for _, child := range item.Contents {
+			visit(child)
+		}
+	}
+	for _, item := range l.Types {
+		visit(item)
+	}
+	return known, nil
+}
+
+func (r *orphanReport) write() error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fsutil.WriteFileIfChanged(filepath.Join(reportDir, orphansReportFile), append(b, '\n'))
+	return err
+}
@@ -0,0 +1,248 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// issue is a lint finding at a location within a schema file.
+type issue struct {
+	Rule    string `json:"rule"`
+	File    string `json:"file"`
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// rule checks a single subschema and returns a message for each problem.
+type rule struct {
+	name  string
+	check func(schema map[string]any) []string
+}
+
+var rules = []rule{
+	{"single_allof", singleBranch("allOf")},
+	{"single_anyof", singleBranch("anyOf")},
+	{"single_oneof", singleBranch("oneOf")},
+	{"enum_to_const", enumToConst},
+	{"duplicate_enum_values", duplicateEnumValues},
+	{"enum_type_mismatch", enumTypeMismatch},
+	{"then_else_without_if", thenElseWithoutIf},
+	{"if_without_then_else", ifWithoutThenElse},
+	{"unsatisfiable_required", unsatisfiableRequired},
+}
+
+// singleBranch reports applicators with a single subschema, which can be
+// replaced by the subschema itself.
+func singleBranch(keyword string) func(map[string]any) []string {
+	return func(schema map[string]any) []string {
+		if branches, ok := schema[keyword].([]any); ok && len(branches) == 1 {
+			return []string{fmt.Sprintf("unnecessary %s wrapper around a single subschema", keyword)}
+		}
+		return nil
+	}
+}
+
+func enumToConst(schema map[string]any) []string {
+	if values, ok := schema["enum"].([]any); ok && len(values) == 1 {
+		return []string{"enum with a single value can be expressed as const"}
+	}
+	return nil
+}
+
+func duplicateEnumValues(schema map[string]any) []string {
+	values, ok := schema["enum"].([]any)
+	if !ok {
+		return nil
+	}
+	seen := map[string]bool{}
+	var msgs []string
+	for _, v := range values {
+		b, _ := json.Marshal(v)
+		if seen[string(b)] {
+			msgs = append(msgs, fmt.Sprintf("duplicate enum value %s", b))
+		}
+		seen[string(b)] = true
+	}
+	return msgs
+}
+
+// enumTypeMismatch reports enum values that can never validate because they
+// do not match the declared type.
+func enumTypeMismatch(schema map[string]any) []string {
+	values, ok := schema["enum"].([]any)
+	if !ok {
+		return nil
+	}
+	types := schemaTypes(schema)
+	if len(types) == 0 {
+		return nil
+	}
+	var msgs []string
+	for _, v := range values {
+		if !slices.ContainsFunc(types, func(t string) bool { return matchesType(v, t) }) {
+			b, _ := json.Marshal(v)
+			msgs = append(msgs, fmt.Sprintf("enum value %s is unreachable because it does not match type %s", b, strings.Join(types, ", ")))
+		}
+	}
+	return msgs
+}
+
+func thenElseWithoutIf(schema map[string]any) []string {
+	if _, ok := schema["if"]; ok {
+		return nil
+	}
+	var msgs []string
+	for _, k := range []string{"then", "else"} {
+		if _, ok := schema[k]; ok {
+			msgs = append(msgs, fmt.Sprintf("%s is ignored without if", k))
+		}
+	}
+	return msgs
+}
+
+func ifWithoutThenElse(schema map[string]any) []string {
+	_, hasIf := schema["if"]
+	_, hasThen := schema["then"]
+	_, hasElse := schema["else"]
+	if hasIf && !hasThen && !hasElse {
+		return []string{"if has no effect without then or else"}
+	}
+	return nil
+}
+
+// unsatisfiableRequired reports required properties that are forbidden by
+// additionalProperties: false.
+func unsatisfiableRequired(schema map[string]any) []string {
+	if schema["additionalProperties"] != false {
+		return nil
+	}
+	if _, ok := schema["patternProperties"]; ok {
+		return nil
+	}
+	required, _ := schema["required"].([]any)
+	properties, _ := schema["properties"].(map[string]any)
+	var msgs []string
+	for _, r := range required {
+		name, ok := r.(string)
+		if !ok {
+			continue
+		}
+		if _, ok := properties[name]; !ok {
+			msgs = append(msgs, fmt.Sprintf("required property %q is not allowed by additionalProperties: false", name))
+		}
+	}
+	return msgs
+}
+
+func schemaTypes(schema map[string]any) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []any:
+		var types []string
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func matchesType(v any, typ string) bool {
+	switch typ {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	}
+	return true
+}
+
+// Keywords whose values are subschemas, maps of subschemas, or arrays of
+// subschemas.
+var (
+	schemaKeywords = []string{
+		"additionalItems", "additionalProperties", "contains", "else", "if",
+		"items", "not", "propertyNames", "then", "unevaluatedItems",
+		"unevaluatedProperties",
+	}
+	schemaMapKeywords   = []string{"$defs", "definitions", "dependentSchemas", "patternProperties", "properties"}
+	schemaArrayKeywords = []string{"allOf", "anyOf", "items", "oneOf", "prefixItems"}
+)
+
+// walkSchema calls visit for schema and each of its subschemas along with
+// their JSON pointers.
+func walkSchema(schema any, ptr string, visit func(schema map[string]any, ptr string)) {
+	m, ok := schema.(map[string]any)
+	if !ok {
+		return
+	}
+	visit(m, ptr)
+
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		v := m[k]
+		kptr := ptr + "/" + escapePointer(k)
+		switch {
+		case slices.Contains(schemaMapKeywords, k):
+			if children, ok := v.(map[string]any); ok {
+				for _, name := range slices.Sorted(maps.Keys(children)) {
+					walkSchema(children[name], kptr+"/"+escapePointer(name), visit)
+				}
+			}
+		case slices.Contains(schemaArrayKeywords, k):
+			if children, ok := v.([]any); ok {
+				for i, child := range children {
+					walkSchema(child, kptr+"/"+strconv.Itoa(i), visit)
+				}
+				continue
+			}
+			walkSchema(v, kptr, visit)
+		case slices.Contains(schemaKeywords, k):
+			walkSchema(v, kptr, visit)
+		}
+	}
+}
+
+// lintSchema applies every rule to each subschema of the schema document.
+func lintSchema(file string, doc any) []issue {
+	var issues []issue
+	walkSchema(doc, "", func(schema map[string]any, ptr string) {
+		for _, r := range rules {
+			for _, msg := range r.check(schema) {
+				issues = append(issues, issue{Rule: r.name, File: file, Pointer: ptr, Message: msg})
+			}
+		}
+	})
+	return issues
+}
+
+// escapePointer escapes a JSON pointer reference token.
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
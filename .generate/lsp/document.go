@@ -0,0 +1,174 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// decodeYAML decodes a YAML document into the JSON data model. It returns
+// nil if the document cannot be decoded, such as while it is being edited.
+func decodeYAML(text string) any {
+	var v any
+	if err := yaml.Unmarshal([]byte(text), &v); err != nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// pointerAt returns the JSON pointer tokens of the key or scalar value at the
+// zero-based position of the YAML document. A value is identified by its
+// key, so hovering either shows the documentation of the property.
+func pointerAt(text string, pos position) ([]string, bool) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil || len(doc.Content) == 0 {
+		return nil, false
+	}
+	line, column := pos.Line+1, pos.Character+1
+
+	var find func(node *yaml.Node, tokens []string) ([]string, bool)
+	find = func(node *yaml.Node, tokens []string) ([]string, bool) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key, value := node.Content[i], node.Content[i+1]
+				child := append(tokens[:len(tokens):len(tokens)], key.Value)
+				if covers(key, line, column) {
+					return child, true
+				}
+				if value.Kind == yaml.ScalarNode && covers(value, line, column) {
+					return child, true
+				}
+				if found, ok := find(value, child); ok {
+					return found, true
+				}
+			}
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				child := append(tokens[:len(tokens):len(tokens)], strconv.Itoa(i))
+				if item.Kind == yaml.ScalarNode && covers(item, line, column) {
+					return child, true
+				}
+				if found, ok := find(item, child); ok {
+					return found, true
+				}
+			}
+		}
+		return nil, false
+	}
+	return find(doc.Content[0], nil)
+}
+
+// covers reports whether the scalar node spans the one-based line and column.
+func covers(node *yaml.Node, line, column int) bool {
+	return node.Line == line && column >= node.Column && column <= node.Column+len(node.Value)
+}
+
+var (
+	// valuePrefix matches the text of a line before the cursor when the
+	// cursor is at the value of a key.
+	valuePrefix = regexp.MustCompile(`^(\s*)(- +)?([^\s:#'"][^:#]*):\s+\S*$`)
+	// keyLine matches a line holding a key, possibly as the first key of a
+	// sequence item.
+	keyLine = regexp.MustCompile(`^(\s*)(- +)?([^\s:#'"-][^:#]*):(\s|$)`)
+	// itemLine matches a line starting a sequence item.
+	itemLine = regexp.MustCompile(`^(\s*)- *`)
+)
+
+// completionContext returns the JSON pointer tokens of the object whose keys
+// are completed at the zero-based position, or with isValue set, of the
+// value being completed. The document may be incomplete, so the context is
+// derived from the indentation of the preceding lines. Sequence items are
+// identified by index 0, which selects the same item schemas as any other
+// index.
+func completionContext(text string, pos position) (tokens []string, isValue bool) {
+	lines := strings.Split(text, "\n")
+	if pos.Line >= len(lines) {
+		return nil, false
+	}
+	prefix := lines[pos.Line]
+	if pos.Character < len(prefix) {
+		prefix = prefix[:pos.Character]
+	}
+
+	if m := valuePrefix.FindStringSubmatch(prefix); m != nil {
+		indent, inItem := len(m[1]), m[2] != ""
+		var parent []string
+		if inItem {
+			parent = append(parentTokens(lines, pos.Line, indent, true), "0")
+		} else {
+			parent = parentTokens(lines, pos.Line, indent, false)
+		}
+		return append(parent, strings.TrimSpace(m[3])), true
+	}
+
+	trimmed := strings.TrimLeft(prefix, " ")
+	indent := len(prefix) - len(trimmed)
+	if m := itemLine.FindStringSubmatch(prefix); m != nil {
+		// The first key of a new sequence item.
+		return append(parentTokens(lines, pos.Line, indent, true), "0"), false
+	}
+	return parentTokens(lines, pos.Line, indent, false), false
+}
+
+// parentTokens returns the JSON pointer tokens of the object containing the
+// keys at indent on the line. When item is set, the line starts a sequence
+// item at indent and the tokens are those of the sequence.
+func parentTokens(lines []string, line, indent int, item bool) []string {
+	var reversed []string
+	cur, owner := indent, item
+	for l := line - 1; l >= 0 && (cur > 0 || owner); l-- {
+		text := lines[l]
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		ind := len(text) - len(trimmed)
+
+		if m := itemLine.FindStringSubmatch(text); m != nil {
+			if ind >= cur {
+				continue
+			}
+			// The line starts an item of the sequence containing the
+			// object. A key on the same line whose value is nested deeper
+			// contains the object itself.
+			content := len(m[0])
+			if k := keyLine.FindStringSubmatch(text); k != nil && content < cur {
+				reversed = append(reversed, strings.TrimSpace(k[3]))
+			}
+			reversed = append(reversed, "0")
+			cur, owner = ind, true
+			continue
+		}
+
+		k := keyLine.FindStringSubmatch(text)
+		if k == nil {
+			continue
+		}
+		if ind < cur || (owner && ind == cur) {
+			reversed = append(reversed, strings.TrimSpace(k[3]))
+			cur, owner = ind, false
+		}
+	}
+
+	tokens := make([]string, len(reversed))
+	for i, t := range reversed {
+		tokens[len(reversed)-1-i] = t
+	}
+	return tokens
+}
@@ -0,0 +1,26 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+//go:build embed_schemas
+
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// schemas holds the versioned directories that `just lsp` copies into
+// schemas/.
+//
+//go:embed schemas
+var schemas embed.FS
+
+func init() {
+	fsys, err := fs.Sub(schemas, "schemas")
+	if err != nil {
+		panic(err)
+	}
+	embedded = fsys
+}
@@ -0,0 +1,478 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// lsp is a language server for the files of Elastic packages. It speaks the
+// Language Server Protocol over stdin and stdout and provides validation
+// diagnostics, hover documentation, and completion of keys and enum values
+// for package manifests, data stream manifests, fields files, and the other
+// package files that have a schema. The schemas are selected by the
+// format_version of the package containing each file.
+//
+// The schemas are read from the output directory, or embedded in the binary
+// when it is built with the embed_schemas tag (see `just lsp`).
+//
+//	go run ./lsp [flags]
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+	"github.com/andrewkroh/package-spec-schema/internal/specversion"
+	"github.com/andrewkroh/package-spec-schema/internal/validator"
+)
+
+// embedded holds the schemas of a binary built with the embed_schemas tag.
+var embedded fs.FS
+
+var (
+	outDir  string // Directory containing the versioned directories.
+	baseURL string // Base of the $id of the schemas.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories, unused when the schemas are embedded")
+	flag.StringVar(&baseURL, "base-url", validator.DefaultBaseURL, "base URL of the $id of the schemas")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	// Logs are written to stderr, which editors show in their output panel.
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	fsys := embedded
+	if fsys == nil {
+		fsys = os.DirFS(outDir)
+	}
+	s := &server{
+		conn:      newConn(os.Stdin, os.Stdout),
+		validator: validator.New(fsys, baseURL),
+		schemas:   newSchemaStore(fsys, baseURL),
+		fsys:      fsys,
+		docs:      map[string]string{},
+	}
+	if err := s.serve(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+// server is a language server. Messages are handled one at a time.
+type server struct {
+	conn      *conn
+	validator *validator.Validator
+	schemas   *schemaStore
+	fsys      fs.FS             // Output directory containing the versioned directories.
+	docs      map[string]string // Text of the open documents keyed by URI.
+	shutdown  bool
+}
+
+// serve handles messages until the client exits.
+func (s *server) serve() error {
+	for {
+		m, err := s.conn.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if m.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+		result, rerr := s.handle(m)
+		if m.ID == nil {
+			if rerr != nil {
+				slog.Warn("Failed to handle notification.", "method", m.Method, "error", rerr.Message)
+			}
+			continue
+		}
+		if err := s.conn.reply(m.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+// handle handles a request or notification and returns the result of a
+// request.
+func (s *server) handle(m *message) (any, *responseError) {
+	switch m.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":   1, // Full.
+				"hoverProvider":      true,
+				"completionProvider": map[string]any{"triggerCharacters": []string{":", " "}},
+			},
+			"serverInfo": map[string]any{"name": "package-spec-schema"},
+		}, nil
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.docs[p.TextDocument.URI] = p.TextDocument.Text
+		s.changed(p.TextDocument.URI)
+		return nil, nil
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		if n := len(p.ContentChanges); n > 0 {
+			s.docs[p.TextDocument.URI] = p.ContentChanges[n-1].Text
+		}
+		s.changed(p.TextDocument.URI)
+		return nil, nil
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.docs, p.TextDocument.URI)
+		s.publish(p.TextDocument.URI, []diagnostic{})
+		return nil, nil
+	case "textDocument/hover":
+		var p textDocumentPositionParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.hover(p), nil
+	case "textDocument/completion":
+		var p textDocumentPositionParams
+		if err := json.Unmarshal(m.Params, &p); err != nil {
+			return nil, invalidParams(err)
+		}
+		return s.complete(p), nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + m.Method}
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+// changed validates the document. A change to a package manifest may change
+// the format_version of other files, so all open documents are validated.
+func (s *server) changed(uri string) {
+	if path.Base(uri) != "manifest.yml" {
+		s.publish(uri, s.diagnose(uri))
+		return
+	}
+	for _, u := range slices.Sorted(maps.Keys(s.docs)) {
+		s.publish(u, s.diagnose(u))
+	}
+}
+
+func (s *server) publish(uri string, diags []diagnostic) {
+	if err := s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diags}); err != nil {
+		slog.Warn("Failed to publish diagnostics.", "uri", uri, "error", err)
+	}
+}
+
+// yamlErrorLine extracts the line of a YAML syntax error.
+var yamlErrorLine = regexp.MustCompile(`line (\d+):`)
+
+// diagnose validates the document against the schema of its package file.
+func (s *server) diagnose(uri string) []diagnostic {
+	diags := []diagnostic{}
+	text := s.docs[uri]
+	ver, schema, err := s.selectSchema(uri)
+	if err != nil {
+		slog.Debug("No schema for document.", "uri", uri, "reason", err)
+		return diags
+	}
+
+	problems, err := s.validator.ValidateFile(ver, schema, []byte(text))
+	lines := strings.Split(text, "\n")
+	if err != nil {
+		line := 0
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			line--
+		}
+		return append(diags, newDiagnostic(lines, line, 0, err.Error()))
+	}
+	for _, p := range problems {
+		diags = append(diags, newDiagnostic(lines, p.Line-1, p.Column-1, "#"+p.Pointer+": "+p.Message))
+	}
+	return diags
+}
+
+// newDiagnostic returns an error spanning from the zero-based line and
+// column to the end of the line.
+func newDiagnostic(lines []string, line, column int, msg string) diagnostic {
+	line, column = max(line, 0), max(column, 0)
+	end := column
+	if line < len(lines) {
+		end = max(len(strings.TrimRight(lines[line], "\r")), column)
+	}
+	return diagnostic{
+		Range:    lspRange{Start: position{line, column}, End: position{line, end}},
+		Severity: 1,
+		Source:   "package-spec",
+		Message:  msg,
+	}
+}
+
+// selectSchema returns the version and the path of the schema, relative to
+// the jsonschema directory, of the document. The version is the one that the
+// format_version of the package containing the document resolves to.
+func (s *server) selectSchema(uri string) (version, schema string, err error) {
+	name, err := uriPath(uri)
+	if err != nil {
+		return "", "", err
+	}
+	root, formatVersion, err := s.packageRoot(filepath.Dir(name))
+	if err != nil {
+		return "", "", err
+	}
+	rel, err := filepath.Rel(root, name)
+	if err != nil {
+		return "", "", err
+	}
+	schema, ok := validator.MatchSchema(filepath.ToSlash(rel))
+	if !ok {
+		return "", "", fmt.Errorf("no schema for %s", rel)
+	}
+
+	version, err = specversion.ResolveFS(s.fsys, formatVersion)
+	if err != nil {
+		return "", "", fmt.Errorf("no schemas for format_version: %w", err)
+	}
+	if _, err := fs.Stat(s.fsys, path.Join(version, "jsonschema", schema)); err != nil {
+		return "", "", fmt.Errorf("no schema %s for format_version %s", schema, version)
+	}
+	return version, schema, nil
+}
+
+// packageRoot returns the nearest directory at or above dir containing a
+// package manifest and its format_version. The text of open manifests takes
+// precedence over the file on disk.
+func (s *server) packageRoot(dir string) (root, formatVersion string, err error) {
+	for {
+		name := filepath.Join(dir, "manifest.yml")
+		text, open := s.docs[fileURI(name)]
+		if !open {
+			if b, err := os.ReadFile(name); err == nil {
+				text = string(b)
+			}
+		}
+		var m struct {
+			FormatVersion string `yaml:"format_version"`
+		}
+		if yaml.Unmarshal([]byte(text), &m) == nil && m.FormatVersion != "" {
+			return dir, m.FormatVersion, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", errors.New("not in a package")
+		}
+		dir = parent
+	}
+}
+
+// uriPath returns the file path of a file URI.
+func uriPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URI scheme %q", u.Scheme)
+	}
+	return filepath.FromSlash(u.Path), nil
+}
+
+// fileURI returns the file URI of an absolute path.
+func fileURI(name string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(name)}).String()
+}
+
+// root returns the root schema of the document and its decoded content.
+func (s *server) root(uri string) (subschema, any, bool) {
+	ver, schema, err := s.selectSchema(uri)
+	if err != nil {
+		return subschema{}, nil, false
+	}
+	root, err := s.schemas.root(ver, schema)
+	if err != nil {
+		slog.Warn("Failed to load schema.", "version", ver, "schema", schema, "error", err)
+		return subschema{}, nil, false
+	}
+	return root, decodeYAML(s.docs[uri]), true
+}
+
+// hover returns the documentation of the property at the position.
+func (s *server) hover(p textDocumentPositionParams) *hover {
+	root, instance, ok := s.root(p.TextDocument.URI)
+	if !ok {
+		return nil
+	}
+	tokens, ok := pointerAt(s.docs[p.TextDocument.URI], p.Position)
+	if !ok {
+		return nil
+	}
+	doc := documentation(s.schemas.at(root, instance, tokens))
+	if doc == "" {
+		return nil
+	}
+	return &hover{Contents: markupContent{Kind: "markdown", Value: doc}}
+}
+
+// complete returns the keys of the object, or the values of the property,
+// at the position.
+func (s *server) complete(p textDocumentPositionParams) []completionItem {
+	items := []completionItem{}
+	root, instance, ok := s.root(p.TextDocument.URI)
+	if !ok {
+		return items
+	}
+	tokens, isValue := completionContext(s.docs[p.TextDocument.URI], p.Position)
+	subs := s.schemas.at(root, instance, tokens)
+
+	if isValue {
+		for _, v := range allowedValues(subs) {
+			items = append(items, completionItem{Label: v, Kind: completionValue})
+		}
+		return items
+	}
+
+	seen := map[string]bool{}
+	for _, sub := range subs {
+		props, _ := sub.schema["properties"].(map[string]any)
+		for _, name := range slices.Sorted(maps.Keys(props)) {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			prop, _ := props[name].(map[string]any)
+			propSubs := s.schemas.expand(subschema{schema: prop, base: sub.base}, nil)
+			item := completionItem{Label: name, Kind: completionProperty, InsertText: name + ": "}
+			if doc := documentation(propSubs); doc != "" {
+				item.Documentation = &markupContent{Kind: "markdown", Value: doc}
+			}
+			item.Detail = schemaType(propSubs)
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// documentation returns the titles, descriptions, types, and allowed values
+// of the subschemas as Markdown.
+func documentation(subs []subschema) string {
+	var parts []string
+	seen := map[string]bool{}
+	add := func(s string) {
+		s = strings.TrimSpace(s)
+		if s != "" && !seen[s] {
+			seen[s] = true
+			parts = append(parts, s)
+		}
+	}
+	for _, sub := range subs {
+		if title, ok := sub.schema["title"].(string); ok {
+			add("**" + title + "**")
+		}
+	}
+	for _, sub := range subs {
+		if desc, ok := sub.schema["description"].(string); ok {
+			add(desc)
+		}
+	}
+	if t := schemaType(subs); t != "" {
+		add("Type: `" + t + "`")
+	}
+	if values := allowedValues(subs); len(values) > 0 {
+		add("Allowed values: `" + strings.Join(values, "`, `") + "`")
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// schemaType returns the types declared by the subschemas.
+func schemaType(subs []subschema) string {
+	var types []string
+	for _, sub := range subs {
+		switch t := sub.schema["type"].(type) {
+		case string:
+			types = append(types, t)
+		case []any:
+			for _, v := range t {
+				if s, ok := v.(string); ok {
+					types = append(types, s)
+				}
+			}
+		}
+	}
+	slices.Sort(types)
+	return strings.Join(slices.Compact(types), " | ")
+}
+
+// allowedValues returns the const and enum values of the subschemas, and
+// true and false for booleans, formatted as YAML scalars.
+func allowedValues(subs []subschema) []string {
+	var values []string
+	seen := map[string]bool{}
+	add := func(v any) {
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case bool, float64:
+			s = fmt.Sprint(v)
+		default:
+			return
+		}
+		if !seen[s] {
+			seen[s] = true
+			values = append(values, s)
+		}
+	}
+	for _, sub := range subs {
+		if v, found := sub.schema["const"]; found {
+			add(v)
+		}
+		enum, _ := sub.schema["enum"].([]any)
+		for _, v := range enum {
+			add(v)
+		}
+		if sub.schema["type"] == "boolean" {
+			add(true)
+			add(false)
+		}
+	}
+	return values
+}
@@ -0,0 +1,307 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxDepth bounds the references and applicators followed when expanding a
+// schema, which protects against reference cycles.
+const maxDepth = 32
+
+// schemaStore loads the raw schemas of an output directory for navigation.
+// Unlike the validator, it does not compile them.
+type schemaStore struct {
+	fsys    fs.FS  // Output directory containing the versioned directories.
+	baseURL string // Base of the $id of the schemas, without a trailing slash.
+	docs    map[string]any
+}
+
+func newSchemaStore(fsys fs.FS, baseURL string) *schemaStore {
+	return &schemaStore{fsys: fsys, baseURL: strings.TrimSuffix(baseURL, "/"), docs: map[string]any{}}
+}
+
+// subschema is a schema and the URL of the resource that contains it, which
+// is the base of its references.
+type subschema struct {
+	schema map[string]any
+	base   *url.URL
+}
+
+// root returns the schema at the path relative to the jsonschema directory
+// of version.
+func (s *schemaStore) root(version, schema string) (subschema, error) {
+	u, err := url.Parse(s.baseURL + "/" + path.Join(version, schema))
+	if err != nil {
+		return subschema{}, err
+	}
+	doc, err := s.load(u)
+	if err != nil {
+		return subschema{}, err
+	}
+	m, _ := doc.(map[string]any)
+	return subschema{schema: m, base: u}, nil
+}
+
+// load returns the document at the URL, ignoring its fragment.
+func (s *schemaStore) load(u *url.URL) (any, error) {
+	key := *u
+	key.Fragment, key.RawFragment = "", ""
+	if doc, found := s.docs[key.String()]; found {
+		return doc, nil
+	}
+	rel, found := strings.CutPrefix(key.String(), s.baseURL+"/")
+	if !found {
+		return nil, fmt.Errorf("cannot load %s: not below %s", key.String(), s.baseURL)
+	}
+	ver, schema, _ := strings.Cut(rel, "/")
+	b, err := fs.ReadFile(s.fsys, path.Join(ver, "jsonschema", schema))
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", key.String(), err)
+	}
+	s.docs[key.String()] = doc
+	return doc, nil
+}
+
+// resolve returns the schema referenced by ref from within sub. References
+// to anchors are not supported.
+func (s *schemaStore) resolve(sub subschema, ref string) (subschema, bool) {
+	r, err := url.Parse(ref)
+	if err != nil {
+		return subschema{}, false
+	}
+	u := sub.base.ResolveReference(r)
+	doc, err := s.load(u)
+	if err != nil {
+		return subschema{}, false
+	}
+	target := doc
+	if u.Fragment != "" {
+		if !strings.HasPrefix(u.Fragment, "/") {
+			return subschema{}, false
+		}
+		for _, token := range strings.Split(u.Fragment, "/")[1:] {
+			token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+			switch v := target.(type) {
+			case map[string]any:
+				target = v[token]
+			case []any:
+				i, err := strconv.Atoi(token)
+				if err != nil || i < 0 || i >= len(v) {
+					return subschema{}, false
+				}
+				target = v[i]
+			default:
+				return subschema{}, false
+			}
+		}
+	}
+	m, ok := target.(map[string]any)
+	if !ok {
+		return subschema{}, false
+	}
+	base := *u
+	base.Fragment, base.RawFragment = "", ""
+	return subschema{schema: m, base: &base}, true
+}
+
+// expand returns sub and the subschemas that also apply to the instance
+// through $ref and the applicators allOf, anyOf, oneOf, and if/then/else.
+// The branch of a conditional is chosen by the instance when the condition
+// can be decided, and otherwise both branches are included.
+func (s *schemaStore) expand(sub subschema, instance any) []subschema {
+	var out []subschema
+	var walk func(subschema, int)
+	walk = func(sub subschema, depth int) {
+		if sub.schema == nil || depth > maxDepth {
+			return
+		}
+		if id, ok := sub.schema["$id"].(string); ok {
+			if u, err := sub.base.Parse(id); err == nil {
+				sub.base = u
+			}
+		}
+		out = append(out, sub)
+		if ref, ok := sub.schema["$ref"].(string); ok {
+			if target, ok := s.resolve(sub, ref); ok {
+				walk(target, depth+1)
+			}
+		}
+		for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+			list, _ := sub.schema[keyword].([]any)
+			for _, item := range list {
+				if m, ok := item.(map[string]any); ok {
+					walk(subschema{schema: m, base: sub.base}, depth+1)
+				}
+			}
+		}
+		if cond, ok := sub.schema["if"].(map[string]any); ok {
+			matched, known := matchCondition(cond, instance)
+			if then, ok := sub.schema["then"].(map[string]any); ok && (matched || !known) {
+				walk(subschema{schema: then, base: sub.base}, depth+1)
+			}
+			if els, ok := sub.schema["else"].(map[string]any); ok && (!matched || !known) {
+				walk(subschema{schema: els, base: sub.base}, depth+1)
+			}
+		}
+	}
+	walk(sub, 0)
+	return out
+}
+
+// matchCondition evaluates the condition of an if keyword against the
+// instance. It only decides conditions made of required properties and
+// properties with a const or enum, such as the type of a package manifest,
+// and reports whether the outcome is known.
+func matchCondition(cond map[string]any, instance any) (matched, known bool) {
+	obj, ok := instance.(map[string]any)
+	if !ok {
+		return false, false
+	}
+	for keyword, value := range cond {
+		switch keyword {
+		case "required":
+			names, _ := value.([]any)
+			for _, name := range names {
+				if n, ok := name.(string); ok {
+					if _, found := obj[n]; !found {
+						return false, true
+					}
+				}
+			}
+		case "properties":
+			props, _ := value.(map[string]any)
+			for name, p := range props {
+				v, found := obj[name]
+				if !found {
+					// Absent properties satisfy the properties keyword.
+					continue
+				}
+				ps, _ := p.(map[string]any)
+				for k, pv := range ps {
+					switch k {
+					case "const":
+						if !reflect.DeepEqual(v, pv) {
+							return false, true
+						}
+					case "enum":
+						values, _ := pv.([]any)
+						if !containsValue(values, v) {
+							return false, true
+						}
+					case "type", "description", "title":
+					default:
+						return false, false
+					}
+				}
+			}
+		case "type", "description", "title", "$comment":
+		default:
+			return false, false
+		}
+	}
+	return true, true
+}
+
+func containsValue(values []any, v any) bool {
+	for _, value := range values {
+		if reflect.DeepEqual(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// at returns the subschemas that apply to the value at the JSON pointer
+// tokens of the instance, a document decoded into the JSON data model that
+// may be nil when it cannot be decoded.
+func (s *schemaStore) at(root subschema, instance any, tokens []string) []subschema {
+	subs := s.expand(root, instance)
+	for _, token := range tokens {
+		child := childValue(instance, token)
+		var next []subschema
+		for _, sub := range subs {
+			for _, c := range childSchemas(sub, token) {
+				next = append(next, s.expand(c, child)...)
+			}
+		}
+		subs, instance = next, child
+	}
+	return subs
+}
+
+// childValue returns the value of the instance at the token.
+func childValue(instance any, token string) any {
+	switch v := instance.(type) {
+	case map[string]any:
+		return v[token]
+	case []any:
+		if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < len(v) {
+			return v[i]
+		}
+	}
+	return nil
+}
+
+// childSchemas returns the subschemas of sub that apply to the property or
+// array item named by token.
+func childSchemas(sub subschema, token string) []subschema {
+	var out []subschema
+	add := func(v any) {
+		if m, ok := v.(map[string]any); ok {
+			out = append(out, subschema{schema: m, base: sub.base})
+		}
+	}
+
+	props, _ := sub.schema["properties"].(map[string]any)
+	matched := false
+	if p, found := props[token]; found {
+		add(p)
+		matched = true
+	}
+	patterns, _ := sub.schema["patternProperties"].(map[string]any)
+	for pattern, p := range patterns {
+		if re, err := regexp.Compile(pattern); err == nil && re.MatchString(token) {
+			add(p)
+			matched = true
+		}
+	}
+	if !matched {
+		add(sub.schema["additionalProperties"])
+	}
+
+	if i, err := strconv.Atoi(token); err == nil && i >= 0 {
+		prefix, _ := sub.schema["prefixItems"].([]any)
+		switch items := sub.schema["items"].(type) {
+		case []any:
+			// Draft-07 tuple validation.
+			if i < len(items) {
+				add(items[i])
+			} else {
+				add(sub.schema["additionalItems"])
+			}
+		default:
+			if i < len(prefix) {
+				add(prefix[i])
+			} else {
+				add(items)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,26 @@
+export interface VersionFiles {
+  /** Multi-file schemas keyed by path, with paths relative to the package. */
+  jsonschema: Record<string, string>;
+  /** Single-file bundles keyed by path, with paths relative to the package. */
+  bundles: Record<string, string>;
+}
+
+export interface Index {
+  latest: string;
+  versions: Record<string, VersionFiles>;
+}
+
+export interface SchemaOptions {
+  /** Select the bundled schema that has no external references. */
+  bundled?: boolean;
+}
+
+export const index: Index;
+export const latest: string;
+export const versions: string[];
+
+/** Returns the absolute path of a schema file of a version or "latest". */
+export function schemaPath(version: string, file: string, options?: SchemaOptions): string;
+
+/** Reads and parses a schema file of a version or "latest". */
+export function loadSchema(version: string, file: string, options?: SchemaOptions): unknown;
@@ -0,0 +1,33 @@
+'use strict';
+
+const fs = require('fs');
+const path = require('path');
+
+const index = require('./index.json');
+
+// schemaPath returns the absolute path of a schema file of a package-spec
+// version. Bundled schemas have no external references.
+function schemaPath(version, file, { bundled = false } = {}) {
+  const v = index.versions[version === 'latest' ? index.latest : version];
+  if (!v) {
+    throw new Error(`unknown package-spec version ${version}`);
+  }
+  const rel = (bundled ? v.bundles : v.jsonschema)[file];
+  if (!rel) {
+    throw new Error(`unknown schema ${file} in package-spec ${version}`);
+  }
+  return path.join(__dirname, rel);
+}
+
+// loadSchema reads and parses a schema file of a package-spec version.
+function loadSchema(version, file, options) {
+  return JSON.parse(fs.readFileSync(schemaPath(version, file, options), 'utf8'));
+}
+
+module.exports = {
+  index,
+  latest: index.latest,
+  versions: Object.keys(index.versions),
+  schemaPath,
+  loadSchema,
+};
@@ -0,0 +1,286 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// npm writes an npm package containing the schemas of each version so that
+// web UIs and Node based linters can install them instead of fetching them
+// at runtime. The package contains the schemas beneath schemas/<version>/, an
+// index.json mapping each version's schema paths to files, and a small
+// index.js with TypeScript declarations for resolving and loading schemas.
+// Publish it with `npm publish <dir>`.
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/fsutil"
+	"github.com/andrewkroh/package-spec-schema/internal/layout"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+// files contains the static files of the package.
+//
+//go:embed files
+var files embed.FS
+
+// schemaDirs are the directories of each version that are packaged.
+var schemaDirs = []string{"jsonschema", "bundles"}
+
+var (
+	outDir     string // Directory containing the versioned directories.
+	destDir    string // Directory where the package is written.
+	name       string // Package name.
+	version    string // Package version.
+	minVersion string // Oldest package-spec version to include.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	flag.StringVar(&destDir, "d", "dist/npm", "directory where the package is written")
+	flag.StringVar(&name, "name", "@andrewkroh/package-spec-schema", "npm package name")
+	flag.StringVar(&version, "version", "", "npm package version, defaults to the latest package-spec version")
+	flag.StringVar(&minVersion, "min-version", "", "oldest package-spec version to include, defaults to all versions")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+// packageIndex is the content of index.json.
+type packageIndex struct {
+	Latest   string                  `json:"latest"`
+	Versions map[string]versionFiles `json:"versions"`
+}
+
+// versionFiles maps the slash-separated path of each schema, relative to its
+// schema directory, to its path within the package.
+type versionFiles struct {
+	JSONSchema map[string]string `json:"jsonschema"`
+	Bundles    map[string]string `json:"bundles"`
+}
+
+func run() (err error) {
+	var min *semver.Version
+	if minVersion != "" {
+		if min, err = semver.NewVersion(minVersion); err != nil {
+			return fmt.Errorf("invalid -min-version: %w", err)
+		}
+	}
+
+	versions, err := versionDirs(outDir, min)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("no version directories found in %s", outDir)
+	}
+	latest := versions[len(versions)-1]
+	if version == "" {
+		version = latest.String()
+	}
+	if _, err := semver.NewVersion(version); err != nil {
+		return fmt.Errorf("invalid -version: %w", err)
+	}
+
+	start := time.Now()
+	staging, err := fsutil.StageDir(destDir)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Discard the staging directory if it was not committed.
+		if rmErr := os.RemoveAll(staging); rmErr != nil {
+			err = errors.Join(err, rmErr)
+		}
+	}()
+
+	idx := packageIndex{Latest: latest.String(), Versions: map[string]versionFiles{}}
+	for _, v := range versions {
+		vf, err := copyVersion(staging, v.String())
+		if err != nil {
+			return fmt.Errorf("failed packaging %s: %w", v, err)
+		}
+		idx.Versions[v.String()] = vf
+	}
+
+	if err := writeJSON(filepath.Join(staging, "index.json"), idx); err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(staging, "package.json"), packageJSON(versions)); err != nil {
+		return err
+	}
+	if err := writeStaticFiles(staging); err != nil {
+		return err
+	}
+
+	changed, err := fsutil.CommitDir(staging, destDir)
+	if err != nil {
+		return err
+	}
+	slog.Info("Wrote npm package.", "dir", destDir, "name", name, "version", version,
+		"schema_versions", len(versions), "changed", changed, "duration", time.Since(start))
+	return nil
+}
+
+// versionDirs returns the versions in dir that contain schemas, sorted, and
+// not older than min if it is not nil.
+func versionDirs(dir string, min *semver.Version) ([]*semver.Version, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var versions []*semver.Version
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		v, err := semver.NewVersion(e.Name())
+		if err != nil || (min != nil && v.LessThan(*min)) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, e.Name(), "jsonschema")); err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	semver.Sort(versions)
+	return versions, nil
+}
+
+// copyVersion copies the schemas of version into the package and returns
+// their paths.
+func copyVersion(pkgDir, version string) (versionFiles, error) {
+	vf := versionFiles{JSONSchema: map[string]string{}, Bundles: map[string]string{}}
+	for _, sub := range schemaDirs {
+		src := filepath.Join(outDir, version, sub)
+		index := vf.JSONSchema
+		if sub == "bundles" {
+			index = vf.Bundles
+		}
+
+		err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && p == src {
+				return fs.SkipDir
+			}
+			if err != nil || d.IsDir() || !strings.HasSuffix(p, ".json") {
+				return err
+			}
+			rel, err := filepath.Rel(src, p)
+			if err != nil {
+				return err
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			pkgPath := path.Join("schemas", version, sub, filepath.ToSlash(rel))
+			if err := fsutil.WriteFile(filepath.Join(pkgDir, filepath.FromSlash(pkgPath)), data); err != nil {
+				return err
+			}
+			if rel != "metadata.json" && rel != layout.FileName {
+				index[filepath.ToSlash(rel)] = pkgPath
+			}
+			return nil
+		})
+		if err != nil {
+			return vf, err
+		}
+	}
+	return vf, nil
+}
+
+// packageManifest is the content of package.json.
+type packageManifest struct {
+	Name        string            `json:"name"`
+	Version     string            `json:"version"`
+	Description string            `json:"description"`
+	Keywords    []string          `json:"keywords"`
+	Homepage    string            `json:"homepage"`
+	Repository  map[string]string `json:"repository"`
+	License     string            `json:"license"`
+	Main        string            `json:"main"`
+	Types       string            `json:"types"`
+	Exports     map[string]string `json:"exports"`
+	Files       []string          `json:"files"`
+}
+
+func packageJSON(versions []*semver.Version) packageManifest {
+	return packageManifest{
+		Name:    name,
+		Version: version,
+		Description: fmt.Sprintf("JSON Schemas generated from elastic/package-spec %s through %s.",
+			versions[0], versions[len(versions)-1]),
+		Keywords:   []string{"elastic", "package-spec", "json-schema", "integrations"},
+		Homepage:   "https://github.com/andrewkroh/package-spec-schema",
+		Repository: map[string]string{"type": "git", "url": "git+https://github.com/andrewkroh/package-spec-schema.git"},
+		License:    "SEE LICENSE IN LICENSE.txt",
+		Main:       "index.js",
+		Types:      "index.d.ts",
+		Exports: map[string]string{
+			".":            "./index.js",
+			"./index.json": "./index.json",
+			"./schemas/*":  "./schemas/*",
+		},
+		Files: []string{"index.js", "index.d.ts", "index.json", "schemas/", "LICENSE.txt"},
+	}
+}
+
+// writeStaticFiles writes the embedded files and the license of the output
+// directory, if it exists.
+func writeStaticFiles(pkgDir string) error {
+	err := fs.WalkDir(files, "files", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := files.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return fsutil.WriteFile(filepath.Join(pkgDir, strings.TrimPrefix(p, "files/")), data)
+	})
+	if err != nil {
+		return err
+	}
+
+	license, err := os.ReadFile(filepath.Join(outDir, "LICENSE.txt"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(filepath.Join(pkgDir, "LICENSE.txt"), license)
+}
+
+func writeJSON(name string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFile(name, append(b, '\n'))
+}
@@ -0,0 +1,117 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+)
+
+// expectationTest is the upstream test whose table lists the expected
+// outcome of validating each test package.
+const expectationTest = "TestValidateFile"
+
+// expectation is the upstream outcome of validating a test package.
+type expectation struct {
+	Valid  bool     `json:"valid"`
+	File   string   `json:"file,omitempty"`   // Invalid file named by the test, relative to the package root.
+	Errors []string `json:"errors,omitempty"` // Substrings of the expected upstream errors.
+}
+
+// parseExpectations reads the table of the TestValidateFile function of the
+// upstream validator tests in src. Its keys are test package names and each
+// value is empty for a valid package, or holds the invalid file and the
+// expected error substrings. Both positional and keyed values are accepted.
+func parseExpectations(src []byte) (map[string]expectation, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "validator_test.go", src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var fn *ast.FuncDecl
+	for _, d := range f.Decls {
+		if d, ok := d.(*ast.FuncDecl); ok && d.Name.Name == expectationTest {
+			fn = d
+			break
+		}
+	}
+	if fn == nil {
+		return nil, fmt.Errorf("%s not found", expectationTest)
+	}
+
+	expected := map[string]expectation{}
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		lit, ok := n.(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+		if _, ok := lit.Type.(*ast.MapType); !ok {
+			return true
+		}
+		for _, elt := range lit.Elts {
+			kv, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				continue
+			}
+			name, ok := stringLit(kv.Key)
+			if !ok {
+				continue
+			}
+			value, ok := kv.Value.(*ast.CompositeLit)
+			if !ok {
+				continue
+			}
+			expected[name] = parseExpectation(value)
+		}
+		return false
+	})
+	if len(expected) == 0 {
+		return nil, fmt.Errorf("no test packages found in %s", expectationTest)
+	}
+	return expected, nil
+}
+
+// parseExpectation returns the expectation of a value of the test table.
+func parseExpectation(value *ast.CompositeLit) expectation {
+	var e expectation
+	for i, elt := range value.Elts {
+		var field string
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if id, ok := kv.Key.(*ast.Ident); ok {
+				field = id.Name
+			}
+			elt = kv.Value
+		}
+		if s, ok := stringLit(elt); ok && (field == "invalidPkgFilePath" || field == "" && i == 0) {
+			e.File = s
+			continue
+		}
+		if list, ok := elt.(*ast.CompositeLit); ok {
+			for _, item := range list.Elts {
+				if s, ok := stringLit(item); ok {
+					e.Errors = append(e.Errors, s)
+				}
+			}
+		}
+	}
+	e.Valid = e.File == "" && len(e.Errors) == 0
+	return e
+}
+
+// stringLit returns the value of a string literal.
+func stringLit(expr ast.Expr) (string, bool) {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", false
+	}
+	return s, true
+}
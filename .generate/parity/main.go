@@ -0,0 +1,362 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// parity validates the test packages that package-spec ships with each
+// release against the generated schemas of that release and compares the
+// outcome with the upstream expectations, which are read from the table of
+// the upstream TestValidateFile test. Test packages that upstream expects to
+// be valid but that the schemas reject point at conversion bugs. Test
+// packages that upstream expects to be invalid but that the schemas accept
+// are also reported, although many of them are rejected upstream by rules
+// that a JSON schema cannot express.
+//
+// The package-spec repository is read from the working directory of clone,
+// so run clone first.
+//
+//	go run ./parity [flags] [version ...]
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"gopkg.in/yaml.v3"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+	"github.com/andrewkroh/package-spec-schema/internal/validator"
+)
+
+// Paths within the package-spec repository.
+const (
+	testPackagesDir = "test/packages"
+	validatorTest   = "code/go/pkg/validator/validator_test.go"
+)
+
+// Status of a test package.
+const (
+	statusAgree    = "agree"    // The schemas agree with upstream.
+	statusRejected = "rejected" // Upstream expects the package to be valid, but the schemas reject it.
+	statusMissed   = "missed"   // Upstream expects the package to be invalid, but the schemas accept it.
+	statusError    = "error"    // The package could not be validated.
+)
+
+var (
+	outDir     string // Directory containing the versioned directories.
+	gitDir     string // package-spec repository cloned by clone.
+	baseURL    string // Base of the $id of the schemas.
+	jsonOutput bool   // Write the results as JSON.
+	strict     bool   // Fail on missed test packages too.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	flag.StringVar(&gitDir, "git-dir", ".package-spec-schema/git/elastic_package-spec", "package-spec repository cloned by clone")
+	flag.StringVar(&baseURL, "base-url", validator.DefaultBaseURL, "base URL of the $id of the schemas")
+	flag.BoolVar(&jsonOutput, "json", false, "write the result of each test package as JSON")
+	flag.BoolVar(&strict, "strict", false, "also fail if the schemas accept a test package that upstream expects to be invalid")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [version ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+// versionResult is the outcome of the test packages of a version.
+type versionResult struct {
+	Version  string          `json:"version"`
+	Packages []packageResult `json:"packages"`
+	Unlisted []string        `json:"unlisted,omitempty"` // Test packages without an upstream expectation.
+}
+
+// packageResult is the outcome of a test package.
+type packageResult struct {
+	Package       string              `json:"package"`
+	FormatVersion string              `json:"format_version,omitempty"`
+	Status        string              `json:"status"`
+	Expected      expectation         `json:"expected"`
+	Problems      []validator.Problem `json:"problems,omitempty"`
+	Error         string              `json:"error,omitempty"`
+}
+
+func run() error {
+	repo, err := git.PlainOpen(gitDir)
+	if err != nil {
+		return fmt.Errorf("failed to open package-spec repository %s, run clone first: %w", gitDir, err)
+	}
+
+	versions := flag.Args()
+	if len(versions) == 0 {
+		if versions, err = versionDirs(outDir); err != nil {
+			return err
+		}
+	}
+
+	v := validator.New(os.DirFS(outDir), baseURL)
+	var results []versionResult
+	counts := map[string]int{}
+	for _, ver := range versions {
+		r, err := checkVersion(repo, v, ver)
+		if err != nil {
+			return fmt.Errorf("failed to check %s: %w", ver, err)
+		}
+		if r == nil {
+			continue
+		}
+		for _, p := range r.Packages {
+			counts[p.Status]++
+		}
+		slog.Debug("Checked test packages.", "version", ver, "packages", len(r.Packages), "unlisted", len(r.Unlisted))
+		results = append(results, *r)
+	}
+
+	if err := writeResults(os.Stdout, results); err != nil {
+		return err
+	}
+
+	failed := counts[statusRejected] + counts[statusError]
+	if strict {
+		failed += counts[statusMissed]
+	}
+	slog.Info("Checked parity with upstream test packages.", "versions", len(results),
+		statusAgree, counts[statusAgree], statusRejected, counts[statusRejected],
+		statusMissed, counts[statusMissed], statusError, counts[statusError])
+	if failed > 0 {
+		return fmt.Errorf("%d test packages diverge from upstream", failed)
+	}
+	return nil
+}
+
+// checkVersion validates the test packages of the release tag of ver. It
+// returns nil if the release has no test packages or expectations.
+func checkVersion(repo *git.Repository, v *validator.Validator, ver string) (*versionResult, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision("v" + ver))
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := tree.File(validatorTest)
+	if err != nil {
+		if errors.Is(err, object.ErrFileNotFound) {
+			slog.Warn("Skipping version without upstream validator tests.", "version", ver)
+			return nil, nil
+		}
+		return nil, err
+	}
+	src, err := f.Contents()
+	if err != nil {
+		return nil, err
+	}
+	expected, err := parseExpectations([]byte(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", validatorTest, err)
+	}
+
+	packages, err := tree.Tree(testPackagesDir)
+	if err != nil {
+		if errors.Is(err, object.ErrDirectoryNotFound) {
+			slog.Warn("Skipping version without test packages.", "version", ver)
+			return nil, nil
+		}
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp("", "parity-"+ver+"-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	if err := extractTree(packages, tmp); err != nil {
+		return nil, err
+	}
+
+	r := &versionResult{Version: ver}
+	for _, e := range packages.Entries {
+		if e.Mode.IsFile() {
+			continue
+		}
+		exp, found := expected[e.Name]
+		if !found {
+			r.Unlisted = append(r.Unlisted, e.Name)
+			continue
+		}
+		r.Packages = append(r.Packages, checkPackage(v, os.DirFS(filepath.Join(tmp, e.Name)), e.Name, exp))
+	}
+	return r, nil
+}
+
+// checkPackage validates the test package in fsys against the schemas of its
+// format_version and compares the outcome with the upstream expectation.
+func checkPackage(v *validator.Validator, fsys fs.FS, name string, exp expectation) packageResult {
+	r := packageResult{Package: name, Expected: exp}
+	problems, err := validatePackage(v, fsys, &r.FormatVersion)
+	switch {
+	case err != nil:
+		r.Status, r.Error = statusError, err.Error()
+	case exp.Valid && len(problems) > 0:
+		r.Status = statusRejected
+	case !exp.Valid && len(problems) == 0:
+		r.Status = statusMissed
+	default:
+		r.Status = statusAgree
+	}
+	r.Problems = problems
+	return r
+}
+
+// validatePackage validates the files of the package in fsys against the
+// schemas of its format_version, which it stores in formatVersion.
+func validatePackage(v *validator.Validator, fsys fs.FS, formatVersion *string) ([]validator.Problem, error) {
+	b, err := fs.ReadFile(fsys, "manifest.yml")
+	if err != nil {
+		return nil, err
+	}
+	var m struct {
+		FormatVersion string `yaml:"format_version"`
+	}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest.yml: %w", err)
+	}
+	*formatVersion = m.FormatVersion
+
+	sv, err := semver.NewVersion(m.FormatVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid format_version: %w", err)
+	}
+	// Prerelease format versions use the schemas of their release.
+	sv.PreRelease = ""
+	ver := sv.String()
+	if _, err := os.Stat(filepath.Join(outDir, ver, "jsonschema")); err != nil {
+		return nil, fmt.Errorf("no schemas for format_version %s in %s", ver, outDir)
+	}
+	problems, _, err := v.ValidatePackage(fsys, ver)
+	return problems, err
+}
+
+// extractTree writes the files of tree into dir.
+func extractTree(tree *object.Tree, dir string) error {
+	return tree.Files().ForEach(func(f *object.File) error {
+		if !f.Mode.IsFile() {
+			return nil
+		}
+		r, err := f.Reader()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		name := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return err
+		}
+		out, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, r); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+// writeResults writes the divergent test packages, or with -json the result
+// of every test package.
+func writeResults(w io.Writer, results []versionResult) error {
+	if jsonOutput {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+
+	for _, vr := range results {
+		for _, r := range vr.Packages {
+			name := path.Join(vr.Version, r.Package)
+			switch r.Status {
+			case statusError:
+				fmt.Fprintf(w, "%s: error: %s\n", name, r.Error)
+			case statusRejected:
+				for _, p := range r.Problems {
+					fmt.Fprintf(w, "%s: rejected: %s:%d:%d: #%s: %s\n", name, p.Path, p.Line, p.Column, p.Pointer, p.Message)
+				}
+			case statusMissed:
+				msg := "upstream expects the package to be invalid"
+				if r.Expected.File != "" {
+					msg = fmt.Sprintf("upstream expects %s to be invalid", r.Expected.File)
+				}
+				if len(r.Expected.Errors) > 0 {
+					msg += ": " + strings.Join(r.Expected.Errors, "; ")
+				}
+				fmt.Fprintf(w, "%s: missed: %s\n", name, msg)
+			}
+		}
+	}
+	return nil
+}
+
+// versionDirs returns the versions in dir that contain a jsonschema directory
+// sorted by semantic version.
+func versionDirs(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*semver.Version
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		v, err := semver.NewVersion(e.Name())
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(filepath.Join(dir, e.Name(), "jsonschema")); err == nil && info.IsDir() {
+			versions = append(versions, v)
+		}
+	}
+	slices.SortFunc(versions, func(a, b *semver.Version) int { return a.Compare(*b) })
+
+	names := make([]string, len(versions))
+	for i, v := range versions {
+		names[i] = v.String()
+	}
+	return names, nil
+}
@@ -0,0 +1,121 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// noJekyll disables Jekyll processing on GitHub Pages, which would otherwise
+// omit the _dev directories of the schemas.
+const noJekyll = ".nojekyll"
+
+// repository runs git commands against the repository containing a work tree
+// directory. The work tree replaces the repository root as the root of the
+// published tree.
+type repository struct {
+	gitDir   string
+	workTree string
+}
+
+func openRepo(dir string) (*repository, error) {
+	workTree, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	g := &repository{workTree: workTree}
+	out, err := g.exec(nil, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return nil, err
+	}
+	g.gitDir = strings.TrimSpace(string(out))
+	return g, nil
+}
+
+// commitTree commits the paths of the work tree, plus a .nojekyll file, to
+// ref using a temporary index so that the checked out branch and its index
+// are untouched. It returns the new commit, or an empty string if the tree is
+// identical to that of the current commit of ref.
+func (g *repository) commitTree(paths []string, ref, message string) (commit string, err error) {
+	tmp, err := os.MkdirTemp("", "publish-index-")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if rmErr := os.RemoveAll(tmp); rmErr != nil {
+			err = errors.Join(err, rmErr)
+		}
+	}()
+	env := []string{"GIT_INDEX_FILE=" + filepath.Join(tmp, "index")}
+
+	if _, err := g.exec(env, append([]string{"add", "-A", "--"}, paths...)...); err != nil {
+		return "", err
+	}
+	blob, err := g.exec(env, "hash-object", "-w", "--stdin")
+	if err != nil {
+		return "", err
+	}
+	if _, err := g.exec(env, "update-index", "--add", "--cacheinfo", "100644,"+strings.TrimSpace(string(blob))+","+noJekyll); err != nil {
+		return "", err
+	}
+	out, err := g.exec(env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	tree := strings.TrimSpace(string(out))
+
+	args := []string{"commit-tree", tree, "-m", message}
+	var parent string
+	if out, err := g.exec(nil, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err == nil {
+		parent = strings.TrimSpace(string(out))
+		out, err := g.exec(nil, "rev-parse", parent+"^{tree}")
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(string(out)) == tree {
+			return "", nil
+		}
+		args = append(args, "-p", parent)
+	}
+
+	out, err = g.exec(nil, args...)
+	if err != nil {
+		return "", err
+	}
+	commit = strings.TrimSpace(string(out))
+
+	// Fail rather than overwrite the ref if it changed concurrently. An empty
+	// old value requires that the ref does not exist yet.
+	if _, err := g.exec(nil, "update-ref", "-m", "publish", ref, commit, parent); err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+// exec runs git with the work tree as its working directory and the
+// additional environment variables.
+func (g *repository) exec(env []string, args ...string) (stdout []byte, err error) {
+	if g.gitDir != "" {
+		args = append([]string{"--git-dir", g.gitDir, "--work-tree", g.workTree}, args...)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.workTree
+	cmd.Env = append(os.Environ(), env...)
+	outBuf := new(bytes.Buffer)
+	cmd.Stdout = outBuf
+	errBuf := new(bytes.Buffer)
+	cmd.Stderr = errBuf
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed running git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(errBuf.String()))
+	}
+	return outBuf.Bytes(), nil
+}
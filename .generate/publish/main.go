@@ -0,0 +1,220 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// publish commits the generated schemas to a publishing branch, such as
+// gh-pages, so that they can be hosted with GitHub Pages. The branch contains
+// only the generated content with the output directory as its root. A commit
+// is only created when the content changed, and its message names the latest
+// version and the package-spec commit it was generated from so that repeated
+// runs produce the same message.
+//
+// With -target the generated content is instead synced to an S3 or GCS
+// bucket. Only objects that are missing or whose content changed are
+// uploaded, each with the Content-Type of its file. Credentials are read
+// from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN. GCS
+// buckets are accessed through its S3 compatible XML API and require HMAC
+// keys.
+//
+// With an oci://registry/repository target the bundles of each version are
+// pushed as an OCI artifact tagged with the version, one layer per file, with
+// the provenance from metadata.json in its annotations. Registry credentials
+// are read from REGISTRY_USERNAME and REGISTRY_PASSWORD.
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+	"github.com/andrewkroh/package-spec-schema/internal/versionindex"
+)
+
+var (
+	outDir string // Directory containing the versioned directories.
+	branch string // Branch to which the generated content is committed.
+	remote string // Git remote to which the branch is pushed.
+	push   bool   // Push the branch after committing.
+
+	target      string // s3://, gs://, or oci:// URL to publish to instead of committing.
+	endpoint    string // Service URL that overrides the default of the target.
+	region      string // Region of S3 buckets.
+	parallel    int    // Maximum number of concurrent uploads.
+	deleteStale bool   // Delete objects that no longer exist locally.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories, must be within a git repository")
+	flag.StringVar(&branch, "branch", "gh-pages", "branch to which the generated content is committed")
+	flag.StringVar(&remote, "remote", "origin", "git remote to which the branch is pushed")
+	flag.BoolVar(&push, "push", false, "push the branch to the remote after committing")
+	flag.StringVar(&target, "target", "", "sync to an s3://bucket/prefix or gs://bucket/prefix URL, or push artifacts to an oci://registry/repository, instead of committing to a branch")
+	flag.StringVar(&endpoint, "endpoint", "", "URL of an S3 compatible service, used with path-style addressing, or of the OCI registry, overriding the default of the target")
+	flag.StringVar(&region, "region", cmp.Or(os.Getenv("AWS_REGION"), "us-east-1"), "region of s3 targets")
+	flag.IntVar(&parallel, "parallel", 8, "maximum number of concurrent uploads")
+	flag.BoolVar(&deleteStale, "delete", false, "delete objects beneath the target prefix that do not exist in the output directory")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+// publishPaths are the glob patterns, relative to the output directory, of
+// the generated content that is published.
+var publishPaths = []string{
+	"[1-9].[0-9]*.[0-9]*",
+	"[1-9]",
+	"latest",
+	versionindex.FileName,
+	"catalog.json",
+	"manifest.jsonschema.json",
+}
+
+func run() error {
+	if branch == "" {
+		return errors.New("-branch must not be empty")
+	}
+
+	var paths []string
+	for _, pattern := range publishPaths {
+		matches, err := filepath.Glob(filepath.Join(outDir, pattern))
+		if err != nil {
+			return err
+		}
+		for _, m := range matches {
+			paths = append(paths, filepath.Base(m))
+		}
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no generated content found in %s", outDir)
+	}
+
+	if target != "" {
+		if push {
+			return errors.New("-push cannot be used with -target")
+		}
+		return publishTarget(paths)
+	}
+
+	message, err := commitMessage(outDir)
+	if err != nil {
+		return err
+	}
+
+	g, err := openRepo(outDir)
+	if err != nil {
+		return err
+	}
+	commit, err := g.commitTree(paths, "refs/heads/"+branch, message)
+	if err != nil {
+		return err
+	}
+	if commit == "" {
+		slog.Info("Published content is unchanged.", "branch", branch)
+	} else {
+		slog.Info("Committed published content.", "branch", branch, "commit", commit, "message", message)
+	}
+
+	if push {
+		if _, err := g.exec(nil, "push", remote, "refs/heads/"+branch); err != nil {
+			return err
+		}
+		slog.Info("Pushed branch.", "remote", remote, "branch", branch)
+	}
+	return nil
+}
+
+// publishTarget syncs the paths to a bucket or pushes the version bundles to
+// a registry, depending on the scheme of the target.
+func publishTarget(paths []string) error {
+	if strings.HasPrefix(target, "oci://") {
+		r, err := parseRegistryTarget(target, endpoint, os.Getenv("REGISTRY_USERNAME"), os.Getenv("REGISTRY_PASSWORD"))
+		if err != nil {
+			return err
+		}
+		return pushArtifacts(r, outDir)
+	}
+
+	if parallel < 1 {
+		return errors.New("-parallel must be at least 1")
+	}
+	creds := credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to use -target")
+	}
+	b, err := parseTarget(target, endpoint, region, creds)
+	if err != nil {
+		return err
+	}
+	return syncBucket(b, outDir, paths, parallel, deleteStale)
+}
+
+// commitMessage returns a message naming the latest version and the
+// package-spec commit it was generated from according to the version index.
+func commitMessage(dir string) (string, error) {
+	idx, err := versionindex.Read(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read version index: %w", err)
+	}
+
+	latest := idx.Latest
+	if latest == "" {
+		// Fall back to the newest version directory without an index.
+		if latest, err = newestVersionDir(dir); err != nil {
+			return "", err
+		}
+	}
+
+	subject := "Publish package-spec " + latest
+	for _, e := range idx.Versions {
+		if e.Version == latest && e.Commit != "" {
+			subject += " (elastic/package-spec@" + e.Commit + ")"
+		}
+	}
+	return subject, nil
+}
+
+func newestVersionDir(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var newest *semver.Version
+	for _, e := range entries {
+		v, err := semver.NewVersion(e.Name())
+		if !e.IsDir() || err != nil || v.PreRelease != "" {
+			continue
+		}
+		if newest == nil || newest.LessThan(*v) {
+			newest = v
+		}
+	}
+	if newest == nil {
+		return "", fmt.Errorf("no version directories found in %s", dir)
+	}
+	return newest.String(), nil
+}
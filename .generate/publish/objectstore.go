@@ -0,0 +1,215 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// bucket is an S3 compatible object storage bucket. GCS buckets are accessed
+// through the S3 compatible XML API.
+type bucket struct {
+	endpoint *url.URL // Base URL of the bucket, objects are beneath its path.
+	region   string
+	prefix   string // Key prefix without leading or trailing slash.
+	creds    credentials
+	client   *http.Client
+}
+
+// parseTarget returns the bucket of a s3://bucket/prefix or gs://bucket/prefix
+// target. If endpoint is not empty then it is used with path-style addressing
+// instead of the default endpoint of the service.
+func parseTarget(target, endpoint, region string, creds credentials) (*bucket, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target: %w", err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("target %q has no bucket", target)
+	}
+
+	b := &bucket{
+		region: region,
+		prefix: strings.Trim(u.Path, "/"),
+		creds:  creds,
+		client: &http.Client{Timeout: 5 * time.Minute},
+	}
+	switch {
+	case endpoint != "":
+		e, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint: %w", err)
+		}
+		e.Path = path.Join("/", e.Path, u.Host)
+		b.endpoint = e
+	case u.Scheme == "s3":
+		b.endpoint = &url.URL{Scheme: "https", Host: u.Host + ".s3." + region + ".amazonaws.com", Path: "/"}
+	case u.Scheme == "gs":
+		b.endpoint = &url.URL{Scheme: "https", Host: "storage.googleapis.com", Path: "/" + u.Host}
+		b.region = "auto"
+	default:
+		return nil, fmt.Errorf("unsupported target scheme %q, must be s3 or gs", u.Scheme)
+	}
+	if b.region == "" {
+		return nil, errors.New("a region is required for s3 targets")
+	}
+	return b, nil
+}
+
+// key returns the object key of a slash-separated path relative to the
+// published root.
+func (b *bucket) key(rel string) string {
+	if b.prefix == "" {
+		return rel
+	}
+	return b.prefix + "/" + rel
+}
+
+// objectURL returns the URL of the object with key, or of the bucket if key
+// is empty.
+func (b *bucket) objectURL(key string, query url.Values) *url.URL {
+	u := *b.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	u.RawPath = ""
+	u.RawQuery = query.Encode()
+	return &u
+}
+
+// listETags returns the ETag of every object beneath the prefix keyed by
+// object key.
+func (b *bucket) listETags() (map[string]string, error) {
+	type listResult struct {
+		Contents []struct {
+			Key  string `xml:"Key"`
+			ETag string `xml:"ETag"`
+		} `xml:"Contents"`
+		IsTruncated           bool   `xml:"IsTruncated"`
+		NextContinuationToken string `xml:"NextContinuationToken"`
+	}
+
+	etags := map[string]string{}
+	var token string
+	for {
+		query := url.Values{"list-type": {"2"}}
+		if b.prefix != "" {
+			query.Set("prefix", b.prefix+"/")
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		body, err := b.do(http.MethodGet, b.objectURL("", query), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", err)
+		}
+		var res listResult
+		if err := xml.Unmarshal(body, &res); err != nil {
+			return nil, fmt.Errorf("failed to decode object listing: %w", err)
+		}
+		for _, c := range res.Contents {
+			etags[c.Key] = strings.Trim(c.ETag, `"`)
+		}
+		if !res.IsTruncated {
+			return etags, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+// put uploads the published file name to key with the content type and
+// encoding of the file.
+func (b *bucket) put(key string, data []byte, name string) error {
+	sum := md5.Sum(data)
+	header := http.Header{}
+	header.Set("Content-Type", contentType(name))
+	if enc := contentEncoding(name); enc != "" {
+		header.Set("Content-Encoding", enc)
+	}
+	header.Set("Content-Md5", base64.StdEncoding.EncodeToString(sum[:]))
+	_, err := b.do(http.MethodPut, b.objectURL(key, nil), header, data)
+	return err
+}
+
+// delete removes the object with key.
+func (b *bucket) delete(key string) error {
+	_, err := b.do(http.MethodDelete, b.objectURL(key, nil), nil, nil)
+	return err
+}
+
+// do sends a signed request and returns the response body.
+func (b *bucket) do(method string, u *url.URL, header http.Header, body []byte) (respBody []byte, err error) {
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	signV4(req, b.creds, b.region, sha256Hex(body), time.Now())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+	}()
+
+	respBody, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s failed with status %d: %s", method, u.Path, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
+
+// contentType returns the media type of a published file. Precompressed
+// siblings have the media type of the file they compress, see contentEncoding.
+func contentType(name string) string {
+	name = strings.TrimSuffix(name, ".gz")
+	switch {
+	case strings.HasSuffix(name, ".jsonschema.json"):
+		return "application/schema+json"
+	case strings.HasSuffix(name, ".json"):
+		return "application/json"
+	}
+	if t := mime.TypeByExtension(path.Ext(name)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// md5Hex returns the hex encoded MD5 digest of data, which equals the ETag
+// of objects uploaded in a single request.
+func md5Hex(data []byte) string {
+	sum := md5.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// contentEncoding returns the content coding of a published file, gzip for
+// the precompressed .gz siblings written by `just compress`, so that clients
+// are served the decompressed schema.
+func contentEncoding(name string) string {
+	if strings.HasSuffix(name, ".gz") {
+		return "gzip"
+	}
+	return ""
+}
@@ -0,0 +1,402 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBucket is an in-memory S3 bucket served with path-style addressing. It
+// verifies the signature of every request and lists at most pageSize objects
+// per response.
+type fakeBucket struct {
+	t        *testing.T
+	name     string
+	creds    credentials
+	pageSize int
+
+	mu      sync.Mutex
+	objects map[string][]byte
+	headers map[string]http.Header // Headers of the last upload of a key.
+	etags   map[string]string      // Overrides the ETag of an object.
+	fail    map[string]int         // Status code returned for requests of a key.
+	puts    []string
+	deletes []string
+	lists   int
+}
+
+func newFakeBucket(t *testing.T, creds credentials) (*fakeBucket, *httptest.Server) {
+	f := &fakeBucket{
+		t:        t,
+		name:     "schemas",
+		creds:    creds,
+		pageSize: 2,
+		objects:  map[string][]byte{},
+		headers:  map[string]http.Header{},
+		etags:    map[string]string{},
+		fail:     map[string]int{},
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		f.t.Error(err)
+		return
+	}
+	if !f.validSignature(r, body) {
+		writeS3Error(w, http.StatusForbidden, "SignatureDoesNotMatch")
+		return
+	}
+
+	key, ok := strings.CutPrefix(r.URL.Path, "/"+f.name+"/")
+	if !ok {
+		writeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if code := f.fail[key]; code != 0 {
+		writeS3Error(w, code, "InternalError")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		f.lists++
+		f.list(w, r)
+	case r.Method == http.MethodPut:
+		sum := md5.Sum(body)
+		if r.Header.Get("Content-Md5") != base64.StdEncoding.EncodeToString(sum[:]) {
+			writeS3Error(w, http.StatusBadRequest, "BadDigest")
+			return
+		}
+		f.puts = append(f.puts, key)
+		f.objects[key] = body
+		f.headers[key] = r.Header.Clone()
+		delete(f.etags, key)
+	case r.Method == http.MethodDelete:
+		f.deletes = append(f.deletes, key)
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeS3Error(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// validSignature signs a copy of the received request and compares the
+// result, which fails if the signed and the sent request differ.
+func (f *fakeBucket) validSignature(r *http.Request, body []byte) bool {
+	now, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	if err != nil || r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) {
+		return false
+	}
+	req, err := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	if err != nil {
+		return false
+	}
+	for k, v := range r.Header {
+		switch k {
+		case "Authorization", "Accept-Encoding", "Content-Length", "User-Agent":
+		default:
+			req.Header[k] = v
+		}
+	}
+	signV4(req, f.creds, "us-east-1", sha256Hex(body), now)
+	return req.Header.Get("Authorization") == r.Header.Get("Authorization")
+}
+
+func (f *fakeBucket) list(w http.ResponseWriter, r *http.Request) {
+	type object struct {
+		Key  string `xml:"Key"`
+		ETag string `xml:"ETag"`
+	}
+	type listResult struct {
+		XMLName               xml.Name `xml:"ListBucketResult"`
+		Contents              []object `xml:"Contents"`
+		IsTruncated           bool     `xml:"IsTruncated"`
+		NextContinuationToken string   `xml:"NextContinuationToken,omitempty"`
+	}
+
+	query := r.URL.Query()
+	if query.Get("list-type") != "2" {
+		writeS3Error(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+	var keys []string
+	for _, k := range slices.Sorted(maps.Keys(f.objects)) {
+		if strings.HasPrefix(k, query.Get("prefix")) {
+			keys = append(keys, k)
+		}
+	}
+	start := 0
+	if token := query.Get("continuation-token"); token != "" {
+		if start, _ = strconv.Atoi(token); start == 0 {
+			writeS3Error(w, http.StatusBadRequest, "InvalidArgument")
+			return
+		}
+	}
+
+	var res listResult
+	end := min(start+f.pageSize, len(keys))
+	for _, k := range keys[start:end] {
+		etag := f.etags[k]
+		if etag == "" {
+			etag = md5Hex(f.objects[k])
+		}
+		res.Contents = append(res.Contents, object{Key: k, ETag: `"` + etag + `"`})
+	}
+	if end < len(keys) {
+		res.IsTruncated = true
+		res.NextContinuationToken = strconv.Itoa(end)
+	}
+	b, err := xml.Marshal(res)
+	if err != nil {
+		f.t.Error(err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	_, _ = w.Write(b)
+}
+
+func writeS3Error(w http.ResponseWriter, code int, s3Code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(code)
+	_, _ = w.Write([]byte("<Error><Code>" + s3Code + "</Code></Error>"))
+}
+
+var testCreds = credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+
+func TestSyncBucket(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"versions.json":                             `{"latest":"3.6.0"}`,
+		"3.6.0/bundles/manifest.jsonschema.json":    `{"type":"object"}`,
+		"3.6.0/bundles/changelog.jsonschema.json":   `{"type":"array"}`,
+		"3.6.0/bundles/data stream.jsonschema.json": `{}`,
+		"3.6.0/bundles/fields.jsonschema.json":      `{"items":{}}`,
+		"3.6.0/bundles/fields.jsonschema.json.gz":   "gzip",
+	}
+	for name, data := range files {
+		writeTestFile(t, filepath.Join(dir, name), data)
+	}
+
+	f, srv := newFakeBucket(t, testCreds)
+	f.objects["site/versions.json"] = []byte(`{"latest":"3.6.0"}`)
+	// Objects uploaded in parts have an ETag that is not the MD5 digest.
+	f.objects["site/3.6.0/bundles/manifest.jsonschema.json"] = []byte(`{"type":"object"}`)
+	f.etags["site/3.6.0/bundles/manifest.jsonschema.json"] = "9b2cf535f27731c974343645a3985328-2"
+	f.objects["site/3.6.0/bundles/changelog.jsonschema.json"] = []byte(`{}`)
+	f.objects["site/3.5.0/bundles/manifest.jsonschema.json"] = []byte(`{}`)
+	f.objects["other/file.json"] = []byte(`{}`)
+
+	b, err := parseTarget("s3://schemas/site", srv.URL, "us-east-1", testCreds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syncBucket(b, dir, []string{"3.6.0", "versions.json"}, 2, true); err != nil {
+		t.Fatal(err)
+	}
+
+	wantPuts := []string{
+		"site/3.6.0/bundles/changelog.jsonschema.json",
+		"site/3.6.0/bundles/data stream.jsonschema.json",
+		"site/3.6.0/bundles/fields.jsonschema.json",
+		"site/3.6.0/bundles/fields.jsonschema.json.gz",
+		"site/3.6.0/bundles/manifest.jsonschema.json",
+	}
+	slices.Sort(f.puts)
+	if !slices.Equal(f.puts, wantPuts) {
+		t.Errorf("uploaded %q, want %q", f.puts, wantPuts)
+	}
+	if want := []string{"site/3.5.0/bundles/manifest.jsonschema.json"}; !slices.Equal(f.deletes, want) {
+		t.Errorf("deleted %q, want %q", f.deletes, want)
+	}
+	// Four objects beneath the prefix are listed two per page.
+	if f.lists != 2 {
+		t.Errorf("listed %d pages, want 2", f.lists)
+	}
+	for name, data := range files {
+		if got := string(f.objects["site/"+name]); got != data {
+			t.Errorf("object %s = %q, want %q", name, got, data)
+		}
+	}
+	if _, ok := f.objects["other/file.json"]; !ok {
+		t.Error("object outside of the prefix was deleted")
+	}
+	for key, want := range map[string][2]string{
+		"site/3.6.0/bundles/fields.jsonschema.json":    {"application/schema+json", ""},
+		"site/3.6.0/bundles/fields.jsonschema.json.gz": {"application/schema+json", "gzip"},
+	} {
+		h := f.headers[key]
+		if got := [2]string{h.Get("Content-Type"), h.Get("Content-Encoding")}; got != want {
+			t.Errorf("object %s has Content-Type and Content-Encoding %q, want %q", key, got, want)
+		}
+	}
+
+	// A second sync has nothing to do.
+	f.puts, f.deletes = nil, nil
+	if err := syncBucket(b, dir, []string{"3.6.0", "versions.json"}, 2, true); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.puts) != 0 || len(f.deletes) != 0 {
+		t.Errorf("second sync uploaded %q and deleted %q", f.puts, f.deletes)
+	}
+}
+
+func TestSyncBucketErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "3.6.0", "a.json"), "{}")
+	writeTestFile(t, filepath.Join(dir, "3.6.0", "b.json"), "[]")
+
+	tests := []struct {
+		name   string
+		creds  credentials
+		bucket string
+		fail   string // Key whose requests fail, empty for the listing.
+		status int    // Status of failing requests, zero for none.
+		want   []string
+	}{
+		{
+			name:   "list fails",
+			creds:  testCreds,
+			bucket: "s3://schemas",
+			status: http.StatusInternalServerError,
+			want:   []string{"failed to list objects", "status 500", "InternalError"},
+		},
+		{
+			name:   "upload fails",
+			creds:  testCreds,
+			bucket: "s3://schemas",
+			fail:   "3.6.0/b.json",
+			status: http.StatusInternalServerError,
+			want:   []string{"failed to upload 3.6.0/b.json", "status 500"},
+		},
+		{
+			name:   "signature rejected",
+			creds:  credentials{AccessKeyID: testCreds.AccessKeyID, SecretAccessKey: "wrong"},
+			bucket: "s3://schemas",
+			want:   []string{"status 403", "SignatureDoesNotMatch"},
+		},
+		{
+			name:   "missing bucket",
+			creds:  testCreds,
+			bucket: "s3://missing",
+			want:   []string{"status 404", "NoSuchBucket"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f, srv := newFakeBucket(t, testCreds)
+			if tc.status != 0 {
+				f.fail[tc.fail] = tc.status
+			}
+
+			b, err := parseTarget(tc.bucket, srv.URL, "us-east-1", tc.creds)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = syncBucket(b, dir, []string{"3.6.0"}, 1, false)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, s := range tc.want {
+				if !strings.Contains(err.Error(), s) {
+					t.Errorf("error %q does not contain %q", err, s)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncBucketReadError(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "3.6.0", "a.json"), "{}")
+	writeTestFile(t, filepath.Join(dir, "3.6.0", "b.json"), "[]")
+	if err := os.Symlink("missing.json", filepath.Join(dir, "3.6.0", "c.json")); err != nil {
+		t.Fatal(err)
+	}
+
+	f, srv := newFakeBucket(t, testCreds)
+	b, err := parseTarget("s3://schemas", srv.URL, "us-east-1", testCreds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = syncBucket(b, dir, []string{"3.6.0"}, 2, false)
+	if err == nil || !strings.Contains(err.Error(), "c.json") {
+		t.Fatalf("expected an error for c.json, got %v", err)
+	}
+
+	// The uploads started before the error have completed.
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	slices.Sort(f.puts)
+	if want := []string{"3.6.0/a.json", "3.6.0/b.json"}; !slices.Equal(f.puts, want) {
+		t.Errorf("uploaded %q, want %q", f.puts, want)
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		target, endpoint, region string
+		url, prefix              string
+		err                      bool
+	}{
+		{target: "s3://bucket/a/b/", region: "eu-west-1", url: "https://bucket.s3.eu-west-1.amazonaws.com/", prefix: "a/b"},
+		{target: "gs://bucket", region: "us-east-1", url: "https://storage.googleapis.com/bucket"},
+		{target: "s3://bucket/p", endpoint: "http://localhost:9000", region: "us-east-1", url: "http://localhost:9000/bucket", prefix: "p"},
+		{target: "s3://bucket", err: true},
+		{target: "s3:///prefix", region: "us-east-1", err: true},
+		{target: "ftp://bucket", region: "us-east-1", err: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.target, func(t *testing.T) {
+			b, err := parseTarget(tc.target, tc.endpoint, tc.region, testCreds)
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := b.endpoint.String(); got != tc.url {
+				t.Errorf("endpoint = %q, want %q", got, tc.url)
+			}
+			if b.prefix != tc.prefix {
+				t.Errorf("prefix = %q, want %q", b.prefix, tc.prefix)
+			}
+		})
+	}
+}
+
+func writeTestFile(t *testing.T, name, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
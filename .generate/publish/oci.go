@@ -0,0 +1,181 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/versionindex"
+)
+
+const (
+	manifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	emptyMediaType    = "application/vnd.oci.empty.v1+json"
+	// artifactType identifies the bundled schemas of a package-spec version.
+	artifactType = "application/vnd.package-spec-schema.bundles.v1"
+)
+
+// emptyConfig is the config blob of artifacts that have no configuration.
+var emptyConfig = []byte("{}")
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType"`
+	Config        descriptor        `json:"config"`
+	Layers        []descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// generationMetadata is the metadata.json written by the clone tool.
+type generationMetadata struct {
+	GitURL    string    `json:"git_url"`
+	Commit    string    `json:"commit"`
+	Generated time.Time `json:"generated"`
+}
+
+// pushArtifacts pushes the bundles of each version in dir as an OCI artifact
+// tagged with the version. Each file is a layer titled with its path so that
+// `oras pull` recreates the bundles directory. The latest version is also
+// tagged as latest.
+func pushArtifacts(r *registry, dir string) error {
+	start := time.Now()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var pushed, unchanged int
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := semver.NewVersion(e.Name()); err != nil {
+			continue
+		}
+		bundles := filepath.Join(dir, e.Name(), "bundles")
+		if _, err := os.Stat(bundles); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		m, blobs, err := versionArtifact(filepath.Join(dir, e.Name()), e.Name())
+		if err != nil {
+			return fmt.Errorf("failed building artifact of %s: %w", e.Name(), err)
+		}
+		changed, err := r.pushArtifact(e.Name(), m, blobs)
+		if err != nil {
+			return fmt.Errorf("failed pushing %s: %w", e.Name(), err)
+		}
+		if changed {
+			pushed++
+			slog.Info("Pushed artifact.", "ref", r.ref(e.Name()), "layers", len(m.Layers))
+		} else {
+			unchanged++
+			slog.Debug("Artifact is unchanged.", "ref", r.ref(e.Name()))
+		}
+	}
+
+	idx, err := versionindex.Read(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read version index: %w", err)
+	}
+	if idx.Latest != "" {
+		if err := r.tag(idx.Latest, "latest"); err != nil {
+			return fmt.Errorf("failed tagging latest: %w", err)
+		}
+	}
+
+	slog.Info("Pushed artifacts.", "repository", r.repository, "pushed", pushed, "unchanged", unchanged, "duration", time.Since(start))
+	return nil
+}
+
+// versionArtifact returns the manifest of a version's bundles and its blobs
+// keyed by digest. The provenance of the version is recorded in standard
+// annotations.
+func versionArtifact(versionDir, version string) (*manifest, map[string][]byte, error) {
+	blobs := map[string][]byte{}
+	add := func(data []byte) string {
+		sum := sha256.Sum256(data)
+		d := "sha256:" + hex.EncodeToString(sum[:])
+		blobs[d] = data
+		return d
+	}
+
+	m := &manifest{
+		SchemaVersion: 2,
+		MediaType:     manifestMediaType,
+		ArtifactType:  artifactType,
+		Config:        descriptor{MediaType: emptyMediaType, Digest: add(emptyConfig), Size: int64(len(emptyConfig))},
+		Annotations: map[string]string{
+			"org.opencontainers.image.title":   "package-spec " + version + " schema bundles",
+			"org.opencontainers.image.version": version,
+		},
+	}
+
+	// WalkDir visits files in lexical order, so the manifest is reproducible.
+	root := filepath.Join(versionDir, "bundles")
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		// Registries compress on transfer, so precompressed siblings would
+		// only duplicate the bundles.
+		if strings.HasSuffix(path, ".gz") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		m.Layers = append(m.Layers, descriptor{
+			MediaType:   contentType(rel),
+			Digest:      add(data),
+			Size:        int64(len(data)),
+			Annotations: map[string]string{"org.opencontainers.image.title": filepath.ToSlash(rel)},
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := os.ReadFile(filepath.Join(versionDir, "jsonschema", "metadata.json"))
+	switch {
+	case err == nil:
+		var meta generationMetadata
+		if err := json.Unmarshal(b, &meta); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode metadata.json: %w", err)
+		}
+		m.Annotations["org.opencontainers.image.source"] = meta.GitURL
+		m.Annotations["org.opencontainers.image.revision"] = meta.Commit
+		m.Annotations["org.opencontainers.image.created"] = meta.Generated.UTC().Format(time.RFC3339)
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, nil, err
+	}
+	return m, blobs, nil
+}
@@ -0,0 +1,300 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// registry is a client of the OCI distribution API for a single repository.
+type registry struct {
+	base       *url.URL // Registry URL, e.g. https://ghcr.io.
+	host       string   // Registry host used in references.
+	repository string
+	username   string
+	password   string
+	client     *http.Client
+
+	auth string // Authorization header value obtained from a challenge.
+}
+
+// parseRegistryTarget returns the registry of an oci://host/repository
+// target. If endpoint is not empty then it is used as the registry URL, which
+// allows plain HTTP registries.
+func parseRegistryTarget(target, endpoint, username, password string) (*registry, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target: %w", err)
+	}
+	repo := strings.Trim(u.Path, "/")
+	if u.Host == "" || repo == "" {
+		return nil, fmt.Errorf("target %q must be oci://registry/repository", target)
+	}
+
+	base := &url.URL{Scheme: "https", Host: u.Host}
+	if endpoint != "" {
+		if base, err = url.Parse(endpoint); err != nil {
+			return nil, fmt.Errorf("invalid endpoint: %w", err)
+		}
+	}
+	return &registry{
+		base:       base,
+		host:       u.Host,
+		repository: repo,
+		username:   username,
+		password:   password,
+		client:     &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// ref returns the reference of tag in the repository.
+func (r *registry) ref(tag string) string {
+	return r.host + "/" + r.repository + ":" + tag
+}
+
+// pushArtifact uploads the missing blobs and the manifest under tag. It
+// reports false without uploading anything if tag already refers to an
+// identical manifest.
+func (r *registry) pushArtifact(tag string, m *manifest, blobs map[string][]byte) (bool, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return false, err
+	}
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	resp, err := r.do(http.MethodHead, r.path("manifests", tag), http.Header{"Accept": {manifestMediaType}}, nil)
+	if err != nil {
+		return false, err
+	}
+	if resp.StatusCode == http.StatusOK && resp.Header.Get("Docker-Content-Digest") == digest {
+		return false, nil
+	}
+
+	for d, blob := range blobs {
+		if err := r.uploadBlob(d, blob); err != nil {
+			return false, err
+		}
+	}
+	if err := r.putManifest(tag, data); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// tag points dst at the manifest that src refers to.
+func (r *registry) tag(src, dst string) error {
+	resp, err := r.do(http.MethodGet, r.path("manifests", src), http.Header{"Accept": {manifestMediaType}}, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return r.putManifest(dst, resp.body)
+}
+
+func (r *registry) putManifest(tag string, data []byte) error {
+	resp, err := r.do(http.MethodPut, r.path("manifests", tag), http.Header{"Content-Type": {manifestMediaType}}, data)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// uploadBlob uploads blob unless the registry already has it.
+func (r *registry) uploadBlob(digest string, blob []byte) error {
+	resp, err := r.do(http.MethodHead, r.path("blobs", digest), nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	resp, err = r.do(http.MethodPost, r.path("blobs", "uploads")+"/", nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		return responseError(resp)
+	}
+	loc, err := r.base.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return fmt.Errorf("invalid upload location: %w", err)
+	}
+	q := loc.Query()
+	q.Set("digest", digest)
+	loc.RawQuery = q.Encode()
+
+	resp, err = r.do(http.MethodPut, loc.String(), http.Header{"Content-Type": {"application/octet-stream"}}, blob)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// path returns the URL of a resource of the repository.
+func (r *registry) path(kind, reference string) string {
+	return r.base.JoinPath("v2", r.repository, kind, reference).String()
+}
+
+// registryResponse is a response whose body was read.
+type registryResponse struct {
+	*http.Response
+	body []byte
+}
+
+func responseError(resp *registryResponse) error {
+	return fmt.Errorf("%s %s failed with status %d: %s", resp.Request.Method, resp.Request.URL.Path, resp.StatusCode, strings.TrimSpace(string(resp.body)))
+}
+
+// do sends a request. If the registry challenges the request then it
+// authenticates and retries it once.
+func (r *registry) do(method, rawURL string, header http.Header, body []byte) (*registryResponse, error) {
+	resp, err := r.send(method, rawURL, header, body)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if err := r.authenticate(resp.Header.Get("WWW-Authenticate")); err != nil {
+		return nil, err
+	}
+	return r.send(method, rawURL, header, body)
+}
+
+func (r *registry) send(method, rawURL string, header http.Header, body []byte) (_ *registryResponse, err error) {
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if r.auth != "" {
+		req.Header.Set("Authorization", r.auth)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+	}()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &registryResponse{Response: resp, body: b}, nil
+}
+
+// authenticate answers a Basic or Bearer WWW-Authenticate challenge. Bearer
+// tokens are requested with pull and push scope for the repository.
+func (r *registry) authenticate(challenge string) (err error) {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if r.username == "" && r.password == "" {
+			return errors.New("registry requires credentials, set REGISTRY_USERNAME and REGISTRY_PASSWORD")
+		}
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(r.username, r.password)
+		r.auth = req.Header.Get("Authorization")
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("unsupported registry authentication challenge %q", challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return fmt.Errorf("invalid registry authentication realm %q", params["realm"])
+	}
+	q := realm.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	q.Set("scope", "repository:"+r.repository+":pull,push")
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if r.username != "" || r.password != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to get registry token: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get registry token: status %d", resp.StatusCode)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("failed to decode registry token: %w", err)
+	}
+	t := token.Token
+	if t == "" {
+		t = token.AccessToken
+	}
+	r.auth = "Bearer " + t
+	return nil
+}
+
+// parseChallenge parses a WWW-Authenticate header into its scheme and
+// parameters. Quoted values may contain commas.
+func parseChallenge(challenge string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+	params := map[string]string{}
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		key, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			break
+		}
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				params[strings.ToLower(key)] = value[1:]
+				break
+			}
+			params[strings.ToLower(key)] = value[1 : end+1]
+			rest = value[end+2:]
+			continue
+		}
+		v, after, _ := strings.Cut(value, ",")
+		params[strings.ToLower(key)] = strings.TrimSpace(v)
+		rest = after
+	}
+	return scheme, params
+}
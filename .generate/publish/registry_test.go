@@ -0,0 +1,351 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRegistry is an in-memory OCI distribution registry for the repository
+// "schemas/bundles". Requests to it must carry the bearer token issued by its
+// token endpoint, which requires basic authentication.
+type fakeRegistry struct {
+	t      *testing.T
+	srv    *httptest.Server
+	user   string
+	pass   string
+	token  string
+	basic  bool // Challenge with Basic instead of Bearer.
+	failOn string
+
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte // Keyed by tag and by digest.
+	uploads   []string          // Digests of uploaded blobs.
+	puts      []string          // Tags of uploaded manifests.
+	scopes    []string          // Scopes of issued tokens.
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	f := &fakeRegistry{
+		t:         t,
+		user:      "user",
+		pass:      "pass",
+		token:     "token-1",
+		blobs:     map[string][]byte{},
+		manifests: map[string][]byte{},
+	}
+	f.srv = httptest.NewServer(f)
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/token" {
+		if user, pass, ok := r.BasicAuth(); !ok || user != f.user || pass != f.pass {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("service") != "registry.test" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.scopes = append(f.scopes, r.URL.Query().Get("scope"))
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": f.token})
+		return
+	}
+
+	if !f.authorized(r) {
+		if f.basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+f.srv.URL+`/token",service="registry.test",scope="repository:schemas/bundles:pull"`)
+		}
+		http.Error(w, `{"errors":[{"code":"UNAUTHORIZED"}]}`, http.StatusUnauthorized)
+		return
+	}
+
+	rest, ok := strings.CutPrefix(r.URL.Path, "/v2/schemas/bundles/")
+	if !ok {
+		http.Error(w, `{"errors":[{"code":"NAME_UNKNOWN"}]}`, http.StatusNotFound)
+		return
+	}
+	if f.failOn != "" && r.Method+" "+rest == f.failOn {
+		http.Error(w, `{"errors":[{"code":"DENIED"}]}`, http.StatusForbidden)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		f.t.Error(err)
+		return
+	}
+
+	kind, ref, _ := strings.Cut(rest, "/")
+	switch {
+	case kind == "blobs" && ref == "uploads/" && r.Method == http.MethodPost:
+		w.Header().Set("Location", "/v2/schemas/bundles/blobs/uploads/session-1?_state=abc")
+		w.WriteHeader(http.StatusAccepted)
+	case kind == "blobs" && strings.HasPrefix(ref, "uploads/") && r.Method == http.MethodPut:
+		digest := r.URL.Query().Get("digest")
+		if r.URL.Query().Get("_state") != "abc" || digest != sha256Digest(body) {
+			http.Error(w, `{"errors":[{"code":"DIGEST_INVALID"}]}`, http.StatusBadRequest)
+			return
+		}
+		f.blobs[digest] = body
+		f.uploads = append(f.uploads, digest)
+		w.WriteHeader(http.StatusCreated)
+	case kind == "blobs" && r.Method == http.MethodHead:
+		if _, ok := f.blobs[ref]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case kind == "manifests" && r.Method == http.MethodPut:
+		if r.Header.Get("Content-Type") != manifestMediaType {
+			http.Error(w, `{"errors":[{"code":"MANIFEST_INVALID"}]}`, http.StatusBadRequest)
+			return
+		}
+		var m manifest
+		if err := json.Unmarshal(body, &m); err != nil {
+			http.Error(w, `{"errors":[{"code":"MANIFEST_INVALID"}]}`, http.StatusBadRequest)
+			return
+		}
+		for _, d := range append([]descriptor{m.Config}, m.Layers...) {
+			if _, ok := f.blobs[d.Digest]; !ok {
+				http.Error(w, `{"errors":[{"code":"MANIFEST_BLOB_UNKNOWN"}]}`, http.StatusBadRequest)
+				return
+			}
+		}
+		f.manifests[ref] = body
+		f.manifests[sha256Digest(body)] = body
+		f.puts = append(f.puts, ref)
+		w.WriteHeader(http.StatusCreated)
+	case kind == "manifests" && (r.Method == http.MethodHead || r.Method == http.MethodGet):
+		m, ok := f.manifests[ref]
+		if !ok {
+			http.Error(w, `{"errors":[{"code":"MANIFEST_UNKNOWN"}]}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", manifestMediaType)
+		w.Header().Set("Docker-Content-Digest", sha256Digest(m))
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(m)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeRegistry) authorized(r *http.Request) bool {
+	if f.basic {
+		user, pass, ok := r.BasicAuth()
+		return ok && user == f.user && pass == f.pass
+	}
+	return r.Header.Get("Authorization") == "Bearer "+f.token
+}
+
+func sha256Digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// testArtifact returns the artifact of a version directory with two bundles.
+func testArtifact(t *testing.T, version string) (*manifest, map[string][]byte) {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "bundles", "manifest.jsonschema.json"), `{"type":"object"}`)
+	writeTestFile(t, filepath.Join(dir, "bundles", "data_stream", "manifest.jsonschema.json"), `{"type":"object","title":"`+version+`"}`)
+	writeTestFile(t, filepath.Join(dir, "bundles", "manifest.jsonschema.json.gz"), "gzip")
+	m, blobs, err := versionArtifact(dir, version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, blobs
+}
+
+func TestRegistryPushArtifact(t *testing.T) {
+	f := newFakeRegistry(t)
+	r, err := parseRegistryTarget("oci://registry.test/schemas/bundles", f.srv.URL, f.user, f.pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, blobs := testArtifact(t, "3.5.0")
+	if got := len(m.Layers); got != 2 {
+		t.Fatalf("layers = %d, want 2 without the precompressed file", got)
+	}
+	changed, err := r.pushArtifact("3.5.0", m, blobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("first push reported no change")
+	}
+	if len(f.uploads) != 3 {
+		t.Errorf("uploaded %d blobs, want the config and two layers", len(f.uploads))
+	}
+	if want := []string{"repository:schemas/bundles:pull,push"}; len(f.scopes) != 1 || f.scopes[0] != want[0] {
+		t.Errorf("token scopes = %q, want %q", f.scopes, want)
+	}
+
+	// Pushing the same artifact again uploads nothing.
+	f.uploads, f.puts = nil, nil
+	changed, err = r.pushArtifact("3.5.0", m, blobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || len(f.uploads) != 0 || len(f.puts) != 0 {
+		t.Errorf("second push changed=%v uploaded %q and put %q", changed, f.uploads, f.puts)
+	}
+
+	// A new version uploads only the blobs the registry does not have.
+	m2, blobs2 := testArtifact(t, "3.6.0")
+	if _, err := r.pushArtifact("3.6.0", m2, blobs2); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.uploads) != 1 {
+		t.Errorf("uploaded %d blobs, want only the changed layer", len(f.uploads))
+	}
+
+	if err := r.tag("3.6.0", "latest"); err != nil {
+		t.Fatal(err)
+	}
+	if string(f.manifests["latest"]) != string(f.manifests["3.6.0"]) {
+		t.Error("latest does not refer to the 3.6.0 manifest")
+	}
+	// The token was reused for every request after the first challenge.
+	if len(f.scopes) != 1 {
+		t.Errorf("requested %d tokens, want 1", len(f.scopes))
+	}
+}
+
+func TestRegistryTokenRenewal(t *testing.T) {
+	f := newFakeRegistry(t)
+	r, err := parseRegistryTarget("oci://registry.test/schemas/bundles", f.srv.URL, f.user, f.pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, blobs := testArtifact(t, "3.5.0")
+	if _, err := r.pushArtifact("3.5.0", m, blobs); err != nil {
+		t.Fatal(err)
+	}
+
+	// An expired token is answered with a new challenge.
+	f.token = "token-2"
+	if err := r.tag("3.5.0", "latest"); err != nil {
+		t.Fatal(err)
+	}
+	if r.auth != "Bearer token-2" {
+		t.Errorf("auth = %q, want the renewed token", r.auth)
+	}
+}
+
+func TestRegistryBasicAuth(t *testing.T) {
+	f := newFakeRegistry(t)
+	f.basic = true
+	r, err := parseRegistryTarget("oci://registry.test/schemas/bundles", f.srv.URL, f.user, f.pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, blobs := testArtifact(t, "3.5.0")
+	if _, err := r.pushArtifact("3.5.0", m, blobs); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.puts) != 1 {
+		t.Errorf("put %d manifests, want 1", len(f.puts))
+	}
+}
+
+func TestRegistryErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   string
+		pass   string
+		basic  bool
+		failOn string
+		want   string
+	}{
+		{name: "token denied", user: "user", pass: "wrong", want: "failed to get registry token: status 401"},
+		{name: "basic without credentials", basic: true, want: "registry requires credentials"},
+		{name: "wrong basic credentials", user: "user", pass: "wrong", basic: true, want: "status 401"},
+		{name: "upload denied", user: "user", pass: "pass", failOn: "POST blobs/uploads/", want: "failed with status 403: {\"errors\":[{\"code\":\"DENIED\"}]}"},
+		{name: "manifest denied", user: "user", pass: "pass", failOn: "PUT manifests/3.5.0", want: "PUT /v2/schemas/bundles/manifests/3.5.0 failed with status 403"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newFakeRegistry(t)
+			f.basic = tc.basic
+			f.failOn = tc.failOn
+			r, err := parseRegistryTarget("oci://registry.test/schemas/bundles", f.srv.URL, tc.user, tc.pass)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, blobs := testArtifact(t, "3.5.0")
+			_, err = r.pushArtifact("3.5.0", m, blobs)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error %q does not contain %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	tests := []struct {
+		challenge string
+		scheme    string
+		params    map[string]string
+	}{
+		{
+			challenge: `Bearer realm="https://ghcr.io/token",service="ghcr.io",scope="repository:a/b:pull,push"`,
+			scheme:    "Bearer",
+			params:    map[string]string{"realm": "https://ghcr.io/token", "service": "ghcr.io", "scope": "repository:a/b:pull,push"},
+		},
+		{
+			challenge: `Basic realm="Registry Realm"`,
+			scheme:    "Basic",
+			params:    map[string]string{"realm": "Registry Realm"},
+		},
+		{
+			challenge: `Bearer realm=https://auth.example.com/token, Service=registry`,
+			scheme:    "Bearer",
+			params:    map[string]string{"realm": "https://auth.example.com/token", "service": "registry"},
+		},
+		{
+			challenge: `Bearer realm="unterminated`,
+			scheme:    "Bearer",
+			params:    map[string]string{"realm": "unterminated"},
+		},
+		{
+			challenge: `Bearer`,
+			scheme:    "Bearer",
+			params:    map[string]string{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.challenge, func(t *testing.T) {
+			scheme, params := parseChallenge(tc.challenge)
+			if scheme != tc.scheme {
+				t.Errorf("scheme = %q, want %q", scheme, tc.scheme)
+			}
+			if !maps.Equal(params, tc.params) {
+				t.Errorf("params = %q, want %q", params, tc.params)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// syncBucket uploads the files beneath paths in dir that are missing from the
+// bucket or whose content differs, and optionally deletes objects beneath the
+// prefix that no longer exist locally.
+func syncBucket(b *bucket, dir string, paths []string, parallel int, deleteStale bool) error {
+	start := time.Now()
+	etags, err := b.listETags()
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	for _, p := range paths {
+		err := filepath.WalkDir(filepath.Join(dir, p), func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		errs     []error
+		uploaded int
+		sem      = make(chan struct{}, parallel)
+	)
+	local := make(map[string]struct{}, len(files))
+	for _, rel := range files {
+		key := b.key(rel)
+		local[key] = struct{}{}
+
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			// Wait for the running uploads before returning.
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+			break
+		}
+		// Objects uploaded in a single request have the MD5 digest as ETag.
+		if etags[key] == md5Hex(data) {
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			err := b.put(key, data, rel)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to upload %s: %w", key, err))
+				return
+			}
+			uploaded++
+			slog.Debug("Uploaded object.", "key", key)
+		})
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	var deleted int
+	if deleteStale {
+		for key := range etags {
+			if _, ok := local[key]; ok {
+				continue
+			}
+			if err := b.delete(key); err != nil {
+				return fmt.Errorf("failed to delete %s: %w", key, err)
+			}
+			deleted++
+			slog.Debug("Deleted object.", "key", key)
+		}
+	}
+
+	slog.Info("Synced objects.", "endpoint", b.endpoint.String(), "prefix", b.prefix,
+		"files", len(files), "uploaded", uploaded, "deleted", deleted, "duration", time.Since(start))
+	return nil
+}
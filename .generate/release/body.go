@@ -0,0 +1,107 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/jsondiff"
+)
+
+// changeReport is the subset of the changes.json written by the changes tool
+// that is summarized.
+type changeReport struct {
+	Previous string `json:"previous"`
+	Files    []struct {
+		Path    string            `json:"path"`
+		Op      jsondiff.Op       `json:"op"`
+		Changes []json.RawMessage `json:"changes"`
+	} `json:"files"`
+}
+
+// pullRequestBody describes the new versions, listing the schema files that
+// changed relative to the previous version of each.
+func pullRequestBody(dir, gitURL string, tags []string) (string, error) {
+	sb := new(strings.Builder)
+	fmt.Fprintf(sb, "Generate schemas for %s of %s.\n", strings.Join(tags, ", "), repoLink(gitURL))
+
+	for _, tag := range tags {
+		ver := strings.TrimPrefix(tag, "v")
+		fmt.Fprintf(sb, "\n## %s\n\n", ver)
+		if u := releaseURL(gitURL, tag); u != "" {
+			fmt.Fprintf(sb, "Release notes: %s\n\n", u)
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, ver, "changes.json"))
+		if errors.Is(err, fs.ErrNotExist) {
+			sb.WriteString("No changes.json was generated for this version.\n")
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		var report changeReport
+		if err := json.Unmarshal(b, &report); err != nil {
+			return "", fmt.Errorf("failed to decode changes of %s: %w", ver, err)
+		}
+		writeChanges(sb, report)
+	}
+	return sb.String(), nil
+}
+
+// writeChanges writes a table of the changed schema files.
+func writeChanges(sb *strings.Builder, report changeReport) {
+	if report.Previous == "" {
+		sb.WriteString("This is the first version.\n")
+		return
+	}
+	if len(report.Files) == 0 {
+		fmt.Fprintf(sb, "No schema changes since %s.\n", report.Previous)
+		return
+	}
+
+	counts := map[jsondiff.Op]int{}
+	for _, f := range report.Files {
+		counts[f.Op]++
+	}
+	fmt.Fprintf(sb, "Compared with %s: %d added, %d removed, and %d changed schema files.\n\n",
+		report.Previous, counts[jsondiff.Added], counts[jsondiff.Removed], counts[jsondiff.Changed])
+	sb.WriteString("| schema | change |\n|---|---|\n")
+	for _, f := range report.Files {
+		change := string(f.Op)
+		if f.Op == jsondiff.Changed {
+			change = fmt.Sprintf("changed (%d pointers)", len(f.Changes))
+		}
+		fmt.Fprintf(sb, "| `%s` | %s |\n", f.Path, change)
+	}
+}
+
+// repoLink returns a markdown link to the repository of a GitHub git URL, or
+// the URL itself for other hosts.
+func repoLink(gitURL string) string {
+	u, err := url.Parse(gitURL)
+	if err != nil || u.Host != "github.com" {
+		return gitURL
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(u.Path, "/"), ".git")
+	return fmt.Sprintf("[%s](https://github.com/%s)", name, name)
+}
+
+// releaseURL returns the URL of the GitHub release of tag, or an empty string
+// if the git URL is not hosted on GitHub.
+func releaseURL(gitURL, tag string) string {
+	u, err := url.Parse(gitURL)
+	if err != nil || u.Host != "github.com" {
+		return ""
+	}
+	return "https://github.com/" + strings.TrimSuffix(strings.TrimPrefix(u.Path, "/"), ".git") + "/releases/tag/" + tag
+}
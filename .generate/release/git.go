@@ -0,0 +1,121 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// releasePaths are the glob patterns, relative to the output directory, of
+// the generated content that is committed.
+var releasePaths = []string{
+	"[1-9].[0-9]*.[0-9]*",
+	"[1-9]",
+	"latest",
+	"versions.json",
+	"catalog.json",
+	"manifest.jsonschema.json",
+}
+
+// newReleaseTags lists the release tags of the remote repository, which are
+// semantic versions prefixed with v and without a prerelease, whose version
+// directory does not exist in dir. The tags are sorted by version.
+func newReleaseTags(url, dir string) ([]string, error) {
+	r := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: "origin", URLs: []string{url}})
+	refs, err := r.List(&git.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %s: %w", url, err)
+	}
+
+	var versions []*semver.Version
+	for _, ref := range refs {
+		if !ref.Name().IsTag() {
+			continue
+		}
+		tag, ok := strings.CutPrefix(ref.Name().Short(), "v")
+		if !ok {
+			continue
+		}
+		ver, err := semver.NewVersion(tag)
+		if err != nil || ver.PreRelease != "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, ver.String(), "jsonschema")); err == nil {
+			continue
+		}
+		versions = append(versions, ver)
+	}
+	semver.Sort(versions)
+
+	tags := make([]string, 0, len(versions))
+	for _, v := range versions {
+		tags = append(tags, "v"+v.String())
+	}
+	return tags, nil
+}
+
+// commitAndPush commits the generated content in dir on branch, which is
+// created from the current HEAD, and force pushes it to remote.
+func commitAndPush(dir, remote, branch, message string) error {
+	if _, err := gitExec(dir, "checkout", "-B", branch); err != nil {
+		return err
+	}
+	// git fails on pathspecs that match nothing, so only the existing paths
+	// are added.
+	args := []string{"add", "-A", "--"}
+	for _, pattern := range releasePaths {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return err
+		}
+		for _, m := range matches {
+			rel, err := filepath.Rel(dir, m)
+			if err != nil {
+				return err
+			}
+			args = append(args, rel)
+		}
+	}
+	if _, err := gitExec(dir, args...); err != nil {
+		return err
+	}
+	if _, err := gitExec(dir, "diff", "--cached", "--quiet"); err == nil {
+		return errors.New("generation produced no changes to commit")
+	}
+	if _, err := gitExec(dir, "commit", "-m", message); err != nil {
+		return err
+	}
+	if _, err := gitExec(dir, "push", "--force", "-u", remote, branch); err != nil {
+		return err
+	}
+	slog.Info("Pushed branch.", "remote", remote, "branch", branch)
+	return nil
+}
+
+func gitExec(dir string, args ...string) (stdout []byte, err error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	outBuf := new(bytes.Buffer)
+	cmd.Stdout = outBuf
+	errBuf := new(bytes.Buffer)
+	cmd.Stderr = errBuf
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed running git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(errBuf.String()))
+	}
+	return outBuf.Bytes(), nil
+}
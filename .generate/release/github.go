@@ -0,0 +1,112 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// githubClient is a minimal client of the GitHub REST API.
+type githubClient struct {
+	api   string
+	token string
+}
+
+type pullRequest struct {
+	Title string `json:"title"`
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+	Body  string `json:"body"`
+}
+
+type pullRequestResponse struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+}
+
+// createPullRequest opens a pull request in repo. If a pull request for the
+// head branch is already open then it is returned instead, because pushing
+// the branch has already updated it.
+func (c *githubClient) createPullRequest(repo string, pr pullRequest) (*pullRequestResponse, error) {
+	var resp pullRequestResponse
+	err := c.do(http.MethodPost, "/repos/"+repo+"/pulls", pr, &resp)
+	if err == nil {
+		return &resp, nil
+	}
+	if !strings.Contains(err.Error(), "A pull request already exists") {
+		return nil, err
+	}
+
+	var open []pullRequestResponse
+	owner, _, _ := strings.Cut(repo, "/")
+	if err := c.do(http.MethodGet, "/repos/"+repo+"/pulls?state=open&head="+owner+":"+pr.Head, nil, &open); err != nil {
+		return nil, err
+	}
+	if len(open) == 0 {
+		return nil, fmt.Errorf("no open pull request found for %s", pr.Head)
+	}
+	return &open[0], nil
+}
+
+// addLabels adds labels to the pull request or issue number in repo.
+func (c *githubClient) addLabels(repo string, number int, labels []string) error {
+	body := map[string][]string{"labels": labels}
+	return c.do(http.MethodPost, "/repos/"+repo+"/issues/"+strconv.Itoa(number)+"/labels", body, nil)
+}
+
+// do sends a request with a JSON body, if not nil, and decodes the JSON
+// response into out, if not nil.
+func (c *githubClient) do(method, path string, in, out any) (err error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.api, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+	}()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s failed with status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
@@ -0,0 +1,127 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// release automates the maintenance loop of this repository. It lists the
+// release tags of package-spec, generates each tag whose version directory
+// does not exist yet, commits the generated output on a branch, pushes it, and
+// opens a pull request whose description summarizes what changed in each new
+// version.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+var (
+	outDir      string // Directory containing the versioned directories.
+	gitURL      string // package-spec git URL whose tags are released.
+	command     string // Command that generates a tag.
+	repo        string // GitHub repository in which the pull request is opened.
+	base        string // Branch that the pull request targets.
+	remote      string // Git remote to which the branch is pushed.
+	labels      string // Comma separated labels added to the pull request.
+	githubToken string // Token used to authenticate to the GitHub API.
+	githubAPI   string // GitHub API URL.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories, must be within the git repository")
+	flag.StringVar(&gitURL, "git-url", "https://github.com/elastic/package-spec.git", "package-spec git URL whose release tags are generated")
+	flag.StringVar(&command, "command", "just generate", "command that generates a release tag, the tag is appended as the last argument")
+	flag.StringVar(&repo, "repo", "andrewkroh/package-spec-schema", "GitHub repository in which to open the pull request")
+	flag.StringVar(&base, "base", "main", "branch that the pull request targets")
+	flag.StringVar(&remote, "remote", "origin", "git remote to which the branch is pushed")
+	flag.StringVar(&labels, "labels", "automation", "comma separated labels to add to the pull request")
+	flag.StringVar(&githubToken, "github-token", "", "GitHub token used to open the pull request, defaults to $GITHUB_TOKEN")
+	flag.StringVar(&githubAPI, "github-api", "https://api.github.com", "GitHub API URL")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+func run() error {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return errors.New("-command must not be empty")
+	}
+	if githubToken == "" {
+		githubToken = os.Getenv("GITHUB_TOKEN")
+	}
+	if githubToken == "" {
+		return errors.New("a GitHub token is required, set -github-token or GITHUB_TOKEN")
+	}
+
+	tags, err := newReleaseTags(gitURL, outDir)
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		slog.Info("No new release tags.")
+		return nil
+	}
+	slog.Info("Found new release tags.", "tags", tags)
+
+	for _, tag := range tags {
+		if err := generate(args, tag); err != nil {
+			return err
+		}
+	}
+
+	newest := tags[len(tags)-1]
+	branch := "package-spec-" + newest
+	title := "Add package-spec " + strings.Join(tags, ", ")
+	if err := commitAndPush(outDir, remote, branch, title); err != nil {
+		return err
+	}
+
+	body, err := pullRequestBody(outDir, gitURL, tags)
+	if err != nil {
+		return err
+	}
+	gh := &githubClient{api: githubAPI, token: githubToken}
+	pr, err := gh.createPullRequest(repo, pullRequest{Title: title, Head: branch, Base: base, Body: body})
+	if err != nil {
+		return err
+	}
+	if labels != "" {
+		if err := gh.addLabels(repo, pr.Number, strings.Split(labels, ",")); err != nil {
+			return err
+		}
+	}
+	slog.Info("Opened pull request.", "url", pr.HTMLURL)
+	return nil
+}
+
+// generate runs the generation command for tag, streaming its output.
+func generate(args []string, tag string) error {
+	slog.Info("Generating tag.", "tag", tag)
+	cmd := exec.Command(args[0], append(args[1:len(args):len(args)], tag)...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed generating %s: %w", tag, err)
+	}
+	return nil
+}
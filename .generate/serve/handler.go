@@ -0,0 +1,167 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// newHandler returns a handler serving the files of root. Requests beneath
+// the path of the $id base URI are mapped to the jsonschema
+// directories so that a schema with the $id <base>/<version>/<file> is served
+// from <version>/jsonschema/<file>. Versions of named remotes may span
+// multiple path segments (e.g. <name>/<version>). Hidden files and
+// directories, such as .git when serving the repository, are not served.
+//
+// Files are served with an ETag and Last-Modified header, and conditional
+// requests are answered with 304 Not Modified. Cache-Control permits caching
+// for maxAge, or requires revalidation when maxAge is zero.
+//
+// If rewriteIDs is set, $id and $ref values in JSON files that begin with the
+// base URI are rewritten to the origin of the request so that tools resolving
+// strictly by $id fetch the local schemas instead of the published ones.
+func newHandler(root fs.FS, base *url.URL, maxAge time.Duration, rewriteIDs bool) http.Handler {
+	files := http.FileServerFS(root)
+	prefix := strings.TrimSuffix(base.Path, "/") + "/"
+	etags := &etagCache{root: root, entries: map[string]etagEntry{}}
+	cacheControl := "no-cache"
+	if maxAge > 0 {
+		cacheControl = "public, max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		if hidden(r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+
+		name, isSchema := "", false
+		if rest, ok := strings.CutPrefix(r.URL.Path, prefix); ok {
+			name, isSchema = schemaFile(root, rest)
+		}
+		if !isSchema {
+			name = strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		}
+
+		// Directories, redirects, and missing files are left to the file
+		// server.
+		info, err := fs.Stat(root, name)
+		if err != nil || !info.Mode().IsRegular() || strings.HasSuffix(r.URL.Path, "/index.html") {
+			files.ServeHTTP(w, r)
+			return
+		}
+
+		etag, err := etags.get(name, info)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		h := w.Header()
+		h.Set("ETag", etag)
+		h.Set("Cache-Control", cacheControl)
+		if isSchema {
+			h.Set("Content-Type", "application/schema+json")
+		}
+		if rewriteIDs && path.Ext(name) == ".json" {
+			serveRewritten(w, r, root, name, info.ModTime(), base)
+			return
+		}
+		http.ServeFileFS(w, r, root, name)
+	})
+}
+
+// etagCache computes ETags from the content of files and caches them until
+// the modification time or size of a file changes. It is safe for concurrent
+// use.
+type etagCache struct {
+	root    fs.FS
+	mu      sync.Mutex
+	entries map[string]etagEntry // Keyed by file name.
+}
+
+type etagEntry struct {
+	modTime time.Time
+	size    int64
+	etag    string
+}
+
+// get returns the ETag of the named file. The ETag is weak because a gzip
+// compressed response shares it with the uncompressed representation.
+func (c *etagCache) get(name string, info fs.FileInfo) (string, error) {
+	c.mu.Lock()
+	e, ok := c.entries[name]
+	c.mu.Unlock()
+	if ok && e.modTime.Equal(info.ModTime()) && e.size == info.Size() {
+		return e.etag, nil
+	}
+
+	f, err := c.root.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	etag := `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+
+	c.mu.Lock()
+	c.entries[name] = etagEntry{modTime: info.ModTime(), size: info.Size(), etag: etag}
+	c.mu.Unlock()
+	return etag, nil
+}
+
+// schemaFile returns the file that holds the schema at the $id path rest,
+// which is relative to the base URI. A version directory may contain the
+// schemas of other spec majors beneath spec-vN/jsonschema, so the search
+// continues past a jsonschema directory lacking the file. Schemas that are not
+// beneath a version, like the root manifest.jsonschema.json, are served from
+// their path relative to the output directory.
+func schemaFile(root fs.FS, rest string) (string, bool) {
+	segments := strings.Split(path.Clean(rest), "/")
+	for i := 1; i < len(segments); i++ {
+		dir := path.Join(append(segments[:i:i], "jsonschema")...)
+		info, err := fs.Stat(root, dir)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		name := path.Join(dir, path.Join(segments[i:]...))
+		if info, err := fs.Stat(root, name); err == nil && !info.IsDir() {
+			return name, true
+		}
+	}
+	name := path.Clean(rest)
+	if info, err := fs.Stat(root, name); err == nil && info.Mode().IsRegular() && strings.HasSuffix(name, ".jsonschema.json") {
+		return name, true
+	}
+	return "", false
+}
+
+// hidden reports whether any segment of the URL path starts with a dot.
+func hidden(urlPath string) bool {
+	for seg := range strings.SplitSeq(urlPath, "/") {
+		if strings.HasPrefix(seg, ".") {
+			return true
+		}
+	}
+	return false
+}
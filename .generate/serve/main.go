@@ -0,0 +1,139 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// serve runs an HTTP server for the generated tree. Schemas are served at the
+// paths of their $id so that editors and validators can resolve references
+// against it without configuring a web server. All other files, such as
+// bundles and versions.json, are served at their path in the output directory.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+var (
+	outDir  string        // Directory containing the versioned directories.
+	addr    string        // Address on which to listen.
+	baseURI string        // Base URI of the schema $ids.
+	maxAge  time.Duration // Duration for which clients may cache responses.
+	gzipOn  bool          // Compress responses.
+	corsOn  bool          // Allow cross-origin requests.
+	rewrite bool          // Rewrite $ids to the origin of the server.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	flag.StringVar(&addr, "addr", "localhost:8080", "address on which to listen")
+	flag.StringVar(&baseURI, "base-uri", "https://schemas.elastic.dev/package-spec", "base URI of the schema $ids, its path is the URL path prefix of schemas")
+	flag.DurationVar(&maxAge, "max-age", 0, "duration for which clients may cache responses without revalidating, 0 requires revalidation with ETag or Last-Modified")
+	flag.BoolVar(&gzipOn, "gzip", true, "gzip compress responses for clients that accept it")
+	flag.BoolVar(&corsOn, "cors", true, "send CORS headers allowing requests from any origin")
+	flag.BoolVar(&rewrite, "rewrite-ids", false, "rewrite $id and $ref values that begin with the base URI to the origin of the request")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+func run() error {
+	u, err := url.Parse(baseURI)
+	if err != nil {
+		return fmt.Errorf("failed to parse base-uri: %w", err)
+	}
+
+	root, err := os.OpenRoot(outDir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	handler := newHandler(root.FS(), u, maxAge, rewrite)
+	if gzipOn {
+		handler = compress(handler)
+	}
+	if corsOn {
+		handler = cors(handler)
+	}
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           logRequests(handler),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- srv.ListenAndServe()
+	}()
+	slog.Info("Serving schemas.", "addr", addr, "dir", outDir, "schema_path", u.Path)
+
+	select {
+	case err := <-errC:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errC; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("Server stopped.")
+	return nil
+}
+
+// logRequests logs each request at debug level.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		slog.Debug("Handled request.", "method", r.Method, "path", r.URL.Path, "status", rec.status, "duration", time.Since(start))
+	})
+}
+
+// statusRecorder records the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
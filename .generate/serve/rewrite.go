@@ -0,0 +1,84 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// serveRewritten serves the named JSON file with the $id and $ref values that
+// begin with base rewritten to the origin of the request.
+func serveRewritten(w http.ResponseWriter, r *http.Request, root fs.FS, name string, modTime time.Time, base *url.URL) {
+	data, err := fs.ReadFile(root, name)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	local := &url.URL{Scheme: scheme, Host: r.Host, Path: base.Path}
+	out, err := rewriteIDs(data, strings.TrimSuffix(base.String(), "/"), strings.TrimSuffix(local.String(), "/"))
+	if err != nil {
+		// Serve files that are not valid JSON unchanged.
+		slog.Debug("Not rewriting file.", "path", name, "error", err)
+		out = data
+	}
+	http.ServeContent(w, r, name, modTime, bytes.NewReader(out))
+}
+
+// rewriteIDs replaces the prefix from with to in the $id and $ref values of
+// the JSON document. The document is returned unchanged if it does not
+// mention from.
+func rewriteIDs(data []byte, from, to string) ([]byte, error) {
+	if !bytes.Contains(data, []byte(from)) {
+		return data, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	rewriteNode(doc, from, to)
+
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func rewriteNode(node any, from, to string) {
+	switch node := node.(type) {
+	case map[string]any:
+		for k, v := range node {
+			if s, ok := v.(string); ok && (k == "$id" || k == "$ref") {
+				if s == from || strings.HasPrefix(s, from+"/") || strings.HasPrefix(s, from+"#") {
+					node[k] = to + strings.TrimPrefix(s, from)
+				}
+				continue
+			}
+			rewriteNode(v, from, to)
+		}
+	case []any:
+		for _, v := range node {
+			rewriteNode(v, from, to)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// structure checks the file structure of Elastic packages against the
+// layout.json of the package-spec version named by each package's
+// format_version. It reports missing required files, files that are not
+// allowed or forbidden, and files and folders that exceed their size or count
+// limits. It complements the validation of file contents against the schemas.
+// Like annotate, it walks the given directories, which may be single packages
+// or a repository of packages.
+//
+//	go run ./structure [flags] [dir ...]
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+	"gopkg.in/yaml.v3"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/layout"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+var (
+	outDir     string // Directory containing the versioned directories.
+	jsonOutput bool   // Write the problems as JSON.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	flag.BoolVar(&jsonOutput, "json", false, "write the problems of each package as JSON")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [dir ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+// result is the outcome of checking a package.
+type result struct {
+	Package       string           `json:"package"`
+	FormatVersion string           `json:"format_version"`
+	Type          string           `json:"type"`
+	Problems      []layout.Problem `json:"problems"`
+}
+
+func run() error {
+	dirs := flag.Args()
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	layouts := map[string]*layout.Layout{}
+	results := []result{}
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return err
+			}
+			if strings.HasPrefix(d.Name(), ".") && p != dir {
+				return fs.SkipDir
+			}
+
+			m, err := readManifest(filepath.Join(p, "manifest.yml"))
+			if err != nil {
+				return err
+			}
+			if m == nil {
+				return nil
+			}
+			r, err := checkPackage(p, m, layouts)
+			if err != nil {
+				return fmt.Errorf("failed checking package %s: %w", p, err)
+			}
+			results = append(results, r)
+			// Packages do not contain other packages.
+			return fs.SkipDir
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var failed int
+	for _, r := range results {
+		if len(r.Problems) > 0 {
+			failed++
+		}
+		if jsonOutput {
+			continue
+		}
+		for _, p := range r.Problems {
+			fmt.Printf("%s: %s\n", filepath.Join(r.Package, filepath.FromSlash(p.Path)), p.Message)
+		}
+	}
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d packages have structure problems", failed, len(results))
+	}
+	slog.Info("Checked package structure.", "packages", len(results))
+	return nil
+}
+
+// manifest holds the fields of a package manifest that select its layout.
+type manifest struct {
+	FormatVersion string `yaml:"format_version"`
+	Type          string `yaml:"type"`
+}
+
+// readManifest reads a package manifest. It returns nil if the file does not
+// exist or is not a package manifest, such as the manifest of a data stream.
+func readManifest(name string) (*manifest, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var m manifest
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	if m.FormatVersion == "" {
+		return nil, nil
+	}
+	if m.Type == "" {
+		m.Type = "integration"
+	}
+	return &m, nil
+}
+
+// checkPackage checks the package in dir against the layout of its
+// format_version. Layouts are cached by version.
+func checkPackage(dir string, m *manifest, layouts map[string]*layout.Layout) (result, error) {
+	v, err := semver.NewVersion(m.FormatVersion)
+	if err != nil {
+		return result{}, fmt.Errorf("invalid format_version: %w", err)
+	}
+	// Prerelease format versions use the layout of their release.
+	v.PreRelease = ""
+	ver := v.String()
+
+	l, found := layouts[ver]
+	if !found {
+		if l, err = layout.Read(filepath.Join(outDir, ver, "jsonschema", layout.FileName)); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return result{}, fmt.Errorf("no layout for format_version %s in %s", ver, outDir)
+			}
+			return result{}, err
+		}
+		layouts[ver] = l
+	}
+
+	problems, err := l.Check(os.DirFS(dir), m.Type)
+	if err != nil {
+		return result{}, err
+	}
+	if problems == nil {
+		problems = []layout.Problem{}
+	}
+	slog.Debug("Checked package.", "package", dir, "format_version", m.FormatVersion, "problems", len(problems))
+	return result{
+		Package:       path.Clean(filepath.ToSlash(dir)),
+		FormatVersion: m.FormatVersion,
+		Type:          m.Type,
+		Problems:      problems,
+	}, nil
+}
@@ -0,0 +1,123 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// junitTestSuites is the root element of a JUnit XML report.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     float64          `xml:"time,attr"` // Seconds.
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite holds the test cases of a package.
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     float64         `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is the validation of a file.
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"` // The package could not be validated.
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnitFile writes a JUnit XML report of the results to name.
+func writeJUnitFile(name string, results []result) (err error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+	}()
+	return writeJUnit(f, results)
+}
+
+// writeJUnit writes a JUnit XML report of the results to w. Each package is
+// a test suite with a test case for each validated file. A file with
+// problems is a failed test case listing the problems.
+func writeJUnit(w io.Writer, results []result) error {
+	report := junitTestSuites{Name: "package-spec validation"}
+	for _, r := range results {
+		suite := junitTestSuite{
+			Name: r.Package,
+			Time: r.Duration,
+		}
+		if r.Status == statusError {
+			suite.Cases = append(suite.Cases, junitTestCase{
+				Name:      "manifest.yml",
+				ClassName: r.Package,
+				Error:     &junitFailure{Message: r.Error, Type: "error"},
+			})
+			suite.Errors++
+		}
+		for _, p := range r.paths {
+			tc := junitTestCase{Name: p, ClassName: r.Package}
+			var lines []string
+			for _, problem := range r.Problems {
+				if problem.Path == p {
+					lines = append(lines, fmt.Sprintf("%s:%d:%d: %s", p, problem.Line, problem.Column, pointerMessage(problem)))
+				}
+			}
+			if len(lines) > 0 {
+				message := fmt.Sprintf("%d problems", len(lines))
+				if len(lines) == 1 {
+					message = "1 problem"
+				}
+				tc.Failure = &junitFailure{
+					Message: message,
+					Type:    "schema",
+					Text:    strings.Join(lines, "\n"),
+				}
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suite.Tests = len(suite.Cases)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Time += suite.Time
+		report.Suites = append(report.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
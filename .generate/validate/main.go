@@ -0,0 +1,409 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// validate validates the files of Elastic packages against the schemas of
+// the package-spec version named by each package's format_version. Like
+// structure, it walks the given directories, which may be single packages or
+// a repository of packages. Each value that does not validate is reported
+// with its file, line, column, and JSON pointer.
+//
+//	go run ./validate [flags] [dir ...]
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+	"github.com/andrewkroh/package-spec-schema/internal/specversion"
+	"github.com/andrewkroh/package-spec-schema/internal/validator"
+)
+
+// Output formats.
+const (
+	outputText   = "text"
+	outputJSON   = "json"
+	outputGitHub = "github" // GitHub Actions workflow commands.
+)
+
+var (
+	outDir     string // Directory containing the versioned directories.
+	baseURL    string // Base of the $id of the schemas.
+	output     string // Output format.
+	junitFile  string // File to write a JUnit XML report to.
+	reportFile string // File to write the aggregated JSON report to.
+	jobs       int    // Number of packages validated concurrently.
+	staged     bool   // Validate the files staged in git.
+
+	registryURL      string       // Base URL of the Elastic Package Registry.
+	registryPackages packagesFlag // Packages to download from the registry.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing versioned directories")
+	flag.StringVar(&baseURL, "base-url", validator.DefaultBaseURL, "base URL of the $id of the schemas")
+	flag.StringVar(&output, "output", outputText, "output format: text, json, or github for GitHub Actions annotations")
+	flag.StringVar(&junitFile, "junit", "", "write a JUnit XML report with a test case for each validated file to this file")
+	flag.StringVar(&reportFile, "report", "", "write a JSON report with the status, problems, and timing of each package and totals to this file")
+	flag.IntVar(&jobs, "jobs", runtime.NumCPU(), "number of packages to validate concurrently")
+	flag.BoolVar(&staged, "staged", false, "validate only the files staged in the git repository of the working directory, for use as a pre-commit hook")
+	flag.StringVar(&registryURL, "registry-url", "https://epr.elastic.co", "base URL of the Elastic Package Registry")
+	flag.Var(&registryPackages, "package", "download and validate a package from the registry given as name@version, or name for the latest version (repeatable)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [dir ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+// Status of a package.
+const (
+	statusPass  = "pass"
+	statusFail  = "fail"  // Files of the package do not validate.
+	statusError = "error" // The package could not be validated.
+)
+
+// result is the outcome of validating a package.
+type result struct {
+	Package       string              `json:"package"`
+	FormatVersion string              `json:"format_version"`
+	Status        string              `json:"status"`
+	Error         string              `json:"error,omitempty"`
+	Files         int                 `json:"files"` // Number of files validated.
+	Problems      []validator.Problem `json:"problems"`
+	Duration      float64             `json:"duration_seconds"`
+
+	paths []string // Paths of the files validated.
+}
+
+// report is the aggregated outcome of a run written to -report.
+type report struct {
+	Packages int      `json:"packages"`
+	Passed   int      `json:"passed"`
+	Failed   int      `json:"failed"`
+	Errors   int      `json:"errors"`
+	Files    int      `json:"files"`
+	Problems int      `json:"problems"`
+	Duration float64  `json:"duration_seconds"`
+	Results  []result `json:"results"`
+}
+
+// packageDir is a package found beneath the given directories or downloaded
+// from the registry.
+type packageDir struct {
+	dir      string // Directory of the package, or name-version of a download.
+	fsys     fs.FS  // Package root.
+	manifest *manifest
+	files    []string // Files to validate relative to the root, or nil for all.
+	err      error    // Error reading or downloading the package.
+}
+
+func run() error {
+	switch output {
+	case outputText, outputJSON, outputGitHub:
+	default:
+		return fmt.Errorf("unknown -output %q", output)
+	}
+	if jobs < 1 {
+		return errors.New("-jobs must be at least 1")
+	}
+
+	if staged && (flag.NArg() > 0 || len(registryPackages) > 0) {
+		return errors.New("-staged cannot be combined with directories or -package")
+	}
+	dirs := flag.Args()
+	if len(dirs) == 0 && len(registryPackages) == 0 {
+		dirs = []string{"."}
+	}
+
+	start := time.Now()
+	var pkgs []packageDir
+	var err error
+	if staged {
+		pkgs, err = stagedPackages()
+	} else {
+		pkgs, err = findPackages(dirs)
+	}
+	if err != nil {
+		return err
+	}
+	if len(registryPackages) > 0 {
+		r := &registry{url: strings.TrimSuffix(registryURL, "/"), client: &http.Client{Timeout: 5 * time.Minute}}
+		pkgs = append(pkgs, r.fetchPackages(registryPackages)...)
+	}
+	results := validatePackages(pkgs)
+
+	rep := report{Packages: len(results), Results: results, Duration: time.Since(start).Seconds()}
+	for _, r := range results {
+		switch r.Status {
+		case statusPass:
+			rep.Passed++
+		case statusFail:
+			rep.Failed++
+		case statusError:
+			rep.Errors++
+		}
+		rep.Files += r.Files
+		rep.Problems += len(r.Problems)
+	}
+
+	if err := writeResults(os.Stdout, results); err != nil {
+		return err
+	}
+	if junitFile != "" {
+		if err := writeJUnitFile(junitFile, results); err != nil {
+			return fmt.Errorf("failed to write JUnit report: %w", err)
+		}
+	}
+	if reportFile != "" {
+		if err := writeReport(reportFile, rep); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+
+	if failed := rep.Failed + rep.Errors; failed > 0 {
+		return fmt.Errorf("%d of %d packages failed validation", failed, len(results))
+	}
+	slog.Info("Validated packages.", "packages", len(results), "files", rep.Files, "duration", time.Since(start))
+	return nil
+}
+
+// findPackages returns the packages beneath dirs, which may be single
+// packages or a repository of packages such as elastic/integrations.
+func findPackages(dirs []string) ([]packageDir, error) {
+	var pkgs []packageDir
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return err
+			}
+			if strings.HasPrefix(d.Name(), ".") && p != dir {
+				return fs.SkipDir
+			}
+
+			fsys := os.DirFS(p)
+			m, err := readManifest(fsys)
+			if err == nil && m == nil {
+				return nil
+			}
+			// A manifest that cannot be read is reported as a package error.
+			pkgs = append(pkgs, packageDir{dir: p, fsys: fsys, manifest: m, err: err})
+			// Packages do not contain other packages.
+			return fs.SkipDir
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pkgs, nil
+}
+
+// validatePackages validates the packages using a pool of -jobs workers. The
+// results are in the order of pkgs.
+func validatePackages(pkgs []packageDir) []result {
+	results := make([]result, len(pkgs))
+	var wg sync.WaitGroup
+	work := make(chan int)
+	// The workers share a validator so that each schema is compiled once.
+	v := validator.New(os.DirFS(outDir), baseURL)
+	for range jobs {
+		wg.Go(func() {
+			for i := range work {
+				results[i] = validatePackage(v, pkgs[i])
+			}
+		})
+	}
+	for i := range pkgs {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	return results
+}
+
+// writeResults writes the problems of the packages to w in the -output
+// format.
+func writeResults(w io.Writer, results []result) error {
+	if output == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+
+	for _, r := range results {
+		if r.Status == statusError {
+			name := filepath.Join(r.Package, "manifest.yml")
+			switch output {
+			case outputGitHub:
+				fmt.Fprintf(w, "::error file=%s::%s\n", escapeProperty(workspacePath(name)), escapeData(r.Error))
+			default:
+				fmt.Fprintf(w, "%s: %s\n", r.Package, r.Error)
+			}
+			continue
+		}
+		for _, p := range r.Problems {
+			name := filepath.Join(r.Package, filepath.FromSlash(p.Path))
+			switch output {
+			case outputGitHub:
+				props := "file=" + escapeProperty(workspacePath(name))
+				if p.Line > 0 {
+					props += fmt.Sprintf(",line=%d,col=%d", p.Line, p.Column)
+				}
+				fmt.Fprintf(w, "::error %s::%s\n", props, escapeData(pointerMessage(p)))
+			default:
+				fmt.Fprintf(w, "%s:%d:%d: %s\n", name, p.Line, p.Column, pointerMessage(p))
+			}
+		}
+	}
+	return nil
+}
+
+// pointerMessage returns the message of the problem prefixed with the JSON
+// pointer of the invalid value.
+func pointerMessage(p validator.Problem) string {
+	return "#" + p.Pointer + ": " + p.Message
+}
+
+// workspacePath returns name relative to the GitHub Actions workspace, which
+// is the base of the file paths of annotations. It returns name unchanged if
+// it is not in the workspace or no workspace is set.
+func workspacePath(name string) string {
+	workspace := os.Getenv("GITHUB_WORKSPACE")
+	if workspace == "" {
+		return filepath.ToSlash(name)
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return filepath.ToSlash(name)
+	}
+	rel, err := filepath.Rel(workspace, abs)
+	if err != nil || !filepath.IsLocal(rel) {
+		return filepath.ToSlash(name)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// manifest holds the fields of a package manifest that select its schemas.
+type manifest struct {
+	FormatVersion string `yaml:"format_version"`
+}
+
+// readManifest reads the manifest.yml of a package root. It returns nil if
+// the file does not exist or is not a package manifest, such as the manifest
+// of a data stream.
+func readManifest(fsys fs.FS) (*manifest, error) {
+	const name = "manifest.yml"
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var m manifest
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	if m.FormatVersion == "" {
+		return nil, nil
+	}
+	return &m, nil
+}
+
+// validatePackage validates the files of the package against the schemas of
+// its format_version.
+func validatePackage(v *validator.Validator, pkg packageDir) result {
+	start := time.Now()
+	r := result{Package: path.Clean(filepath.ToSlash(pkg.dir)), Problems: []validator.Problem{}}
+	err := pkg.err
+	if err == nil {
+		r.FormatVersion = pkg.manifest.FormatVersion
+		var problems []validator.Problem
+		problems, r.paths, err = checkPackage(v, pkg)
+		if problems != nil {
+			r.Problems = problems
+		}
+	}
+	r.Files = len(r.paths)
+	r.Duration = time.Since(start).Seconds()
+
+	switch {
+	case err != nil:
+		r.Status, r.Error = statusError, err.Error()
+		slog.Warn("Failed to validate package.", "package", r.Package, "error", err)
+	case len(r.Problems) > 0:
+		r.Status = statusFail
+	default:
+		r.Status = statusPass
+	}
+	slog.Debug("Validated package.", "package", r.Package, "format_version", r.FormatVersion, "status", r.Status, "files", r.Files, "problems", len(r.Problems), "duration", time.Since(start))
+	return r
+}
+
+// checkPackage validates the files of the package against the schemas of the
+// version that its format_version resolves to. It returns the problems and
+// the paths of the files validated.
+func checkPackage(v *validator.Validator, pkg packageDir) ([]validator.Problem, []string, error) {
+	ver, err := specversion.ResolveFS(os.DirFS(outDir), pkg.manifest.FormatVersion)
+	if err != nil {
+		return nil, nil, fmt.Errorf("no schemas for format_version in %s: %w", outDir, err)
+	}
+	if ver != pkg.manifest.FormatVersion {
+		slog.Debug("Resolved format_version.", "package", pkg.dir, "format_version", pkg.manifest.FormatVersion, "version", ver)
+	}
+	if pkg.files != nil {
+		return v.ValidateFiles(pkg.fsys, ver, pkg.files)
+	}
+	return v.ValidatePackage(pkg.fsys, ver)
+}
+
+// writeReport writes the aggregated report of the run to name as JSON.
+func writeReport(name string, rep report) error {
+	b, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(b, '\n'), 0o644)
+}
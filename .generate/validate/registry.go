@@ -0,0 +1,160 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxArchiveSize is the maximum size of a package archive downloaded from
+// the registry.
+const maxArchiveSize = 512 << 20
+
+// packagesFlag is a flag.Value accepting repeated registry package
+// references of the form name@version, or name for the latest version. A
+// single value may also contain a comma separated list.
+type packagesFlag []string
+
+func (f *packagesFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *packagesFlag) Set(value string) error {
+	for ref := range strings.SplitSeq(value, ",") {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(ref, "@")
+		if name == "" || strings.ContainsAny(name, "/\\") {
+			return fmt.Errorf("invalid package reference %q, want name@version", ref)
+		}
+		*f = append(*f, ref)
+	}
+	return nil
+}
+
+// registry is a client of the Elastic Package Registry.
+type registry struct {
+	url    string // Base URL of the registry, without a trailing slash.
+	client *http.Client
+}
+
+// searchResult is a package listed by the /search API of the registry.
+type searchResult struct {
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Download string `json:"download"` // Path of the archive relative to the registry URL.
+}
+
+// fetchPackages downloads the package of each reference from the registry.
+// A package that cannot be downloaded is returned with its error so that it
+// is reported with the other packages.
+func (r *registry) fetchPackages(refs []string) []packageDir {
+	pkgs := make([]packageDir, 0, len(refs))
+	for _, ref := range refs {
+		pkg, err := r.fetchPackage(ref)
+		if err != nil {
+			pkg = packageDir{dir: ref, err: err}
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs
+}
+
+// fetchPackage downloads the archive of the package named by ref and
+// returns it as a packageDir rooted at the top-level directory of the
+// archive, named <name>-<version>.
+func (r *registry) fetchPackage(ref string) (packageDir, error) {
+	name, version, _ := strings.Cut(ref, "@")
+	download := fmt.Sprintf("/epr/%s/%s-%s.zip", url.PathEscape(name), url.PathEscape(name), url.PathEscape(version))
+	if version == "" {
+		latest, err := r.latest(name)
+		if err != nil {
+			return packageDir{}, err
+		}
+		version, download = latest.Version, latest.Download
+	}
+
+	start := time.Now()
+	data, err := r.get(download, maxArchiveSize)
+	if err != nil {
+		return packageDir{}, err
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return packageDir{}, fmt.Errorf("failed to open archive of %s@%s: %w", name, version, err)
+	}
+	root := name + "-" + version
+	fsys, err := fs.Sub(zr, root)
+	if err != nil {
+		return packageDir{}, err
+	}
+	slog.Debug("Downloaded package.", "package", root, "bytes", len(data), "duration", time.Since(start))
+
+	m, err := readManifest(fsys)
+	if err == nil && m == nil {
+		err = fmt.Errorf("archive of %s@%s has no package manifest in %s/", name, version, root)
+	}
+	return packageDir{dir: root, fsys: fsys, manifest: m, err: err}, nil
+}
+
+// latest returns the newest version of the package named name.
+func (r *registry) latest(name string) (*searchResult, error) {
+	data, err := r.get("/search?package="+url.QueryEscape(name), 1<<20)
+	if err != nil {
+		return nil, err
+	}
+	var results []searchResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("failed to decode search results for %s: %w", name, err)
+	}
+	for _, res := range results {
+		if res.Name == name {
+			return &res, nil
+		}
+	}
+	return nil, fmt.Errorf("package %s not found in %s", name, r.url)
+}
+
+// get returns the body of the registry resource at the path, which may
+// include a query, and must not exceed limit bytes.
+func (r *registry) get(p string, limit int64) (data []byte, err error) {
+	u := r.url + "/" + strings.TrimPrefix(p, "/")
+	resp, err := r.client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			err = errors.Join(err, closeErr)
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", u, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", u, err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("%s exceeds the maximum size of %d bytes", u, limit)
+	}
+	return body, nil
+}
@@ -0,0 +1,111 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/andrewkroh/package-spec-schema/internal/validator"
+)
+
+// stagedPackages returns the packages containing the files staged in the git
+// repository of the working directory. Each package only lists its staged
+// files that have a schema, and packages without such files are omitted.
+// Files are read from the working tree.
+func stagedPackages() ([]packageDir, error) {
+	out, err := gitExec("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	top := strings.TrimSpace(string(out))
+
+	// Deleted files have nothing to validate.
+	out, err = gitExec(top, "diff", "--cached", "--name-only", "-z", "--diff-filter=ACMR")
+	if err != nil {
+		return nil, err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgs []packageDir
+	byRoot := map[string]int{}   // Index in pkgs of each package root.
+	roots := map[string]string{} // Package root of each directory, "" if none.
+	for name := range strings.SplitSeq(strings.TrimSuffix(string(out), "\x00"), "\x00") {
+		if name == "" {
+			continue
+		}
+		root := packageRoot(top, path.Dir(name), roots)
+		if root == "" {
+			continue
+		}
+		rel := name
+		if root != "." {
+			rel = strings.TrimPrefix(name, root+"/")
+		}
+		if _, ok := validator.MatchSchema(rel); !ok {
+			continue
+		}
+
+		i, found := byRoot[root]
+		if !found {
+			dir := filepath.Join(top, filepath.FromSlash(root))
+			if r, err := filepath.Rel(wd, dir); err == nil {
+				dir = r
+			}
+			fsys := os.DirFS(dir)
+			m, err := readManifest(fsys)
+			i = len(pkgs)
+			byRoot[root] = i
+			pkgs = append(pkgs, packageDir{dir: dir, fsys: fsys, manifest: m, err: err})
+		}
+		pkgs[i].files = append(pkgs[i].files, rel)
+	}
+	slog.Debug("Found staged package files.", "packages", len(pkgs))
+	return pkgs, nil
+}
+
+// packageRoot returns the slash separated path, relative to the top of the
+// repository, of the nearest directory at or above dir containing a package
+// manifest, or "" if dir is not in a package. Results are memoized in roots.
+func packageRoot(top, dir string, roots map[string]string) string {
+	if root, found := roots[dir]; found {
+		return root
+	}
+	m, err := readManifest(os.DirFS(filepath.Join(top, filepath.FromSlash(dir))))
+	var root string
+	switch {
+	case err != nil || m != nil:
+		// A manifest that cannot be read is reported as a package error.
+		root = dir
+	case dir != ".":
+		root = packageRoot(top, path.Dir(dir), roots)
+	}
+	roots[dir] = root
+	return root
+}
+
+func gitExec(dir string, args ...string) (stdout []byte, err error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	outBuf := new(bytes.Buffer)
+	cmd.Stdout = outBuf
+	errBuf := new(bytes.Buffer)
+	cmd.Stderr = errBuf
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed running git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(errBuf.String()))
+	}
+	return outBuf.Bytes(), nil
+}
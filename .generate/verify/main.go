@@ -0,0 +1,125 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// verify compares freshly generated schemas against the committed output tree
+// and exits with an error when they differ. Files are compared semantically,
+// so differences in formatting or key order are ignored. A readable diff of
+// each differing file is written to stdout. It is meant to be run in CI
+// after regenerating into a temporary directory (see `just verify`).
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/jsondiff"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+// metadataFile is excluded from comparison because it records the time of
+// generation.
+const metadataFile = "metadata.json"
+
+var (
+	outDir       string // Directory containing the committed versioned directories.
+	generatedDir string // Directory containing the regenerated versioned directories.
+	maxChanges   int    // Maximum number of changes to print per file.
+)
+
+func init() {
+	flag.StringVar(&outDir, "o", ".", "output directory containing the committed versioned directories")
+	flag.StringVar(&generatedDir, "generated", "", "directory containing the regenerated versioned directories (required)")
+	flag.IntVar(&maxChanges, "max-changes", 20, "maximum number of changes to print for each file, 0 for no limit")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+func run() error {
+	if generatedDir == "" {
+		return errors.New("-generated is required")
+	}
+
+	entries, err := os.ReadDir(generatedDir)
+	if err != nil {
+		return err
+	}
+
+	var versions, differing int
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if _, err := semver.NewVersion(e.Name()); err != nil {
+			continue
+		}
+		versions++
+
+		n, err := verifyVersion(os.Stdout, e.Name())
+		if err != nil {
+			return fmt.Errorf("failed verifying %s: %w", e.Name(), err)
+		}
+		differing += n
+	}
+	if versions == 0 {
+		return fmt.Errorf("no versioned directories found in %s", generatedDir)
+	}
+
+	if differing > 0 {
+		return fmt.Errorf("%d files differ from the committed output in %s, regenerate and commit the schemas", differing, outDir)
+	}
+	slog.Info("Committed schemas are up to date.", "versions", versions)
+	return nil
+}
+
+// verifyVersion compares each directory that was regenerated for a version,
+// such as jsonschema/ or bundles/, with its committed counterpart. It writes
+// a diff of each differing file to w and returns the number of such files.
+func verifyVersion(w io.Writer, version string) (int, error) {
+	dirs, err := os.ReadDir(filepath.Join(generatedDir, version))
+	if err != nil {
+		return 0, err
+	}
+
+	var differing int
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		rel := filepath.Join(version, d.Name())
+		diffs, err := jsondiff.CompareDirs(filepath.Join(outDir, rel), filepath.Join(generatedDir, rel), func(name string) bool {
+			return path.Base(name) != metadataFile
+		})
+		if err != nil {
+			return 0, err
+		}
+		for _, fd := range diffs {
+			fd.Path = path.Join(filepath.ToSlash(rel), fd.Path)
+			fd.Write(w, maxChanges)
+		}
+		differing += len(diffs)
+	}
+	return differing, nil
+}
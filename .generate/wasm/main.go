@@ -0,0 +1,134 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+//go:build js && wasm
+
+// wasm is a WebAssembly build of the validator for web UIs, such as package
+// upload forms and documentation playgrounds, that validate packages on the
+// client. It embeds the schemas that `just wasm` copies into schemas/ and
+// registers a packageSpecSchema global with these functions:
+//
+//	validate(yaml, formatVersion, path = "manifest.yml")
+//	versions()
+//
+// validate returns {valid, problems} where each problem has a pointer, line,
+// column, and message, or {error} if the file cannot be validated. versions
+// returns the embedded versions sorted ascending.
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall/js"
+
+	"github.com/coreos/go-semver/semver"
+
+	"github.com/andrewkroh/package-spec-schema/internal/specversion"
+	"github.com/andrewkroh/package-spec-schema/internal/validator"
+)
+
+//go:embed schemas
+var schemas embed.FS
+
+func main() {
+	fsys, err := fs.Sub(schemas, "schemas")
+	if err != nil {
+		panic(err)
+	}
+	v := validator.New(fsys, validator.DefaultBaseURL)
+
+	api := js.Global().Get("Object").New()
+	api.Set("validate", js.FuncOf(func(_ js.Value, args []js.Value) any {
+		return validate(v, fsys, args)
+	}))
+	api.Set("versions", js.FuncOf(func(js.Value, []js.Value) any {
+		vers, err := versions(fsys)
+		if err != nil {
+			return map[string]any{"error": err.Error()}
+		}
+		return toJS(vers)
+	}))
+	js.Global().Set("packageSpecSchema", api)
+
+	// Keep the functions available.
+	select {}
+}
+
+// validate implements validate(yaml, formatVersion, path).
+func validate(v *validator.Validator, fsys fs.FS, args []js.Value) any {
+	if len(args) < 2 || args[0].Type() != js.TypeString || args[1].Type() != js.TypeString {
+		return map[string]any{"error": "usage: validate(yaml, formatVersion, path)"}
+	}
+	name := "manifest.yml"
+	if len(args) > 2 && args[2].Type() == js.TypeString {
+		name = args[2].String()
+	}
+
+	problems, err := validateFile(v, fsys, []byte(args[0].String()), args[1].String(), name)
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	list := make([]any, 0, len(problems))
+	for _, p := range problems {
+		list = append(list, map[string]any{
+			"pointer": p.Pointer,
+			"line":    p.Line,
+			"column":  p.Column,
+			"message": p.Message,
+		})
+	}
+	return map[string]any{"valid": len(problems) == 0, "problems": list}
+}
+
+// validateFile validates the data of the package file at name, a slash
+// separated path relative to the package root, against the schemas of the
+// version that formatVersion resolves to.
+func validateFile(v *validator.Validator, fsys fs.FS, data []byte, formatVersion, name string) ([]validator.Problem, error) {
+	ver, err := specversion.ResolveFS(fsys, formatVersion)
+	if err != nil {
+		return nil, fmt.Errorf("no schemas for format_version: %w", err)
+	}
+
+	schema, ok := validator.MatchSchema(name)
+	if !ok {
+		return nil, fmt.Errorf("no schema for %s", name)
+	}
+	problems, err := v.ValidateFile(ver, schema, data)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("format_version %s has no schema for %s", ver, name)
+	}
+	return problems, err
+}
+
+// versions returns the embedded versions sorted by semantic version.
+func versions(fsys fs.FS) ([]string, error) {
+	names, err := specversion.Available(fsys)
+	if err != nil {
+		return nil, err
+	}
+	var vers []*semver.Version
+	for _, name := range names {
+		if v, err := semver.NewVersion(name); err == nil {
+			vers = append(vers, v)
+		}
+	}
+	semver.Sort(vers)
+
+	sorted := make([]string, len(vers))
+	for i, v := range vers {
+		sorted[i] = v.String()
+	}
+	return sorted, nil
+}
+
+// toJS converts the strings to a JavaScript array.
+func toJS(s []string) []any {
+	list := make([]any, len(s))
+	for i, v := range s {
+		list[i] = v
+	}
+	return list
+}
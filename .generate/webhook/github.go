@@ -0,0 +1,110 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+)
+
+// maxPayloadSize limits the size of webhook payloads that are read.
+const maxPayloadSize = 1 << 20
+
+// createEvent is the subset of a GitHub create event payload that is used.
+type createEvent struct {
+	Ref        string `json:"ref"`
+	RefType    string `json:"ref_type"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// webhookHandler receives GitHub webhook deliveries and queues generation for
+// each created release tag.
+type webhookHandler struct {
+	secret []byte // Verifies signatures if not empty.
+	repo   string
+	queue  *queue
+}
+
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil {
+		http.Error(w, "failed to read payload", http.StatusBadRequest)
+		return
+	}
+	if len(h.secret) > 0 && !validSignature(h.secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		slog.Warn("Rejected webhook with an invalid signature.", "delivery", r.Header.Get("X-GitHub-Delivery"))
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event := r.Header.Get("X-GitHub-Event")
+	log := slog.With("event", event, "delivery", r.Header.Get("X-GitHub-Delivery"))
+	switch event {
+	case "ping":
+		log.Info("Received ping.")
+		w.WriteHeader(http.StatusOK)
+		return
+	case "create":
+	default:
+		log.Debug("Ignoring event.")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var e createEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if !strings.EqualFold(e.Repository.FullName, h.repo) || e.RefType != "tag" || !isReleaseTag(e.Ref) {
+		log.Debug("Ignoring created ref.", "repo", e.Repository.FullName, "ref", e.Ref, "ref_type", e.RefType)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if h.queue.add(e.Ref) {
+		log.Info("Queued generation.", "tag", e.Ref)
+	} else {
+		log.Info("Generation is already queued.", "tag", e.Ref)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// validSignature reports whether signature, the value of the
+// X-Hub-Signature-256 header, is the HMAC-SHA256 of body.
+func validSignature(secret, body []byte, signature string) bool {
+	got, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	gotMAC, err := hex.DecodeString(got)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(gotMAC, mac.Sum(nil))
+}
+
+// isReleaseTag reports whether the tag names a package-spec release, which
+// is a semantic version prefixed with v and without a prerelease.
+func isReleaseTag(tag string) bool {
+	v, ok := strings.CutPrefix(tag, "v")
+	if !ok {
+		return false
+	}
+	ver, err := semver.NewVersion(v)
+	return err == nil && ver.PreRelease == ""
+}
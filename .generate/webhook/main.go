@@ -0,0 +1,126 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+// webhook runs an HTTP server that receives GitHub webhook events from the
+// package-spec repository. When a release tag is created it runs a command,
+// by default `just generate <tag>`, that generates and bundles the schemas
+// of just the new version. This enables an automated publishing service in
+// place of periodic full rebuilds.
+//
+// Configure the webhook on GitHub with the content type application/json,
+// a secret, and the "Branch or tag creation" event.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/andrewkroh/package-spec-schema/internal/flagconfig"
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+var (
+	addr     string // Address on which to listen.
+	secret   string // Webhook secret used to verify signatures.
+	insecure bool   // Accept deliveries without a secret.
+	repo     string // Repository whose events are accepted.
+	command  string // Command run for each new tag.
+	dir      string // Working directory of the command.
+	timeout  time.Duration
+)
+
+func init() {
+	flag.StringVar(&addr, "addr", "localhost:8080", "address on which to listen")
+	flag.StringVar(&secret, "secret", "", "webhook secret used to verify the X-Hub-Signature-256 header, set it with PACKAGE_SPEC_SCHEMA_SECRET")
+	flag.BoolVar(&insecure, "insecure", false, "accept deliveries without verifying their signature when no -secret is set")
+	flag.StringVar(&repo, "repo", "elastic/package-spec", "full name of the repository whose events are accepted")
+	flag.StringVar(&command, "command", "just generate", "command to run for each new release tag, the tag is appended as the last argument")
+	flag.StringVar(&dir, "dir", ".", "working directory of the command")
+	flag.DurationVar(&timeout, "timeout", 30*time.Minute, "maximum duration of the command")
+	logging.AddFlags(flag.CommandLine)
+}
+
+func main() {
+	flag.Parse()
+	if err := flagconfig.LoadEnv(flag.CommandLine, nil); err != nil {
+		logging.Fatal(err)
+	}
+	if err := logging.Setup(); err != nil {
+		logging.Fatal(err)
+	}
+
+	if err := run(); err != nil {
+		logging.Fatal(err)
+	}
+}
+
+func run() error {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return errors.New("-command must not be empty")
+	}
+	if secret == "" {
+		if !insecure {
+			return errors.New("-secret is required to verify deliveries, pass -insecure to accept unsigned deliveries")
+		}
+		slog.Warn("No webhook secret is configured, signatures are not verified.")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	q := newQueue(args, dir, timeout)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.run(ctx)
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("POST /", &webhookHandler{secret: []byte(secret), repo: repo, queue: q})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- srv.ListenAndServe()
+	}()
+	slog.Info("Listening for webhooks.", "addr", addr, "repo", repo, "command", command)
+
+	select {
+	case err := <-errC:
+		stop()
+		<-done
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := srv.Shutdown(shutdownCtx)
+	// Wait for the running command to be canceled.
+	<-done
+	if err != nil {
+		return err
+	}
+	if err := <-errC; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("Server stopped.")
+	return nil
+}
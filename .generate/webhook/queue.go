@@ -0,0 +1,102 @@
+// Licensed to Elasticsearch B.V. under one or more agreements.
+// Elasticsearch B.V. licenses this file to you under the Apache 2.0 License.
+// See the LICENSE file in the project root for more information.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andrewkroh/package-spec-schema/internal/logging"
+)
+
+// queue runs the generation command for one tag at a time in the order that
+// the tags were received.
+type queue struct {
+	args    []string // Command and arguments, the tag is appended.
+	dir     string
+	timeout time.Duration
+
+	mu      sync.Mutex
+	pending []string      // Tags waiting to be generated.
+	wake    chan struct{} // Signals that a tag was added.
+}
+
+func newQueue(args []string, dir string, timeout time.Duration) *queue {
+	return &queue{args: args, dir: dir, timeout: timeout, wake: make(chan struct{}, 1)}
+}
+
+// add queues tag unless it is already pending and reports whether it was
+// added.
+func (q *queue) add(tag string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if slices.Contains(q.pending, tag) {
+		return false
+	}
+	q.pending = append(q.pending, tag)
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (q *queue) next() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		return "", false
+	}
+	tag := q.pending[0]
+	q.pending = q.pending[1:]
+	return tag, true
+}
+
+// run generates queued tags until ctx is done.
+func (q *queue) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		}
+		for {
+			tag, ok := q.next()
+			if !ok || ctx.Err() != nil {
+				break
+			}
+			q.generate(ctx, tag)
+		}
+	}
+}
+
+// generate runs the command for tag and logs the result. Failures are logged
+// rather than retried so that a broken release does not block later ones.
+func (q *queue) generate(ctx context.Context, tag string) {
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+
+	start := time.Now()
+	args := append(slices.Clone(q.args[1:]), tag)
+	cmd := exec.CommandContext(ctx, q.args[0], args...)
+	cmd.Dir = q.dir
+	out := new(bytes.Buffer)
+	cmd.Stdout = out
+	cmd.Stderr = out
+
+	slog.Info("Generating tag.", "tag", tag)
+	if err := cmd.Run(); err != nil {
+		slog.Error("Generation failed.", "tag", tag, "error", err, "output", strings.TrimSpace(out.String()), "duration", time.Since(start))
+		return
+	}
+	slog.Log(ctx, logging.LevelTrace, "Generation output.", "tag", tag, "output", strings.TrimSpace(out.String()))
+	slog.Info("Generated tag.", "tag", tag, "duration", time.Since(start))
+}